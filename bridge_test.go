@@ -152,6 +152,7 @@ func TestHandlePacket(t *testing.T) {
 					OMetricName: "foo",
 					OValue:      200,
 					OLabels:     map[string]string{},
+					OKind:       event.ObserverKindHistogram,
 				},
 			},
 		}, {
@@ -162,6 +163,7 @@ func TestHandlePacket(t *testing.T) {
 					OMetricName: "foo",
 					OValue:      200,
 					OLabels:     map[string]string{},
+					OKind:       event.ObserverKindDistribution,
 				},
 			},
 		}, {
@@ -172,26 +174,8 @@ func TestHandlePacket(t *testing.T) {
 					OMetricName: "foo",
 					OValue:      0.01,
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo",
-					OValue:      0.01,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo",
-					OValue:      0.01,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo",
-					OValue:      0.01,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo",
-					OValue:      0.01,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
+					OSampleRate: 0.2,
+					OKind:       event.ObserverKindDistribution,
 				},
 			},
 		}, {
@@ -398,26 +382,8 @@ func TestHandlePacket(t *testing.T) {
 					OMetricName: "foo",
 					OValue:      0.01,
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo",
-					OValue:      0.01,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo",
-					OValue:      0.01,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo",
-					OValue:      0.01,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo",
-					OValue:      0.01,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
+					OSampleRate: 0.2,
+					OKind:       event.ObserverKindHistogram,
 				},
 			},
 		}, {
@@ -485,16 +451,7 @@ func TestHandlePacket(t *testing.T) {
 			name: "timings with sampling factor",
 			in:   "foo.timing:0.5|ms|@0.1",
 			out: event.Events{
-				&event.ObserverEvent{OMetricName: "foo.timing", OValue: 0.0005, OLabels: map[string]string{}},
-				&event.ObserverEvent{OMetricName: "foo.timing", OValue: 0.0005, OLabels: map[string]string{}},
-				&event.ObserverEvent{OMetricName: "foo.timing", OValue: 0.0005, OLabels: map[string]string{}},
-				&event.ObserverEvent{OMetricName: "foo.timing", OValue: 0.0005, OLabels: map[string]string{}},
-				&event.ObserverEvent{OMetricName: "foo.timing", OValue: 0.0005, OLabels: map[string]string{}},
-				&event.ObserverEvent{OMetricName: "foo.timing", OValue: 0.0005, OLabels: map[string]string{}},
-				&event.ObserverEvent{OMetricName: "foo.timing", OValue: 0.0005, OLabels: map[string]string{}},
-				&event.ObserverEvent{OMetricName: "foo.timing", OValue: 0.0005, OLabels: map[string]string{}},
-				&event.ObserverEvent{OMetricName: "foo.timing", OValue: 0.0005, OLabels: map[string]string{}},
-				&event.ObserverEvent{OMetricName: "foo.timing", OValue: 0.0005, OLabels: map[string]string{}},
+				&event.ObserverEvent{OMetricName: "foo.timing", OValue: 0.0005, OLabels: map[string]string{}, OSampleRate: 0.1},
 			},
 		}, {
 			name: "bad line",
@@ -569,6 +526,7 @@ func TestHandlePacket(t *testing.T) {
 					OMetricName: "foo",
 					OValue:      200,
 					OLabels:     map[string]string{},
+					OKind:       event.ObserverKindHistogram,
 				},
 			},
 		}, {
@@ -579,6 +537,7 @@ func TestHandlePacket(t *testing.T) {
 					OMetricName: "foo",
 					OValue:      200,
 					OLabels:     map[string]string{},
+					OKind:       event.ObserverKindDistribution,
 				},
 			},
 		},
@@ -590,7 +549,7 @@ func TestHandlePacket(t *testing.T) {
 	parser.EnableLibratoParsing()
 	parser.EnableSignalFXParsing()
 
-	for k, l := range []statsDPacketHandler{&listener.StatsDUDPListener{
+	for k, l := range []listener.PacketHandler{&listener.StatsDUDPListener{
 		Conn:            nil,
 		EventHandler:    nil,
 		Logger:          promslog.NewNopLogger(),
@@ -643,11 +602,6 @@ func TestHandlePacket(t *testing.T) {
 	}
 }
 
-type statsDPacketHandler interface {
-	HandlePacket(packet []byte)
-	SetEventHandler(eh event.EventHandler)
-}
-
 type mockStatsDTCPListener struct {
 	listener.StatsDTCPListener
 	*slog.Logger
@@ -725,6 +679,7 @@ mappings:
 		&event.ObserverEvent{
 			OMetricName: "bazqux.main",
 			OValue:      42,
+			OKind:       event.ObserverKindDistribution,
 		},
 	}
 
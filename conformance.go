@@ -0,0 +1,192 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"embed"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/prometheus/statsd_exporter/pkg/event"
+	"github.com/prometheus/statsd_exporter/pkg/line"
+)
+
+//go:embed conformance/corpus.txt
+var bundledCorpus embed.FS
+
+// conformanceResult is how the parser interpreted a single line.
+type conformanceResult struct {
+	Line   string             `json:"line"`
+	Events []conformanceEvent `json:"events"`
+	// Errors lists the reasons of sample errors, as counted in
+	// statsd_exporter_sample_errors_total.
+	Errors    []string `json:"errors,omitempty"`
+	TagErrors int      `json:"tag_errors,omitempty"`
+}
+
+type conformanceEvent struct {
+	Name       string            `json:"name"`
+	Type       string            `json:"type"`
+	Values     []float64         `json:"values"`
+	Member     string            `json:"member,omitempty"`
+	Relative   bool              `json:"relative,omitempty"`
+	SampleRate float64           `json:"sample_rate,omitempty"`
+	Kind       string            `json:"kind,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"`
+}
+
+// MarshalJSON encodes values that JSON numbers can't represent as strings.
+func (e conformanceEvent) MarshalJSON() ([]byte, error) {
+	type alias conformanceEvent
+	values := make([]interface{}, len(e.Values))
+	for i, v := range e.Values {
+		values[i] = v
+		if s := formatSpecialFloat(v); s != "" {
+			values[i] = s
+		}
+	}
+	return json.Marshal(struct {
+		alias
+		Values []interface{} `json:"values"`
+	}{alias(e), values})
+}
+
+func formatSpecialFloat(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return ""
+}
+
+// readCorpus returns the StatsD lines in r, skipping empty lines and comments.
+func readCorpus(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		l := scanner.Text()
+		if strings.TrimSpace(l) == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+		lines = append(lines, l)
+	}
+	return lines, scanner.Err()
+}
+
+// loadCorpus reads the bundled corpus, followed by the given corpus files.
+func loadCorpus(fileNames []string) ([]string, error) {
+	f, err := bundledCorpus.Open("conformance/corpus.txt")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	lines, err := readCorpus(f)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, fileName := range fileNames {
+		f, err := os.Open(fileName)
+		if err != nil {
+			return nil, err
+		}
+		more, err := readCorpus(f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, more...)
+	}
+	return lines, nil
+}
+
+// interpretLine parses a line the way the listeners do, and records the
+// resulting events and errors.
+func interpretLine(parser *line.Parser, l string) conformanceResult {
+	sampleErrors := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "sample_errors"}, []string{"reason"})
+	tagErrors := prometheus.NewCounter(prometheus.CounterOpts{Name: "tag_errors"})
+	discard := prometheus.NewCounter(prometheus.CounterOpts{Name: "discard"})
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	result := conformanceResult{Line: l, Events: []conformanceEvent{}}
+	for _, e := range parser.LineToEvents(l, *sampleErrors, discard, tagErrors, discard, logger) {
+		ce := conformanceEvent{
+			Name:     e.MetricName(),
+			Type:     string(e.MetricType()),
+			Values:   []float64{e.Value()},
+			Relative: event.IsRelative(e),
+			Labels:   e.Labels(),
+		}
+		if mv, ok := e.(event.MultiValueEvent); ok {
+			ce.Values = mv.Values()
+		}
+		if s, ok := e.(*event.SetEvent); ok {
+			ce.Member = s.Member()
+		}
+		if rate := event.SampleRateOf(e); rate != 1 {
+			ce.SampleRate = rate
+		}
+		if _, ok := e.(event.KindedEvent); ok {
+			ce.Kind = string(event.ObserverKindOf(e))
+		}
+		result.Events = append(result.Events, ce)
+	}
+
+	metrics := make(chan prometheus.Metric)
+	go func() {
+		sampleErrors.Collect(metrics)
+		close(metrics)
+	}()
+	for m := range metrics {
+		var metric dto.Metric
+		if err := m.Write(&metric); err != nil {
+			continue
+		}
+		for i := 0; i < int(metric.GetCounter().GetValue()); i++ {
+			result.Errors = append(result.Errors, metric.GetLabel()[0].GetValue())
+		}
+	}
+	sort.Strings(result.Errors)
+
+	var metric dto.Metric
+	if err := tagErrors.Write(&metric); err == nil {
+		result.TagErrors = int(metric.GetCounter().GetValue())
+	}
+	return result
+}
+
+// runConformance writes how parser interprets each line of the corpus to w,
+// as one JSON object per line.
+func runConformance(parser *line.Parser, corpus []string, w io.Writer) error {
+	enc := json.NewEncoder(w)
+	for _, l := range corpus {
+		if err := enc.Encode(interpretLine(parser, l)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/statsd_exporter/pkg/line"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden conformance report and self-metrics manifest")
+
+// TestConformance catches changes to how the bundled corpus is parsed. Run
+// with -update to accept an intended change.
+func TestConformance(t *testing.T) {
+	parser := line.NewParser()
+	parser.EnableDogstatsdParsing()
+	parser.EnableInfluxdbParsing()
+	parser.EnableLibratoParsing()
+	parser.EnableSignalFXParsing()
+
+	corpus, err := loadCorpus(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report bytes.Buffer
+	if err := runConformance(parser, corpus, &report); err != nil {
+		t.Fatal(err)
+	}
+
+	const goldenFile = "conformance/corpus.golden.jsonl"
+	if *updateGolden {
+		if err := os.WriteFile(goldenFile, report.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	golden, err := os.ReadFile(goldenFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := strings.Split(report.String(), "\n")
+	want := strings.Split(string(golden), "\n")
+	for i := 0; i < len(got) || i < len(want); i++ {
+		if i >= len(got) || i >= len(want) || got[i] != want[i] {
+			var g, w string
+			if i < len(got) {
+				g = got[i]
+			}
+			if i < len(want) {
+				w = want[i]
+			}
+			t.Fatalf("report differs from %s in line %d:\ngot:  %s\nwant: %s", goldenFile, i+1, g, w)
+		}
+	}
+}
+
+func TestReadCorpus(t *testing.T) {
+	lines, err := readCorpus(strings.NewReader("# comment\n\nfoo:1|c\n  \nbar:2|g\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 2 || lines[0] != "foo:1|c" || lines[1] != "bar:2|g" {
+		t.Fatalf("unexpected corpus lines %q", lines)
+	}
+}
@@ -0,0 +1,439 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/prometheus/statsd_exporter/pkg/mapper"
+)
+
+var (
+	// convertibleNameRE matches metric names that can be turned into glob
+	// mappings.
+	convertibleNameRE = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_\-]*(\.[a-zA-Z0-9_][a-zA-Z0-9_\-]*)*$`)
+	invalidNameCharRE = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+)
+
+// statsdConfig are the settings of an Etsy StatsD configuration that carry
+// over to a mapping configuration.
+type statsdConfig struct {
+	PercentThreshold json.RawMessage   `json:"percentThreshold"`
+	Histogram        []statsdHistogram `json:"histogram"`
+}
+
+// statsdHistogram configures the bins of timers whose name contains Metric.
+type statsdHistogram struct {
+	Metric string            `json:"metric"`
+	Bins   []json.RawMessage `json:"bins"`
+}
+
+// convertedConfig is the generated mapping configuration. It only has the
+// fields the conversion sets, so that the output stays readable.
+type convertedConfig struct {
+	Defaults *convertedDefaults `yaml:"defaults,omitempty"`
+	Mappings []convertedMapping `yaml:"mappings,omitempty"`
+}
+
+type convertedDefaults struct {
+	ObserverType     mapper.ObserverType `yaml:"observer_type,omitempty"`
+	SummaryOptions   *convertedSummary   `yaml:"summary_options,omitempty"`
+	HistogramOptions *convertedHistogram `yaml:"histogram_options,omitempty"`
+}
+
+type convertedMapping struct {
+	Match            string              `yaml:"match"`
+	Name             string              `yaml:"name"`
+	Labels           yaml.MapSlice       `yaml:"labels,omitempty"`
+	ObserverType     mapper.ObserverType `yaml:"observer_type,omitempty"`
+	HistogramOptions *convertedHistogram `yaml:"histogram_options,omitempty"`
+}
+
+type convertedSummary struct {
+	Quantiles []mapper.MetricObjective `yaml:"quantiles"`
+}
+
+type convertedHistogram struct {
+	Buckets []float64 `yaml:"buckets"`
+}
+
+// jsToJSON turns the object literal of a StatsD configuration file, which is
+// JavaScript, into JSON. It handles comments, unquoted keys, single-quoted
+// strings and trailing commas, but no other JavaScript.
+func jsToJSON(src string) ([]byte, error) {
+	var out bytes.Buffer
+	depth := 0
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case strings.HasPrefix(src[i:], "//"):
+			end := strings.IndexByte(src[i:], '\n')
+			if end < 0 {
+				end = len(src) - i
+			}
+			i += end
+			continue
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated comment")
+			}
+			i += end + 4
+			continue
+		case depth == 0 && c != '{':
+			// Skip anything around the object, like "module.exports =".
+			i++
+			continue
+		case c == '{' || c == '[':
+			depth++
+			out.WriteByte(c)
+		case c == '}' || c == ']':
+			depth--
+			trimTrailingComma(&out)
+			out.WriteByte(c)
+			if depth == 0 {
+				return out.Bytes(), nil
+			}
+		case c == '"' || c == '\'':
+			s, n, err := readJSString(src[i:])
+			if err != nil {
+				return nil, err
+			}
+			b, _ := json.Marshal(s)
+			out.Write(b)
+			i += n
+			continue
+		case c == '_' || c == '$' || isLetter(c):
+			n := 1
+			for n < len(src)-i && (src[i+n] == '_' || src[i+n] == '$' || isLetter(src[i+n]) || isDigit(src[i+n])) {
+				n++
+			}
+			ident := src[i : i+n]
+			i += n
+			if strings.HasPrefix(strings.TrimLeft(src[i:], " \t\r\n"), ":") {
+				b, _ := json.Marshal(ident)
+				out.Write(b)
+				continue
+			}
+			switch ident {
+			case "true", "false", "null":
+				out.WriteString(ident)
+			default:
+				return nil, fmt.Errorf("unsupported expression %q", ident)
+			}
+			continue
+		default:
+			out.WriteByte(c)
+		}
+		i++
+	}
+	return nil, fmt.Errorf("no configuration object found")
+}
+
+// readJSString reads the quoted string at the start of s, and returns its
+// contents and length.
+func readJSString(s string) (string, int, error) {
+	quote := s[0]
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case quote:
+			return b.String(), i + 1, nil
+		case '\\':
+			i++
+			if i == len(s) {
+				break
+			}
+			switch s[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			default:
+				b.WriteByte(s[i])
+			}
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string")
+}
+
+func trimTrailingComma(b *bytes.Buffer) {
+	trimmed := bytes.TrimRight(b.Bytes(), " \t\r\n")
+	if len(trimmed) > 0 && trimmed[len(trimmed)-1] == ',' {
+		b.Truncate(len(trimmed) - 1)
+	}
+}
+
+func isLetter(c byte) bool { return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' }
+func isDigit(c byte) bool  { return c >= '0' && c <= '9' }
+
+func parseStatsdConfig(src string) (*statsdConfig, error) {
+	b, err := jsToJSON(src)
+	if err != nil {
+		return nil, err
+	}
+	var cfg statsdConfig
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// quantiles converts the percent thresholds of StatsD timers to summary
+// quantiles. Negative thresholds, for the lower percentiles, are skipped.
+func (c *statsdConfig) quantiles() ([]mapper.MetricObjective, error) {
+	if len(c.PercentThreshold) == 0 {
+		return nil, nil
+	}
+	var thresholds []float64
+	if err := json.Unmarshal(c.PercentThreshold, &thresholds); err != nil {
+		var threshold float64
+		if err := json.Unmarshal(c.PercentThreshold, &threshold); err != nil {
+			return nil, fmt.Errorf("invalid percentThreshold: %w", err)
+		}
+		thresholds = []float64{threshold}
+	}
+	var quantiles []mapper.MetricObjective
+	for _, t := range thresholds {
+		if t <= 0 || t >= 100 {
+			continue
+		}
+		// This matches the error of the default quantiles, and is rounded
+		// to keep the output readable.
+		quantiles = append(quantiles, mapper.MetricObjective{
+			Quantile: t / 100,
+			Error:    math.Round((100-t)*1e3) / 1e6,
+		})
+	}
+	return quantiles, nil
+}
+
+// buckets returns the bins of h without the implicit infinite one.
+func (h statsdHistogram) buckets() ([]float64, error) {
+	var buckets []float64
+	for _, bin := range h.Bins {
+		var f float64
+		if err := json.Unmarshal(bin, &f); err != nil {
+			var s string
+			if json.Unmarshal(bin, &s) == nil && strings.EqualFold(s, "inf") {
+				continue
+			}
+			return nil, fmt.Errorf("invalid bin %s for histogram %q", bin, h.Metric)
+		}
+		buckets = append(buckets, f)
+	}
+	return buckets, nil
+}
+
+// readMetricNames returns the metric names in r, which has one name or StatsD
+// line per line.
+func readMetricNames(r io.Reader) ([]string, error) {
+	lines, err := readCorpus(r)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(lines))
+	for _, l := range lines {
+		l = strings.TrimSpace(l)
+		if i := strings.IndexAny(l, ":|,#"); i >= 0 {
+			l = l[:i]
+		}
+		names = append(names, l)
+	}
+	return names, nil
+}
+
+// suggestMappings groups dotted metric names that have the same number of
+// components, and the same first and last component. Components that vary
+// within a group become labels, named after the component before them, and
+// the others form the metric name. Names that don't vary with any other are
+// left to the default mapping.
+func suggestMappings(names []string) (mappings []convertedMapping, skipped []string) {
+	groups := map[string][][]string{}
+	for _, name := range names {
+		if !convertibleNameRE.MatchString(name) {
+			skipped = append(skipped, name)
+			continue
+		}
+		parts := strings.Split(name, ".")
+		key := fmt.Sprintf("%d\xff%s\xff%s", len(parts), parts[0], parts[len(parts)-1])
+		groups[key] = append(groups[key], parts)
+	}
+
+	for _, group := range groups {
+		varying := make([]bool, len(group[0]))
+		anyVarying := false
+		for _, parts := range group[1:] {
+			for i, part := range parts {
+				if part != group[0][i] {
+					varying[i] = true
+					anyVarying = true
+				}
+			}
+		}
+		if !anyVarying {
+			continue
+		}
+
+		var match, name []string
+		var labels yaml.MapSlice
+		used := map[string]bool{}
+		for i, part := range group[0] {
+			if !varying[i] {
+				match = append(match, part)
+				name = append(name, invalidNameCharRE.ReplaceAllString(part, "_"))
+				continue
+			}
+			match = append(match, "*")
+			label := ""
+			if !varying[i-1] {
+				label = invalidNameCharRE.ReplaceAllString(group[0][i-1], "_")
+			}
+			if len(label) < 2 || isDigit(label[0]) || used[label] {
+				label = "label" + strconv.Itoa(i)
+			}
+			used[label] = true
+			labels = append(labels, yaml.MapItem{Key: label, Value: "$" + strconv.Itoa(len(labels)+1)})
+		}
+		mappings = append(mappings, convertedMapping{
+			Match:  strings.Join(match, "."),
+			Name:   strings.Join(name, "_"),
+			Labels: labels,
+		})
+	}
+	sort.Slice(mappings, func(i, j int) bool { return mappings[i].Match < mappings[j].Match })
+	return mappings, skipped
+}
+
+// convertConfig generates a starter mapping configuration from a StatsD
+// configuration and observed metric names, either of which may be missing.
+// It returns the configuration as YAML, and warnings about what couldn't be
+// converted.
+func convertConfig(cfg *statsdConfig, names []string) ([]byte, []string, error) {
+	var out convertedConfig
+	var warnings []string
+
+	mappings, skipped := suggestMappings(names)
+	out.Mappings = mappings
+	for _, name := range skipped {
+		warnings = append(warnings, fmt.Sprintf("metric name %q can't be matched by a glob mapping", name))
+	}
+
+	if cfg != nil {
+		quantiles, err := cfg.quantiles()
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(quantiles) > 0 {
+			out.Defaults = &convertedDefaults{SummaryOptions: &convertedSummary{Quantiles: quantiles}}
+		}
+
+		// StatsD uses the first histogram whose metric is contained in the
+		// name of a timer.
+		applied := make([]bool, len(cfg.Histogram))
+		for i := range out.Mappings {
+			m := &out.Mappings[i]
+			for j, h := range cfg.Histogram {
+				if h.Metric == "" || !strings.Contains(m.Match, h.Metric) {
+					continue
+				}
+				buckets, err := h.buckets()
+				if err != nil {
+					return nil, nil, err
+				}
+				m.ObserverType = mapper.ObserverTypeHistogram
+				m.HistogramOptions = &convertedHistogram{Buckets: buckets}
+				applied[j] = true
+				break
+			}
+		}
+		for j, h := range cfg.Histogram {
+			if h.Metric == "" {
+				buckets, err := h.buckets()
+				if err != nil {
+					return nil, nil, err
+				}
+				if out.Defaults == nil {
+					out.Defaults = &convertedDefaults{}
+				}
+				out.Defaults.ObserverType = mapper.ObserverTypeHistogram
+				out.Defaults.HistogramOptions = &convertedHistogram{Buckets: buckets}
+				break
+			}
+			if !applied[j] {
+				warnings = append(warnings, fmt.Sprintf("histogram for metrics containing %q matches no suggested mapping", h.Metric))
+			}
+		}
+	}
+
+	b, err := yaml.Marshal(out)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Make sure the suggestion loads, for example that no generated name is
+	// invalid.
+	var m mapper.MetricMapper
+	if err := m.InitFromYAMLString(string(b)); err != nil {
+		return nil, nil, fmt.Errorf("generated an invalid mapping configuration: %w", err)
+	}
+	return append([]byte("# Generated by statsd_exporter convert. Review the metric and label names before use.\n"), b...), warnings, nil
+}
+
+// runConvert reads the StatsD configuration and metric name files, either of
+// which may be empty, and writes the converted mapping configuration to w.
+func runConvert(configFile, namesFile string, w io.Writer) ([]string, error) {
+	var cfg *statsdConfig
+	if configFile != "" {
+		src, err := os.ReadFile(configFile)
+		if err != nil {
+			return nil, err
+		}
+		if cfg, err = parseStatsdConfig(string(src)); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", configFile, err)
+		}
+	}
+
+	var names []string
+	if namesFile != "" {
+		f, err := os.Open(namesFile)
+		if err != nil {
+			return nil, err
+		}
+		names, err = readMetricNames(f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	b, warnings, err := convertConfig(cfg, names)
+	if err != nil {
+		return nil, err
+	}
+	_, err = w.Write(b)
+	return warnings, err
+}
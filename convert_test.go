@@ -0,0 +1,123 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/prometheus/statsd_exporter/pkg/mapper"
+)
+
+func TestParseStatsdConfig(t *testing.T) {
+	cfg, err := parseStatsdConfig(`/* Example config */
+module.exports = {
+  graphiteHost: 'graphite.example.com' // the "host"
+, percentThreshold: 95
+, histogram: [
+    { metric: 'render', bins: [0.1, 1, 'inf'] },
+  ],
+};`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	quantiles, err := cfg.quantiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(quantiles, []mapper.MetricObjective{{Quantile: 0.95, Error: 0.005}}) {
+		t.Errorf("unexpected quantiles %v", quantiles)
+	}
+	if len(cfg.Histogram) != 1 || cfg.Histogram[0].Metric != "render" {
+		t.Fatalf("unexpected histograms %v", cfg.Histogram)
+	}
+	buckets, err := cfg.Histogram[0].buckets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(buckets, []float64{0.1, 1}) {
+		t.Errorf("unexpected buckets %v", buckets)
+	}
+
+	if _, err := parseStatsdConfig(`{ port: require('port') }`); err == nil {
+		t.Errorf("expected an error for unsupported JavaScript")
+	}
+}
+
+func TestSuggestMappings(t *testing.T) {
+	names, err := readMetricNames(strings.NewReader(`# observed names
+servers.web01.cpu.load
+servers.web02.cpu.load:0.5|g
+app.eu.web01.requests
+app.us.web02.requests
+app.login
+invalid name
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mappings, skipped := suggestMappings(names)
+
+	expected := []convertedMapping{
+		{
+			Match:  "app.*.*.requests",
+			Name:   "app_requests",
+			Labels: yaml.MapSlice{{Key: "app", Value: "$1"}, {Key: "label2", Value: "$2"}},
+		},
+		{
+			Match:  "servers.*.cpu.load",
+			Name:   "servers_cpu_load",
+			Labels: yaml.MapSlice{{Key: "servers", Value: "$1"}},
+		},
+	}
+	if !reflect.DeepEqual(mappings, expected) {
+		t.Errorf("expected mappings %v, got %v", expected, mappings)
+	}
+	if !reflect.DeepEqual(skipped, []string{"invalid name"}) {
+		t.Errorf("unexpected skipped names %v", skipped)
+	}
+}
+
+func TestConvertConfig(t *testing.T) {
+	cfg := &statsdConfig{Histogram: []statsdHistogram{
+		{Metric: "render", Bins: []json.RawMessage{json.RawMessage("0.5"), json.RawMessage(`"inf"`)}},
+	}}
+
+	b, warnings, err := convertConfig(cfg, []string{"app.render.web01.time", "app.render.web02.time"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 0 {
+		t.Errorf("unexpected warnings %v", warnings)
+	}
+
+	var m mapper.MetricMapper
+	if err := m.InitFromYAMLString(string(b)); err != nil {
+		t.Fatal(err)
+	}
+	mapping, labels, ok := m.GetMapping("app.render.web01.time", mapper.MetricTypeObserver)
+	if !ok {
+		t.Fatalf("expected a mapping in\n%s", b)
+	}
+	if mapping.Name != "app_render_time" || labels["render"] != "web01" {
+		t.Errorf("unexpected mapping %s with labels %v", mapping.Name, labels)
+	}
+	if mapping.ObserverType != mapper.ObserverTypeHistogram || !reflect.DeepEqual(mapping.HistogramOptions.Buckets, []float64{0.5}) {
+		t.Errorf("expected a histogram with the buckets of the StatsD histogram, got %s %v", mapping.ObserverType, mapping.HistogramOptions)
+	}
+}
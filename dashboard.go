@@ -0,0 +1,294 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/statsd_exporter/pkg/mapper"
+)
+
+const (
+	// dashboardRateInterval lets Grafana pick the range of rate() from the
+	// scrape interval of the data source.
+	dashboardRateInterval = "$__rate_interval"
+	dashboardPanelWidth   = 12
+	dashboardPanelHeight  = 8
+)
+
+// templateRE matches the capture group references in mapped metric names.
+var templateRE = regexp.MustCompile(`\$\{?[a-zA-Z0-9_]+\}?`)
+
+type grafanaDashboard struct {
+	Title         string           `json:"title"`
+	Tags          []string         `json:"tags"`
+	SchemaVersion int              `json:"schemaVersion"`
+	Time          grafanaTimeRange `json:"time"`
+	Templating    grafanaVariables `json:"templating"`
+	Panels        []grafanaPanel   `json:"panels"`
+}
+
+type grafanaTimeRange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type grafanaVariables struct {
+	List []grafanaVariable `json:"list"`
+}
+
+type grafanaVariable struct {
+	Name  string `json:"name"`
+	Label string `json:"label"`
+	Type  string `json:"type"`
+	Query string `json:"query"`
+}
+
+type grafanaPanel struct {
+	ID          int                `json:"id"`
+	Type        string             `json:"type"`
+	Title       string             `json:"title"`
+	Description string             `json:"description,omitempty"`
+	Datasource  grafanaDatasource  `json:"datasource"`
+	GridPos     grafanaGridPos     `json:"gridPos"`
+	Targets     []grafanaTarget    `json:"targets"`
+	FieldConfig grafanaFieldConfig `json:"fieldConfig"`
+}
+
+type grafanaDatasource struct {
+	Type string `json:"type"`
+	UID  string `json:"uid"`
+}
+
+type grafanaGridPos struct {
+	H int `json:"h"`
+	W int `json:"w"`
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type grafanaTarget struct {
+	RefID        string `json:"refId"`
+	Expr         string `json:"expr"`
+	LegendFormat string `json:"legendFormat,omitempty"`
+}
+
+type grafanaFieldConfig struct {
+	Defaults grafanaFieldDefaults `json:"defaults"`
+}
+
+type grafanaFieldDefaults struct {
+	Unit string `json:"unit,omitempty"`
+}
+
+// dashboardFamily is a metric family the mapping configuration produces.
+type dashboardFamily struct {
+	name         string
+	help         string
+	metricType   mapper.MetricType
+	observerType mapper.ObserverType
+	labels       []string
+	quantiles    []float64
+}
+
+// selector returns the series selector for the family, with suffix appended
+// to its name. Names with capture group references match any value in their
+// place.
+func (f dashboardFamily) selector(suffix string, matchers ...string) string {
+	name := f.name + suffix
+	if templateRE.MatchString(f.name) {
+		matchers = append([]string{fmt.Sprintf("__name__=~%q", templateRE.ReplaceAllString(f.name, ".+")+suffix)}, matchers...)
+		name = ""
+	}
+	if len(matchers) == 0 {
+		return name
+	}
+	return name + "{" + strings.Join(matchers, ",") + "}"
+}
+
+// by returns the grouping clause for the labels of the family, with extra
+// labels added.
+func (f dashboardFamily) by(extra ...string) string {
+	labels := append(append([]string{}, f.labels...), extra...)
+	if len(labels) == 0 {
+		return ""
+	}
+	return " by (" + strings.Join(labels, ", ") + ") "
+}
+
+func (f dashboardFamily) legend() string {
+	parts := make([]string, 0, len(f.labels))
+	for _, l := range f.labels {
+		parts = append(parts, "{{"+l+"}}")
+	}
+	return strings.Join(parts, " ")
+}
+
+// targets returns the queries of the panel for the family.
+func (f dashboardFamily) targets() []grafanaTarget {
+	legend := f.legend()
+	rate := func(suffix string) string {
+		return fmt.Sprintf("sum%s(rate(%s[%s]))", f.by(), f.selector(suffix), dashboardRateInterval)
+	}
+
+	switch f.metricType {
+	case mapper.MetricTypeCounter:
+		return []grafanaTarget{{Expr: rate(""), LegendFormat: legend}}
+	case mapper.MetricTypeGauge:
+		return []grafanaTarget{{Expr: f.selector(""), LegendFormat: legend}}
+	}
+
+	var targets []grafanaTarget
+	switch f.observerType {
+	case mapper.ObserverTypeHistogram, mapper.ObserverTypeBoth:
+		for _, q := range []float64{0.5, 0.9, 0.99} {
+			targets = append(targets, grafanaTarget{
+				Expr:         fmt.Sprintf("histogram_quantile(%g, sum%s(rate(%s[%s])))", q, f.by("le"), f.selector("_bucket"), dashboardRateInterval),
+				LegendFormat: strings.TrimSpace(fmt.Sprintf("p%g %s", q*100, legend)),
+			})
+		}
+	default:
+		// Summaries and digests export their quantiles directly.
+		targets = append(targets, grafanaTarget{
+			Expr:         f.selector(""),
+			LegendFormat: strings.TrimSpace("{{quantile}} " + legend),
+		})
+	}
+	if f.observerType != mapper.ObserverTypeDigest {
+		targets = append(targets, grafanaTarget{
+			Expr:         rate("_count"),
+			LegendFormat: strings.TrimSpace("rate " + legend),
+		})
+	}
+	return targets
+}
+
+// dashboardFamilies returns the metric families of the mapping configuration,
+// ordered by name. Mappings with the drop action don't produce any, and
+// mappings without a metric type are assumed to produce counters if their
+// name ends in _total, and gauges otherwise.
+func dashboardFamilies(m *mapper.MetricMapper) []dashboardFamily {
+	families := map[string]*dashboardFamily{}
+	for _, mapping := range m.Mappings {
+		if mapping.Action == mapper.ActionTypeDrop || mapping.Name == "" {
+			continue
+		}
+		if _, ok := families[mapping.Name]; ok {
+			continue
+		}
+
+		f := &dashboardFamily{
+			name:       mapping.Name,
+			help:       mapping.HelpText,
+			metricType: mapping.MatchMetricType,
+		}
+		switch f.metricType {
+		case mapper.MetricTypeCounter, mapper.MetricTypeGauge:
+		case mapper.MetricTypeSet:
+			f.metricType = mapper.MetricTypeGauge
+		case mapper.MetricTypeObserver, mapper.MetricTypeTimer:
+			f.metricType = mapper.MetricTypeObserver
+			f.observerType = mapping.ObserverType
+			if f.observerType == mapper.ObserverTypeDefault {
+				f.observerType = m.Defaults.ObserverType
+			}
+		default:
+			f.metricType = mapper.MetricTypeGauge
+			if strings.HasSuffix(mapping.Name, "_total") {
+				f.metricType = mapper.MetricTypeCounter
+			}
+		}
+		for l := range mapping.Labels {
+			f.labels = append(f.labels, l)
+		}
+		sort.Strings(f.labels)
+		families[mapping.Name] = f
+	}
+
+	result := make([]dashboardFamily, 0, len(families))
+	for _, f := range families {
+		result = append(result, *f)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].name < result[j].name })
+	return result
+}
+
+// generateDashboard returns a Grafana dashboard with one panel for every
+// metric family of the mapping configuration.
+func generateDashboard(m *mapper.MetricMapper, title string) ([]byte, error) {
+	d := grafanaDashboard{
+		Title:         title,
+		Tags:          []string{"statsd"},
+		SchemaVersion: 39,
+		Time:          grafanaTimeRange{From: "now-1h", To: "now"},
+		Templating: grafanaVariables{List: []grafanaVariable{{
+			Name:  "datasource",
+			Label: "Data source",
+			Type:  "datasource",
+			Query: "prometheus",
+		}}},
+		Panels: []grafanaPanel{},
+	}
+	for i, f := range dashboardFamilies(m) {
+		targets := f.targets()
+		for j := range targets {
+			targets[j].RefID = string(rune('A' + j))
+		}
+		unit := ""
+		if f.metricType == mapper.MetricTypeCounter {
+			unit = "ops"
+		}
+		d.Panels = append(d.Panels, grafanaPanel{
+			ID:          i + 1,
+			Type:        "timeseries",
+			Title:       f.name,
+			Description: f.help,
+			Datasource:  grafanaDatasource{Type: "prometheus", UID: "${datasource}"},
+			GridPos: grafanaGridPos{
+				H: dashboardPanelHeight,
+				W: dashboardPanelWidth,
+				X: (i % 2) * dashboardPanelWidth,
+				Y: (i / 2) * dashboardPanelHeight,
+			},
+			Targets:     targets,
+			FieldConfig: grafanaFieldConfig{Defaults: grafanaFieldDefaults{Unit: unit}},
+		})
+	}
+	b, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// runDashboard writes a dashboard for the mapping configuration in
+// mappingFile to w.
+func runDashboard(mappingFile, title string, logger *slog.Logger, w io.Writer) error {
+	m := &mapper.MetricMapper{Logger: logger}
+	if err := m.InitFromFile(mappingFile); err != nil {
+		return err
+	}
+	b, err := generateDashboard(m, title)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
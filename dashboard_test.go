@@ -0,0 +1,96 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/statsd_exporter/pkg/mapper"
+)
+
+func TestGenerateDashboard(t *testing.T) {
+	m := &mapper.MetricMapper{}
+	err := m.InitFromYAMLString(`
+defaults:
+  observer_type: histogram
+mappings:
+- match: "*.requests"
+  name: "requests_total"
+  help: "Requests served."
+  labels:
+    service: "$1"
+- match: "*.requests"
+  name: "requests_total"
+  match_metric_type: gauge
+- match: "*.queue"
+  name: "queue_length"
+  match_metric_type: gauge
+- match: "*.latency"
+  name: "latency_seconds"
+  match_metric_type: observer
+  labels:
+    service: "$1"
+- match: "*.size"
+  name: "size_bytes"
+  match_metric_type: observer
+  observer_type: digest
+- match: "api.*.errors"
+  name: "api_${1}_errors_total"
+  match_metric_type: counter
+- match: "noise.*"
+  name: "noise"
+  action: drop
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := generateDashboard(m, "Test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var d grafanaDashboard
+	if err := json.Unmarshal(b, &d); err != nil {
+		t.Fatal(err)
+	}
+	if d.Title != "Test" {
+		t.Errorf("unexpected title %q", d.Title)
+	}
+	got := map[string][]string{}
+	for _, p := range d.Panels {
+		for _, target := range p.Targets {
+			got[p.Title] = append(got[p.Title], target.Expr)
+		}
+	}
+	expected := map[string][]string{
+		"api_${1}_errors_total": {`sum(rate({__name__=~"api_.+_errors_total"}[$__rate_interval]))`},
+		"latency_seconds": {
+			`histogram_quantile(0.5, sum by (service, le) (rate(latency_seconds_bucket[$__rate_interval])))`,
+			`histogram_quantile(0.9, sum by (service, le) (rate(latency_seconds_bucket[$__rate_interval])))`,
+			`histogram_quantile(0.99, sum by (service, le) (rate(latency_seconds_bucket[$__rate_interval])))`,
+			`sum by (service) (rate(latency_seconds_count[$__rate_interval]))`,
+		},
+		"queue_length":   {`queue_length`},
+		"requests_total": {`sum by (service) (rate(requests_total[$__rate_interval]))`},
+		"size_bytes":     {`size_bytes`},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("unexpected queries\n got: %v\nwant: %v", got, expected)
+	}
+	if d.Panels[1].GridPos.X != dashboardPanelWidth || d.Panels[2].GridPos.Y != dashboardPanelHeight {
+		t.Errorf("unexpected panel layout %v", d.Panels)
+	}
+}
@@ -14,6 +14,7 @@
 package main
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/prometheus/common/promslog"
@@ -103,3 +104,75 @@ func BenchmarkLineFormats(b *testing.B) {
 		})
 	}
 }
+
+// Allocations for label maps by the number of tags of a line.
+func BenchmarkLineLabels(b *testing.B) {
+	parser := line.NewParser()
+	parser.EnableDogstatsdParsing()
+
+	for _, tags := range []int{0, 1, 4, 8, 16, 32} {
+		l := "foo:1|c"
+		for i := 0; i < tags; i++ {
+			if i == 0 {
+				l += "|#"
+			} else {
+				l += ","
+			}
+			l += fmt.Sprintf("tag%d:value%d", i, i)
+		}
+		b.Run(fmt.Sprintf("tags=%d", tags), func(b *testing.B) {
+			b.ReportAllocs()
+			for n := 0; n < b.N; n++ {
+				parser.LineToEvents(l, *sampleErrors, samplesReceived, tagErrors, tagsReceived, nopLogger)
+			}
+		})
+	}
+}
+
+// Statsite frames compared to the equivalent StatsD lines.
+func BenchmarkStatsiteBinary(b *testing.B) {
+	input := []struct {
+		statType string
+		value    float64
+	}{
+		{"c", 2},
+		{"g", 3},
+		{"ms", 200},
+	}
+
+	var frames []byte
+	var lines []string
+	for i, in := range input {
+		name := fmt.Sprintf("foo%d", i)
+		frame, err := line.AppendStatsiteFrame(nil, in.statType, name, in.value, false)
+		if err != nil {
+			b.Fatal(err)
+		}
+		frames = append(frames, frame...)
+		lines = append(lines, fmt.Sprintf("%s:%g|%s", name, in.value, in.statType))
+	}
+	parser := line.NewParser()
+
+	b.Run("binary", func(b *testing.B) {
+		// always report allocations since this is a hot path
+		b.ReportAllocs()
+		for n := 0; n < b.N; n++ {
+			for buf := frames; len(buf) > 0; {
+				_, length, err := line.DecodeStatsiteFrame(buf)
+				if err != nil {
+					b.Fatal(err)
+				}
+				buf = buf[length:]
+			}
+		}
+	})
+	b.Run("text", func(b *testing.B) {
+		// always report allocations since this is a hot path
+		b.ReportAllocs()
+		for n := 0; n < b.N; n++ {
+			for _, l := range lines {
+				parser.LineToEvents(l, *sampleErrors, samplesReceived, tagErrors, tagsReceived, nopLogger)
+			}
+		}
+	})
+}
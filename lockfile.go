@@ -0,0 +1,66 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// errLocked is returned by flockFile if another process holds the lock.
+var errLocked = errors.New("file is locked")
+
+// instanceLock is an exclusive lock on a file, held while the exporter runs.
+// Two instances started with the same lock file would otherwise both receive
+// a share of the packets sent to them, halving the rates each of them exports.
+type instanceLock struct {
+	f *os.File
+}
+
+// acquireInstanceLock locks the file at path, creating it if necessary, and
+// records the process ID in it. It fails if another process holds the lock,
+// naming that process in the error.
+func acquireInstanceLock(path string) (*instanceLock, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := flockFile(f); err != nil {
+		owner, _ := io.ReadAll(io.LimitReader(f, 64))
+		f.Close()
+		if errors.Is(err, errLocked) {
+			return nil, fmt.Errorf("another instance (%s) holds the lock file %s", bytes.TrimSpace(owner), path)
+		}
+		return nil, fmt.Errorf("locking %s: %w", path, err)
+	}
+
+	if err := f.Truncate(0); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := fmt.Fprintf(f, "pid %d\n", os.Getpid()); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &instanceLock{f: f}, nil
+}
+
+// Release releases the lock. The file is left in place, since removing it
+// could let a process that opened it before lock a file nobody else sees.
+func (l *instanceLock) Release() error {
+	return l.f.Close()
+}
@@ -0,0 +1,51 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestInstanceLock(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("lock files are not supported on windows")
+	}
+	path := filepath.Join(t.TempDir(), "statsd_exporter.lock")
+
+	lock, err := acquireInstanceLock(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = acquireInstanceLock(path)
+	if err == nil {
+		t.Fatal("expected locking a locked file to fail")
+	}
+	if !strings.Contains(err.Error(), "pid "+strconv.Itoa(os.Getpid())) {
+		t.Errorf("expected the error to name the holder of the lock, got %v", err)
+	}
+
+	if err := lock.Release(); err != nil {
+		t.Fatal(err)
+	}
+	lock, err = acquireInstanceLock(path)
+	if err != nil {
+		t.Fatalf("expected a released lock to be acquired again, got %v", err)
+	}
+	lock.Release()
+}
@@ -15,7 +15,11 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"io/fs"
 	"log/slog"
 	"net"
 	"net/http"
@@ -23,19 +27,20 @@ import (
 	"os"
 	"os/signal"
 	"strconv"
-	"syscall"
+	"strings"
+	"time"
 
 	"github.com/alecthomas/kingpin/v2"
 	"github.com/prometheus/client_golang/prometheus"
 	versioncollector "github.com/prometheus/client_golang/prometheus/collectors/version"
-	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/prometheus/common/promslog"
 	"github.com/prometheus/common/promslog/flag"
 	"github.com/prometheus/common/version"
 	"github.com/prometheus/exporter-toolkit/web"
+	"github.com/prometheus/exporter-toolkit/web/kingpinflag"
 
-	"github.com/prometheus/statsd_exporter/pkg/address"
+	"github.com/prometheus/statsd_exporter/pkg/clock"
 	"github.com/prometheus/statsd_exporter/pkg/event"
 	"github.com/prometheus/statsd_exporter/pkg/exporter"
 	"github.com/prometheus/statsd_exporter/pkg/line"
@@ -43,144 +48,451 @@ import (
 	"github.com/prometheus/statsd_exporter/pkg/mapper"
 	"github.com/prometheus/statsd_exporter/pkg/mappercache/lru"
 	"github.com/prometheus/statsd_exporter/pkg/mappercache/randomreplacement"
+	"github.com/prometheus/statsd_exporter/pkg/metrics"
 	"github.com/prometheus/statsd_exporter/pkg/relay"
 )
 
 var (
-	eventStats = promauto.NewCounterVec(
+	eventStats = metrics.SelfMetrics.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "statsd_exporter_events_total",
 			Help: "The total number of StatsD events seen.",
 		},
 		[]string{"type"},
 	)
-	eventsFlushed = promauto.NewCounter(
+	eventsFlushed = metrics.SelfMetrics.NewCounter(
 		prometheus.CounterOpts{
 			Name: "statsd_exporter_event_queue_flushed_total",
 			Help: "Number of times events were flushed to exporter",
 		},
 	)
-	eventsUnmapped = promauto.NewCounter(
+	eventBytesQueued = metrics.SelfMetrics.NewCounter(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_event_queue_bytes_total",
+			Help: "The approximate size of the events queued, if the queue is limited by size.",
+		},
+	)
+	deadLettered = metrics.SelfMetrics.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_dead_letters_total",
+			Help: "The total number of events that could not be applied to the registry, by reason.",
+		},
+		[]string{"reason"},
+	)
+	counterWindows = metrics.SelfMetrics.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "statsd_exporter_counter_window_seconds",
+			Help: "The window over which the increments of a counter are accumulated before they are applied, by metric name.",
+		},
+		[]string{"metric"},
+	)
+	clusterPeersUp = metrics.SelfMetrics.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "statsd_exporter_cluster_peer_up",
+			Help: "Whether the peer answers heartbeats (1) or its series were taken over (0).",
+		},
+		[]string{"peer"},
+	)
+	mappedInfo = metrics.SelfMetrics.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "statsd_exporter_mapped_info",
+			Help: "Set to 1 for each metric name and the match of the mapping rule that produced it.",
+		},
+		[]string{"metric", "rule"},
+	)
+	eventsShed = metrics.SelfMetrics.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_events_shed_total",
+			Help: "The total number of events shed because the event queue was backed up, by mapping priority.",
+		},
+		[]string{"priority"},
+	)
+	mappingStageEvents = metrics.SelfMetrics.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_mapping_stage_events_total",
+			Help: "The total number of events whose mapping was looked up before handling them, by whether regex mappings had to be evaluated (slow) or not (fast).",
+		},
+		[]string{"path"},
+	)
+	regexMappingShed = metrics.SelfMetrics.NewCounter(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_regex_mapping_events_shed_total",
+			Help: "The total number of events dropped because the queue of their regex mapping worker was full.",
+		},
+	)
+	regexMappingQueue = metrics.SelfMetrics.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "statsd_exporter_regex_mapping_queue_length",
+			Help: "The number of events waiting for regex mappings to be evaluated.",
+		},
+	)
+	applyDuration = metrics.SelfMetrics.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "statsd_exporter_event_apply_duration_seconds",
+			Help:    "Time taken to apply an event to the registry, by metric type.",
+			Buckets: prometheus.ExponentialBuckets(1e-6, 4, 10),
+		},
+		[]string{"type"},
+	)
+	eventLatency = metrics.SelfMetrics.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "statsd_exporter_event_exposition_latency_seconds",
+			Help:    "Time from receiving sampled events until their values were first served to a scrape.",
+			Buckets: []float64{0.1, 0.5, 1, 2.5, 5, 10, 15, 30, 60, 120, 300},
+		},
+	)
+	activeMetricNames = metrics.SelfMetrics.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "statsd_exporter_active_metric_names",
+			Help: "The number of metric names with a series updated within the cardinality window.",
+		},
+	)
+	activeSeries = metrics.SelfMetrics.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "statsd_exporter_active_series",
+			Help: "The number of series updated within the cardinality window.",
+		},
+	)
+	cardinalityWarnings = metrics.SelfMetrics.NewCounter(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_cardinality_growth_warnings_total",
+			Help: "The number of times the number of active series grew faster than the warning threshold.",
+		},
+	)
+	expiryPaused = metrics.SelfMetrics.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "statsd_exporter_expiry_paused_seconds",
+			Help: "How long metric expiry has been paused for because the exporter was not scraped, or 0 if it is not paused.",
+		},
+	)
+	memoryProtection = metrics.SelfMetrics.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "statsd_exporter_memory_protection_active",
+			Help: "Whether new series are rejected because the heap exceeds --statsd.memory-limit.",
+		},
+	)
+	eventsUnmapped = metrics.SelfMetrics.NewCounter(
 		prometheus.CounterOpts{
 			Name: "statsd_exporter_events_unmapped_total",
 			Help: "The total number of StatsD events no mapping was found for.",
 		})
-	udpPackets = promauto.NewCounter(
+	udpPackets = metrics.SelfMetrics.NewShardedCounter(
 		prometheus.CounterOpts{
 			Name: "statsd_exporter_udp_packets_total",
 			Help: "The total number of StatsD packets received over UDP.",
 		},
 	)
-	udpPacketDrops = promauto.NewCounter(
+	udpPacketDrops = metrics.SelfMetrics.NewCounter(
 		prometheus.CounterOpts{
 			Name: "statsd_exporter_udp_packet_drops_total",
 			Help: "The total number of dropped StatsD packets which received over UDP.",
 		},
 	)
-	tcpConnections = promauto.NewCounter(
+	tcpConnections = metrics.SelfMetrics.NewCounter(
 		prometheus.CounterOpts{
 			Name: "statsd_exporter_tcp_connections_total",
 			Help: "The total number of TCP connections handled.",
 		},
 	)
-	tcpErrors = promauto.NewCounter(
+	tcpErrors = metrics.SelfMetrics.NewCounter(
 		prometheus.CounterOpts{
 			Name: "statsd_exporter_tcp_connection_errors_total",
 			Help: "The number of errors encountered reading from TCP.",
 		},
 	)
-	tcpLineTooLong = promauto.NewCounter(
+	tcpLineTooLong = metrics.SelfMetrics.NewCounter(
 		prometheus.CounterOpts{
 			Name: "statsd_exporter_tcp_too_long_lines_total",
 			Help: "The number of lines discarded due to being too long.",
 		},
 	)
-	unixgramPackets = promauto.NewCounter(
+	tcpProtocols = metrics.SelfMetrics.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_tcp_detected_protocols_total",
+			Help: "The total number of TCP connections by detected protocol.",
+		},
+		[]string{"protocol"},
+	)
+	rejectedSources = metrics.SelfMetrics.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_source_rejections_total",
+			Help: "The total number of packets and connections rejected by source address, by listener and the rule that rejected them.",
+		},
+		[]string{"listener", "rule"},
+	)
+	unixConnections = metrics.SelfMetrics.NewCounter(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_unix_connections_total",
+			Help: "The total number of Unix stream socket connections handled.",
+		},
+	)
+	unixErrors = metrics.SelfMetrics.NewCounter(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_unix_connection_errors_total",
+			Help: "The number of errors encountered reading from Unix stream sockets.",
+		},
+	)
+	unixLineTooLong = metrics.SelfMetrics.NewCounter(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_unix_too_long_lines_total",
+			Help: "The number of lines from Unix stream sockets discarded due to being too long.",
+		},
+	)
+	syslogConnections = metrics.SelfMetrics.NewCounter(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_syslog_connections_total",
+			Help: "The total number of syslog over TLS connections handled.",
+		},
+	)
+	syslogErrors = metrics.SelfMetrics.NewCounter(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_syslog_connection_errors_total",
+			Help: "The number of errors encountered in TLS handshakes and reading from syslog over TLS connections.",
+		},
+	)
+	syslogMalformed = metrics.SelfMetrics.NewCounter(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_syslog_malformed_messages_total",
+			Help: "The number of syslog messages discarded due to not being valid RFC 5424 messages.",
+		},
+	)
+	unixgramPackets = metrics.SelfMetrics.NewShardedCounter(
 		prometheus.CounterOpts{
 			Name: "statsd_exporter_unixgram_packets_total",
 			Help: "The total number of StatsD packets received over Unixgram.",
 		},
 	)
-	linesReceived = promauto.NewCounter(
+	linesReceived = metrics.SelfMetrics.NewShardedCounter(
 		prometheus.CounterOpts{
 			Name: "statsd_exporter_lines_total",
 			Help: "The total number of StatsD lines received.",
 		},
 	)
-	samplesReceived = promauto.NewCounter(
+	samplesReceived = metrics.SelfMetrics.NewShardedCounter(
 		prometheus.CounterOpts{
 			Name: "statsd_exporter_samples_total",
 			Help: "The total number of StatsD samples received.",
 		},
 	)
-	sampleErrors = promauto.NewCounterVec(
+	sampleErrors = metrics.SelfMetrics.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "statsd_exporter_sample_errors_total",
 			Help: "The total number of errors parsing StatsD samples.",
 		},
 		[]string{"reason"},
 	)
-	tagsReceived = promauto.NewCounter(
+	tagsReceived = metrics.SelfMetrics.NewShardedCounter(
 		prometheus.CounterOpts{
 			Name: "statsd_exporter_tags_total",
 			Help: "The total number of DogStatsD tags processed.",
 		},
 	)
-	tagErrors = promauto.NewCounter(
+	lenientValues = metrics.SelfMetrics.NewCounter(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_lenient_values_total",
+			Help: "The total number of sample values only accepted because of --statsd.lenient-numbers.",
+		},
+	)
+	tagErrors = metrics.SelfMetrics.NewCounter(
 		prometheus.CounterOpts{
 			Name: "statsd_exporter_tag_errors_total",
 			Help: "The number of errors parsing DogStatsD tags.",
 		},
 	)
-	configLoads = promauto.NewCounterVec(
+	configLoads = metrics.SelfMetrics.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "statsd_exporter_config_reloads_total",
 			Help: "The number of configuration reloads.",
 		},
 		[]string{"outcome"},
 	)
-	mappingsCount = promauto.NewGauge(prometheus.GaugeOpts{
+	mappingsCount = metrics.SelfMetrics.NewGauge(prometheus.GaugeOpts{
 		Name: "statsd_exporter_loaded_mappings",
 		Help: "The current number of configured metric mappings.",
 	})
-	conflictingEventStats = promauto.NewCounterVec(
+	mappingReloadTime = metrics.SelfMetrics.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "statsd_exporter_mapping_reload_duration_seconds",
+			Help:    "The time spent compiling and validating reloaded mapping configurations, by phase.",
+			Buckets: prometheus.ExponentialBuckets(0.001, 4, 8),
+		},
+		[]string{"phase"},
+	)
+	conflictingEventStats = metrics.SelfMetrics.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "statsd_exporter_events_conflict_total",
 			Help: "The total number of StatsD events with conflicting names.",
 		},
 		[]string{"type", "metric_name"},
 	)
-	errorEventStats = promauto.NewCounterVec(
+	errorEventStats = metrics.SelfMetrics.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "statsd_exporter_events_error_total",
 			Help: "The total number of StatsD events discarded due to errors.",
 		},
 		[]string{"reason"},
 	)
-	eventsActions = promauto.NewCounterVec(
+	eventsActions = metrics.SelfMetrics.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "statsd_exporter_events_actions_total",
 			Help: "The total number of StatsD events by action.",
 		},
 		[]string{"action"},
 	)
-	metricsCount = promauto.NewGaugeVec(
+	metricsCount = metrics.SelfMetrics.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "statsd_exporter_metrics_total",
 			Help: "The total number of metrics.",
 		},
 		[]string{"type"},
 	)
+	gaugeChanges = metrics.SelfMetrics.NewCounter(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_gauge_changes_total",
+			Help: "The total number of gauge events that changed the value of a gauge.",
+		},
+	)
+	relativeGauges = metrics.SelfMetrics.NewCounter(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_relative_gauge_operations_total",
+			Help: "The total number of relative changes applied to gauges.",
+		},
+	)
+	listenerHealth = listener.HealthMetrics{
+		Up: metrics.SelfMetrics.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "statsd_exporter_listener_up",
+				Help: "Whether the listener is receiving (1) or stopped (0).",
+			},
+			[]string{"listener"},
+		),
+		LastRead: metrics.SelfMetrics.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "statsd_exporter_listener_last_read_timestamp_seconds",
+				Help: "The time of the last successful read of the listener.",
+			},
+			[]string{"listener"},
+		),
+		ReadErrors: metrics.SelfMetrics.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "statsd_exporter_listener_read_errors_total",
+				Help: "The total number of errors reading from or accepting on the listener.",
+			},
+			[]string{"listener"},
+		),
+		Restarts: metrics.SelfMetrics.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "statsd_exporter_listener_restarts_total",
+				Help: "The total number of times the listener was restarted after stopping unexpectedly.",
+			},
+			[]string{"listener"},
+		),
+	}
+	acceptRetries = metrics.SelfMetrics.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_accept_retries_total",
+			Help: "The total number of accepts retried after transient errors, such as running out of file descriptors.",
+		},
+		[]string{"listener"},
+	)
+	listenerIdle = metrics.SelfMetrics.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "statsd_exporter_listener_idle",
+			Help: "Whether the listener received fewer lines in the last minute than expected (1) or not (0).",
+		},
+		[]string{"listener"},
+	)
+	budgetDroppedLines = metrics.SelfMetrics.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_packet_budget_dropped_lines_total",
+			Help: "The total number of lines of datagrams dropped without parsing because the packet exceeded a limit.",
+		},
+		[]string{"limit"},
+	)
+	quarantinedEvents = metrics.SelfMetrics.NewCounter(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_quarantined_events_total",
+			Help: "The total number of events dropped because their metric name is quarantined.",
+		},
+	)
+	quarantinedNames = metrics.SelfMetrics.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "statsd_exporter_quarantined_metric_names",
+			Help: "The number of metric names currently quarantined.",
+		},
+	)
+	listenerReceive = listener.ReceiveMetrics{
+		Bytes: metrics.SelfMetrics.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "statsd_exporter_listener_received_bytes_total",
+				Help: "The total number of bytes received by the listener.",
+			},
+			[]string{"listener"},
+		),
+		Packets: metrics.SelfMetrics.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "statsd_exporter_listener_received_packets_total",
+				Help: "The total number of packets received by the listener, or reads from connections for stream listeners.",
+			},
+			[]string{"listener"},
+		),
+		LinesPerPacket: metrics.SelfMetrics.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "statsd_exporter_listener_lines_per_packet",
+				Help:    "The number of non-empty lines in packets received by datagram listeners.",
+				Buckets: prometheus.ExponentialBuckets(1, 2, 10),
+			},
+			[]string{"listener"},
+		),
+		Truncated: metrics.SelfMetrics.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "statsd_exporter_listener_truncated_packets_total",
+				Help: "The total number of packets larger than the maximum packet size of the listener, whose remaining lines were dropped.",
+			},
+			[]string{"listener"},
+		),
+	}
+	clockJumps = metrics.SelfMetrics.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_clock_jumps_total",
+			Help: "The total number of detected wall clock steps and exporter stalls.",
+		},
+		[]string{"type"},
+	)
+	cappedSampleWeights = metrics.SelfMetrics.NewCounter(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_sample_weights_capped_total",
+			Help: "The total number of sampled observer values that summaries or native histograms observed fewer times than the values stand for, because of their low sample rate.",
+		},
+	)
+	slowScrapes = metrics.SelfMetrics.NewCounter(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_scrapes_exceeded_soft_deadline_total",
+			Help: "The total number of scrapes that took longer than the soft deadline.",
+		},
+	)
+	abortedScrapes = metrics.SelfMetrics.NewCounter(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_scrapes_aborted_total",
+			Help: "The total number of scrapes aborted because they exceeded the scrape deadline.",
+		},
+	)
 )
 
-func serveHTTP(mux http.Handler, listenAddress string, logger *slog.Logger) {
-	logger.Error(http.ListenAndServe(listenAddress, mux).Error())
-	os.Exit(1)
+func serveHTTP(mux http.Handler, toolkitFlags *web.FlagConfig, logger *slog.Logger) {
+	server := &http.Server{Handler: mux}
+	if err := web.ListenAndServe(server, toolkitFlags, logger); err != nil {
+		logger.Error("Error serving HTTP", "err", err)
+		os.Exit(1)
+	}
 }
 
 func sighupConfigReloader(fileName string, mapper *mapper.MetricMapper, logger *slog.Logger) {
+	if len(reloadSignals) == 0 {
+		return
+	}
 	signals := make(chan os.Signal, 1)
-	signal.Notify(signals, syscall.SIGHUP)
+	signal.Notify(signals, reloadSignals...)
 
 	for s := range signals {
 		if fileName == "" {
@@ -195,12 +507,13 @@ func sighupConfigReloader(fileName string, mapper *mapper.MetricMapper, logger *
 }
 
 func reloadConfig(fileName string, mapper *mapper.MetricMapper, logger *slog.Logger) {
-	err := mapper.InitFromFile(fileName)
+	err := mapper.Reload(fileName)
 	if err != nil {
 		logger.Info("Error reloading config", "error", err)
 		configLoads.WithLabelValues("failure").Inc()
 	} else {
 		logger.Info("Config reloaded successfully")
+		mappedInfo.Reset()
 		configLoads.WithLabelValues("success").Inc()
 	}
 }
@@ -242,46 +555,164 @@ func getCache(cacheSize int, cacheType string, registerer prometheus.Registerer)
 	return cache, nil
 }
 
+// anyListener reports whether any of the listener addresses is configured.
+func anyListener(addrs ...string) bool {
+	for _, addr := range addrs {
+		if addr != "" {
+			return true
+		}
+	}
+	return false
+}
+
 func main() {
 	var (
-		listenAddress        = kingpin.Flag("web.listen-address", "The address on which to expose the web interface and generated Prometheus metrics.").Default(":9102").String()
+		toolkitFlags         = kingpinflag.AddFlags(kingpin.CommandLine, ":9102")
 		enableLifecycle      = kingpin.Flag("web.enable-lifecycle", "Enable shutdown and reload via HTTP request.").Default("false").Bool()
+		enableAdminAPI       = kingpin.Flag("web.enable-admin-api", "Enable the admin API, to change the event queue configuration, approve quarantined metric names and seed metrics via HTTP request.").Default("false").Bool()
 		metricsEndpoint      = kingpin.Flag("web.telemetry-path", "Path under which to expose metrics.").Default("/metrics").String()
+		scrapeTimeout        = kingpin.Flag("web.scrape-timeout", "Maximum time to spend serving a scrape. 0 only applies the timeout sent by Prometheus.").Default("0s").Duration()
+		scrapeTimeoutOffset  = kingpin.Flag("web.scrape-timeout-offset", "Time to subtract from the timeout sent by Prometheus, to leave time for the response to reach it.").Default("500ms").Duration()
+		scrapeSoftDeadline   = kingpin.Flag("web.scrape-soft-deadline", "Fraction of the scrape timeout after which a scrape is counted as slow.").Default("0.8").Float64()
+		streamExposition     = kingpin.Flag("web.streaming-exposition", "Serve the metrics created from StatsD events one metric family at a time, to bound the memory needed to serve very large numbers of series.").Default("false").Bool()
+		enableOpenMetrics    = kingpin.Flag("web.enable-openmetrics", "Serve the OpenMetrics format, including created timestamps of counters, histograms and summaries, to scrapers that ask for it.").Default("false").Bool()
 		statsdListenUDP      = kingpin.Flag("statsd.listen-udp", "The UDP address on which to receive statsd metric lines. \"\" disables it.").Default(":9125").String()
 		statsdListenTCP      = kingpin.Flag("statsd.listen-tcp", "The TCP address on which to receive statsd metric lines. \"\" disables it.").Default(":9125").String()
+		udpFallbackAddrs     = kingpin.Flag("statsd.listen-udp-fallback", "Fallback UDP address to try if the --statsd.listen-udp address cannot be bound. Can be repeated, addresses are tried in order.").Strings()
+		tcpFallbackAddrs     = kingpin.Flag("statsd.listen-tcp-fallback", "Fallback TCP address to try if the --statsd.listen-tcp address cannot be bound. Can be repeated, addresses are tried in order.").Strings()
+		udpMulticastGroup    = kingpin.Flag("statsd.listen-udp-multicast", "The multicast group address (group:port) to join to receive statsd metric lines. \"\" disables it.").Default("").String()
+		udpMulticastIface    = kingpin.Flag("statsd.listen-udp-multicast-interface", "The network interface on which to join the multicast group. Uses the system default interface if empty.").Default("").String()
+		portRetries          = kingpin.Flag("statsd.port-retry", "Number of times to retry binding each listen address before moving on to the next fallback address.").Default("0").Int()
+		portRetryInterval    = kingpin.Flag("statsd.port-retry-interval", "Time to wait between attempts to bind a listen address.").Default("1s").Duration()
 		statsdListenUnixgram = kingpin.Flag("statsd.listen-unixgram", "The Unixgram socket path to receive statsd metric lines in datagram. \"\" disables it.").Default("").String()
+		udpProtocol          = kingpin.Flag("statsd.udp-protocol", "The protocol received by the UDP listeners, one of statsd, statsite-binary or graphite.").Default(listener.ProtocolStatsD).Enum(append(line.Formats(), listener.ProtocolStatsiteBinary)...)
+		tcpProtocol          = kingpin.Flag("statsd.tcp-protocol", "The protocol received by the TCP listener, one of statsd, statsite-binary or graphite.").Default(listener.ProtocolStatsD).Enum(append(line.Formats(), listener.ProtocolStatsiteBinary)...)
+		tcpAck               = kingpin.Flag("statsd.tcp-ack", "Reply OK to a line containing only \".\" on TCP connections once the lines before it are queued, or ERR if they might not have been.").Default("false").Bool()
+		tcpDetectProtocol    = kingpin.Flag("statsd.tcp-detect-protocol", "Detect PROXY protocol headers and HTTP requests on the TCP listener. HTTP requests can POST StatsD lines.").Default("false").Bool()
+		udpAllowSources      = kingpin.Flag("statsd.udp-allow-source", "Only accept UDP packets from this CIDR prefix or address. Can be repeated.").Strings()
+		udpDenySources       = kingpin.Flag("statsd.udp-deny-source", "Drop UDP packets from this CIDR prefix or address. Can be repeated, and takes precedence over allowed sources.").Strings()
+		tcpAllowSources      = kingpin.Flag("statsd.tcp-allow-source", "Only accept TCP connections from this CIDR prefix or address. Can be repeated.").Strings()
+		tcpDenySources       = kingpin.Flag("statsd.tcp-deny-source", "Close TCP connections from this CIDR prefix or address. Can be repeated, and takes precedence over allowed sources.").Strings()
+		statsdListenUnix     = kingpin.Flag("statsd.listen-unix", "The Unix stream socket path to receive statsd metric lines. \"\" disables it.").Default("").String()
 		// not using Int here because flag displays default in decimal, 0755 will show as 493
 		statsdUnixSocketMode = kingpin.Flag("statsd.unixsocket-mode", "The permission mode of the unix socket.").Default("755").String()
+		unixSocketOwner      = kingpin.Flag("statsd.unixsocket-owner", "The user name or ID to own the unix socket.").Default("").String()
+		unixSocketGroup      = kingpin.Flag("statsd.unixsocket-group", "The group name or ID to own the unix socket.").Default("").String()
+		unixSocketRmStale    = kingpin.Flag("statsd.unixsocket-remove-stale", "Remove a unix socket file left behind by a previous run that nothing listens on anymore.").Default("false").Bool()
+		statsdListenSyslog   = kingpin.Flag("statsd.listen-syslog-tls", "The TCP address on which to receive statsd metric lines in RFC 5424 syslog messages over TLS (RFC 5425). \"\" disables it.").Default("").String()
+		syslogTLSCertFile    = kingpin.Flag("statsd.syslog-tls-cert-file", "The certificate of the syslog over TLS listener, in PEM format.").Default("").String()
+		syslogTLSKeyFile     = kingpin.Flag("statsd.syslog-tls-key-file", "The private key of the syslog over TLS listener, in PEM format.").Default("").String()
+		syslogTLSClientCA    = kingpin.Flag("statsd.syslog-tls-client-ca-file", "Require syslog over TLS clients to present a certificate signed by a CA in this PEM file. \"\" accepts any client.").Default("").String()
+		syslogMaxMessage     = kingpin.Flag("statsd.syslog-max-message-size", "The size in bytes of the largest syslog message accepted. Larger messages close the connection.").Default("65536").Int()
 		mappingConfig        = kingpin.Flag("statsd.mapping-config", "Metric mapping configuration file name.").String()
 		readBuffer           = kingpin.Flag("statsd.read-buffer", "Size (in bytes) of the operating system's transmit read buffer associated with the UDP or Unixgram connection. Please make sure the kernel parameters net.core.rmem_max is set to a value greater than the value specified.").Int()
+		mappingSampleSize    = kingpin.Flag("statsd.mapping-validation-sample-size", "Number of recently mapped metric names to validate a reloaded mapping configuration with, and to warm the cache with after applying it. 0 disables the sample.").Default("1000").Int()
 		cacheSize            = kingpin.Flag("statsd.cache-size", "Maximum size of your metric mapping cache. Relies on least recently used replacement policy if max size is reached.").Default("1000").Int()
 		cacheType            = kingpin.Flag("statsd.cache-type", "Metric mapping cache type. Valid options are \"lru\" and \"random\"").Default("lru").Enum("lru", "random")
 		eventQueueSize       = kingpin.Flag("statsd.event-queue-size", "Size of internal queue for processing events.").Default("10000").Uint()
 		eventFlushThreshold  = kingpin.Flag("statsd.event-flush-threshold", "Number of events to hold in queue before flushing.").Default("1000").Int()
+		eventFlushBytes      = kingpin.Flag("statsd.event-flush-bytes", "Approximate size (in bytes) of events to hold in queue before flushing, in addition to the number of events. 0 disables the limit.").Default("0").Int()
+		shedByPriority       = kingpin.Flag("statsd.shed-by-priority", "Shed debug priority events once the event queue is half full, and normal priority events once it is full.").Default("false").Bool()
+		regexMappingWorkers  = kingpin.Flag("statsd.regex-mapping-workers", "Number of workers evaluating regex mappings, separately from other events. 0 evaluates them inline.").Default("0").Int()
+		regexMappingQueueLen = kingpin.Flag("statsd.regex-mapping-queue-size", "Number of events each regex mapping worker can queue.").Default("1000").Int()
+		regexMappingShedding = kingpin.Flag("statsd.regex-mapping-shed", "Drop events for a regex mapping worker whose queue is full, instead of waiting for room in the queue.").Default("false").Bool()
+		latencyProbeEvery    = kingpin.Flag("statsd.latency-probe-every", "Measure the time until every nth event is served to a scrape. 0 disables latency measurement.").Default("0").Int()
+		cardinalityWindow    = kingpin.Flag("statsd.cardinality-window", "Window within which metric names and series count as active for statsd_exporter_active_series. 0 disables counting.").Default("0s").Duration()
+		cardinalityWarning   = kingpin.Flag("statsd.cardinality-growth-warning", "Growth of active series per minute beyond which a warning is logged. 0 disables warnings.").Default("0").Float64()
+		scrapeOutageWindow   = kingpin.Flag("statsd.pause-expiry-without-scrapes", "Pause metric expiry once the exporter has not been scraped for this long, until scrapes resume. 0 never pauses expiry.").Default("0s").Duration()
+		memoryLimit          = kingpin.Flag("statsd.memory-limit", "Heap size (e.g. 512MB) beyond which no new series are created until it shrinks below 80% of it. 0 disables the limit.").Default("0").Bytes()
 		eventFlushInterval   = kingpin.Flag("statsd.event-flush-interval", "Maximum time between event queue flushes.").Default("200ms").Duration()
+		flushOnShutdown      = kingpin.Flag("statsd.flush-on-shutdown", "On shutdown, apply the events still in the event queue before exiting.").Default("false").Bool()
 		dumpFSMPath          = kingpin.Flag("debug.dump-fsm", "The path to dump internal FSM generated for glob matching as Dot file.").Default("").String()
+		ingestRateWindow     = kingpin.Flag("debug.ingest-rate-window", "Time constant of the moving average of samples per second per metric, exposed at /debug/ingest-rates. 0 disables it.").Default("0s").Duration()
+		quarantineEnabled    = kingpin.Flag("statsd.quarantine", "Drop events for metric names that were not seen before, until they are approved via the admin API or the quarantine period has passed. Quarantined names are listed at /debug/quarantine.").Default("false").Bool()
+		quarantinePeriod     = kingpin.Flag("statsd.quarantine-period", "Time after which quarantined metric names are approved automatically. 0 quarantines them until approved via the admin API, which requires --web.enable-admin-api.").Default("0s").Duration()
+		digestInterval       = kingpin.Flag("statsd.digest-interval", "Interval over which observers with the observer type digest estimate quantiles. The digests of the last interval are exposed at /debug/digests.").Default("1m").Duration()
+		setWindow            = kingpin.Flag("statsd.set-window", "Window over which the unique members of StatsD sets are counted. Each set is exported as a gauge of the number of unique members in the last window. 0 rejects sets.").Default("1m").Duration()
+		deadLetterSize       = kingpin.Flag("debug.dead-letters", "Number of events that could not be applied to keep, exposed at /debug/dead-letters. 0 disables it.").Default("0").Int()
+		applyDurations       = kingpin.Flag("debug.apply-durations", "Export histograms of the time taken to apply events, by metric type, as statsd_exporter_event_apply_duration_seconds.").Default("false").Bool()
+		slowApplyThreshold   = kingpin.Flag("debug.slow-apply-threshold", "Log the metric name of events that take longer than this to apply, at most every 10 seconds. 0 disables it.").Default("0s").Duration()
+		mappingTrace         = kingpin.Flag("debug.mapping-trace", "Export statsd_exporter_mapped_info with the match of the mapping rule that produced each metric name.").Default("false").Bool()
+		magnitudeWindow      = kingpin.Flag("debug.value-magnitude-window", "Window over which to record the base-10 magnitudes of observed values, exposed at /debug/value-magnitudes. 0 disables it.").Default("0s").Duration()
+		statusRateWindow     = kingpin.Flag("web.status-rate-window", "Sliding window of the lines per second shown on the status page.").Default("5m").Duration()
+		labelHashTableSize   = kingpin.Flag("web.label-hash-table-size", "Number of hashed label values to keep for lookups at /api/v1/label-hash/{hash}. 0 disables the endpoint.").Default("10000").Int()
 		checkConfig          = kingpin.Flag("check-config", "Check configuration and exit.").Default("false").Bool()
+		_                    = kingpin.Flag(profileFlag, "Configuration profile to apply from the profiles file.").Envar(profileEnvar).String()
+		_                    = kingpin.Flag(profilesFileFlag, "File defining configuration profiles, which set default values for command line flags.").Envar(profilesFileEnvar).String()
 		dogstatsdTagsEnabled = kingpin.Flag("statsd.parse-dogstatsd-tags", "Parse DogStatsd style tags. Enabled by default.").Default("true").Bool()
+		valuelessTags        = kingpin.Flag("statsd.dogstatsd-valueless-tags", "Turn DogStatsD tags without a value, e.g. #shipping, into labels with the value \"true\" instead of rejecting them.").Default("false").Bool()
+		dogstatsdEvents      = kingpin.Flag("statsd.dogstatsd-events", "Count DogStatsD events (_e{...}) in a counter labeled with their title and alert type, instead of rejecting them.").Default("false").Bool()
+		eventMetricName      = kingpin.Flag("statsd.dogstatsd-events-metric", "Name of the counter DogStatsD events are counted in. Mappings apply to it like to any other metric.").Default(line.DefaultEventMetricName).String()
+		eventLogFile         = kingpin.Flag("statsd.dogstatsd-events-log", "File to append the payload of counted DogStatsD events to, as JSON lines.").Default("").String()
+		serviceChecks        = kingpin.Flag("statsd.dogstatsd-service-checks", "Record DogStatsD service checks (_sc|...) in a gauge set to their status and labeled with the name of the check, instead of rejecting them.").Default("false").Bool()
+		serviceCheckMetric   = kingpin.Flag("statsd.dogstatsd-service-checks-metric", "Name of the gauge DogStatsD service checks are recorded in. Mappings apply to it like to any other metric.").Default(line.DefaultServiceCheckMetricName).String()
 		influxdbTagsEnabled  = kingpin.Flag("statsd.parse-influxdb-tags", "Parse InfluxDB style tags. Enabled by default.").Default("true").Bool()
 		libratoTagsEnabled   = kingpin.Flag("statsd.parse-librato-tags", "Parse Librato style tags. Enabled by default.").Default("true").Bool()
 		signalFXTagsEnabled  = kingpin.Flag("statsd.parse-signalfx-tags", "Parse SignalFX style tags. Enabled by default.").Default("true").Bool()
+		resourceDetection    = kingpin.Flag("statsd.resource-detector", "Detect OpenTelemetry resource attributes with this detector, one of env, host, kubernetes, ec2 or gcp, and add them as labels to metrics created from StatsD events. Can be repeated, earlier detectors take precedence.").Enums(resourceEnv, resourceHost, resourceKubernetes, resourceEC2, resourceGCP)
+		resourceTimeout      = kingpin.Flag("statsd.resource-detection-timeout", "Maximum time to wait for cloud metadata services during resource detection.").Default("2s").Duration()
+		prefixLabels         = kingpin.Flag("statsd.prefix-labels", "Default labels for metrics by name prefix, as <prefix>:<label>=<value>[,<label>=<value>...]. Can be repeated.").Strings()
+		maxLabels            = kingpin.Flag("statsd.max-labels", "Maximum number of labels of a sample, from tags and default labels. Samples with more labels are rejected. 0 disables the limit.").Default("0").Int()
+		clientTelemetry      = kingpin.Flag("statsd.dogstatsd-client-telemetry", "How to handle the datadog.dogstatsd.client.* telemetry metrics of DogStatsD clients: map them like other metrics, expose them as dogstatsd_client_* metrics, or drop them.").Default(line.ClientTelemetryMap).Enum(line.ClientTelemetryMap, line.ClientTelemetryExpose, line.ClientTelemetryDrop)
+		etsyNamespaces       = kingpin.Flag("statsd.parse-etsy-namespaces", "Strip the Graphite namespaces of Etsy StatsD, such as stats.counters., from metric names, and take the type of samples without one from the namespace.").Default("false").Bool()
+		invalidSampleRates   = kingpin.Flag("statsd.invalid-sample-rates", "How to handle samples with a sample rate that is not a number greater than 0 and at most 1, such as @0: clamp accepts them as if they were not sampled, reject drops them. Either way they are counted as invalid_sample_factor.").Default(line.InvalidSampleRateClamp).Enum(line.InvalidSampleRateClamp, line.InvalidSampleRateReject)
+		utf8Names            = kingpin.Flag("statsd.utf8-names", "Keep dotted and Unicode StatsD metric and tag names instead of escaping them to legacy Prometheus names. Scrapers that don't accept UTF-8 names receive escaped names.").Default("false").Bool()
+		lenientNumbers       = kingpin.Flag("statsd.lenient-numbers", "Accept a comma as the decimal separator in sample values, e.g. 1,5.").Default("false").Bool()
 		relayAddr            = kingpin.Flag("statsd.relay.address", "The UDP relay target address (host:port)").String()
 		relayPacketLen       = kingpin.Flag("statsd.relay.packet-length", "Maximum relay output packet length to avoid fragmentation").Default("1400").Uint()
+		relayConnected       = kingpin.Flag("statsd.relay.connected", "Relay through a connected UDP socket to detect an unreachable relay target.").Default("false").Bool()
+		warmupDuration       = kingpin.Flag("statsd.warmup-duration", "Duration of the warm-up phase after startup, during which metric expiry and other non-essential work is deferred. 0 disables it.").Default("0s").Duration()
+		warmupReadBuffer     = kingpin.Flag("statsd.warmup-read-buffer", "Size (in bytes) of the UDP read buffer during the warm-up phase.").Int()
+		sizeHintFile         = kingpin.Flag("statsd.size-hint-file", "File in which to persist the number of metrics, used to pre-size internal maps on the next start.").Default("").String()
+		clusterPeers         = kingpin.Flag("cluster.peer", "Web address of a peer receiving the same StatsD traffic, such as the other instance of an HA pair. Each series is only exported by one live instance. Can be repeated.").Strings()
+		clusterName          = kingpin.Flag("cluster.name", "Name of this instance among its peers. Must be unique. Defaults to the host name.").Default("").String()
+		clusterInterval      = kingpin.Flag("cluster.heartbeat-interval", "Interval between heartbeats to the peers.").Default("5s").Duration()
+		clusterPeerTimeout   = kingpin.Flag("cluster.peer-timeout", "Time without a successful heartbeat after which a peer is considered down and its series are taken over.").Default("15s").Duration()
+		clusterPeerConfig    = kingpin.Flag("cluster.peer-config-file", "Path to a Prometheus HTTP client configuration file with the TLS settings and credentials for heartbeats to peers whose web configuration enables TLS or authentication.").Default("").String()
+		instanceLockFile     = kingpin.Flag("statsd.lock-file", "File to lock while running, so that starting a second instance with the same lock file fails instead of splitting the received traffic.").Default("").String()
+		listenerTagFormats   = kingpin.Flag("statsd.listener-tag-formats", "Tag formats parsed by a listener, overriding the --statsd.parse-*-tags flags, as <listener>=<format>[,<format>...], where a format is one of dogstatsd, influxdb, librato or signalfx, for example tcp=dogstatsd. Without formats, no tags are parsed. Can be repeated.").Strings()
+		minLinesPerMinute    = kingpin.Flag("statsd.listener-min-lines-per-minute", "Flag a listener as idle while it receives fewer lines per minute than expected, as <listener>=<lines>, for example udp=100. Can be repeated.").Strings()
+		udpMaxPacketSize     = kingpin.Flag("statsd.udp-max-packet-size", "Size (in bytes) of the largest UDP packet read in full. Lines beyond it are dropped. At most 65535.").Default("65535").Int()
+		unixgramMaxPacket    = kingpin.Flag("statsd.unixgram-max-packet-size", "Size (in bytes) of the largest Unixgram packet read in full. Lines beyond it are dropped. Unixgram packets can be larger than 65535 bytes, up to the socket send buffer of the client.").Default("65535").Int()
+		packetMaxLines       = kingpin.Flag("statsd.packet-max-lines", "Maximum number of lines parsed per UDP or Unixgram packet. Further lines are dropped. 0 disables the limit.").Default("0").Int()
+		packetMaxBytes       = kingpin.Flag("statsd.packet-max-parse-bytes", "Maximum number of bytes of lines parsed per UDP or Unixgram packet. Further lines are dropped. 0 disables the limit.").Default("0").Int()
+		maxSamplesPerLine    = kingpin.Flag("statsd.max-samples-per-line", "Maximum number of colon-separated samples of a line. Further samples are dropped. 0 disables the limit.").Default("0").Int()
 		udpPacketQueueSize   = kingpin.Flag("statsd.udp-packet-queue-size", "Size of internal queue for processing UDP packets.").Default("10000").Int()
+		udpShards            = kingpin.Flag("statsd.udp-shards", "Number of goroutines handling the packets of each UDP listener. Packets are assigned by source address and port, so the packets of each client are handled in order. The packet queue is split between them.").Default("1").Int()
+		clockJumpThreshold   = kingpin.Flag("statsd.clock-jump-threshold", "Minimum wall clock step or exporter stall to report, such as 5s. Metric expiry is delayed by the length of a stall. Readings are delayed while the exporter is busy applying events, so set it well above the time a large batch takes. 0 disables detection.").Default("0").Duration()
+		skipUnchangedGauges  = kingpin.Flag("statsd.skip-unchanged-gauges", "Do not re-apply gauge sets that do not change the value of the gauge.").Default("false").Bool()
+
+		conformanceCmd    = kingpin.Command("conformance", "Report how each line of the bundled corpus and any given corpus files is parsed, as JSON lines.")
+		conformanceCorpus = conformanceCmd.Arg("corpus", "Additional corpus files, with one StatsD line per line. Empty lines and lines starting with # are ignored.").ExistingFiles()
+
+		convertCmd   = kingpin.Command("convert", "Generate a starter mapping configuration from a StatsD configuration file and observed metric names.")
+		convertFrom  = convertCmd.Flag("from", "StatsD configuration file (config.js) to take timer percentiles and histograms from.").ExistingFile()
+		convertNames = convertCmd.Flag("names", "File with one observed metric name or StatsD line per line, to suggest mappings with labels for.").ExistingFile()
+
+		dashboardCmd   = kingpin.Command("dashboard", "Generate a starter Grafana dashboard with a panel for every metric family of the mapping configuration given with --statsd.mapping-config.")
+		dashboardTitle = dashboardCmd.Flag("title", "Title of the dashboard.").Default("StatsD exporter").String()
+
+		selfMetricsCmd = kingpin.Command("self-metrics", "List the metrics the exporter exposes about itself, with their type, help and labels, as JSON.")
 	)
 
+	kingpin.Command("serve", "Run the exporter.").Default()
+
 	promslogConfig := &promslog.Config{}
 	flag.AddFlags(kingpin.CommandLine, promslogConfig)
 	kingpin.Version(version.Print("statsd_exporter"))
 	kingpin.CommandLine.UsageWriter(os.Stdout)
 	kingpin.HelpFlag.Short('h')
-	kingpin.Parse()
+	kingpin.FatalIfError(applyProfile(kingpin.CommandLine, os.Args[1:]), "error applying configuration profile")
+	command := kingpin.Parse()
 	logger := promslog.New(promslogConfig)
 	prometheus.MustRegister(versioncollector.NewCollector("statsd_exporter"))
 
 	parser := line.NewParser()
 	if *dogstatsdTagsEnabled {
 		parser.EnableDogstatsdParsing()
+		if *valuelessTags {
+			parser.EnableValuelessTags("true")
+		}
 	}
 	if *influxdbTagsEnabled {
 		parser.EnableInfluxdbParsing()
@@ -292,15 +723,118 @@ func main() {
 	if *signalFXTagsEnabled {
 		parser.EnableSignalFXParsing()
 	}
+	if *etsyNamespaces {
+		parser.EnableEtsyNamespaces()
+	}
+	if *dogstatsdEvents {
+		parser.EnableDogStatsDEvents(*eventMetricName)
+		if *eventLogFile != "" {
+			f, err := os.OpenFile(*eventLogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+			if err != nil {
+				logger.Error("Unable to open DogStatsD event log", "error", err)
+				os.Exit(1)
+			}
+			parser.EventLogger = slog.New(slog.NewJSONHandler(f, nil))
+		}
+	}
+	if *serviceChecks {
+		parser.EnableDogStatsDServiceChecks(*serviceCheckMetric)
+	}
+	parser.MaxLabels = *maxLabels
+	parser.MaxSamples = *maxSamplesPerLine
+	parser.ClientTelemetry = *clientTelemetry
+	parser.InvalidSampleRates = *invalidSampleRates
+	if *utf8Names {
+		parser.EnableUTF8Names()
+	}
+	if *lenientNumbers {
+		parser.EnableLenientNumbers()
+		parser.LenientValues = lenientValues
+	}
+	for _, rule := range *prefixLabels {
+		pl, err := line.ParsePrefixLabels(rule)
+		if err != nil {
+			logger.Error("Invalid prefix labels", "error", err)
+			os.Exit(1)
+		}
+		parser.AddPrefixLabels(pl)
+	}
+
+	if command == conformanceCmd.FullCommand() {
+		corpus, err := loadCorpus(*conformanceCorpus)
+		if err != nil {
+			logger.Error("Unable to load conformance corpus", "error", err)
+			os.Exit(1)
+		}
+		if err := runConformance(parser, corpus, os.Stdout); err != nil {
+			logger.Error("Unable to write conformance report", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if command == convertCmd.FullCommand() {
+		if *convertFrom == "" && *convertNames == "" {
+			logger.Error("At least one of --from and --names must be specified.")
+			os.Exit(1)
+		}
+		warnings, err := runConvert(*convertFrom, *convertNames, os.Stdout)
+		if err != nil {
+			logger.Error("Unable to convert configuration", "error", err)
+			os.Exit(1)
+		}
+		for _, w := range warnings {
+			logger.Warn("Not converted", "reason", w)
+		}
+		return
+	}
+
+	if command == selfMetricsCmd.FullCommand() {
+		if err := runSelfMetrics(prometheus.DefaultGatherer, os.Stdout); err != nil {
+			logger.Error("Unable to list self-metrics", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if command == dashboardCmd.FullCommand() {
+		if *mappingConfig == "" {
+			logger.Error("--statsd.mapping-config must be specified.")
+			os.Exit(1)
+		}
+		if err := runDashboard(*mappingConfig, *dashboardTitle, logger, os.Stdout); err != nil {
+			logger.Error("Unable to generate dashboard", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
 
 	logger.Info("Starting StatsD -> Prometheus Exporter", "version", version.Info())
 	logger.Info("Build context", "context", version.BuildContext())
 
+	thisMapper := &mapper.MetricMapper{Registerer: prometheus.DefaultRegisterer, MappingsCount: mappingsCount, Logger: logger}
+	thisMapper.CompileDuration = mappingReloadTime.WithLabelValues("compile")
+	thisMapper.ValidationDuration = mappingReloadTime.WithLabelValues("validate")
+	thisMapper.UTF8Names = *utf8Names
+	if *mappingSampleSize > 0 {
+		thisMapper.Sample = mapper.NewNameSample(*mappingSampleSize)
+	}
+
 	events := make(chan event.Events, *eventQueueSize)
-	defer close(events)
 	eventQueue := event.NewEventQueue(events, *eventFlushThreshold, *eventFlushInterval, eventsFlushed)
-
-	thisMapper := &mapper.MetricMapper{Registerer: prometheus.DefaultRegisterer, MappingsCount: mappingsCount, Logger: logger}
+	if *eventFlushBytes > 0 {
+		eventQueue.EnableByteLimit(*eventFlushBytes, eventBytesQueued)
+	}
+	if *shedByPriority {
+		eventQueue.EnablePriorityShedding(func(e event.Event) mapper.Priority {
+			return thisMapper.PriorityOf(e.MetricName(), e.MetricType())
+		}, eventsShed)
+	}
+	var latencyTracker *exporter.LatencyTracker
+	if *latencyProbeEvery > 0 {
+		eventQueue.EnableLatencyProbes(*latencyProbeEvery)
+		latencyTracker = &exporter.LatencyTracker{Latency: eventLatency}
+	}
 
 	cache, err := getCache(*cacheSize, *cacheType, thisMapper.Registerer)
 	if err != nil {
@@ -326,58 +860,349 @@ func main() {
 		}
 	}
 
-	exporter := exporter.NewExporter(prometheus.DefaultRegisterer, thisMapper, logger, eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+	var deadLetters *exporter.DeadLetters
+	if *deadLetterSize > 0 {
+		deadLetters = exporter.NewDeadLetters(*deadLetterSize, deadLettered)
+	}
+	var ingestRates *exporter.RateTracker
+	if *ingestRateWindow > 0 {
+		ingestRates = exporter.NewRateTracker(*ingestRateWindow)
+	}
+	var cardinality *exporter.CardinalityMonitor
+	if *cardinalityWindow > 0 {
+		cardinality = &exporter.CardinalityMonitor{
+			Window:         *cardinalityWindow,
+			MetricNames:    activeMetricNames,
+			Series:         activeSeries,
+			GrowthWarning:  *cardinalityWarning,
+			GrowthWarnings: cardinalityWarnings,
+		}
+	}
+	var outage *exporter.OutageDetector
+	if *scrapeOutageWindow > 0 {
+		outage = exporter.NewOutageDetector(*scrapeOutageWindow, expiryPaused)
+	}
+	var magnitudeTracker *exporter.MagnitudeTracker
+	if *magnitudeWindow > 0 {
+		magnitudeTracker = exporter.NewMagnitudeTracker(*magnitudeWindow)
+	}
+
+	var sizeHint exporter.SizeHint
+	if *sizeHintFile != "" {
+		var err error
+		sizeHint, err = exporter.ReadSizeHint(*sizeHintFile)
+		switch {
+		case err == nil:
+			logger.Info("Pre-sizing registry", "metric_names", sizeHint.MetricNames, "series", sizeHint.Series)
+		case !errors.Is(err, fs.ErrNotExist):
+			logger.Warn("Failed to read size hint", "file", *sizeHintFile, "error", err)
+		}
+	}
+
+	var labelHashes *exporter.LabelHashTable
+	if *labelHashTableSize > 0 {
+		labelHashes = exporter.NewLabelHashTable(*labelHashTableSize)
+	}
+
+	var statsdRegisterer prometheus.Registerer = prometheus.DefaultRegisterer
+	var streamingRegistry *exporter.StreamingRegistry
+	if *streamExposition {
+		streamingRegistry = exporter.NewStreamingRegistry()
+		statsdRegisterer = streamingRegistry
+	}
+	// Resource attributes are added as constant labels by the registry, as
+	// wrapping the Registerer would hide the metric names of the collectors
+	// from the streaming registry.
+	var resource prometheus.Labels
+	if len(*resourceDetection) > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), *resourceTimeout)
+		resource = resourceLabels(newResourceDetector(*resourceTimeout).detect(ctx, *resourceDetection, logger))
+		cancel()
+		logger.Info("Detected resource attributes", "labels", resource)
+	}
+	var peers *peerSet
+	if len(*clusterPeers) > 0 {
+		name := *clusterName
+		if name == "" {
+			if name, err = os.Hostname(); err != nil {
+				logger.Error("Unable to determine the name of this instance, set --cluster.name", "error", err)
+				os.Exit(1)
+			}
+		}
+		client, err := newPeerClient(*clusterPeerConfig)
+		if err != nil {
+			logger.Error("Unable to configure the peer client", "error", err)
+			os.Exit(1)
+		}
+		peers = newPeerSet(name, *clusterPeers, *clusterPeerTimeout, client, clusterPeersUp, logger)
+		statsdRegisterer = peers.Registerer(statsdRegisterer)
+		go peers.run(*clusterInterval)
+	}
+
+	// The series of mappings with sparse_scrapes are removed after being
+	// exported by the scrapes recorded here.
+	scrapes := &exporter.ScrapeRecorder{}
+	scrapeHandler := &exporter.ScrapeHandler{
+		Gatherer:          prometheus.DefaultGatherer,
+		Timeout:           *scrapeTimeout,
+		TimeoutOffset:     *scrapeTimeoutOffset,
+		SoftDeadline:      *scrapeSoftDeadline,
+		EnableOpenMetrics: *enableOpenMetrics,
+		SlowScrapes:       slowScrapes,
+		AbortedScrapes:    abortedScrapes,
+		Logger:            logger,
+		Latency:           latencyTracker,
+		Outage:            outage,
+		Scrapes:           scrapes,
+		Stream:            streamingRegistry,
+	}
+
+	derivedMetrics := &exporter.DerivedCollector{Interval: *eventFlushInterval}
+	prometheus.MustRegister(derivedMetrics)
+	if *udpMaxPacketSize <= 0 || *udpMaxPacketSize > listener.DefaultMaxPacketSize {
+		logger.Error("UDP maximum packet size must be between 1 and 65535", "size", *udpMaxPacketSize)
+		os.Exit(1)
+	}
+	if *udpShards < 1 {
+		logger.Error("Number of UDP shards must be positive", "shards", *udpShards)
+		os.Exit(1)
+	}
+	if *unixgramMaxPacket <= 0 {
+		logger.Error("Unixgram maximum packet size must be positive", "size", *unixgramMaxPacket)
+		os.Exit(1)
+	}
+	if *digestInterval <= 0 {
+		logger.Error("Digest interval must be positive", "interval", *digestInterval)
+		os.Exit(1)
+	}
+	var quarantine *exporter.Quarantine
+	var quarantineApproval http.Handler
+	if *quarantineEnabled {
+		if *quarantinePeriod <= 0 && !*enableAdminAPI {
+			logger.Error("Quarantined metric names can only be approved via the admin API without a quarantine period, enable it with --web.enable-admin-api")
+			os.Exit(1)
+		}
+		quarantine = exporter.NewQuarantine(*quarantinePeriod)
+		quarantine.HeldEvents = quarantinedEvents
+		quarantine.HeldNames = quarantinedNames
+		quarantineApproval = &exporter.QuarantineApprovalHandler{Quarantine: quarantine, Logger: logger}
+	}
+	digests := exporter.NewDigestCollector(*digestInterval)
+	prometheus.MustRegister(digests)
+
+	exporterEvents := events
+	if *regexMappingWorkers > 0 {
+		mappedEvents := make(chan event.Events, *eventQueueSize)
+		stage := &exporter.MappingStage{
+			Mapper:      thisMapper,
+			Workers:     *regexMappingWorkers,
+			QueueSize:   *regexMappingQueueLen,
+			Events:      mappingStageEvents,
+			QueueLength: regexMappingQueue,
+		}
+		if *regexMappingShedding {
+			stage.ShedEvents = regexMappingShed
+		}
+		go stage.Run(events, mappedEvents)
+		exporterEvents = mappedEvents
+	}
+
+	exporter := exporter.NewExporter(statsdRegisterer, thisMapper, logger, eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+	exporter.MagnitudeTracker = magnitudeTracker
+	exporter.IngestRates = ingestRates
+	exporter.DeadLetters = deadLetters
+	exporter.CounterWindows = counterWindows
+	if *mappingTrace {
+		exporter.MappedInfo = mappedInfo
+	}
+	if *applyDurations {
+		exporter.ApplyDuration = applyDuration
+	}
+	exporter.SlowApplyThreshold = *slowApplyThreshold
+	exporter.UTF8Names = *utf8Names
+	exporter.CappedSampleWeights = cappedSampleWeights
+	exporter.GaugeChanges = gaugeChanges
+	exporter.RelativeGauges = relativeGauges
+	exporter.SkipUnchangedGauges = *skipUnchangedGauges
+	exporter.ClockJumpThreshold = *clockJumpThreshold
+	exporter.ClockJumps = clockJumps
+	exporter.LabelHashes = labelHashes
+	if *warmupDuration > 0 {
+		exporter.WarmupUntil = time.Now().Add(*warmupDuration)
+	}
+	exporter.SizeHintFile = *sizeHintFile
+	exporter.Derived = derivedMetrics
+	exporter.Digests = digests
+	exporter.SetWindow = *setWindow
+	exporter.Quarantine = quarantine
+	exporter.Latency = latencyTracker
+	exporter.MemoryLimit = uint64(*memoryLimit)
+	exporter.MemoryProtection = memoryProtection
+	exporter.Cardinality = cardinality
+	exporter.Outage = outage
+	exporter.Scrapes = scrapes
+	exporter.Registry.Presize(sizeHint.MetricNames, sizeHint.Series)
+	exporter.Registry.SetConstLabels(resource)
 
 	if *checkConfig {
 		logger.Info("Configuration check successful, exiting")
 		return
 	}
 
+	if *instanceLockFile != "" {
+		lock, err := acquireInstanceLock(*instanceLockFile)
+		if err != nil {
+			logger.Error("Unable to acquire instance lock", "error", err)
+			os.Exit(1)
+		}
+		defer lock.Release()
+	}
+
 	var relayTarget *relay.Relay
 	if *relayAddr != "" {
 		var err error
-		relayTarget, err = relay.NewRelay(logger, *relayAddr, *relayPacketLen)
+		relayTarget, err = relay.NewRelayWithOptions(logger, *relayAddr, *relayPacketLen, relay.Options{Connected: *relayConnected})
 		if err != nil {
 			logger.Error("Unable to create relay", "err", err)
 			os.Exit(1)
 		}
 	}
 
-	logger.Info("Accepting StatsD Traffic", "udp", *statsdListenUDP, "tcp", *statsdListenTCP, "unixgram", *statsdListenUnixgram)
-	logger.Info("Accepting Prometheus Requests", "addr", *listenAddress)
+	logger.Info("Accepting StatsD Traffic", "udp", *statsdListenUDP, "tcp", *statsdListenTCP, "unixgram", *statsdListenUnixgram, "unix", *statsdListenUnix, "syslog_tls", *statsdListenSyslog, "udp_multicast", *udpMulticastGroup)
 
-	if *statsdListenUDP == "" && *statsdListenTCP == "" && *statsdListenUnixgram == "" {
-		logger.Error("At least one of UDP/TCP/Unixgram listeners must be specified.")
+	if !anyListener(*statsdListenUDP, *statsdListenTCP, *statsdListenUnixgram, *statsdListenUnix, *statsdListenSyslog, *udpMulticastGroup) {
+		logger.Error("At least one of UDP/TCP/Unixgram/Unix/syslog/multicast listeners must be specified.")
 		os.Exit(1)
 	}
 
-	if *statsdListenUDP != "" {
-		udpListenAddr, err := address.UDPAddrFromString(*statsdListenUDP)
+	bindPolicy := listener.BindPolicy{
+		Retries:       *portRetries,
+		RetryInterval: *portRetryInterval,
+		Logger:        logger,
+	}
+
+	sourceFilter := func(name string, allow, deny []string) *listener.SourceFilter {
+		f, err := listener.ParseSourceFilter(allow, deny)
 		if err != nil {
-			logger.Error("invalid UDP listen address", "address", *statsdListenUDP, "error", err)
+			logger.Error("invalid source filter", "listener", name, "error", err)
 			os.Exit(1)
 		}
-		uconn, err := net.ListenUDP("udp", udpListenAddr)
+		if f != nil {
+			f.Rejected = rejectedSources.MustCurryWith(prometheus.Labels{"listener": name})
+		}
+		return f
+	}
+
+	idleExpectations := map[string]float64{}
+	for _, expectation := range *minLinesPerMinute {
+		name, lines, err := listener.ParseIdleExpectation(expectation)
 		if err != nil {
-			logger.Error("failed to start UDP listener", "error", err)
+			logger.Error("Invalid listener expectation", "error", err)
+			os.Exit(1)
+		}
+		switch name {
+		case "udp", "udp_multicast", "tcp", "unixgram", "unix", "syslog_tls":
+		default:
+			logger.Error("Invalid listener expectation, unknown listener", "listener", name)
+			os.Exit(1)
+		}
+		idleExpectations[name] = lines
+	}
+
+	var packetBudget *listener.PacketBudget
+	if *packetMaxLines > 0 || *packetMaxBytes > 0 {
+		packetBudget = &listener.PacketBudget{
+			MaxLines: *packetMaxLines,
+			MaxBytes: *packetMaxBytes,
+			Dropped:  budgetDroppedLines,
+		}
+	}
+	idleWatchdog := func(name string) *listener.IdleWatchdog {
+		lines, ok := idleExpectations[name]
+		if !ok {
+			return nil
+		}
+		w := listener.NewIdleWatchdog(name, lines, listenerIdle, logger)
+		go w.Run()
+		return w
+	}
+
+	listenerParsers := map[string]*line.Parser{}
+	for _, spec := range *listenerTagFormats {
+		name, formats, ok := strings.Cut(spec, "=")
+		switch name {
+		case "udp", "udp_multicast", "tcp", "unixgram", "unix", "syslog_tls":
+		default:
+			logger.Error("Invalid listener tag formats, unknown listener", "listener", name)
+			os.Exit(1)
+		}
+		if !ok {
+			logger.Error("Invalid listener tag formats, expected <listener>=<format>[,<format>...]", "tag_formats", spec)
+			os.Exit(1)
+		}
+		var enabled []string
+		if formats != "" {
+			enabled = strings.Split(formats, ",")
+		}
+		p, err := parser.WithTagFormats(enabled)
+		if err != nil {
+			logger.Error("Invalid listener tag formats", "listener", name, "error", err)
+			os.Exit(1)
+		}
+		listenerParsers[name] = p
+	}
+
+	lineParser := func(name, protocol string) listener.Parser {
+		p, ok := listenerParsers[name]
+		if !ok {
+			p = parser
+		}
+		if protocol == listener.ProtocolStatsiteBinary {
+			return p
+		}
+		lp, err := line.NewLineParser(protocol, p)
+		if err != nil {
+			logger.Error("Invalid listener protocol", "error", err)
 			os.Exit(1)
 		}
+		return lp
+	}
 
+	listenerHealths := &listener.HealthReport{}
+	newHealth := func(name string) *listener.Health {
+		return listenerHealths.Add(listener.NewHealth(name, listenerHealth, logger))
+	}
+
+	listenUDP := func(name string, uconn *net.UDPConn, reopen func() (*net.UDPConn, error)) {
 		if *readBuffer != 0 {
-			err = uconn.SetReadBuffer(*readBuffer)
+			err := uconn.SetReadBuffer(*readBuffer)
 			if err != nil {
 				logger.Error("error setting UDP read buffer", "error", err)
 				os.Exit(1)
 			}
 		}
 
+		if *warmupDuration > 0 && *warmupReadBuffer > *readBuffer {
+			err := listener.RaiseReadBuffer(uconn, *warmupReadBuffer, *readBuffer, *warmupDuration, logger)
+			if err != nil {
+				logger.Warn("Not raising UDP read buffer during warm-up", "error", err)
+			}
+		}
+
 		udpPacketQueue := make(chan []byte, *udpPacketQueueSize)
+		reopenWithBuffer := func() (*net.UDPConn, error) {
+			conn, err := reopen()
+			if err == nil && *readBuffer != 0 {
+				err = conn.SetReadBuffer(*readBuffer)
+			}
+			return conn, err
+		}
 
 		ul := &listener.StatsDUDPListener{
 			Conn:            uconn,
 			EventHandler:    eventQueue,
 			Logger:          logger,
-			LineParser:      parser,
+			LineParser:      lineParser(name, *udpProtocol),
 			UDPPackets:      udpPackets,
 			UDPPacketDrops:  udpPacketDrops,
 			LinesReceived:   linesReceived,
@@ -388,18 +1213,45 @@ func main() {
 			TagErrors:       tagErrors,
 			TagsReceived:    tagsReceived,
 			UdpPacketQueue:  udpPacketQueue,
+			Protocol:        *udpProtocol,
+			Health:          newHealth(name),
+			Reopen:          reopenWithBuffer,
+			Sources:         sourceFilter(name, *udpAllowSources, *udpDenySources),
+			Receive:         listener.NewReceiveStats(name, listenerReceive),
+			MaxPacketSize:   *udpMaxPacketSize,
+			Idle:            idleWatchdog(name),
+			Budget:          packetBudget,
+			Shards:          *udpShards,
 		}
 
 		go ul.Listen()
 	}
 
-	if *statsdListenTCP != "" {
-		tcpListenAddr, err := address.TCPAddrFromString(*statsdListenTCP)
+	if *statsdListenUDP != "" {
+		uconn, err := bindPolicy.ListenUDP(append([]string{*statsdListenUDP}, *udpFallbackAddrs...))
+		if err != nil {
+			logger.Error("failed to start UDP listener", "error", err)
+			os.Exit(1)
+		}
+		addr := uconn.LocalAddr().String()
+		listenUDP("udp", uconn, func() (*net.UDPConn, error) {
+			return bindPolicy.ListenUDP([]string{addr})
+		})
+	}
+
+	if *udpMulticastGroup != "" {
+		mconn, err := listener.ListenMulticastUDP(*udpMulticastGroup, *udpMulticastIface)
 		if err != nil {
-			logger.Error("invalid TCP listen address", "address", *statsdListenUDP, "error", err)
+			logger.Error("failed to start UDP multicast listener", "error", err)
 			os.Exit(1)
 		}
-		tconn, err := net.ListenTCP("tcp", tcpListenAddr)
+		listenUDP("udp_multicast", mconn, func() (*net.UDPConn, error) {
+			return listener.ListenMulticastUDP(*udpMulticastGroup, *udpMulticastIface)
+		})
+	}
+
+	if *statsdListenTCP != "" {
+		tconn, err := bindPolicy.ListenTCP(append([]string{*statsdListenTCP}, *tcpFallbackAddrs...))
 		if err != nil {
 			logger.Error("failed to start TCP listener", "err", err)
 			os.Exit(1)
@@ -410,7 +1262,7 @@ func main() {
 			Conn:            tconn,
 			EventHandler:    eventQueue,
 			Logger:          logger,
-			LineParser:      parser,
+			LineParser:      lineParser("tcp", *tcpProtocol),
 			LinesReceived:   linesReceived,
 			EventsFlushed:   eventsFlushed,
 			Relay:           relayTarget,
@@ -421,26 +1273,60 @@ func main() {
 			TCPConnections:  tcpConnections,
 			TCPErrors:       tcpErrors,
 			TCPLineTooLong:  tcpLineTooLong,
+			Health:          newHealth("tcp"),
+			Protocol:        *tcpProtocol,
+			Sources:         sourceFilter("tcp", *tcpAllowSources, *tcpDenySources),
+			Receive:         listener.NewReceiveStats("tcp", listenerReceive),
+			Ack:             *tcpAck,
+			Idle:            idleWatchdog("tcp"),
+			AcceptRetries:   acceptRetries.WithLabelValues("tcp"),
+		}
+		if *tcpDetectProtocol {
+			tl.DetectProtocol = true
+			tl.DetectedProtocols = tcpProtocols
+			tl.HTTPHandler = &listener.StatsDHTTPHandler{
+				EventHandler:    eventQueue,
+				Logger:          logger,
+				LineParser:      lineParser("tcp", listener.ProtocolStatsD),
+				LinesReceived:   linesReceived,
+				Relay:           relayTarget,
+				SampleErrors:    *sampleErrors,
+				SamplesReceived: samplesReceived,
+				TagErrors:       tagErrors,
+				TagsReceived:    tagsReceived,
+			}
 		}
 
 		go tl.Listen()
 	}
 
-	if *statsdListenUnixgram != "" {
-		var err error
-		if _, err = os.Stat(*statsdListenUnixgram); !os.IsNotExist(err) {
-			logger.Error("Unixgram socket already exists", "socket_name", *statsdListenUnixgram)
-			os.Exit(1)
+	socketOptions := listener.UnixSocketOptions{
+		Owner:       *unixSocketOwner,
+		Group:       *unixSocketGroup,
+		RemoveStale: *unixSocketRmStale,
+	}
+	if *statsdListenUnixgram != "" || *statsdListenUnix != "" {
+		// convert the string to octet
+		perm, err := strconv.ParseInt("0"+string(*statsdUnixSocketMode), 8, 32)
+		if err != nil {
+			logger.Warn("Bad permission, ignoring", "mode", *statsdUnixSocketMode, "error", err)
+		} else {
+			socketOptions.Mode = os.FileMode(perm)
 		}
-		uxgconn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{
-			Net:  "unixgram",
-			Name: *statsdListenUnixgram,
-		})
+	}
+
+	if *statsdListenUnixgram != "" {
+		uxgconn, err := listener.ListenUnixgram(*statsdListenUnixgram, socketOptions)
 		if err != nil {
 			logger.Error("failed to listen on Unixgram socket", "error", err)
 			os.Exit(1)
 		}
 
+		// Remove the socket file after closing the connection, unless it's
+		// an abstract unix domain socket that doesn't exist on the fs.
+		if !strings.HasPrefix(*statsdListenUnixgram, "@") {
+			defer os.Remove(*statsdListenUnixgram)
+		}
 		defer uxgconn.Close()
 
 		if *readBuffer != 0 {
@@ -455,7 +1341,7 @@ func main() {
 			Conn:            uxgconn,
 			EventHandler:    eventQueue,
 			Logger:          logger,
-			LineParser:      parser,
+			LineParser:      lineParser("unixgram", listener.ProtocolStatsD),
 			UnixgramPackets: unixgramPackets,
 			LinesReceived:   linesReceived,
 			EventsFlushed:   eventsFlushed,
@@ -464,48 +1350,105 @@ func main() {
 			SamplesReceived: samplesReceived,
 			TagErrors:       tagErrors,
 			TagsReceived:    tagsReceived,
+			Health:          newHealth("unixgram"),
+			Receive:         listener.NewReceiveStats("unixgram", listenerReceive),
+			MaxPacketSize:   *unixgramMaxPacket,
+			Idle:            idleWatchdog("unixgram"),
+			Budget:          packetBudget,
 		}
 
 		go ul.Listen()
 
-		// if it's an abstract unix domain socket, it won't exist on fs
-		// so we can't chmod it either
-		if _, err := os.Stat(*statsdListenUnixgram); !os.IsNotExist(err) {
-			defer os.Remove(*statsdListenUnixgram)
+	}
 
-			// convert the string to octet
-			perm, err := strconv.ParseInt("0"+string(*statsdUnixSocketMode), 8, 32)
-			if err != nil {
-				logger.Warn("Bad permission %s: %v, ignoring\n", *statsdUnixSocketMode, err)
-			} else {
-				err = os.Chmod(*statsdListenUnixgram, os.FileMode(perm))
-				if err != nil {
-					logger.Warn("Failed to change unixgram socket permission", "error", err)
-				}
-			}
+	if *statsdListenUnix != "" {
+		uxconn, err := listener.ListenUnix(*statsdListenUnix, socketOptions)
+		if err != nil {
+			logger.Error("failed to listen on Unix socket", "error", err)
+			os.Exit(1)
+		}
+		// Closing the listener removes the socket file.
+		defer uxconn.Close()
+
+		xl := &listener.StatsDUnixListener{
+			Conn:            uxconn,
+			EventHandler:    eventQueue,
+			Logger:          logger,
+			LineParser:      lineParser("unix", listener.ProtocolStatsD),
+			LinesReceived:   linesReceived,
+			EventsFlushed:   eventsFlushed,
+			Relay:           relayTarget,
+			SampleErrors:    *sampleErrors,
+			SamplesReceived: samplesReceived,
+			TagErrors:       tagErrors,
+			TagsReceived:    tagsReceived,
+			UnixConnections: unixConnections,
+			UnixErrors:      unixErrors,
+			UnixLineTooLong: unixLineTooLong,
+			Health:          newHealth("unix"),
+			Receive:         listener.NewReceiveStats("unix", listenerReceive),
+			Idle:            idleWatchdog("unix"),
+			AcceptRetries:   acceptRetries.WithLabelValues("unix"),
 		}
+
+		go xl.Listen()
 	}
 
-	mux := http.DefaultServeMux
-	mux.Handle(*metricsEndpoint, promhttp.Handler())
-	if *metricsEndpoint != "/" && *metricsEndpoint != "" {
-		landingConfig := web.LandingConfig{
-			Name:        "StatsD Exporter",
-			Description: "Prometheus Exporter for converting StatsD to Prometheus metrics",
-			Version:     version.Info(),
-			Links: []web.LandingLinks{
-				{
-					Address: *metricsEndpoint,
-					Text:    "Metrics",
-				},
-			},
+	if *statsdListenSyslog != "" {
+		tlsConfig, err := listener.NewSyslogTLSConfig(*syslogTLSCertFile, *syslogTLSKeyFile, *syslogTLSClientCA)
+		if err != nil {
+			logger.Error("failed to load syslog TLS configuration", "error", err)
+			os.Exit(1)
 		}
-		landingPage, err := web.NewLandingPage(landingConfig)
+		sconn, err := bindPolicy.ListenTCP([]string{*statsdListenSyslog})
 		if err != nil {
-			logger.Error("error creating landing page", "err", err)
+			logger.Error("failed to start syslog over TLS listener", "error", err)
 			os.Exit(1)
 		}
-		mux.Handle("/", landingPage)
+		defer sconn.Close()
+
+		sl := &listener.StatsDSyslogListener{
+			Conn:              tls.NewListener(sconn, tlsConfig),
+			EventHandler:      eventQueue,
+			Logger:            logger,
+			LineParser:        lineParser("syslog_tls", listener.ProtocolStatsD),
+			LinesReceived:     linesReceived,
+			Relay:             relayTarget,
+			SampleErrors:      *sampleErrors,
+			SamplesReceived:   samplesReceived,
+			TagErrors:         tagErrors,
+			TagsReceived:      tagsReceived,
+			SyslogConnections: syslogConnections,
+			SyslogErrors:      syslogErrors,
+			SyslogMalformed:   syslogMalformed,
+			MaxMessageSize:    *syslogMaxMessage,
+			Health:            newHealth("syslog_tls"),
+			Receive:           listener.NewReceiveStats("syslog_tls", listenerReceive),
+			Idle:              idleWatchdog("syslog_tls"),
+			AcceptRetries:     acceptRetries.WithLabelValues("syslog_tls"),
+		}
+
+		go sl.Listen()
+	}
+
+	mux := http.DefaultServeMux
+	mux.Handle(*metricsEndpoint, promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer, scrapeHandler))
+	if *metricsEndpoint != "/" && *metricsEndpoint != "" {
+		status := &statusPage{
+			version:       version.Info(),
+			started:       clock.Now(),
+			metricsPath:   *metricsEndpoint,
+			lineRates:     newLineRates(linesReceived, *statusRateWindow),
+			ingestRates:   ingestRates,
+			queue:         eventQueue,
+			listeners:     listenerHealths,
+			webConfigFile: *toolkitFlags.WebConfigFile,
+		}
+		if *mappingConfig != "" {
+			status.mapper = thisMapper
+		}
+		go status.lineRates.run()
+		mux.Handle("/", status)
 	}
 
 	quitChan := make(chan struct{}, 1)
@@ -530,6 +1473,39 @@ func main() {
 		})
 	}
 
+	if *enableAdminAPI {
+		mux.Handle("/api/v1/queue/config", &event.QueueConfigHandler{Queue: eventQueue, Logger: logger})
+		mux.Handle("/api/v1/seed", &event.SeedHandler{Queue: eventQueue, Logger: logger})
+		if quarantineApproval != nil {
+			mux.Handle("/api/v1/quarantine/approve", quarantineApproval)
+		}
+	}
+
+	if magnitudeTracker != nil {
+		mux.Handle("/debug/value-magnitudes", magnitudeTracker)
+	}
+	if ingestRates != nil {
+		mux.Handle("/debug/ingest-rates", ingestRates)
+	}
+	if deadLetters != nil {
+		mux.Handle("/debug/dead-letters", deadLetters)
+	}
+	mux.Handle("/debug/digests", digests)
+	mux.Handle("/debug/listeners", listenerHealths)
+	if peers != nil {
+		mux.Handle(peerPath, peers)
+	}
+	if quarantine != nil {
+		mux.Handle("/debug/quarantine", quarantine)
+	}
+
+	if labelHashes != nil {
+		mux.Handle("GET /api/v1/label-hash/{hash}", labelHashes)
+	}
+	if cardinality != nil {
+		mux.Handle("GET /api/v1/cardinality", cardinality)
+	}
+
 	mux.HandleFunc("/-/healthy", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == http.MethodGet {
 			logger.Debug("Received health check")
@@ -546,13 +1522,17 @@ func main() {
 		}
 	})
 
-	go serveHTTP(mux, *listenAddress, logger)
+	go serveHTTP(mux, toolkitFlags, logger)
 
 	go sighupConfigReloader(*mappingConfig, thisMapper, logger)
-	go exporter.Listen(events)
+	exporterDone := make(chan struct{})
+	go func() {
+		exporter.Listen(exporterEvents)
+		close(exporterDone)
+	}()
 
 	signals := make(chan os.Signal, 1)
-	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	signal.Notify(signals, stopSignals...)
 
 	// quit if we get a message on either channel
 	select {
@@ -561,4 +1541,12 @@ func main() {
 	case <-quitChan:
 		logger.Info("Received lifecycle api quit, exiting")
 	}
+
+	if *flushOnShutdown {
+		// Closing the queue flushes it, and the exporter returns once it
+		// has applied all events.
+		eventQueue.Close()
+		<-exporterDone
+		logger.Info("Applied queued events")
+	}
 }
@@ -0,0 +1,32 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import "testing"
+
+func TestAnyListener(t *testing.T) {
+	for _, tc := range []struct {
+		addrs    []string
+		expected bool
+	}{
+		{addrs: []string{"", "", "", "", "", ""}, expected: false},
+		{addrs: []string{":9125", "", "", "", "", ""}, expected: true},
+		// Only a multicast group is enough to receive lines.
+		{addrs: []string{"", "", "", "", "", "239.1.2.3:9125"}, expected: true},
+	} {
+		if got := anyListener(tc.addrs...); got != tc.expected {
+			t.Errorf("%q: expected %v, got %v", tc.addrs, tc.expected, got)
+		}
+	}
+}
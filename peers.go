@@ -0,0 +1,315 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/config"
+
+	"github.com/prometheus/statsd_exporter/pkg/clock"
+	"github.com/prometheus/statsd_exporter/pkg/registry"
+)
+
+// peerPath is the path peers answer heartbeats on.
+const peerPath = "/-/peer"
+
+// peerSet decides which instance of a group of exporters receiving the same
+// traffic exports a series, so that scraping all of them doesn't count it
+// twice. Every instance applies all events and keeps the same state, but each
+// series is only exported by one live instance, chosen by rendezvous hashing
+// of the series over the names of the live instances. When a peer stops
+// answering heartbeats, the remaining instances take over its series.
+type peerSet struct {
+	name    string
+	peers   []string
+	timeout time.Duration
+	client  *http.Client
+	up      *prometheus.GaugeVec
+	logger  *slog.Logger
+
+	mtx     sync.RWMutex
+	seen    map[string]peerState
+	live    map[string]bool
+	members []uint64
+	self    uint64
+}
+
+type peerState struct {
+	name     string
+	lastSeen time.Time
+}
+
+type peerHeartbeat struct {
+	Name string `json:"name"`
+}
+
+// newPeerSet returns a peer set sending heartbeats with client, whose timeout
+// is set to timeout.
+func newPeerSet(name string, peers []string, timeout time.Duration, client *http.Client, up *prometheus.GaugeVec, logger *slog.Logger) *peerSet {
+	urls := make([]string, 0, len(peers))
+	for _, p := range peers {
+		if !strings.Contains(p, "://") {
+			p = "http://" + p
+		}
+		urls = append(urls, strings.TrimSuffix(p, "/"))
+	}
+	self := memberSeed(name)
+	client.Timeout = timeout
+	return &peerSet{
+		name:    name,
+		peers:   urls,
+		timeout: timeout,
+		client:  client,
+		up:      up,
+		logger:  logger,
+		seen:    map[string]peerState{},
+		live:    map[string]bool{},
+		members: []uint64{self},
+		self:    self,
+	}
+}
+
+// newPeerClient returns the client for heartbeats, configured by the HTTP
+// client configuration file at path, if any. Peers whose web configuration
+// enables TLS or authentication need it to accept the heartbeats.
+func newPeerClient(path string) (*http.Client, error) {
+	if path == "" {
+		return &http.Client{}, nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := config.LoadHTTPConfig(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	// Unlike config.LoadHTTPConfigFile, resolve relative paths against the
+	// directory of the file itself.
+	cfg.SetDirectory(filepath.Dir(path))
+	return config.NewClientFromConfig(*cfg, "cluster_peer")
+}
+
+func memberSeed(name string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return h.Sum64()
+}
+
+// run checks the peers every interval.
+func (s *peerSet) run(interval time.Duration) {
+	ticker := clock.NewTicker(interval)
+	for {
+		s.check()
+		<-ticker.C
+	}
+}
+
+// check sends a heartbeat to every peer and updates the live members.
+func (s *peerSet) check() {
+	now := clock.Now()
+	for _, peer := range s.peers {
+		name, err := s.heartbeat(peer)
+		if err != nil {
+			s.logger.Debug("Peer heartbeat failed", "peer", peer, "error", err)
+			continue
+		}
+		if name == s.name {
+			s.logger.Warn("Peer has the same name as this instance, both export all series", "peer", peer, "name", name)
+		}
+		s.mtx.Lock()
+		s.seen[peer] = peerState{name: name, lastSeen: now}
+		s.mtx.Unlock()
+	}
+	s.updateMembers(now)
+}
+
+func (s *peerSet) heartbeat(peer string) (string, error) {
+	resp, err := s.client.Get(peer + peerPath)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	var hb peerHeartbeat
+	if err := json.NewDecoder(resp.Body).Decode(&hb); err != nil {
+		return "", err
+	}
+	if hb.Name == "" {
+		return "", fmt.Errorf("peer has no name")
+	}
+	return hb.Name, nil
+}
+
+// updateMembers recomputes the live members from the last heartbeats.
+func (s *peerSet) updateMembers(now time.Time) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	members := []uint64{s.self}
+	for _, peer := range s.peers {
+		state, ok := s.seen[peer]
+		live := ok && now.Sub(state.lastSeen) < s.timeout
+		if live {
+			members = append(members, memberSeed(state.name))
+		}
+		switch {
+		case live && !s.live[peer]:
+			s.logger.Info("Peer is up, sharing series with it", "peer", peer, "name", state.name)
+		case !live && s.live[peer]:
+			s.logger.Warn("Peer is down, taking over its series", "peer", peer, "name", state.name)
+		}
+		s.live[peer] = live
+		if s.up == nil {
+			continue
+		}
+		if live {
+			s.up.WithLabelValues(peer).Set(1)
+		} else {
+			s.up.WithLabelValues(peer).Set(0)
+		}
+	}
+	slices.Sort(members)
+	s.members = members
+}
+
+// alone reports whether no peer is live, so that this instance owns all
+// series.
+func (s *peerSet) alone() bool {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	return len(s.members) == 1
+}
+
+// owns reports whether this instance exports the series with the given key.
+func (s *peerSet) owns(key uint64) bool {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	if len(s.members) == 1 {
+		return true
+	}
+	var best, bestScore uint64
+	for _, m := range s.members {
+		if score := registry.Mix64(m ^ key); score >= bestScore {
+			best, bestScore = m, score
+		}
+	}
+	return best == s.self
+}
+
+// ServeHTTP answers heartbeats of peers with the name of this instance.
+func (s *peerSet) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(peerHeartbeat{Name: s.name})
+}
+
+// Registerer returns a Registerer that registers collectors with r, such that
+// they only collect the series this instance owns.
+func (s *peerSet) Registerer(r prometheus.Registerer) prometheus.Registerer {
+	return ownedRegisterer{Registerer: r, peers: s}
+}
+
+type ownedRegisterer struct {
+	prometheus.Registerer
+	peers *peerSet
+}
+
+func (r ownedRegisterer) Register(c prometheus.Collector) error {
+	return r.Registerer.Register(r.wrap(c))
+}
+
+func (r ownedRegisterer) MustRegister(cs ...prometheus.Collector) {
+	for _, c := range cs {
+		r.Registerer.MustRegister(r.wrap(c))
+	}
+}
+
+func (r ownedRegisterer) Unregister(c prometheus.Collector) bool {
+	return r.Registerer.Unregister(r.wrap(c))
+}
+
+func (r ownedRegisterer) wrap(c prometheus.Collector) prometheus.Collector {
+	o := ownedCollector{Collector: c, peers: r.peers}
+	if n, ok := c.(interface{ MetricName() string }); ok {
+		o.name = n.MetricName()
+	}
+	return o
+}
+
+// ownedCollector only collects the series of its collector that its peer set
+// owns. Collectors are compared by value when they are unregistered, so it
+// holds nothing but comparable fields.
+type ownedCollector struct {
+	prometheus.Collector
+	name  string
+	peers *peerSet
+}
+
+// MetricName returns the name of the metric family of the collector, if it
+// has one.
+func (o ownedCollector) MetricName() string {
+	return o.name
+}
+
+func (o ownedCollector) Collect(ch chan<- prometheus.Metric) {
+	if o.peers.alone() {
+		o.Collector.Collect(ch)
+		return
+	}
+	metrics := make(chan prometheus.Metric)
+	go func() {
+		o.Collector.Collect(metrics)
+		close(metrics)
+	}()
+	for m := range metrics {
+		if o.peers.owns(o.seriesKey(m)) {
+			ch <- m
+		}
+	}
+}
+
+// seriesKey hashes the metric family name and the labels of m. The name is
+// taken from the Desc of m if the collector doesn't have one.
+func (o ownedCollector) seriesKey(m prometheus.Metric) uint64 {
+	h := fnv.New64a()
+	if o.name != "" {
+		h.Write([]byte(o.name))
+	} else {
+		h.Write([]byte(m.Desc().String()))
+	}
+	var metric dto.Metric
+	if err := m.Write(&metric); err == nil {
+		for _, l := range metric.Label {
+			h.Write([]byte{0xff})
+			h.Write([]byte(l.GetName()))
+			h.Write([]byte{0xff})
+			h.Write([]byte(l.GetValue()))
+		}
+	}
+	return h.Sum64()
+}
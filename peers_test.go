@@ -0,0 +1,128 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/promslog"
+
+	"github.com/prometheus/statsd_exporter/pkg/clock"
+)
+
+func TestPeerSet(t *testing.T) {
+	srvA := httptest.NewServer(nil)
+	defer srvA.Close()
+	srvB := httptest.NewServer(nil)
+	defer srvB.Close()
+
+	a := newPeerSet("a", []string{srvB.URL}, time.Minute, &http.Client{}, nil, promslog.NewNopLogger())
+	b := newPeerSet("b", []string{srvA.URL}, time.Minute, &http.Client{}, nil, promslog.NewNopLogger())
+	srvA.Config.Handler = a
+	srvB.Config.Handler = b
+
+	// Both instances receive the same events.
+	regA, regB := prometheus.NewRegistry(), prometheus.NewRegistry()
+	gaugeA := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "requests"}, []string{"path"})
+	gaugeB := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "requests"}, []string{"path"})
+	a.Registerer(regA).MustRegister(gaugeA)
+	b.Registerer(regB).MustRegister(gaugeB)
+	const series = 100
+	for i := 0; i < series; i++ {
+		gaugeA.WithLabelValues(strconv.Itoa(i)).Set(1)
+		gaugeB.WithLabelValues(strconv.Itoa(i)).Set(1)
+	}
+
+	count := func(reg *prometheus.Registry) int {
+		families, err := reg.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(families) == 0 {
+			return 0
+		}
+		return len(families[0].Metric)
+	}
+
+	// Without heartbeats, each instance exports all series.
+	if n := count(regA); n != series {
+		t.Fatalf("expected a lone instance to export all %d series, got %d", series, n)
+	}
+
+	a.check()
+	b.check()
+	nA, nB := count(regA), count(regB)
+	if nA+nB != series || nA == 0 || nB == 0 {
+		t.Fatalf("expected the series to be split between the instances, got %d and %d", nA, nB)
+	}
+
+	// Once b misses its heartbeats, a takes over its series.
+	a.updateMembers(clock.Now().Add(2 * time.Minute))
+	if n := count(regA); n != series {
+		t.Fatalf("expected a to take over all %d series, got %d", series, n)
+	}
+}
+
+func TestPeerClientConfig(t *testing.T) {
+	b := newPeerSet("b", nil, time.Minute, &http.Client{}, nil, promslog.NewNopLogger())
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "statsd" || pass != "secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		b.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	// Paths in the configuration are relative to its directory.
+	dir := t.TempDir()
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(filepath.Join(dir, "ca.crt"), ca, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "password"), []byte("secret"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "peer.yml")
+	cfg := "basic_auth:\n  username: statsd\n  password_file: password\ntls_config:\n  ca_file: ca.crt\n"
+	if err := os.WriteFile(path, []byte(cfg), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	unconfigured := newPeerSet("a", []string{srv.URL}, time.Minute, &http.Client{}, nil, promslog.NewNopLogger())
+	if _, err := unconfigured.heartbeat(unconfigured.peers[0]); err == nil {
+		t.Fatalf("expected a heartbeat without the peer configuration to fail")
+	}
+
+	client, err := newPeerClient(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := newPeerSet("a", []string{srv.URL}, time.Minute, client, nil, promslog.NewNopLogger())
+	name, err := a.heartbeat(a.peers[0])
+	if err != nil {
+		t.Fatalf("expected the heartbeat to succeed, got %v", err)
+	}
+	if name != "b" {
+		t.Fatalf("expected peer b, got %q", name)
+	}
+}
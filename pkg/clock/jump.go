@@ -0,0 +1,53 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clock
+
+import "time"
+
+// JumpDetector detects steps of the wall clock, such as NTP corrections, and
+// stalls of the process, such as VM pauses, from readings taken every
+// Interval.
+//
+// Timers and TTLs use the monotonic clock and are not affected by wall clock
+// steps, these are only reported. Stalls delay the readings themselves.
+type JumpDetector struct {
+	Interval  time.Duration
+	Threshold time.Duration
+	last      time.Time
+}
+
+// Check takes a reading and returns how far the wall clock stepped and how
+// long the process stalled since the previous reading. Values below the
+// threshold are returned as 0.
+func (d *JumpDetector) Check(now time.Time) (wallStep, stall time.Duration) {
+	if d.last.IsZero() {
+		d.last = now
+		return 0, 0
+	}
+
+	// Sub uses the monotonic clock reading if both times have one, Round(0)
+	// strips it to compare wall clock times.
+	elapsed := now.Sub(d.last)
+	wallStep = now.Round(0).Sub(d.last.Round(0)) - elapsed
+	stall = elapsed - d.Interval
+	d.last = now
+
+	if wallStep < d.Threshold && wallStep > -d.Threshold {
+		wallStep = 0
+	}
+	if stall < d.Threshold {
+		stall = 0
+	}
+	return wallStep, stall
+}
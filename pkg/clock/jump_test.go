@@ -0,0 +1,44 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestJumpDetector(t *testing.T) {
+	d := &JumpDetector{Interval: time.Second, Threshold: 2 * time.Second}
+
+	start := time.Now()
+	readings := []struct {
+		now      time.Time
+		wallStep time.Duration
+		stall    time.Duration
+	}{
+		{now: start},
+		{now: start.Add(time.Second)},
+		{now: start.Add(2500 * time.Millisecond)},
+		{now: start.Add(10500 * time.Millisecond), stall: 7 * time.Second},
+		// Without a monotonic clock reading, a wall clock step can't be told
+		// apart from a stall.
+		{now: start.Add(20 * time.Second).Round(0), stall: 8500 * time.Millisecond},
+	}
+	for i, r := range readings {
+		wallStep, stall := d.Check(r.now)
+		if wallStep != r.wallStep || stall != r.stall {
+			t.Errorf("%d: expected step %s and stall %s, got %s and %s", i, r.wallStep, r.stall, wallStep, stall)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package event
+
+import "math"
+
+// The Event interface is frozen at version 1: methods are never added to it,
+// so that implementations outside of this package keep working. Properties
+// added to events later are exposed through optional interfaces such as
+// RelativeEvent and SampledEvent, which consumers query with helpers like
+// IsRelative and SampleRateOf that fall back to sensible defaults.
+//
+// Events should be created with the constructors below rather than struct
+// literals, and read and changed through their methods rather than their
+// fields. The exported fields are deprecated and only kept for
+// compatibility, they may gain siblings at any time.
+
+// RelativeEvent is implemented by events whose value is relative to the
+// current value of the metric.
+type RelativeEvent interface {
+	Relative() bool
+}
+
+// SampledEvent is implemented by events that carry the sample rate of the
+// client that sent them.
+type SampledEvent interface {
+	SamplingRate() float64
+}
+
+// ObserverKind is the StatsD type an observed value was sent as. Timers are
+// converted to seconds, while histograms and distributions are in the unit of
+// the client.
+type ObserverKind string
+
+const (
+	ObserverKindTimer        ObserverKind = "ms"
+	ObserverKindHistogram    ObserverKind = "h"
+	ObserverKindDistribution ObserverKind = "d"
+)
+
+// KindedEvent is implemented by observer events that know the StatsD type
+// their value was sent as.
+type KindedEvent interface {
+	ObserverKind() ObserverKind
+}
+
+// NewCounterEvent returns an event that increments a counter by value.
+func NewCounterEvent(metricName string, value float64, labels map[string]string) *CounterEvent {
+	return &CounterEvent{
+		CMetricName: metricName,
+		CValue:      value,
+		CLabels:     labels,
+	}
+}
+
+// NewGaugeEvent returns an event that sets a gauge to value, or changes it by
+// value if relative is set.
+func NewGaugeEvent(metricName string, value float64, relative bool, labels map[string]string) *GaugeEvent {
+	return &GaugeEvent{
+		GMetricName: metricName,
+		GValue:      value,
+		GRelative:   relative,
+		GLabels:     labels,
+	}
+}
+
+// NewObserverEvent returns an event that observes a single value.
+func NewObserverEvent(metricName string, value float64, labels map[string]string) *ObserverEvent {
+	return &ObserverEvent{
+		OMetricName: metricName,
+		OValue:      value,
+		OLabels:     labels,
+	}
+}
+
+// NewSampledObserverEvent returns an event that observes a single value, sent
+// with the given sample rate. A sample rate of 0 means the value was not
+// sampled.
+func NewSampledObserverEvent(metricName string, value float64, sampleRate float64, labels map[string]string) *ObserverEvent {
+	return &ObserverEvent{
+		OMetricName: metricName,
+		OValue:      value,
+		OLabels:     labels,
+		OSampleRate: sampleRate,
+	}
+}
+
+// NewSetEvent returns an event that adds member to a set.
+func NewSetEvent(metricName, member string, labels map[string]string) *SetEvent {
+	return &SetEvent{
+		SMetricName: metricName,
+		SMember:     member,
+		SLabels:     labels,
+	}
+}
+
+// NewMultiObserverEvent returns an event that observes several values, sent
+// with the given sample rate. A sample rate of 0 means the values were not
+// sampled.
+func NewMultiObserverEvent(metricName string, values []float64, sampleRate float64, labels map[string]string) *MultiObserverEvent {
+	return &MultiObserverEvent{
+		OMetricName: metricName,
+		OValues:     values,
+		OLabels:     labels,
+		SampleRate:  sampleRate,
+	}
+}
+
+func (g *GaugeEvent) Relative() bool { return g.GRelative }
+
+// Add adds v to the value of the event, such as when summing up the
+// increments of a counter before it is queued.
+func (c *CounterEvent) Add(v float64) { c.CValue += v }
+
+// AddValue appends a value to the values of the event.
+func (m *MultiObserverEvent) AddValue(v float64) { m.OValues = append(m.OValues, v) }
+
+// WithObserverKind sets the StatsD type the value of the event was sent as,
+// and returns the event.
+func (o *ObserverEvent) WithObserverKind(kind ObserverKind) *ObserverEvent {
+	o.OKind = kind
+	return o
+}
+
+// WithObserverKind sets the StatsD type the values of the event were sent
+// as, and returns the event.
+func (m *MultiObserverEvent) WithObserverKind(kind ObserverKind) *MultiObserverEvent {
+	m.OKind = kind
+	return m
+}
+
+// SamplingRate returns the sample rate of the event, or 1 if it was not
+// sampled.
+func (o *ObserverEvent) SamplingRate() float64 {
+	if o.OSampleRate <= 0 {
+		return 1
+	}
+	return o.OSampleRate
+}
+
+// SamplingRate returns the sample rate of the event, or 1 if it was not
+// sampled.
+func (m *MultiObserverEvent) SamplingRate() float64 {
+	if m.SampleRate <= 0 {
+		return 1
+	}
+	return m.SampleRate
+}
+
+// ObserverKind returns the StatsD type the value of the event was sent as.
+// Events without a kind are timers.
+func (o *ObserverEvent) ObserverKind() ObserverKind {
+	if o.OKind == "" {
+		return ObserverKindTimer
+	}
+	return o.OKind
+}
+
+// ObserverKind returns the StatsD type the values of the event were sent as.
+// Events without a kind are timers.
+func (m *MultiObserverEvent) ObserverKind() ObserverKind {
+	if m.OKind == "" {
+		return ObserverKindTimer
+	}
+	return m.OKind
+}
+
+// IsRelative reports whether the value of e is relative to the current value
+// of the metric. Events that don't implement RelativeEvent are absolute.
+func IsRelative(e Event) bool {
+	if r, ok := e.(RelativeEvent); ok {
+		return r.Relative()
+	}
+	return false
+}
+
+// ObserverKindOf returns the StatsD type the value of e was sent as. Events
+// that don't implement KindedEvent are timers.
+func ObserverKindOf(e Event) ObserverKind {
+	if k, ok := e.(KindedEvent); ok {
+		return k.ObserverKind()
+	}
+	return ObserverKindTimer
+}
+
+// SampleRateOf returns the sample rate of e. Events that don't implement
+// SampledEvent were not sampled, and have a sample rate of 1.
+func SampleRateOf(e Event) float64 {
+	if s, ok := e.(SampledEvent); ok {
+		return s.SamplingRate()
+	}
+	return 1
+}
+
+// MaxSampleWeight is the largest number of times that observers which have no
+// notion of weight observe a sampled value. Values that stand for more
+// observations are observed MaxSampleWeight times by them, which bounds the
+// work of a single event.
+const MaxSampleWeight = 10000
+
+// SampleWeightOf returns the number of observations that a value of e stands
+// for: the inverse of its sample rate, rounded down. Observers apply sampled
+// values with this weight, rather than receiving as many copies of the event.
+func SampleWeightOf(e Event) uint64 {
+	rate := SampleRateOf(e)
+	if rate <= 0 || rate >= 1 {
+		return 1
+	}
+	// The inverse of tiny sample rates doesn't fit into an integer.
+	if rate < 1.0/math.MaxUint32 {
+		return math.MaxUint32
+	}
+	return uint64(1 / rate)
+}
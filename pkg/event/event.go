@@ -14,6 +14,8 @@
 package event
 
 import (
+	"context"
+	"runtime/trace"
 	"sync"
 	"time"
 
@@ -31,9 +33,12 @@ type Event interface {
 }
 
 type CounterEvent struct {
+	// Deprecated: Use NewCounterEvent and MetricName instead.
 	CMetricName string
-	CValue      float64
-	CLabels     map[string]string
+	// Deprecated: Use NewCounterEvent, Value and Add instead.
+	CValue float64
+	// Deprecated: Use NewCounterEvent and Labels instead.
+	CLabels map[string]string
 }
 
 func (c *CounterEvent) MetricName() string            { return c.CMetricName }
@@ -43,10 +48,14 @@ func (c *CounterEvent) MetricType() mapper.MetricType { return mapper.MetricType
 func (c *CounterEvent) Values() []float64             { return []float64{c.CValue} }
 
 type GaugeEvent struct {
+	// Deprecated: Use NewGaugeEvent and MetricName instead.
 	GMetricName string
-	GValue      float64
-	GRelative   bool
-	GLabels     map[string]string
+	// Deprecated: Use NewGaugeEvent and Value instead.
+	GValue float64
+	// Deprecated: Use NewGaugeEvent and Relative instead.
+	GRelative bool
+	// Deprecated: Use NewGaugeEvent and Labels instead.
+	GLabels map[string]string
 }
 
 func (g *GaugeEvent) MetricName() string            { return g.GMetricName }
@@ -56,9 +65,18 @@ func (g *GaugeEvent) MetricType() mapper.MetricType { return mapper.MetricTypeGa
 func (g *GaugeEvent) Values() []float64             { return []float64{g.GValue} }
 
 type ObserverEvent struct {
+	// Deprecated: Use NewObserverEvent and MetricName instead.
 	OMetricName string
-	OValue      float64
-	OLabels     map[string]string
+	// Deprecated: Use NewObserverEvent and Value instead.
+	OValue float64
+	// Deprecated: Use NewObserverEvent and Labels instead.
+	OLabels map[string]string
+	// OSampleRate is 0 if the value was not sampled.
+	//
+	// Deprecated: Use NewSampledObserverEvent and SamplingRate instead.
+	OSampleRate float64
+	// Deprecated: Use WithObserverKind and ObserverKind instead.
+	OKind ObserverKind
 }
 
 func (o *ObserverEvent) MetricName() string            { return o.OMetricName }
@@ -67,6 +85,23 @@ func (o *ObserverEvent) Labels() map[string]string     { return o.OLabels }
 func (o *ObserverEvent) MetricType() mapper.MetricType { return mapper.MetricTypeObserver }
 func (o *ObserverEvent) Values() []float64             { return []float64{o.OValue} }
 
+// SetEvent adds a member to a StatsD set, which counts its unique members.
+// Its value is always 1.
+type SetEvent struct {
+	// Deprecated: Use NewSetEvent and MetricName instead.
+	SMetricName string
+	// Deprecated: Use NewSetEvent and Member instead.
+	SMember string
+	// Deprecated: Use NewSetEvent and Labels instead.
+	SLabels map[string]string
+}
+
+func (s *SetEvent) MetricName() string            { return s.SMetricName }
+func (s *SetEvent) Value() float64                { return 1 }
+func (s *SetEvent) Labels() map[string]string     { return s.SLabels }
+func (s *SetEvent) MetricType() mapper.MetricType { return mapper.MetricTypeSet }
+func (s *SetEvent) Member() string                { return s.SMember }
+
 type Events []Event
 
 type EventQueue struct {
@@ -77,6 +112,15 @@ type EventQueue struct {
 	flushThreshold int
 	flushInterval  time.Duration
 	eventsFlushed  prometheus.Counter
+	priority       func(Event) mapper.Priority
+	eventsShed     *prometheus.CounterVec
+	probeEvery     int
+	sinceProbe     int
+	closed         bool
+	done           chan struct{}
+	flushBytes     int
+	queuedBytes    int
+	bytesQueued    prometheus.Counter
 }
 
 type EventHandler interface {
@@ -92,40 +136,87 @@ func NewEventQueue(c chan Events, flushThreshold int, flushInterval time.Duratio
 		flushTicker:    ticker,
 		q:              make([]Event, 0, flushThreshold),
 		eventsFlushed:  eventsFlushed,
+		done:           make(chan struct{}),
 	}
 	go func() {
 		for {
-			<-ticker.C
-			eq.Flush()
+			select {
+			case <-ticker.C:
+				eq.Flush()
+			case <-eq.done:
+				return
+			}
 		}
 	}()
 	return eq
 }
 
 func (eq *EventQueue) Queue(events Events) {
+	defer trace.StartRegion(context.Background(), TraceRegionQueue).End()
 	eq.m.Lock()
 	defer eq.m.Unlock()
+	if eq.closed {
+		return
+	}
 
 	for _, e := range events {
 		eq.q = append(eq.q, e)
-		if len(eq.q) >= eq.flushThreshold {
+		if eq.flushBytes > 0 {
+			size := Size(e)
+			eq.queuedBytes += size
+			eq.bytesQueued.Add(float64(size))
+		}
+		eq.maybeProbe()
+		if len(eq.q) >= eq.flushThreshold || (eq.flushBytes > 0 && eq.queuedBytes >= eq.flushBytes) {
 			eq.FlushUnlocked()
 		}
 	}
 }
 
 func (eq *EventQueue) Flush() {
+	defer trace.StartRegion(context.Background(), TraceRegionQueue).End()
 	eq.m.Lock()
 	defer eq.m.Unlock()
+	if eq.closed {
+		return
+	}
 	eq.FlushUnlocked()
 }
 
+// Close flushes the events in the queue, however few, and closes C, so that
+// its consumer can apply them and stop. Events queued afterwards are dropped.
+func (eq *EventQueue) Close() {
+	eq.m.Lock()
+	defer eq.m.Unlock()
+	if eq.closed {
+		return
+	}
+	eq.closed = true
+	eq.flushTicker.Stop()
+	close(eq.done)
+	if len(eq.q) > 0 {
+		eq.FlushUnlocked()
+	}
+	close(eq.C)
+}
+
 func (eq *EventQueue) FlushUnlocked() {
+	if eq.priority != nil {
+		eq.shed()
+	}
 	eq.C <- eq.q
 	eq.q = make([]Event, 0, cap(eq.q))
+	eq.queuedBytes = 0
 	eq.eventsFlushed.Inc()
 }
 
+// Closed reports whether the queue was closed and drops events.
+func (eq *EventQueue) Closed() bool {
+	eq.m.Lock()
+	defer eq.m.Unlock()
+	return eq.closed
+}
+
 func (eq *EventQueue) Len() int {
 	eq.m.Lock()
 	defer eq.m.Unlock()
@@ -150,10 +241,19 @@ type MultiValueEvent interface {
 }
 
 type MultiObserverEvent struct {
+	// Deprecated: Use NewMultiObserverEvent and MetricName instead.
 	OMetricName string
-	OValues     []float64 // DataDog extensions allow multiple values in a single sample
-	OLabels     map[string]string
-	SampleRate  float64
+	// OValues holds several values, as DataDog extensions allow multiple
+	// values in a single sample.
+	//
+	// Deprecated: Use NewMultiObserverEvent, Values and AddValue instead.
+	OValues []float64
+	// Deprecated: Use NewMultiObserverEvent and Labels instead.
+	OLabels map[string]string
+	// Deprecated: Use NewMultiObserverEvent and SamplingRate instead.
+	SampleRate float64
+	// Deprecated: Use WithObserverKind and ObserverKind instead.
+	OKind ObserverKind
 }
 
 type ExpandableEvent interface {
@@ -169,42 +269,15 @@ func (m *MultiObserverEvent) Values() []float64             { return m.OValues }
 // Expand returns a list of events that are the result of expanding the multi-value event.
 // This will be used as a middle-step in the pipeline to convert multi-value events to single-value events.
 // And keep the exporter code compatible with previous versions.
+// The expanded events keep the sample rate, so that each of them is weighted
+// when it is observed.
 func (m *MultiObserverEvent) Expand() []Event {
-	if len(m.OValues) == 1 && m.SampleRate == 0 {
-		return []Event{
-			&ObserverEvent{
-				OMetricName: m.OMetricName,
-				OValue:      m.OValues[0],
-				OLabels:     copyLabels(m.OLabels),
-			},
-		}
-	}
-
 	events := make([]Event, 0, len(m.OValues))
 	for _, value := range m.OValues {
-		events = append(events, &ObserverEvent{
-			OMetricName: m.OMetricName,
-			OValue:      value,
-			OLabels:     copyLabels(m.OLabels),
-		})
-	}
-
-	if m.SampleRate > 0 && m.SampleRate < 1 {
-		multiplier := int(1 / m.SampleRate)
-		multipliedEvents := make([]Event, 0, len(events)*multiplier)
-		for i := 0; i < multiplier; i++ {
-			for _, event := range events {
-				e := event.(*ObserverEvent)
-				multipliedEvents = append(multipliedEvents, &ObserverEvent{
-					OMetricName: e.OMetricName,
-					OValue:      e.OValue,
-					OLabels:     copyLabels(e.OLabels),
-				})
-			}
-		}
-		return multipliedEvents
+		e := NewSampledObserverEvent(m.OMetricName, value, m.SampleRate, copyLabels(m.OLabels))
+		e.OKind = m.OKind
+		events = append(events, e)
 	}
-
 	return events
 }
 
@@ -20,6 +20,7 @@ import (
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 
 	"github.com/prometheus/statsd_exporter/pkg/clock"
 	"github.com/prometheus/statsd_exporter/pkg/mapper"
@@ -55,6 +56,44 @@ func TestEventThresholdFlush(t *testing.T) {
 	}
 }
 
+func TestEventQueueByteLimit(t *testing.T) {
+	c := make(chan Events, 100)
+	eq := NewEventQueue(c, 1000, time.Second, eventsFlushed)
+	bytesQueued := prometheus.NewCounter(prometheus.CounterOpts{})
+	eq.EnableByteLimit(1000, bytesQueued)
+
+	small := NewCounterEvent("a", 1, map[string]string{})
+	large := NewMultiObserverEvent("b", make([]float64, 100), 0, map[string]string{})
+	if Size(small) != 73 || Size(large) != 865 {
+		t.Fatalf("unexpected sizes %d and %d", Size(small), Size(large))
+	}
+
+	events := Events{}
+	for i := 0; i < 10; i++ {
+		events = append(events, small)
+	}
+	eq.Queue(events)
+	if len(c) != 0 {
+		t.Fatalf("expected 730 bytes of events to not be flushed")
+	}
+	eq.Queue(Events{large})
+	if batch := <-c; len(batch) != 11 {
+		t.Fatalf("expected a batch of 11 events, got %d", len(batch))
+	}
+
+	// The size is reset by the flush.
+	eq.Queue(Events{small})
+	if len(c) != 0 || eq.Len() != 1 {
+		t.Fatalf("expected the event to be held after the flush")
+	}
+
+	var m dto.Metric
+	bytesQueued.Write(&m)
+	if v := m.GetCounter().GetValue(); v != 73*11+865 {
+		t.Fatalf("expected %d bytes to be counted, got %v", 73*11+865, v)
+	}
+}
+
 func TestEventIntervalFlush(t *testing.T) {
 	// Mock a time.NewTicker
 	tickerCh := make(chan time.Time)
@@ -90,6 +129,136 @@ func TestEventIntervalFlush(t *testing.T) {
 	}
 }
 
+func TestEventQueueClose(t *testing.T) {
+	c := make(chan Events, 100)
+	eq := NewEventQueue(c, 1000, time.Hour, eventsFlushed)
+	eq.Queue(make(Events, 10))
+	if eq.Closed() {
+		t.Fatal("Expected the queue to be open")
+	}
+	eq.Close()
+	if !eq.Closed() {
+		t.Fatal("Expected the queue to be closed")
+	}
+
+	batch, ok := <-c
+	if !ok || len(batch) != 10 {
+		t.Fatalf("Expected the 10 queued events to be flushed on close, got %v", batch)
+	}
+	if _, ok := <-c; ok {
+		t.Fatal("Expected the event channel to be closed")
+	}
+
+	// Events queued after closing are dropped instead of being sent on the
+	// closed channel.
+	eq.Queue(make(Events, 10))
+	eq.Flush()
+	eq.Close()
+	if eq.Len() != 0 {
+		t.Fatal("Expected events queued after closing to be dropped, but got", eq.Len())
+	}
+}
+
+func TestEventQueueShedding(t *testing.T) {
+	priorities := map[string]mapper.Priority{
+		"critical": mapper.PriorityCritical,
+		"normal":   mapper.PriorityNormal,
+		"debug":    mapper.PriorityDebug,
+	}
+	batch := Events{
+		NewCounterEvent("critical", 1, nil),
+		NewCounterEvent("normal", 1, nil),
+		NewCounterEvent("debug", 1, nil),
+		NewCounterEvent("debug", 1, nil),
+	}
+
+	scenarios := []struct {
+		name    string
+		backlog int
+		kept    []string
+		shed    map[mapper.Priority]float64
+	}{
+		{
+			name: "empty channel",
+			kept: []string{"critical", "normal", "debug", "debug"},
+		},
+		{
+			name:    "half full channel",
+			backlog: 2,
+			kept:    []string{"critical", "normal"},
+			shed:    map[mapper.Priority]float64{mapper.PriorityDebug: 2},
+		},
+		{
+			name:    "full channel",
+			backlog: 4,
+			kept:    []string{"critical"},
+			shed:    map[mapper.Priority]float64{mapper.PriorityDebug: 2, mapper.PriorityNormal: 1},
+		},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			c := make(chan Events, 4)
+			for i := 0; i < s.backlog; i++ {
+				c <- Events{}
+			}
+			eq := &EventQueue{
+				C: c,
+				q: append(Events{}, batch...),
+			}
+			eq.EnablePriorityShedding(
+				func(e Event) mapper.Priority { return priorities[e.MetricName()] },
+				prometheus.NewCounterVec(prometheus.CounterOpts{}, []string{"priority"}),
+			)
+			eq.shed()
+
+			var kept []string
+			for _, e := range eq.q {
+				kept = append(kept, e.MetricName())
+			}
+			if !reflect.DeepEqual(kept, s.kept) {
+				t.Fatalf("expected %v to be kept, got %v", s.kept, kept)
+			}
+			for _, p := range []mapper.Priority{mapper.PriorityCritical, mapper.PriorityNormal, mapper.PriorityDebug} {
+				var m dto.Metric
+				if err := eq.eventsShed.WithLabelValues(string(p)).Write(&m); err != nil {
+					t.Fatal(err)
+				}
+				if v := m.GetCounter().GetValue(); v != s.shed[p] {
+					t.Errorf("expected %v %s events to be shed, got %v", s.shed[p], p, v)
+				}
+			}
+		})
+	}
+}
+
+func TestEventQueueLatencyProbes(t *testing.T) {
+	clock.ClockInstance = &clock.Clock{Instant: time.Unix(10, 0), TickerCh: make(chan time.Time)}
+	defer func() { clock.ClockInstance = nil }()
+
+	c := make(chan Events, 1)
+	eq := NewEventQueue(c, 100, time.Second, eventsFlushed)
+	eq.EnableLatencyProbes(2)
+	for i := 0; i < 5; i++ {
+		eq.Queue(Events{NewCounterEvent("foo", 1, nil)})
+	}
+	eq.Flush()
+
+	batch := <-c
+	if len(batch) != 7 {
+		t.Fatalf("expected 5 events and 2 probes, got %d events", len(batch))
+	}
+	for i, e := range batch {
+		p, ok := e.(*Probe)
+		if ok != (i == 2 || i == 5) {
+			t.Fatalf("unexpected event %d: %#v", i, e)
+		}
+		if ok && !p.ReceivedAt.Equal(time.Unix(10, 0)) {
+			t.Fatalf("expected probe to be received at %v, got %v", time.Unix(10, 0), p.ReceivedAt)
+		}
+	}
+}
+
 func TestMultiValueEvent(t *testing.T) {
 	tests := []struct {
 		name       string
@@ -243,21 +412,13 @@ func TestMultiObserverEvent_Expand(t *testing.T) {
 					OMetricName: "test_metric",
 					OValue:      1.0,
 					OLabels:     map[string]string{"label": "value"},
+					OSampleRate: 0.5,
 				},
 				&ObserverEvent{
 					OMetricName: "test_metric",
 					OValue:      2.0,
 					OLabels:     map[string]string{"label": "value"},
-				},
-				&ObserverEvent{
-					OMetricName: "test_metric",
-					OValue:      1.0,
-					OLabels:     map[string]string{"label": "value"},
-				},
-				&ObserverEvent{
-					OMetricName: "test_metric",
-					OValue:      2.0,
-					OLabels:     map[string]string{"label": "value"},
+					OSampleRate: 0.5,
 				},
 			},
 		},
@@ -292,3 +453,81 @@ func TestMultiObserverEvent_Expand(t *testing.T) {
 		})
 	}
 }
+
+// legacyEvent is an Event implemented outside of this package, which only
+// implements the version 1 interface.
+type legacyEvent struct{}
+
+func (legacyEvent) MetricName() string            { return "legacy" }
+func (legacyEvent) Value() float64                { return 1 }
+func (legacyEvent) Labels() map[string]string     { return nil }
+func (legacyEvent) MetricType() mapper.MetricType { return mapper.MetricTypeGauge }
+
+func TestEventCompatibility(t *testing.T) {
+	labels := map[string]string{"a": "b"}
+	tests := []struct {
+		name       string
+		event      Event
+		expected   Event
+		relative   bool
+		sampleRate float64
+	}{
+		{
+			name:       "counter",
+			event:      NewCounterEvent("c", 1, labels),
+			expected:   &CounterEvent{CMetricName: "c", CValue: 1, CLabels: labels},
+			sampleRate: 1,
+		},
+		{
+			name:       "relative gauge",
+			event:      NewGaugeEvent("g", -1, true, labels),
+			expected:   &GaugeEvent{GMetricName: "g", GValue: -1, GRelative: true, GLabels: labels},
+			relative:   true,
+			sampleRate: 1,
+		},
+		{
+			name:       "observer",
+			event:      NewObserverEvent("o", 0.5, labels),
+			expected:   &ObserverEvent{OMetricName: "o", OValue: 0.5, OLabels: labels},
+			sampleRate: 1,
+		},
+		{
+			name:       "sampled multi observer",
+			event:      NewMultiObserverEvent("m", []float64{1, 2}, 0.5, labels),
+			expected:   &MultiObserverEvent{OMetricName: "m", OValues: []float64{1, 2}, SampleRate: 0.5, OLabels: labels},
+			sampleRate: 0.5,
+		},
+		{
+			name:       "unsampled multi observer",
+			event:      NewMultiObserverEvent("m", []float64{1, 2}, 0, labels),
+			expected:   &MultiObserverEvent{OMetricName: "m", OValues: []float64{1, 2}, OLabels: labels},
+			sampleRate: 1,
+		},
+		{
+			name:       "histogram observer",
+			event:      NewSampledObserverEvent("m", 1, 0.5, labels).WithObserverKind(ObserverKindHistogram),
+			expected:   &ObserverEvent{OMetricName: "m", OValue: 1, OLabels: labels, OSampleRate: 0.5, OKind: ObserverKindHistogram},
+			sampleRate: 0.5,
+		},
+		{
+			name:       "event from another package",
+			event:      legacyEvent{},
+			expected:   legacyEvent{},
+			sampleRate: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !reflect.DeepEqual(tt.event, tt.expected) {
+				t.Errorf("expected %#v, got %#v", tt.expected, tt.event)
+			}
+			if got := IsRelative(tt.event); got != tt.relative {
+				t.Errorf("expected relative %v, got %v", tt.relative, got)
+			}
+			if got := SampleRateOf(tt.event); got != tt.sampleRate {
+				t.Errorf("expected sample rate %v, got %v", tt.sampleRate, got)
+			}
+		})
+	}
+}
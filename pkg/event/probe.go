@@ -0,0 +1,57 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package event
+
+import (
+	"time"
+
+	"github.com/prometheus/statsd_exporter/pkg/clock"
+	"github.com/prometheus/statsd_exporter/pkg/mapper"
+)
+
+// Probe is a pseudo event that measures the latency of events. It is queued
+// right after a sampled event, and passes through the same queues, so that
+// once it is handled, the sampled event has been applied as well. Consumers
+// of events must not treat it as a metric.
+type Probe struct {
+	// ReceivedAt is when the sampled event was queued.
+	ReceivedAt time.Time
+}
+
+func (p *Probe) MetricName() string            { return "" }
+func (p *Probe) Value() float64                { return 0 }
+func (p *Probe) Labels() map[string]string     { return nil }
+func (p *Probe) MetricType() mapper.MetricType { return "" }
+
+// EnableLatencyProbes queues a Probe after every nth event.
+func (eq *EventQueue) EnableLatencyProbes(n int) {
+	eq.m.Lock()
+	defer eq.m.Unlock()
+	eq.probeEvery = n
+	eq.sinceProbe = 0
+}
+
+// maybeProbe queues a Probe if the event just queued is to be sampled. It
+// must be called with the queue locked.
+func (eq *EventQueue) maybeProbe() {
+	if eq.probeEvery <= 0 {
+		return
+	}
+	eq.sinceProbe++
+	if eq.sinceProbe < eq.probeEvery {
+		return
+	}
+	eq.sinceProbe = 0
+	eq.q = append(eq.q, &Probe{ReceivedAt: clock.Now()})
+}
@@ -0,0 +1,143 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package event
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Bounds of the queue configuration that can be set at runtime.
+const (
+	MaxFlushThreshold = 1000000
+	MinFlushInterval  = time.Millisecond
+	MaxFlushInterval  = time.Minute
+)
+
+// FlushThreshold returns the number of events that are held before the queue
+// is flushed.
+func (eq *EventQueue) FlushThreshold() int {
+	eq.m.Lock()
+	defer eq.m.Unlock()
+	return eq.flushThreshold
+}
+
+// FlushInterval returns the maximum time between flushes of the queue.
+func (eq *EventQueue) FlushInterval() time.Duration {
+	eq.m.Lock()
+	defer eq.m.Unlock()
+	return eq.flushInterval
+}
+
+// SetFlushThreshold changes the number of events that are held before the
+// queue is flushed. If the queue already holds that many events, it is
+// flushed right away.
+func (eq *EventQueue) SetFlushThreshold(n int) {
+	eq.m.Lock()
+	defer eq.m.Unlock()
+	eq.flushThreshold = n
+	if len(eq.q) >= n {
+		eq.FlushUnlocked()
+	}
+}
+
+// SetFlushInterval changes the maximum time between flushes of the queue.
+func (eq *EventQueue) SetFlushInterval(d time.Duration) {
+	eq.m.Lock()
+	defer eq.m.Unlock()
+	eq.flushInterval = d
+	eq.flushTicker.Reset(d)
+}
+
+type queueConfig struct {
+	FlushThreshold int    `json:"flush_threshold"`
+	FlushInterval  string `json:"flush_interval"`
+}
+
+// queueConfigUpdate is the body of a request to change the configuration.
+// Fields that are not set are left unchanged.
+type queueConfigUpdate struct {
+	FlushThreshold *int    `json:"flush_threshold"`
+	FlushInterval  *string `json:"flush_interval"`
+}
+
+// QueueConfigHandler serves the configuration of an EventQueue as JSON, and
+// changes it on PUT requests. Changes are bounded by MaxFlushThreshold,
+// MinFlushInterval and MaxFlushInterval, and are logged.
+type QueueConfigHandler struct {
+	Queue  *EventQueue
+	Logger *slog.Logger
+}
+
+func (h *QueueConfigHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		if err := h.update(r); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	config := queueConfig{
+		FlushThreshold: h.Queue.FlushThreshold(),
+		FlushInterval:  h.Queue.FlushInterval().String(),
+	}
+	if err := json.NewEncoder(w).Encode(config); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (h *QueueConfigHandler) update(r *http.Request) error {
+	var update queueConfigUpdate
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&update); err != nil {
+		return fmt.Errorf("invalid queue configuration: %w", err)
+	}
+
+	var interval time.Duration
+	if update.FlushInterval != nil {
+		var err error
+		if interval, err = time.ParseDuration(*update.FlushInterval); err != nil {
+			return fmt.Errorf("invalid flush interval: %w", err)
+		}
+		if interval < MinFlushInterval || interval > MaxFlushInterval {
+			return fmt.Errorf("flush interval %s is not between %s and %s", interval, MinFlushInterval, MaxFlushInterval)
+		}
+	}
+	if update.FlushThreshold != nil && (*update.FlushThreshold < 1 || *update.FlushThreshold > MaxFlushThreshold) {
+		return fmt.Errorf("flush threshold %d is not between 1 and %d", *update.FlushThreshold, MaxFlushThreshold)
+	}
+
+	if update.FlushThreshold != nil {
+		old := h.Queue.FlushThreshold()
+		h.Queue.SetFlushThreshold(*update.FlushThreshold)
+		h.Logger.Info("Changed event flush threshold", "old", old, "new", *update.FlushThreshold, "remote_addr", r.RemoteAddr)
+	}
+	if update.FlushInterval != nil {
+		old := h.Queue.FlushInterval()
+		h.Queue.SetFlushInterval(interval)
+		h.Logger.Info("Changed event flush interval", "old", old, "new", interval, "remote_addr", r.RemoteAddr)
+	}
+	return nil
+}
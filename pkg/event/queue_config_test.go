@@ -0,0 +1,81 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package event
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/promslog"
+
+	"github.com/prometheus/statsd_exporter/pkg/clock"
+)
+
+func TestQueueConfigHandler(t *testing.T) {
+	// Changing the flush interval needs a real ticker.
+	clock.ClockInstance = nil
+
+	c := make(chan Events, 100)
+	eq := NewEventQueue(c, 10, time.Hour, eventsFlushed)
+	eq.Queue(make(Events, 4))
+	h := &QueueConfigHandler{Queue: eq, Logger: promslog.NewNopLogger()}
+
+	for _, tc := range []struct {
+		method string
+		body   string
+		status int
+		config string
+	}{
+		{method: http.MethodGet, status: http.StatusOK, config: `{"flush_threshold":10,"flush_interval":"1h0m0s"}`},
+		{method: http.MethodPut, body: `{"flush_threshold":3}`, status: http.StatusOK, config: `{"flush_threshold":3,"flush_interval":"1h0m0s"}`},
+		{method: http.MethodPut, body: `{"flush_interval":"10ms"}`, status: http.StatusOK, config: `{"flush_threshold":3,"flush_interval":"10ms"}`},
+		{method: http.MethodPut, body: `{"flush_threshold":0}`, status: http.StatusBadRequest},
+		{method: http.MethodPut, body: `{"flush_interval":"2h"}`, status: http.StatusBadRequest},
+		{method: http.MethodPut, body: `{"flush_threshold":5,"flush_interval":"soon"}`, status: http.StatusBadRequest},
+		{method: http.MethodPut, body: `{"flush_treshold":5}`, status: http.StatusBadRequest},
+		{method: http.MethodPost, body: `{}`, status: http.StatusMethodNotAllowed},
+		{method: http.MethodGet, status: http.StatusOK, config: `{"flush_threshold":3,"flush_interval":"10ms"}`},
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(tc.method, "/api/v1/queue/config", strings.NewReader(tc.body)))
+		if rec.Code != tc.status {
+			t.Fatalf("%s %s: expected status %d, got %d: %s", tc.method, tc.body, tc.status, rec.Code, rec.Body)
+		}
+		if tc.config != "" && strings.TrimSpace(rec.Body.String()) != tc.config {
+			t.Fatalf("%s %s: expected configuration %s, got %s", tc.method, tc.body, tc.config, rec.Body)
+		}
+	}
+
+	// Lowering the threshold below the number of queued events flushes them.
+	if batch := <-c; len(batch) != 4 {
+		t.Fatalf("expected a batch of 4 events, got %d", len(batch))
+	}
+	// The new interval takes effect right away. Empty batches are flushed
+	// as well.
+	eq.Queue(make(Events, 1))
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case batch := <-c:
+			if len(batch) == 1 {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("expected queue to be flushed after the new interval")
+		}
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package event
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+)
+
+// maxSeedRequestSize limits the body of seed requests.
+const maxSeedRequestSize = 10 << 20
+
+// SeedMetric is a metric to seed, with the name and labels of an incoming
+// StatsD line. Type is "gauge" or "counter".
+type SeedMetric struct {
+	Name   string            `json:"name"`
+	Type   string            `json:"type"`
+	Value  float64           `json:"value"`
+	Labels map[string]string `json:"labels"`
+}
+
+type seedRequest struct {
+	Metrics []SeedMetric `json:"metrics"`
+}
+
+// Event returns the event that seeds the metric. Gauges are set to the value,
+// and counters are incremented by it.
+func (m SeedMetric) Event() (Event, error) {
+	if m.Name == "" {
+		return nil, fmt.Errorf("metric without a name")
+	}
+	if math.IsNaN(m.Value) || math.IsInf(m.Value, 0) {
+		return nil, fmt.Errorf("metric %s has an invalid value %v", m.Name, m.Value)
+	}
+	// Mappings add their labels to the labels of the event.
+	labels := m.Labels
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	switch m.Type {
+	case "gauge":
+		return NewGaugeEvent(m.Name, m.Value, false, labels), nil
+	case "counter":
+		if m.Value < 0 {
+			return nil, fmt.Errorf("counter %s has a negative value %v", m.Name, m.Value)
+		}
+		return NewCounterEvent(m.Name, m.Value, labels), nil
+	default:
+		return nil, fmt.Errorf("metric %s has unsupported type %q", m.Name, m.Type)
+	}
+}
+
+// SeedHandler queues the gauges and counters in the JSON body of POST
+// requests, so that they are mapped and exported like incoming StatsD lines.
+// A request is queued only if all of its metrics are valid, and is flushed
+// right away. Seeds are logged.
+type SeedHandler struct {
+	Queue  *EventQueue
+	Logger *slog.Logger
+}
+
+func (h *SeedHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var seed seedRequest
+	dec := json.NewDecoder(io.LimitReader(r.Body, maxSeedRequestSize))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&seed); err != nil {
+		http.Error(w, fmt.Sprintf("invalid seed: %v", err), http.StatusBadRequest)
+		return
+	}
+	if len(seed.Metrics) == 0 {
+		http.Error(w, "no metrics to seed", http.StatusBadRequest)
+		return
+	}
+
+	events := make(Events, 0, len(seed.Metrics))
+	for _, m := range seed.Metrics {
+		e, err := m.Event()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid seed: %v", err), http.StatusBadRequest)
+			return
+		}
+		events = append(events, e)
+	}
+
+	h.Queue.Queue(events)
+	h.Queue.Flush()
+	h.Logger.Info("Seeded metrics", "metrics", len(events), "remote_addr", r.RemoteAddr)
+	w.WriteHeader(http.StatusNoContent)
+}
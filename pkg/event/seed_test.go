@@ -0,0 +1,79 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package event
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/promslog"
+)
+
+func TestSeedHandler(t *testing.T) {
+	c := make(chan Events, 100)
+	eq := NewEventQueue(c, 1000, time.Hour, eventsFlushed)
+	h := &SeedHandler{Queue: eq, Logger: promslog.NewNopLogger()}
+
+	for _, tc := range []struct {
+		method string
+		body   string
+		status int
+	}{
+		{method: http.MethodPost, body: `{"metrics": []}`, status: http.StatusBadRequest},
+		{method: http.MethodPost, body: `{"metrics": [{"name": "a", "type": "summary", "value": 1}]}`, status: http.StatusBadRequest},
+		{method: http.MethodPost, body: `{"metrics": [{"name": "a", "type": "counter", "value": -1}]}`, status: http.StatusBadRequest},
+		{method: http.MethodPost, body: `{"metrics": [{"type": "gauge", "value": 1}]}`, status: http.StatusBadRequest},
+		{method: http.MethodPost, body: `{"metrics": [{"name": "a", "type": "gauge", "value": 1, "help": "a"}]}`, status: http.StatusBadRequest},
+		// Valid metrics are not seeded if any metric of the request is invalid.
+		{method: http.MethodPost, body: `{"metrics": [{"name": "a", "type": "gauge", "value": 1}, {"name": "b", "type": "set", "value": 1}]}`, status: http.StatusBadRequest},
+		{method: http.MethodPut, body: `{}`, status: http.StatusMethodNotAllowed},
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(tc.method, "/api/v1/seed", strings.NewReader(tc.body)))
+		if rec.Code != tc.status {
+			t.Fatalf("%s %s: expected status %d, got %d: %s", tc.method, tc.body, tc.status, rec.Code, rec.Body)
+		}
+	}
+	if len(c) != 0 {
+		t.Fatalf("expected no events to be queued for invalid seeds")
+	}
+
+	body := `{"metrics": [
+		{"name": "orders_open", "type": "gauge", "value": 42, "labels": {"region": "eu"}},
+		{"name": "orders_total", "type": "counter", "value": 1000}
+	]}`
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/seed", strings.NewReader(body)))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected seed to succeed, got %d: %s", rec.Code, rec.Body)
+	}
+
+	// The seed is flushed without waiting for the flush interval.
+	expected := Events{
+		NewGaugeEvent("orders_open", 42, false, map[string]string{"region": "eu"}),
+		NewCounterEvent("orders_total", 1000, map[string]string{}),
+	}
+	select {
+	case batch := <-c:
+		if !reflect.DeepEqual(batch, expected) {
+			t.Fatalf("expected events %+v, got %+v", expected, batch)
+		}
+	default:
+		t.Fatalf("expected seeded events to be flushed")
+	}
+}
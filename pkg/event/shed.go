@@ -0,0 +1,60 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package event
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/prometheus/statsd_exporter/pkg/mapper"
+)
+
+// EnablePriorityShedding sheds events by the priority returned by priority
+// while the channel is backed up. Shed events are counted in eventsShed by
+// priority.
+func (eq *EventQueue) EnablePriorityShedding(priority func(Event) mapper.Priority, eventsShed *prometheus.CounterVec) {
+	eq.m.Lock()
+	defer eq.m.Unlock()
+	eq.priority = priority
+	eq.eventsShed = eventsShed
+}
+
+// shed drops queued events of low priority while the channel is backed up.
+// Debug events are shed once the channel is half full, normal events once it
+// is full. Critical events are never shed, they wait for room in the channel.
+func (eq *EventQueue) shed() {
+	used, size := len(eq.C), cap(eq.C)
+	if size == 0 || used*2 < size {
+		return
+	}
+	full := used >= size
+
+	kept := eq.q[:0]
+	shed := map[mapper.Priority]int{}
+	for _, e := range eq.q {
+		if _, ok := e.(*Probe); ok {
+			kept = append(kept, e)
+			continue
+		}
+		switch p := eq.priority(e); {
+		case p == mapper.PriorityDebug, p == mapper.PriorityNormal && full:
+			shed[p]++
+		default:
+			kept = append(kept, e)
+		}
+	}
+	eq.q = kept
+	for p, n := range shed {
+		eq.eventsShed.WithLabelValues(string(p)).Add(float64(n))
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package event
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// Approximate overheads of an event and of each of its labels, for the
+// struct, the interface and the label map.
+const (
+	eventOverhead = 64
+	labelOverhead = 32
+)
+
+// Size returns the approximate number of bytes an event takes in memory. It
+// is meant for limits that keep memory usage predictable, not for exact
+// accounting.
+func Size(e Event) int {
+	size := eventOverhead + len(e.MetricName())
+	for k, v := range e.Labels() {
+		size += labelOverhead + len(k) + len(v)
+	}
+	if m, ok := e.(*MultiObserverEvent); ok {
+		return size + 8*len(m.OValues)
+	}
+	if s, ok := e.(*SetEvent); ok {
+		return size + len(s.SMember)
+	}
+	return size + 8
+}
+
+// EnableByteLimit flushes the queue once the events it holds take
+// approximately flushBytes bytes, in addition to the flush threshold. Since
+// the channel holds a bounded number of batches, this bounds the memory used
+// by queued events even if some of them carry many values. The size of all
+// queued events is counted in bytesQueued.
+func (eq *EventQueue) EnableByteLimit(flushBytes int, bytesQueued prometheus.Counter) {
+	eq.m.Lock()
+	defer eq.m.Unlock()
+	eq.flushBytes = flushBytes
+	eq.bytesQueued = bytesQueued
+}
@@ -0,0 +1,31 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package event
+
+// Names of the runtime/trace regions around the stages of the pipeline. They
+// show where events are held up in traces taken from /debug/pprof/trace and
+// opened with `go tool trace`.
+const (
+	// TraceRegionRead is a listener waiting for and reading a packet or
+	// line.
+	TraceRegionRead = "statsd.read"
+	// TraceRegionParse is a line or frame being parsed into events.
+	TraceRegionParse = "statsd.parse"
+	// TraceRegionQueue is events being added to the event queue, including
+	// waiting for the exporter to take a full queue.
+	TraceRegionQueue = "statsd.queue"
+	// TraceRegionApply is the exporter applying a batch of events to the
+	// registry.
+	TraceRegionApply = "statsd.apply"
+)
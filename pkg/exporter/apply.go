@@ -0,0 +1,56 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"time"
+
+	"github.com/prometheus/statsd_exporter/pkg/clock"
+	"github.com/prometheus/statsd_exporter/pkg/event"
+)
+
+// slowApplyLogInterval is the minimum interval between two logs of slow
+// events, so that a slow registry doesn't also flood the log.
+const slowApplyLogInterval = 10 * time.Second
+
+// applyEvent handles the event, and records how long it took.
+func (b *Exporter) applyEvent(e event.Event) {
+	if b.ApplyDuration == nil && b.SlowApplyThreshold <= 0 {
+		b.handleEvent(e)
+		return
+	}
+	if _, ok := e.(*event.Probe); ok {
+		b.handleEvent(e)
+		return
+	}
+
+	start := time.Now()
+	b.handleEvent(e)
+	elapsed := time.Since(start)
+
+	if b.ApplyDuration != nil {
+		b.ApplyDuration.WithLabelValues(string(e.MetricType())).Observe(elapsed.Seconds())
+	}
+	if b.SlowApplyThreshold <= 0 || elapsed < b.SlowApplyThreshold {
+		return
+	}
+	now := clock.Now()
+	if now.Sub(b.lastSlowApplyLog) < slowApplyLogInterval {
+		b.slowAppliesSuppressed++
+		return
+	}
+	b.Logger.Warn("Slow event apply", "metric", e.MetricName(), "type", e.MetricType(), "labels", len(e.Labels()), "duration", elapsed, "suppressed", b.slowAppliesSuppressed)
+	b.lastSlowApplyLog = now
+	b.slowAppliesSuppressed = 0
+}
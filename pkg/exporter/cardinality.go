@@ -0,0 +1,111 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/prometheus/statsd_exporter/pkg/clock"
+	"github.com/prometheus/statsd_exporter/pkg/registry"
+)
+
+// cardinalityInterval is how often the number of active series is counted.
+const cardinalityInterval = 15 * time.Second
+
+// CardinalityMonitor periodically counts the metric names and series that
+// were updated within Window. Counting walks all series of the registry, so
+// it is done at most every 15 seconds. The last count, including the number
+// of values of each label name, is served as JSON.
+type CardinalityMonitor struct {
+	Window      time.Duration
+	MetricNames prometheus.Gauge
+	Series      prometheus.Gauge
+	// GrowthWarning, if positive, is the number of new active series per
+	// minute beyond which growth is logged and counted in GrowthWarnings.
+	GrowthWarning  float64
+	GrowthWarnings prometheus.Counter
+
+	lastUpdate time.Time
+	lastSeries int
+
+	mtx    sync.Mutex
+	report CardinalityReport
+}
+
+// CardinalityReport is the JSON representation of the last count of active
+// series.
+type CardinalityReport struct {
+	Time        time.Time                   `json:"time"`
+	Window      string                      `json:"window"`
+	MetricNames int                         `json:"metric_names"`
+	Series      int                         `json:"series"`
+	Labels      []registry.LabelCardinality `json:"labels"`
+}
+
+func (c *CardinalityMonitor) update(r Registry, logger *slog.Logger) {
+	now := clock.Now()
+	elapsed := now.Sub(c.lastUpdate)
+	if elapsed < cardinalityInterval {
+		return
+	}
+
+	metricNames, series := r.ActiveSince(now.Add(-c.Window))
+	c.MetricNames.Set(float64(metricNames))
+	c.Series.Set(float64(series))
+	report := CardinalityReport{
+		Time:        now,
+		Window:      c.Window.String(),
+		MetricNames: metricNames,
+		Series:      series,
+		Labels:      r.LabelCardinality(now.Add(-c.Window)),
+	}
+	c.mtx.Lock()
+	c.report = report
+	c.mtx.Unlock()
+
+	// The first count has nothing to compare to.
+	if !c.lastUpdate.IsZero() && c.GrowthWarning > 0 {
+		growth := float64(series-c.lastSeries) / elapsed.Minutes()
+		if growth > c.GrowthWarning {
+			logger.Warn("Number of active series is growing fast", "series", series, "growth_per_minute", growth, "threshold", c.GrowthWarning)
+			if c.GrowthWarnings != nil {
+				c.GrowthWarnings.Inc()
+			}
+		}
+	}
+	c.lastUpdate = now
+	c.lastSeries = series
+}
+
+// Report returns the last count of active series.
+func (c *CardinalityMonitor) Report() CardinalityReport {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.report
+}
+
+func (c *CardinalityMonitor) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(c.Report()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/prometheus/statsd_exporter/pkg/clock"
+	"github.com/prometheus/statsd_exporter/pkg/event"
+)
+
+// Reasons for events to be dead-lettered.
+const (
+	DeadLetterEmptyMetricName   = "empty_metric_name"
+	DeadLetterNegativeCounter   = "illegal_negative_counter"
+	DeadLetterSuspectIncrement  = "suspect_counter_increment"
+	DeadLetterDigestsDisabled   = "digests_disabled"
+	DeadLetterSetsDisabled      = "sets_disabled"
+	DeadLetterNewSeriesRejected = "new_series_rejected"
+	DeadLetterConflict          = "conflicting_metric"
+)
+
+// DeadLetters keeps the most recent events that could not be applied to the
+// registry, such as events for a metric that exists with another type or
+// label names, so that they can be inspected rather than only being counted.
+type DeadLetters struct {
+	// Rejected counts all dead-lettered events by reason, including the
+	// ones no longer held.
+	Rejected *prometheus.CounterVec
+
+	mutex   sync.Mutex
+	letters []DeadLetter
+	next    int
+}
+
+// DeadLetter is the JSON representation of a dead-lettered event.
+type DeadLetter struct {
+	Time       time.Time         `json:"time"`
+	Reason     string            `json:"reason"`
+	Error      string            `json:"error,omitempty"`
+	StatsDName string            `json:"statsd_name"`
+	MetricName string            `json:"metric_name"`
+	Type       string            `json:"type"`
+	Value      float64           `json:"value"`
+	Labels     map[string]string `json:"labels"`
+}
+
+// NewDeadLetters returns a buffer of the size most recently dead-lettered
+// events.
+func NewDeadLetters(size int, rejected *prometheus.CounterVec) *DeadLetters {
+	return &DeadLetters{
+		Rejected: rejected,
+		letters:  make([]DeadLetter, 0, size),
+	}
+}
+
+func (d *DeadLetters) add(reason string, e event.Event, metricName string, labels prometheus.Labels, err error) {
+	letter := DeadLetter{
+		Time:       clock.Now(),
+		Reason:     reason,
+		StatsDName: e.MetricName(),
+		MetricName: metricName,
+		Type:       string(e.MetricType()),
+		Value:      e.Value(),
+		Labels:     maps.Clone(labels),
+	}
+	if err != nil {
+		letter.Error = err.Error()
+	}
+	if d.Rejected != nil {
+		d.Rejected.WithLabelValues(reason).Inc()
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if cap(d.letters) == 0 {
+		return
+	}
+	if len(d.letters) < cap(d.letters) {
+		d.letters = append(d.letters, letter)
+		return
+	}
+	d.letters[d.next] = letter
+	d.next = (d.next + 1) % len(d.letters)
+}
+
+// Letters returns the held events, oldest first.
+func (d *DeadLetters) Letters() []DeadLetter {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	letters := make([]DeadLetter, 0, len(d.letters))
+	letters = append(letters, d.letters[d.next:]...)
+	return append(letters, d.letters[:d.next]...)
+}
+
+// ServeHTTP writes the held events as JSON. The optional "reason" query
+// parameter restricts the output to events dead-lettered for that reason.
+func (d *DeadLetters) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	letters := d.Letters()
+	if reason := r.URL.Query().Get("reason"); reason != "" {
+		filtered := letters[:0]
+		for _, l := range letters {
+			if l.Reason == reason {
+				filtered = append(filtered, l)
+			}
+		}
+		letters = filtered
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(letters); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
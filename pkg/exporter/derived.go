@@ -0,0 +1,111 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/prometheus/statsd_exporter/pkg/registry"
+)
+
+// DerivedCollector exposes the derived metrics of the mapping configuration.
+// Their values are recomputed by the Exporter every Interval, and collected
+// from the last computation.
+type DerivedCollector struct {
+	Interval time.Duration
+
+	mtx     sync.Mutex
+	metrics []prometheus.Metric
+}
+
+// Describe yields no descriptions, since derived metrics change with the
+// configuration.
+func (c *DerivedCollector) Describe(_ chan<- *prometheus.Desc) {}
+
+func (c *DerivedCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	for _, m := range c.metrics {
+		ch <- m
+	}
+}
+
+func (c *DerivedCollector) set(metrics []prometheus.Metric) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.metrics = metrics
+}
+
+// derivedGroup is the sum of the series of an operand that share the values
+// of the labels a derived metric keeps.
+type derivedGroup struct {
+	labelValues []string
+	value       float64
+}
+
+func sumBy(samples []registry.Sample, by []string) map[string]*derivedGroup {
+	groups := map[string]*derivedGroup{}
+	for _, s := range samples {
+		labelValues := make([]string, len(by))
+		for i, label := range by {
+			labelValues[i] = s.Labels[label]
+		}
+		key := strings.Join(labelValues, "\xff")
+		g, ok := groups[key]
+		if !ok {
+			g = &derivedGroup{labelValues: labelValues}
+			groups[key] = g
+		}
+		g.value += s.Value
+	}
+	return groups
+}
+
+// updateDerivedMetrics recomputes all derived metrics. Series are only
+// derived for label values that both operands have, and for which the result
+// is defined.
+func (b *Exporter) updateDerivedMetrics() {
+	var metrics []prometheus.Metric
+	for _, d := range b.Mapper.GetDerivedMetrics() {
+		help := d.Help
+		if help == "" {
+			help = "Metric derived by statsd_exporter."
+		}
+		desc := prometheus.NewDesc(d.Name, help, d.By, nil)
+
+		left := sumBy(b.Registry.Values(d.Left), d.By)
+		right := sumBy(b.Registry.Values(d.Right), d.By)
+		for key, l := range left {
+			r, ok := right[key]
+			if !ok {
+				continue
+			}
+			value, ok := d.Op.Apply(l.value, r.value)
+			if !ok {
+				continue
+			}
+			m, err := prometheus.NewConstMetric(desc, prometheus.GaugeValue, value, l.labelValues...)
+			if err != nil {
+				b.Logger.Debug("Failed to derive metric", "metric", d.Name, "error", err)
+				continue
+			}
+			metrics = append(metrics, m)
+		}
+	}
+	b.Derived.set(metrics)
+}
@@ -0,0 +1,199 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/prometheus/statsd_exporter/pkg/clock"
+)
+
+// DefaultDigestQuantiles are the quantiles exported for digests, unless
+// configured otherwise.
+var DefaultDigestQuantiles = []float64{0.5, 0.9, 0.99}
+
+// DigestCollector keeps a t-digest per series of observers with the observer
+// type "digest". Every Interval, the Exporter exports the quantiles of the
+// digests as gauges and starts new ones, so the gauges describe the values
+// observed in the last interval. The digests themselves are served as JSON,
+// to be merged into quantiles across instances.
+type DigestCollector struct {
+	Interval  time.Duration
+	Quantiles []float64
+
+	// current is only accessed by the Exporter.
+	current     map[string]*digestSeries
+	windowStart time.Time
+
+	mtx     sync.Mutex
+	metrics []prometheus.Metric
+	report  DigestReport
+}
+
+type digestSeries struct {
+	name   string
+	help   string
+	labels map[string]string
+	digest *tDigest
+}
+
+// DigestReport is the JSON representation of the digests of an interval.
+type DigestReport struct {
+	Start   time.Time      `json:"start"`
+	End     time.Time      `json:"end"`
+	Digests []DigestSeries `json:"digests"`
+}
+
+// DigestSeries is the digest of the values observed for a series.
+type DigestSeries struct {
+	Name        string            `json:"name"`
+	Labels      map[string]string `json:"labels"`
+	Compression float64           `json:"compression"`
+	Count       float64           `json:"count"`
+	Min         float64           `json:"min"`
+	Max         float64           `json:"max"`
+	Centroids   []Centroid        `json:"centroids"`
+}
+
+func NewDigestCollector(interval time.Duration) *DigestCollector {
+	return &DigestCollector{
+		Interval:    interval,
+		Quantiles:   DefaultDigestQuantiles,
+		current:     make(map[string]*digestSeries),
+		windowStart: clock.Now(),
+		report:      DigestReport{Digests: []DigestSeries{}},
+	}
+}
+
+// Describe yields no descriptions, since digests change with the observed
+// metrics.
+func (c *DigestCollector) Describe(_ chan<- *prometheus.Desc) {}
+
+func (c *DigestCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	for _, m := range c.metrics {
+		ch <- m
+	}
+}
+
+func digestKey(metricName string, labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString(metricName)
+	for _, name := range names {
+		b.WriteByte('\xff')
+		b.WriteString(name)
+		b.WriteByte('\xff')
+		b.WriteString(labels[name])
+	}
+	return b.String()
+}
+
+// observe adds a value to the digest of a series.
+func (c *DigestCollector) observe(metricName string, labels map[string]string, help string, value float64, weight uint64) {
+	key := digestKey(metricName, labels)
+	s, ok := c.current[key]
+	if !ok {
+		s = &digestSeries{
+			name:   metricName,
+			help:   help,
+			labels: labels,
+			digest: newTDigest(defaultCompression),
+		}
+		c.current[key] = s
+	}
+	s.digest.AddWeighted(value, float64(weight))
+}
+
+// rotate exports the quantiles and digests of the current interval, and
+// starts a new one.
+func (c *DigestCollector) rotate() {
+	now := clock.Now()
+	report := DigestReport{Start: c.windowStart, End: now, Digests: []DigestSeries{}}
+	metrics := make([]prometheus.Metric, 0, len(c.current)*len(c.Quantiles))
+	for _, s := range c.current {
+		labelNames := make([]string, 0, len(s.labels)+1)
+		labelValues := make([]string, 0, len(s.labels)+1)
+		for name, value := range s.labels {
+			labelNames = append(labelNames, name)
+			labelValues = append(labelValues, value)
+		}
+		desc := prometheus.NewDesc(s.name, s.help, append(labelNames, "quantile"), nil)
+		for _, q := range c.Quantiles {
+			m, err := prometheus.NewConstMetric(desc, prometheus.GaugeValue, s.digest.Quantile(q), append(labelValues, strconv.FormatFloat(q, 'g', -1, 64))...)
+			if err != nil {
+				continue
+			}
+			metrics = append(metrics, m)
+		}
+
+		report.Digests = append(report.Digests, DigestSeries{
+			Name:        s.name,
+			Labels:      s.labels,
+			Compression: s.digest.compression,
+			Count:       s.digest.count,
+			Min:         s.digest.min,
+			Max:         s.digest.max,
+			Centroids:   s.digest.Centroids(),
+		})
+	}
+	sort.Slice(report.Digests, func(i, j int) bool { return report.Digests[i].Name < report.Digests[j].Name })
+
+	c.current = make(map[string]*digestSeries, len(c.current))
+	c.windowStart = now
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.metrics = metrics
+	c.report = report
+}
+
+// ServeHTTP writes the digests of the last completed interval as JSON. The
+// optional "metric" query parameter restricts the output to a single metric.
+func (c *DigestCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mtx.Lock()
+	report := c.report
+	c.mtx.Unlock()
+
+	if metric := r.URL.Query().Get("metric"); metric != "" {
+		filtered := []DigestSeries{}
+		for _, d := range report.Digests {
+			if d.Name == metric {
+				filtered = append(filtered, d)
+			}
+		}
+		report.Digests = filtered
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
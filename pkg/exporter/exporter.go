@@ -14,15 +14,21 @@
 package exporter
 
 import (
+	"context"
+	"errors"
 	"log/slog"
 	"os"
+	"runtime/trace"
+	"slices"
 	"time"
+	"unicode/utf8"
 
 	"github.com/prometheus/client_golang/prometheus"
 
 	"github.com/prometheus/statsd_exporter/pkg/clock"
 	"github.com/prometheus/statsd_exporter/pkg/event"
 	"github.com/prometheus/statsd_exporter/pkg/mapper"
+	"github.com/prometheus/statsd_exporter/pkg/metrics"
 	"github.com/prometheus/statsd_exporter/pkg/registry"
 )
 
@@ -37,6 +43,15 @@ type Registry interface {
 	GetHistogram(metricName string, labels prometheus.Labels, help string, mapping *mapper.MetricMapping, metricsCount *prometheus.GaugeVec) (prometheus.Observer, error)
 	GetSummary(metricName string, labels prometheus.Labels, help string, mapping *mapper.MetricMapping, metricsCount *prometheus.GaugeVec) (prometheus.Observer, error)
 	RemoveStaleMetrics()
+	RemoveSparseMetrics(scrapes []time.Time)
+	DelayExpiry(d time.Duration)
+	Presize(metricNames, series int)
+	Size() (metricNames, series int)
+	ActiveSince(t time.Time) (metricNames, series int)
+	LabelCardinality(t time.Time) []registry.LabelCardinality
+	Values(metricName string) []registry.Sample
+	RejectNewSeries(reject bool)
+	SetConstLabels(labels prometheus.Labels)
 }
 
 type Exporter struct {
@@ -49,37 +64,220 @@ type Exporter struct {
 	EventStats            *prometheus.CounterVec
 	ConflictingEventStats *prometheus.CounterVec
 	MetricsCount          *prometheus.GaugeVec
+	// MagnitudeTracker, if set, records the magnitude of observed values.
+	MagnitudeTracker *MagnitudeTracker
+	// IngestRates, if set, estimates the rate of samples per metric family.
+	IngestRates *RateTracker
+	// GaugeChanges, if set, counts gauge events that changed the gauge value.
+	GaugeChanges prometheus.Counter
+	// RelativeGauges, if set, counts relative changes applied to gauges.
+	RelativeGauges prometheus.Counter
+	// SkipUnchangedGauges skips setting a gauge to the value it already has.
+	SkipUnchangedGauges bool
+	// ClockJumpThreshold, if set, is the minimum wall clock step or stall
+	// that is reported. Metric expiry is delayed by the length of a stall.
+	ClockJumpThreshold time.Duration
+	// ClockJumps, if set, counts clock jumps by type ("wall" or "stall").
+	ClockJumps *prometheus.CounterVec
+	// LabelHashes, if set, records hashed label values for reverse lookups.
+	LabelHashes *LabelHashTable
+	// WarmupUntil defers expiring metrics and tracking value magnitudes
+	// until the given time, to keep up with the line rate after a restart.
+	WarmupUntil time.Time
+	// SizeHintFile, if set, is periodically updated with the size of the
+	// registry. See ReadSizeHint.
+	SizeHintFile string
+	// Derived, if set, exposes the derived metrics of the mapping
+	// configuration.
+	Derived *DerivedCollector
+	// Latency, if set, is notified of handled latency probes.
+	Latency *LatencyTracker
+	// MemoryLimit, if set, is the heap size in bytes beyond which no new
+	// series are created. MemoryProtection is set to 1 while this is the case.
+	MemoryLimit      uint64
+	MemoryProtection prometheus.Gauge
+	// Cardinality, if set, reports the number of recently updated metric
+	// names and series, and warns about anomalous growth.
+	Cardinality *CardinalityMonitor
+	// Outage, if set, pauses metric expiry while the exporter is not
+	// scraped.
+	Outage *OutageDetector
+	// Scrapes, if set, removes the series of mappings with sparse_scrapes
+	// once they have been exported by the recorded scrapes.
+	Scrapes *ScrapeRecorder
+	// Digests, if set, keeps the t-digests of observers with the observer
+	// type "digest". Without it, their events are counted as errors.
+	Digests *DigestCollector
+	// Quarantine, if set, drops events for new metric names until
+	// they are approved.
+	Quarantine *Quarantine
+	// DeadLetters, if set, keeps the events that could not be applied.
+	DeadLetters *DeadLetters
+	// CounterWindows, if set, is set to the counter window of each metric
+	// name whose increments are accumulated.
+	CounterWindows *prometheus.GaugeVec
+	// MappedInfo, if set, is set to 1 for each metric name and the match
+	// of the mapping rule that produced it, to trace which rule applies
+	// when several could.
+	MappedInfo *prometheus.GaugeVec
+	// SetWindow, if set, is the window over which the unique members of
+	// StatsD sets are counted. Each set is exported as a gauge of the number
+	// of unique members in the last window. Without it, set events are
+	// counted as errors.
+	SetWindow time.Duration
+	// ApplyDuration, if set, observes the time it takes to apply each
+	// event, by metric type.
+	ApplyDuration *prometheus.HistogramVec
+	// SlowApplyThreshold, if set, is the time beyond which applying an
+	// event is logged with its metric name, at most every 10 seconds.
+	SlowApplyThreshold time.Duration
+	// UTF8Names keeps metric names that are valid UTF-8, such as dotted
+	// StatsD names, instead of escaping them to legacy Prometheus names.
+	// Scrapers that don't accept UTF-8 names still receive escaped names.
+	UTF8Names bool
+	// CappedSampleWeights, if set, counts the sampled observer values that
+	// observers without a notion of weight observed event.MaxSampleWeight
+	// times, rather than as often as the values stand for.
+	CappedSampleWeights prometheus.Counter
+
+	memoryProtected   bool
+	pendingIncrements map[prometheus.Counter]*pendingIncrement
+	sets              map[prometheus.Gauge]map[string]struct{}
+
+	lastSlowApplyLog      time.Time
+	slowAppliesSuppressed int
 }
 
 // Listen handles all events sent to the given channel sequentially. It
 // terminates when the channel is closed.
 func (b *Exporter) Listen(e <-chan event.Events) {
 	removeStaleMetricsTicker := clock.NewTicker(time.Second)
+	jumpDetector := &clock.JumpDetector{Interval: time.Second, Threshold: b.ClockJumpThreshold}
+	var lastSizeHint time.Time
+	if b.SizeHintFile != "" {
+		lastSizeHint = clock.Now()
+	}
+	var derivedTicker <-chan time.Time
+	if b.Derived != nil {
+		t := clock.NewTicker(b.Derived.Interval)
+		defer t.Stop()
+		derivedTicker = t.C
+	}
+	var digestTicker <-chan time.Time
+	if b.Digests != nil {
+		t := clock.NewTicker(b.Digests.Interval)
+		defer t.Stop()
+		digestTicker = t.C
+	}
+	var setTicker <-chan time.Time
+	if b.SetWindow > 0 {
+		t := clock.NewTicker(b.SetWindow)
+		defer t.Stop()
+		setTicker = t.C
+	}
 
 	for {
 		select {
+		case <-derivedTicker:
+			b.updateDerivedMetrics()
+		case <-digestTicker:
+			b.Digests.rotate()
+		case <-setTicker:
+			b.rotateSets()
 		case <-removeStaleMetricsTicker.C:
-			b.Registry.RemoveStaleMetrics()
+			b.flushCounterWindows(false)
+			if b.ClockJumpThreshold > 0 {
+				b.checkClockJumps(jumpDetector)
+			}
+			if b.MemoryLimit > 0 {
+				b.checkMemory()
+			}
+			if b.warmingUp() {
+				continue
+			}
+			if b.Outage == nil || !b.Outage.expiryPaused(b.Registry, b.Logger) {
+				b.Registry.RemoveStaleMetrics()
+			}
+			if b.Scrapes != nil {
+				b.Registry.RemoveSparseMetrics(b.Scrapes.take())
+			}
+			if b.Cardinality != nil {
+				b.Cardinality.update(b.Registry, b.Logger)
+			}
+			if b.SizeHintFile != "" && clock.Now().Sub(lastSizeHint) >= sizeHintInterval {
+				if err := b.writeSizeHint(); err != nil {
+					b.Logger.Warn("Failed to write size hint", "file", b.SizeHintFile, "error", err)
+				}
+				lastSizeHint = clock.Now()
+			}
 		case events, ok := <-e:
 			if !ok {
 				b.Logger.Debug("Channel is closed. Break out of Exporter.Listener.")
+				b.flushCounterWindows(true)
 				removeStaleMetricsTicker.Stop()
 				return
 			}
+			region := trace.StartRegion(context.Background(), event.TraceRegionApply)
 			for _, event := range events {
-				b.handleEvent(event)
+				b.applyEvent(event)
 			}
+			region.End()
+		}
+	}
+}
+
+// checkClockJumps reports wall clock steps and stalls of the exporter. After
+// a stall, metrics that would have been kept alive by samples received in the
+// meantime would all expire at once, so their expiry is delayed instead.
+func (b *Exporter) checkClockJumps(d *clock.JumpDetector) {
+	wallStep, stall := d.Check(clock.Now())
+	if wallStep != 0 {
+		b.Logger.Warn("Wall clock stepped, metric expiry is not affected", "step", wallStep)
+		if b.ClockJumps != nil {
+			b.ClockJumps.WithLabelValues("wall").Inc()
+		}
+	}
+	if stall != 0 {
+		b.Logger.Warn("Exporter stalled, delaying metric expiry", "stall", stall)
+		b.Registry.DelayExpiry(stall)
+		if b.ClockJumps != nil {
+			b.ClockJumps.WithLabelValues("stall").Inc()
 		}
 	}
 }
 
 // handleEvent processes a single Event according to the configured mapping.
 func (b *Exporter) handleEvent(thisEvent event.Event) {
+	if p, ok := thisEvent.(*event.Probe); ok {
+		if b.Latency != nil {
+			b.Latency.Applied(p.ReceivedAt)
+		}
+		return
+	}
+	if m, ok := thisEvent.(*mappedEvent); ok {
+		b.handleMappedEvent(m.Event, m.mapping, m.labels, m.present)
+		return
+	}
 	mapping, labels, present := b.Mapper.GetMapping(thisEvent.MetricName(), thisEvent.MetricType())
+	b.handleMappedEvent(thisEvent, mapping, labels, present)
+}
+
+// handleMappedEvent processes a single Event with the mapping that was found
+// for it.
+func (b *Exporter) handleMappedEvent(thisEvent event.Event, mapping *mapper.MetricMapping, labels prometheus.Labels, present bool) {
+	// The values of events such as packed DogStatsD samples share their
+	// mapping, and are applied one by one.
+	if e, ok := thisEvent.(event.ExpandableEvent); ok {
+		for _, expanded := range e.Expand() {
+			b.handleMappedEvent(expanded, mapping, labels, present)
+		}
+		return
+	}
+
 	if mapping == nil {
-		mapping = &mapper.MetricMapping{}
-		if b.Mapper.Defaults.Ttl != 0 {
-			mapping.Ttl = b.Mapper.Defaults.Ttl
+		mapping = &mapper.MetricMapping{
+			Ttl:          b.Mapper.Defaults.TtlFor(thisEvent.MetricType()),
+			RenameLabels: b.Mapper.Defaults.RenameLabels,
 		}
 	}
 
@@ -96,13 +294,18 @@ func (b *Exporter) handleEvent(thisEvent event.Event) {
 	}
 
 	prometheusLabels := thisEvent.Labels()
+	renameLabels(prometheusLabels, mapping.RenameLabels)
 	if present {
 		if mapping.Name == "" {
 			b.Logger.Debug("The mapping generates an empty metric name", "metric_name", thisEvent.MetricName(), "match", mapping.Match)
 			b.ErrorEventStats.WithLabelValues("empty_metric_name").Inc()
+			b.deadLetter(DeadLetterEmptyMetricName, thisEvent, "", prometheusLabels, nil)
 			return
 		}
-		metricName = mapper.EscapeMetricName(mapping.Name)
+		metricName = b.metricName(mapping.Name)
+		if b.MappedInfo != nil {
+			b.MappedInfo.WithLabelValues(metricName, mapping.Match).Set(1)
+		}
 		for label, value := range labels {
 			if _, ok := prometheusLabels[label]; mapping.HonorLabels && ok {
 				continue
@@ -113,10 +316,38 @@ func (b *Exporter) handleEvent(thisEvent event.Event) {
 		b.EventsActions.WithLabelValues(string(mapping.Action)).Inc()
 	} else {
 		b.EventsUnmapped.Inc()
-		metricName = mapper.EscapeMetricName(thisEvent.MetricName())
+		metricName = b.metricName(thisEvent.MetricName())
+	}
+
+	for _, label := range mapping.DropLabels {
+		delete(prometheusLabels, label)
+	}
+
+	for _, label := range mapping.HashLabels {
+		value, ok := prometheusLabels[label]
+		if !ok {
+			continue
+		}
+		if b.LabelHashes != nil {
+			prometheusLabels[label] = b.LabelHashes.Hash(label, value)
+		} else {
+			prometheusLabels[label] = hashLabelValue(value)
+		}
+	}
+
+	if b.Quarantine != nil && !b.Quarantine.admit(metricName) {
+		b.Logger.Debug("Dropping event for quarantined metric", "metric", metricName)
+		return
+	}
+
+	if b.IngestRates != nil {
+		b.IngestRates.Observe(metricName)
 	}
 
 	eventValue := thisEvent.Value()
+	if event.ObserverKindOf(thisEvent) != event.ObserverKindTimer && b.histogramUnit(mapping) == mapper.HistogramUnitMilliseconds {
+		eventValue /= 1000
+	}
 	if mapping.Scale.Set {
 		eventValue *= mapping.Scale.Val
 	}
@@ -128,72 +359,308 @@ func (b *Exporter) handleEvent(thisEvent event.Event) {
 		if eventValue < 0.0 {
 			b.Logger.Debug("counter must be non-negative value", "metric", metricName, "event_value", eventValue)
 			b.ErrorEventStats.WithLabelValues("illegal_negative_counter").Inc()
+			b.deadLetter(DeadLetterNegativeCounter, thisEvent, metricName, prometheusLabels, nil)
+			return
+		}
+		// Increments beyond the maximum of the mapping are more likely to
+		// come from a broken client than from real activity.
+		if mapping.MaxIncrement.Set && eventValue > mapping.MaxIncrement.Val {
+			b.Logger.Debug("Counter increment exceeds the maximum of the mapping", "metric", metricName, "event_value", eventValue, "max_increment", mapping.MaxIncrement.Val)
+			b.ErrorEventStats.WithLabelValues("suspect_counter_increment").Inc()
+			b.deadLetter(DeadLetterSuspectIncrement, thisEvent, metricName, prometheusLabels, nil)
 			return
 		}
 
 		counter, err := b.Registry.GetCounter(metricName, prometheusLabels, help, mapping, b.MetricsCount)
 		if err == nil {
-			counter.Add(eventValue)
+			b.addCounter(counter, metricName, mapping, eventValue)
 			b.EventStats.WithLabelValues("counter").Inc()
 		} else {
-			b.Logger.Debug(regErrF, "metric", metricName, "error", err)
-			b.ConflictingEventStats.WithLabelValues("counter", metricName).Inc()
+			b.registryError(thisEvent, "counter", metricName, prometheusLabels, err)
 		}
 
 	case *event.GaugeEvent:
 		gauge, err := b.Registry.GetGauge(metricName, prometheusLabels, help, mapping, b.MetricsCount)
 
 		if err == nil {
-			if ev.GRelative {
+			if ev.Relative() {
+				if b.RelativeGauges != nil {
+					b.RelativeGauges.Inc()
+				}
+				if eventValue != 0 && b.GaugeChanges != nil {
+					b.GaugeChanges.Inc()
+				}
 				gauge.Add(eventValue)
 			} else {
-				gauge.Set(eventValue)
+				changed := true
+				if tg, ok := gauge.(*metrics.TrackedGauge); ok {
+					changed = tg.Changed(eventValue)
+				}
+				if changed && b.GaugeChanges != nil {
+					b.GaugeChanges.Inc()
+				}
+				if changed || !b.SkipUnchangedGauges {
+					gauge.Set(eventValue)
+				}
 			}
 			b.EventStats.WithLabelValues("gauge").Inc()
 		} else {
-			b.Logger.Debug(regErrF, "metric", metricName, "error", err)
-			b.ConflictingEventStats.WithLabelValues("gauge", metricName).Inc()
+			b.registryError(thisEvent, "gauge", metricName, prometheusLabels, err)
 		}
 
 	case *event.ObserverEvent:
-		t := mapper.ObserverTypeDefault
-		if mapping != nil {
-			t = mapping.ObserverType
-		}
-		if t == mapper.ObserverTypeDefault {
-			t = b.Mapper.Defaults.ObserverType
+		t := b.observerType(mapping, thisEvent)
+		weight := event.SampleWeightOf(thisEvent)
+
+		if b.MagnitudeTracker != nil && !b.warmingUp() {
+			b.MagnitudeTracker.Observe(metricName, eventValue)
 		}
 
 		switch t {
 		case mapper.ObserverTypeHistogram:
 			histogram, err := b.Registry.GetHistogram(metricName, prometheusLabels, help, mapping, b.MetricsCount)
 			if err == nil {
-				histogram.Observe(eventValue)
+				b.observeWeighted(histogram, eventValue, weight)
 				b.EventStats.WithLabelValues("observer").Inc()
 			} else {
-				b.Logger.Debug(regErrF, "metric", metricName, "error", err)
-				b.ConflictingEventStats.WithLabelValues("observer", metricName).Inc()
+				b.registryError(thisEvent, "observer", metricName, prometheusLabels, err)
 			}
 
 		case mapper.ObserverTypeDefault, mapper.ObserverTypeSummary:
 			summary, err := b.Registry.GetSummary(metricName, prometheusLabels, help, mapping, b.MetricsCount)
 			if err == nil {
-				summary.Observe(eventValue)
+				b.observeWeighted(summary, eventValue, weight)
 				b.EventStats.WithLabelValues("observer").Inc()
 			} else {
-				b.Logger.Debug(regErrF, "metric", metricName, "error", err)
-				b.ConflictingEventStats.WithLabelValues("observer", metricName).Inc()
+				b.registryError(thisEvent, "observer", metricName, prometheusLabels, err)
+			}
+
+		case mapper.ObserverTypeBoth:
+			histogram, err := b.Registry.GetHistogram(metricName, prometheusLabels, help, mapping, b.MetricsCount)
+			if err != nil {
+				b.registryError(thisEvent, "observer", metricName, prometheusLabels, err)
+				break
+			}
+			summary, err := b.Registry.GetSummary(metricName+mapper.SummarySuffix, prometheusLabels, help, mapping, b.MetricsCount)
+			if err != nil {
+				b.registryError(thisEvent, "observer", metricName+mapper.SummarySuffix, prometheusLabels, err)
+				break
 			}
+			b.observeWeighted(histogram, eventValue, weight)
+			b.observeWeighted(summary, eventValue, weight)
+			b.EventStats.WithLabelValues("observer").Inc()
+
+		case mapper.ObserverTypeDigest:
+			if b.Digests == nil {
+				b.Logger.Debug("Digests are not enabled", "metric", metricName)
+				b.ErrorEventStats.WithLabelValues("digests_disabled").Inc()
+				b.deadLetter(DeadLetterDigestsDisabled, thisEvent, metricName, prometheusLabels, nil)
+				break
+			}
+			b.Digests.observe(metricName, prometheusLabels, help, eventValue, weight)
+			b.EventStats.WithLabelValues("observer").Inc()
 
 		default:
 			b.Logger.Error("unknown observer type", "type", t)
 			os.Exit(1)
 		}
 
+	case *event.SetEvent:
+		if b.SetWindow <= 0 {
+			b.Logger.Debug("Sets are not enabled", "metric", metricName)
+			b.ErrorEventStats.WithLabelValues("sets_disabled").Inc()
+			b.deadLetter(DeadLetterSetsDisabled, thisEvent, metricName, prometheusLabels, nil)
+			break
+		}
+		gauge, err := b.Registry.GetGauge(metricName, prometheusLabels, help, mapping, b.MetricsCount)
+		if err == nil {
+			b.addSetMember(gauge, ev.Member())
+			b.EventStats.WithLabelValues("set").Inc()
+		} else {
+			b.registryError(thisEvent, "set", metricName, prometheusLabels, err)
+		}
+
 	default:
 		b.Logger.Debug("Unsupported event type")
 		b.EventStats.WithLabelValues("illegal").Inc()
 	}
+
+	if present && len(mapping.Rollups) > 0 {
+		b.handleRollups(thisEvent, mapping, prometheusLabels, help, eventValue)
+	}
+	if present && len(mapping.PreviousNames) > 0 {
+		b.handlePreviousNames(thisEvent, mapping, prometheusLabels, help, eventValue)
+	}
+}
+
+// registryError accounts for a metric the registry failed to return.
+func (b *Exporter) registryError(thisEvent event.Event, metricType, metricName string, labels prometheus.Labels, err error) {
+	b.Logger.Debug(regErrF, "metric", metricName, "error", err)
+	if errors.Is(err, registry.ErrNewSeriesRejected) {
+		b.ErrorEventStats.WithLabelValues("new_series_rejected").Inc()
+		b.deadLetter(DeadLetterNewSeriesRejected, thisEvent, metricName, labels, err)
+		return
+	}
+	b.ConflictingEventStats.WithLabelValues(metricType, metricName).Inc()
+	b.deadLetter(DeadLetterConflict, thisEvent, metricName, labels, err)
+}
+
+// deadLetter keeps an event that could not be applied, if dead letters are
+// enabled.
+func (b *Exporter) deadLetter(reason string, thisEvent event.Event, metricName string, labels prometheus.Labels, err error) {
+	if b.DeadLetters != nil {
+		b.DeadLetters.add(reason, thisEvent, metricName, labels, err)
+	}
+}
+
+// observerType returns the observer type to use for the values of e with a
+// mapping, falling back to the defaults for the StatsD type of e.
+func (b *Exporter) observerType(mapping *mapper.MetricMapping, e event.Event) mapper.ObserverType {
+	return b.Mapper.Defaults.ObserverTypeFor(mapping, string(event.ObserverKindOf(e)))
+}
+
+// histogramUnit returns the unit of the histogram and distribution values of
+// mapping, which defaults to the unit of the defaults.
+func (b *Exporter) histogramUnit(mapping *mapper.MetricMapping) mapper.HistogramUnit {
+	if mapping.HistogramUnit != mapper.HistogramUnitDefault {
+		return mapping.HistogramUnit
+	}
+	return b.Mapper.Defaults.HistogramUnit
+}
+
+// handleRollups applies an already mapped event to the rollups of its
+// mapping. Each rollup aggregates the metric over all but the dropped labels.
+// Gauges are not rolled up: a set replaces the value of one series, so the
+// values of the series a rollup aggregates cannot be combined.
+func (b *Exporter) handleRollups(thisEvent event.Event, mapping *mapper.MetricMapping, labels prometheus.Labels, help string, value float64) {
+	for _, rollup := range mapping.Rollups {
+		rollupLabels := make(prometheus.Labels, len(labels))
+		for label, labelValue := range labels {
+			if !slices.Contains(rollup.DropLabels, label) {
+				rollupLabels[label] = labelValue
+			}
+		}
+
+		var err error
+		switch thisEvent.(type) {
+		case *event.CounterEvent:
+			var counter prometheus.Counter
+			if counter, err = b.Registry.GetCounter(rollup.Name, rollupLabels, help, mapping, b.MetricsCount); err == nil {
+				b.addCounter(counter, rollup.Name, mapping, value)
+			}
+		case *event.ObserverEvent:
+			err = b.observe(rollup.Name, rollupLabels, help, mapping, thisEvent, value)
+		default:
+			return
+		}
+
+		if err != nil {
+			b.registryError(thisEvent, string(thisEvent.MetricType()), rollup.Name, rollupLabels, err)
+		}
+	}
+}
+
+// metricName returns the name a StatsD metric name or mapped name is
+// exported as.
+func (b *Exporter) metricName(name string) string {
+	if b.UTF8Names && utf8.ValidString(name) {
+		return name
+	}
+	return mapper.EscapeMetricName(name)
+}
+
+// handlePreviousNames applies an already mapped event to the previous names
+// of its mapping that are still exported, with the same labels.
+func (b *Exporter) handlePreviousNames(thisEvent event.Event, mapping *mapper.MetricMapping, labels prometheus.Labels, help string, value float64) {
+	now := clock.Now()
+	for _, previous := range mapping.PreviousNames {
+		if !previous.Active(now) {
+			continue
+		}
+		name := b.metricName(previous.Name)
+
+		var err error
+		switch ev := thisEvent.(type) {
+		case *event.CounterEvent:
+			var counter prometheus.Counter
+			if counter, err = b.Registry.GetCounter(name, labels, help, mapping, b.MetricsCount); err == nil {
+				b.addCounter(counter, name, mapping, value)
+			}
+		case *event.GaugeEvent:
+			var gauge prometheus.Gauge
+			if gauge, err = b.Registry.GetGauge(name, labels, help, mapping, b.MetricsCount); err == nil {
+				if ev.Relative() {
+					gauge.Add(value)
+				} else {
+					gauge.Set(value)
+				}
+			}
+		case *event.ObserverEvent:
+			err = b.observe(name, labels, help, mapping, thisEvent, value)
+		default:
+			return
+		}
+
+		if err != nil {
+			b.registryError(thisEvent, string(thisEvent.MetricType()), name, labels, err)
+		}
+	}
+}
+
+// observe observes value in the observer of the type of mapping with the
+// given name, weighted by the sample rate of e, for rollups and previous
+// names.
+func (b *Exporter) observe(metricName string, labels prometheus.Labels, help string, mapping *mapper.MetricMapping, e event.Event, value float64) error {
+	weight := event.SampleWeightOf(e)
+	var observer prometheus.Observer
+	var err error
+	switch b.observerType(mapping, e) {
+	case mapper.ObserverTypeDigest:
+		if b.Digests != nil {
+			b.Digests.observe(metricName, labels, help, value, weight)
+		}
+		return nil
+	case mapper.ObserverTypeHistogram:
+		observer, err = b.Registry.GetHistogram(metricName, labels, help, mapping, b.MetricsCount)
+	case mapper.ObserverTypeBoth:
+		if observer, err = b.Registry.GetSummary(metricName+mapper.SummarySuffix, labels, help, mapping, b.MetricsCount); err == nil {
+			b.observeWeighted(observer, value, weight)
+			observer, err = b.Registry.GetHistogram(metricName, labels, help, mapping, b.MetricsCount)
+		}
+	default:
+		observer, err = b.Registry.GetSummary(metricName, labels, help, mapping, b.MetricsCount)
+	}
+	if err != nil {
+		return err
+	}
+	b.observeWeighted(observer, value, weight)
+	return nil
+}
+
+// observeWeighted observes value as if it had been received weight times.
+// Histograms with classic buckets apply the weight arithmetically. Summaries
+// and native histograms have no notion of weight, so the observation is
+// repeated, which unlike a copy of the event per observation allocates
+// nothing. To bound the work of an event, it is repeated at most
+// event.MaxSampleWeight times.
+func (b *Exporter) observeWeighted(observer prometheus.Observer, value float64, weight uint64) {
+	if weight == 1 {
+		observer.Observe(value)
+		return
+	}
+	if w, ok := observer.(metrics.WeightedObserver); ok {
+		w.ObserveWeighted(value, weight)
+		return
+	}
+	if weight > event.MaxSampleWeight {
+		weight = event.MaxSampleWeight
+		if b.CappedSampleWeights != nil {
+			b.CappedSampleWeights.Inc()
+		}
+	}
+	for i := uint64(0); i < weight; i++ {
+		observer.Observe(value)
+	}
 }
 
 func NewExporter(reg prometheus.Registerer, mapper *mapper.MetricMapper, logger *slog.Logger, eventsActions *prometheus.CounterVec, eventsUnmapped prometheus.Counter, errorEventStats *prometheus.CounterVec, eventStats *prometheus.CounterVec, conflictingEventStats *prometheus.CounterVec, metricsCount *prometheus.GaugeVec) *Exporter {
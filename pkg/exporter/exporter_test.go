@@ -14,11 +14,26 @@
 package exporter
 
 import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
 	"fmt"
+	"io"
 	"log/slog"
+	"math"
 	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"runtime/metrics"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
+	"unicode/utf8"
 
 	"github.com/prometheus/client_golang/prometheus"
 	dto "github.com/prometheus/client_model/go"
@@ -182,6 +197,478 @@ func TestNegativeCounter(t *testing.T) {
 	}
 }
 
+// TestMaxIncrement validates that counter increments beyond the maximum of
+// their mapping are dropped and counted.
+func TestMaxIncrement(t *testing.T) {
+	testMapper := mapper.MetricMapper{}
+	config := `mappings:
+- match: requests
+  name: requests_total
+  max_increment: 1e6`
+	if err := testMapper.InitFromYAMLString(config); err != nil {
+		t.Fatalf("Config load error: %s %s", config, err)
+	}
+
+	events := make(chan event.Events)
+	go func() {
+		events <- event.Events{
+			event.NewCounterEvent("requests", 5, map[string]string{}),
+			event.NewCounterEvent("requests", 9999999999, map[string]string{}),
+			event.NewCounterEvent("requests", 1e6, map[string]string{}),
+		}
+		close(events)
+	}()
+
+	errorCounter := errorEventStats.WithLabelValues("suspect_counter_increment")
+	prev := getTelemetryCounterValue(errorCounter)
+
+	reg := prometheus.NewRegistry()
+	ex := NewExporter(reg, &testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+	ex.Listen(events)
+
+	if updated := getTelemetryCounterValue(errorCounter); updated-prev != 1 {
+		t.Fatalf("expected 1 suspect increment, got %v", updated-prev)
+	}
+	metrics, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := getFloat64(metrics, "requests_total", prometheus.Labels{}); v == nil || *v != 1000005 {
+		t.Fatalf("expected the suspect increment to be dropped, got %v", v)
+	}
+}
+
+// TestApplyDuration validates that apply durations are observed by metric type,
+// and that slow applies are logged at most once per interval.
+func TestApplyDuration(t *testing.T) {
+	events := make(chan event.Events)
+	go func() {
+		events <- event.Events{
+			event.NewCounterEvent("requests", 1, map[string]string{}),
+			event.NewCounterEvent("errors", 1, map[string]string{}),
+			event.NewGaugeEvent("connections", 5, false, map[string]string{}),
+		}
+		close(events)
+	}()
+
+	var log bytes.Buffer
+	applyDuration := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "apply_duration"}, []string{"type"})
+	ex := NewExporter(prometheus.NewRegistry(), &mapper.MetricMapper{}, slog.New(slog.NewTextHandler(&log, nil)), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+	ex.ApplyDuration = applyDuration
+	ex.SlowApplyThreshold = time.Nanosecond
+	ex.Listen(events)
+
+	for metricType, expected := range map[string]uint64{"counter": 2, "gauge": 1} {
+		var m dto.Metric
+		if err := applyDuration.WithLabelValues(metricType).(prometheus.Histogram).Write(&m); err != nil {
+			t.Fatal(err)
+		}
+		if n := m.GetHistogram().GetSampleCount(); n != expected {
+			t.Fatalf("expected %d %s applies, got %d", expected, metricType, n)
+		}
+	}
+	if n := strings.Count(log.String(), "Slow event apply"); n != 1 {
+		t.Fatalf("expected 1 slow apply to be logged, got %d:\n%s", n, log.String())
+	}
+	if !strings.Contains(log.String(), "metric=requests") {
+		t.Fatalf("expected the metric name to be logged, got %s", log.String())
+	}
+}
+
+// TestSeedMappingLabels validates that seeded metrics without labels can be
+// mapped by mappings that add labels.
+func TestSeedMappingLabels(t *testing.T) {
+	testMapper := &mapper.MetricMapper{}
+	if err := testMapper.InitFromYAMLString(`mappings:
+- match: seeded.*
+  name: seeded
+  labels:
+    kind: $1
+`); err != nil {
+		t.Fatal(err)
+	}
+	c := make(chan event.Events, 1)
+	h := &event.SeedHandler{
+		Queue:  event.NewEventQueue(c, 1000, time.Hour, prometheus.NewCounter(prometheus.CounterOpts{})),
+		Logger: promslog.NewNopLogger(),
+	}
+	body := `{"metrics": [{"name": "seeded.gauge", "type": "gauge", "value": 1}, {"name": "seeded.counter", "type": "counter", "value": 2}]}`
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/seed", strings.NewReader(body)))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected seed to succeed, got %d: %s", rec.Code, rec.Body)
+	}
+	close(c)
+
+	reg := prometheus.NewRegistry()
+	ex := NewExporter(reg, testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+	ex.Listen(c)
+
+	metrics, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Cannot gather: %v", err)
+	}
+	if v := getFloat64(metrics, "seeded", prometheus.Labels{"kind": "gauge"}); v == nil || *v != 1 {
+		t.Fatalf("expected the seeded gauge to be mapped, got %v", v)
+	}
+}
+
+// TestSampledObserverWeight validates that a sampled value is observed as
+// many times as it stands for, without the event being multiplied.
+func TestSampledObserverWeight(t *testing.T) {
+	events := make(chan event.Events)
+	go func() {
+		events <- event.Events{
+			event.NewSampledObserverEvent("latency", 0.5, 0.01, map[string]string{}),
+			event.NewMultiObserverEvent("latency", []float64{1, 2}, 0.5, map[string]string{}),
+			event.NewObserverEvent("latency", 4, map[string]string{}),
+		}
+		close(events)
+	}()
+
+	reg := prometheus.NewRegistry()
+	ex := NewExporter(reg, &mapper.MetricMapper{}, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+	ex.Listen(events)
+
+	metrics, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Cannot gather from DefaultGatherer: %v", err)
+	}
+	for _, m := range metrics {
+		if m.GetName() != "latency" {
+			continue
+		}
+		summary := m.GetMetric()[0].GetSummary()
+		if n, sum := summary.GetSampleCount(), summary.GetSampleSum(); n != 105 || sum != 60 {
+			t.Fatalf("expected 105 observations with a sum of 60, got %d with a sum of %v", n, sum)
+		}
+		return
+	}
+	t.Fatalf("latency was not exported")
+}
+
+// TestSampledHistogramWeight validates that histograms apply the weight of
+// sampled values arithmetically, and that only summaries cap the weights.
+func TestSampledHistogramWeight(t *testing.T) {
+	testMapper := &mapper.MetricMapper{}
+	if err := testMapper.InitFromYAMLString(`
+defaults:
+  observer_type: both
+  histogram_options:
+    buckets: [1, 10]
+    native_histogram_bucket_factor: 1
+`); err != nil {
+		t.Fatal(err)
+	}
+	events := make(chan event.Events)
+	go func() {
+		events <- event.Events{
+			event.NewSampledObserverEvent("latency", 0.5, 0.01, map[string]string{}),
+			event.NewSampledObserverEvent("latency", 5, 1.0/65536, map[string]string{}),
+			event.NewObserverEvent("latency", 20, map[string]string{}),
+		}
+		close(events)
+	}()
+
+	reg := prometheus.NewRegistry()
+	capped := prometheus.NewCounter(prometheus.CounterOpts{})
+	ex := NewExporter(reg, testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+	ex.CappedSampleWeights = capped
+	ex.Listen(events)
+
+	metrics, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Cannot gather from DefaultGatherer: %v", err)
+	}
+	if v := getTelemetryCounterValue(capped); v != 1 {
+		t.Fatalf("expected 1 capped sample weight, got %v", v)
+	}
+	var histogram *dto.Histogram
+	var summary *dto.Summary
+	for _, m := range metrics {
+		switch m.GetName() {
+		case "latency":
+			histogram = m.GetMetric()[0].GetHistogram()
+		case "latency" + mapper.SummarySuffix:
+			summary = m.GetMetric()[0].GetSummary()
+		}
+	}
+	if histogram == nil || summary == nil {
+		t.Fatalf("latency was not exported")
+	}
+	if n, sum := histogram.GetSampleCount(), histogram.GetSampleSum(); n != 65637 || sum != 327750 {
+		t.Fatalf("expected 65637 observations with a sum of 327750, got %d with a sum of %v", n, sum)
+	}
+	buckets := histogram.GetBucket()
+	if buckets[0].GetCumulativeCount() != 100 || buckets[1].GetCumulativeCount() != 65636 {
+		t.Fatalf("unexpected buckets %v", buckets)
+	}
+	expectedCount := uint64(100 + event.MaxSampleWeight + 1)
+	expectedSum := 50 + 5*float64(event.MaxSampleWeight) + 20
+	if n, sum := summary.GetSampleCount(), summary.GetSampleSum(); n != expectedCount || sum != expectedSum {
+		t.Fatalf("expected %d summary observations with a sum of %v, got %d with a sum of %v", expectedCount, expectedSum, n, sum)
+	}
+}
+
+// TestSparseScrapes validates that the series of mappings with sparse_scrapes
+// are removed once enough scrapes have exported them, and that scrapes which
+// started before an update don't count.
+func TestSparseScrapes(t *testing.T) {
+	clock.ClockInstance = &clock.Clock{Instant: time.Unix(100, 0)}
+	defer func() { clock.ClockInstance = nil }()
+
+	testMapper := mapper.MetricMapper{}
+	config := `mappings:
+- match: batch.*
+  name: batch_records
+  sparse_scrapes: 2
+  labels:
+    job: $1`
+	if err := testMapper.InitFromYAMLString(config); err != nil {
+		t.Fatalf("Config load error: %s %s", config, err)
+	}
+
+	reg := prometheus.NewRegistry()
+	ex := NewExporter(reg, &testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+	ex.Scrapes = &ScrapeRecorder{}
+	ex.handleEvent(event.NewCounterEvent("batch.import", 5, map[string]string{}))
+	ex.handleEvent(event.NewCounterEvent("requests", 1, map[string]string{}))
+
+	exported := func(name string, labels prometheus.Labels) bool {
+		metrics, err := reg.Gather()
+		if err != nil {
+			t.Fatalf("Cannot gather from DefaultGatherer: %v", err)
+		}
+		return getFloat64(metrics, name, labels) != nil
+	}
+	scrape := func(start int64) {
+		ex.Scrapes.Scraped(time.Unix(start, 0))
+		ex.Registry.RemoveSparseMetrics(ex.Scrapes.take())
+	}
+
+	scrape(99)
+	scrape(101)
+	if !exported("batch_records", prometheus.Labels{"job": "import"}) {
+		t.Fatalf("expected batch_records to be exported after a single scrape")
+	}
+
+	// An update starts the count again.
+	clock.ClockInstance.Instant = time.Unix(102, 0)
+	ex.handleEvent(event.NewCounterEvent("batch.import", 1, map[string]string{}))
+	scrape(103)
+	if !exported("batch_records", prometheus.Labels{"job": "import"}) {
+		t.Fatalf("expected batch_records to be exported after an update")
+	}
+	scrape(104)
+	if exported("batch_records", prometheus.Labels{"job": "import"}) {
+		t.Fatalf("expected batch_records to be removed after two scrapes")
+	}
+	if !exported("requests", prometheus.Labels{}) {
+		t.Fatalf("expected requests without sparse_scrapes to be kept")
+	}
+}
+
+// TestHistogramUnit validates that histogram values are converted from
+// milliseconds according to the defaults and mappings, and timers are not.
+func TestHistogramUnit(t *testing.T) {
+	testMapper := mapper.MetricMapper{}
+	config := `defaults:
+  histogram_unit: milliseconds
+mappings:
+- match: payload.*
+  name: payload_bytes
+  histogram_unit: base
+  labels:
+    handler: $1`
+	if err := testMapper.InitFromYAMLString(config); err != nil {
+		t.Fatalf("Config load error: %s %s", config, err)
+	}
+
+	histogram := func(name string, value float64) event.Event {
+		return event.NewObserverEvent(name, value, map[string]string{}).WithObserverKind(event.ObserverKindHistogram)
+	}
+	events := make(chan event.Events)
+	go func() {
+		events <- event.Events{
+			histogram("latency", 250),
+			event.NewObserverEvent("timer", 0.5, map[string]string{}),
+			histogram("payload.upload", 1024),
+		}
+		close(events)
+	}()
+
+	reg := prometheus.NewRegistry()
+	ex := NewExporter(reg, &testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+	ex.Listen(events)
+
+	metrics, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Cannot gather from DefaultGatherer: %v", err)
+	}
+	for name, expected := range map[string]float64{"latency": 0.25, "timer": 0.5, "payload_bytes": 1024} {
+		found := false
+		for _, m := range metrics {
+			if m.GetName() == name {
+				found = true
+				if sum := m.GetMetric()[0].GetSummary().GetSampleSum(); sum != expected {
+					t.Fatalf("expected %s to observe %v, got %v", name, expected, sum)
+				}
+			}
+		}
+		if !found {
+			t.Fatalf("%s was not exported", name)
+		}
+	}
+}
+
+// TestObserverTypeByKind validates that the defaults for the StatsD type of
+// observed values apply, unless a mapping sets an observer type.
+func TestObserverTypeByKind(t *testing.T) {
+	testMapper := mapper.MetricMapper{}
+	config := `defaults:
+  observer_type: summary
+  histogram_observer_type: histogram
+mappings:
+- match: explicit.*
+  name: explicit_${1}
+  observer_type: summary
+- match: inherited.*
+  name: inherited_${1}`
+	if err := testMapper.InitFromYAMLString(config); err != nil {
+		t.Fatalf("Config load error: %s %s", config, err)
+	}
+
+	kinded := func(name string, kind event.ObserverKind) event.Event {
+		return event.NewObserverEvent(name, 1, map[string]string{}).WithObserverKind(kind)
+	}
+	events := make(chan event.Events)
+	go func() {
+		events <- event.Events{
+			kinded("explicit.h", event.ObserverKindHistogram),
+			kinded("inherited.h", event.ObserverKindHistogram),
+			kinded("inherited.d", event.ObserverKindDistribution),
+			event.NewObserverEvent("inherited.ms", 1, map[string]string{}),
+			kinded("unmapped_h", event.ObserverKindHistogram),
+		}
+		close(events)
+	}()
+
+	reg := prometheus.NewRegistry()
+	ex := NewExporter(reg, &testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+	ex.Listen(events)
+
+	metrics, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Cannot gather from DefaultGatherer: %v", err)
+	}
+	expected := map[string]dto.MetricType{
+		"explicit_h":   dto.MetricType_SUMMARY,
+		"inherited_h":  dto.MetricType_HISTOGRAM,
+		"inherited_d":  dto.MetricType_SUMMARY,
+		"inherited_ms": dto.MetricType_SUMMARY,
+		"unmapped_h":   dto.MetricType_HISTOGRAM,
+	}
+	for _, m := range metrics {
+		if want, ok := expected[m.GetName()]; ok {
+			if m.GetType() != want {
+				t.Fatalf("expected %s to be a %s, got %s", m.GetName(), want, m.GetType())
+			}
+			delete(expected, m.GetName())
+		}
+	}
+	if len(expected) > 0 {
+		t.Fatalf("metrics were not exported: %v", expected)
+	}
+}
+
+// TestMappedInfo validates that the rule producing each metric is traced, and
+// that unmapped metrics are not.
+func TestMappedInfo(t *testing.T) {
+	testMapper := mapper.MetricMapper{}
+	config := `mappings:
+- match: api.*.requests
+  name: requests_total
+- match: api.*.*
+  name: api_${2}`
+	if err := testMapper.InitFromYAMLString(config); err != nil {
+		t.Fatalf("Config load error: %s %s", config, err)
+	}
+
+	events := make(chan event.Events)
+	go func() {
+		events <- event.Events{
+			event.NewCounterEvent("api.users.requests", 1, map[string]string{}),
+			event.NewCounterEvent("api.users.errors", 1, map[string]string{}),
+			event.NewCounterEvent("unmapped", 1, map[string]string{}),
+		}
+		close(events)
+	}()
+
+	mappedInfo := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "mapped_info"}, []string{"metric", "rule"})
+	reg := prometheus.NewRegistry()
+	ex := NewExporter(reg, &testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+	ex.MappedInfo = mappedInfo
+	ex.Listen(events)
+
+	infoReg := prometheus.NewRegistry()
+	infoReg.MustRegister(mappedInfo)
+	metrics, err := infoReg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for metric, rule := range map[string]string{"requests_total": "api.*.requests", "api_errors": "api.*.*"} {
+		if v := getFloat64(metrics, "mapped_info", prometheus.Labels{"metric": metric, "rule": rule}); v == nil || *v != 1 {
+			t.Fatalf("expected %s to be traced to %s, got %v", metric, rule, v)
+		}
+	}
+	if n := len(metrics[0].Metric); n != 2 {
+		t.Fatalf("expected 2 traced metrics, got %d", n)
+	}
+}
+
+// TestRenameLabels validates that labels are renamed by the defaults and the
+// mapping, and that labels already present under the new name are kept.
+func TestRenameLabels(t *testing.T) {
+	testMapper := mapper.MetricMapper{}
+	config := `defaults:
+  rename_labels:
+    svc: service
+    dc: datacenter
+mappings:
+- match: requests
+  name: requests_total
+  rename_labels:
+    dc: region`
+	if err := testMapper.InitFromYAMLString(config); err != nil {
+		t.Fatalf("Config load error: %s %s", config, err)
+	}
+
+	events := make(chan event.Events)
+	go func() {
+		events <- event.Events{
+			event.NewCounterEvent("requests", 1, map[string]string{"svc": "api", "dc": "eu"}),
+			event.NewCounterEvent("errors", 2, map[string]string{"svc": "api", "dc": "eu"}),
+			event.NewCounterEvent("errors", 4, map[string]string{"svc": "web", "service": "api", "dc": "eu"}),
+		}
+		close(events)
+	}()
+
+	reg := prometheus.NewRegistry()
+	ex := NewExporter(reg, &testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+	ex.Listen(events)
+
+	metrics, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := getFloat64(metrics, "requests_total", prometheus.Labels{"service": "api", "region": "eu"}); v == nil || *v != 1 {
+		t.Fatalf("expected the renames of the mapping to take precedence, got %v", v)
+	}
+	if v := getFloat64(metrics, "errors", prometheus.Labels{"service": "api", "datacenter": "eu"}); v == nil || *v != 6 {
+		t.Fatalf("expected unmapped metrics to be renamed by the defaults, got %v", v)
+	}
+}
+
 // TestInconsistentLabelSets validates that the exporter will register
 // and record metrics with the same metric name but inconsistent label
 // sets e.g foo{a="1"} and foo{b="1"}
@@ -734,7 +1221,7 @@ func TestInvalidUtf8InDatadogTagValue(t *testing.T) {
 	parser.EnableSignalFXParsing()
 
 	go func() {
-		for _, l := range []statsDPacketHandler{&listener.StatsDUDPListener{
+		for _, l := range []listener.PacketHandler{&listener.StatsDUDPListener{
 			Conn:            nil,
 			EventHandler:    nil,
 			Logger:          promslog.NewNopLogger(),
@@ -821,6 +1308,74 @@ func TestSummaryWithQuantilesEmptyMapping(t *testing.T) {
 	}
 }
 
+func TestUTF8Names(t *testing.T) {
+	testMapper := mapper.MetricMapper{UTF8Names: true}
+	config := `mappings:
+- match: "api.*.requests"
+  name: "http.requests"
+  labels:
+    service.name: "$1"`
+	if err := testMapper.InitFromYAMLString(config); err != nil {
+		t.Fatalf("Config load error: %s %s", config, err)
+	}
+
+	events := make(chan event.Events)
+	go func() {
+		events <- event.Events{
+			event.NewCounterEvent("api.users.requests", 1, map[string]string{}),
+			event.NewGaugeEvent("queue.depth", 3, false, map[string]string{"région": "eu"}),
+			event.NewGaugeEvent("bad\xffname", 1, false, map[string]string{}),
+		}
+		close(events)
+	}()
+
+	reg := prometheus.NewRegistry()
+	ex := NewExporter(reg, &testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+	ex.UTF8Names = true
+	ex.Listen(events)
+
+	metrics, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Cannot gather from DefaultGatherer: %v", err)
+	}
+	if v := getFloat64(metrics, "http.requests", prometheus.Labels{"service.name": "users"}); v == nil || *v != 1 {
+		t.Fatalf("expected http.requests to be 1, got %v", v)
+	}
+	if v := getFloat64(metrics, "queue.depth", prometheus.Labels{"région": "eu"}); v == nil || *v != 3 {
+		t.Fatalf("expected queue.depth to be 3, got %v", v)
+	}
+	// Names that are not valid UTF-8 are still escaped.
+	if len(metrics) != 3 {
+		t.Fatalf("expected 3 metrics, got %d", len(metrics))
+	}
+	for _, m := range metrics {
+		if !utf8.ValidString(m.GetName()) {
+			t.Fatalf("expected invalid name to be escaped, got %q", m.GetName())
+		}
+	}
+
+	h := &ScrapeHandler{
+		Gatherer:       reg,
+		SlowScrapes:    prometheus.NewCounter(prometheus.CounterOpts{}),
+		AbortedScrapes: prometheus.NewCounter(prometheus.CounterOpts{}),
+		Logger:         promslog.NewNopLogger(),
+	}
+	for accept, expected := range map[string]string{
+		"": `queue_depth{r_gion="eu"} 3`,
+		"text/plain;version=1.0.0;escaping=allow-utf-8": `{"queue.depth","région"="eu"} 3`,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if !strings.Contains(rec.Body.String(), expected) {
+			t.Fatalf("expected %q in the exposition for %q, got %s", expected, accept, rec.Body)
+		}
+	}
+}
+
 func TestHistogramUnits(t *testing.T) {
 	// Start exporter with a synchronous channel
 	events := make(chan event.Events)
@@ -955,109 +1510,1236 @@ func TestGaugeIncrementDecrement(t *testing.T) {
 	}
 }
 
-func TestScaledMapping(t *testing.T) {
-	events := make(chan event.Events)
-	testMapper := mapper.MetricMapper{}
-	config := `mappings:
-- match: foo.processed_kilobytes
-  name: processed_bytes
-  scale: 1024
-  labels:
-    service: foo`
-	err := testMapper.InitFromYAMLString(config)
-	if err != nil {
-		t.Fatalf("Config load error: %s %s", config, err)
-	}
+// TestSkipUnchangedGauges validates that gauge changes are counted and
+// unchanged sets are skipped.
+func TestSkipUnchangedGauges(t *testing.T) {
+	gaugeChanges := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "statsd_exporter_gauge_changes_total",
+		Help: "The total number of gauge events that changed the value of a gauge.",
+	})
 
-	// Start exporter with a synchronous channel
+	events := make(chan event.Events)
 	go func() {
+		testMapper := mapper.MetricMapper{}
 		ex := NewExporter(prometheus.DefaultRegisterer, &testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+		ex.GaugeChanges = gaugeChanges
+		ex.SkipUnchangedGauges = true
 		ex.Listen(events)
 	}()
 
-	// Synchronously send a statsd event to wait for handleEvent execution.
-	// Then close events channel to stop a listener.
-	statsdName := "foo.processed_kilobytes"
-	statsdLabels := map[string]string{}
-	promName := "processed_bytes"
-	promLabels := map[string]string{"service": "foo"}
+	name := "unchanged_gauge"
 	c := event.Events{
-		&event.CounterEvent{
-			CMetricName: statsdName,
-			CValue:      100,
-			CLabels:     statsdLabels,
+		&event.GaugeEvent{GMetricName: name, GValue: 5, GLabels: map[string]string{}},
+		&event.GaugeEvent{GMetricName: name, GValue: 5, GLabels: map[string]string{}},
+		&event.GaugeEvent{GMetricName: name, GValue: 6, GLabels: map[string]string{}},
+		&event.GaugeEvent{GMetricName: name, GValue: 1, GRelative: true, GLabels: map[string]string{}},
+		&event.GaugeEvent{GMetricName: name, GValue: 7, GLabels: map[string]string{}},
+		&event.GaugeEvent{GMetricName: name, GValue: 7, GLabels: map[string]string{}},
+	}
+	events <- c
+	// Push empty event so that we block until the first event is consumed.
+	events <- event.Events{}
+	close(events)
+
+	metrics, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("Cannot gather from DefaultGatherer: %v", err)
+	}
+	value := getFloat64(metrics, name, nil)
+	if value == nil {
+		t.Fatal("gauge value should not be nil")
+	}
+	if *value != 7 {
+		t.Fatalf("gauge has value %f, expected 7", *value)
+	}
+	if changes := getTelemetryCounterValue(gaugeChanges); changes != 4 {
+		t.Fatalf("counted %f gauge changes, expected 4", changes)
+	}
+}
+
+func TestRelativeGauges(t *testing.T) {
+	relativeGauges := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "statsd_exporter_relative_gauge_operations_total",
+		Help: "The total number of relative changes applied to gauges.",
+	})
+
+	events := make(chan event.Events)
+	go func() {
+		testMapper := mapper.MetricMapper{}
+		ex := NewExporter(prometheus.DefaultRegisterer, &testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+		ex.RelativeGauges = relativeGauges
+		ex.Listen(events)
+	}()
+
+	// Samples of a line are applied in order, and sampled relative changes
+	// are scaled like counter increments.
+	parser := line.NewParser()
+	for _, l := range []string{"relative_gauge:+1|g", "relative_gauge:5|g:+1|g|@0.5:-3|g"} {
+		events <- parser.LineToEvents(l, *sampleErrors, samplesReceived, tagErrors, tagsReceived, promslog.NewNopLogger())
+	}
+	// Push empty event so that we block until the first event is consumed.
+	events <- event.Events{}
+	close(events)
+
+	metrics, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("Cannot gather from DefaultGatherer: %v", err)
+	}
+	value := getFloat64(metrics, "relative_gauge", nil)
+	if value == nil {
+		t.Fatal("gauge value should not be nil")
+	}
+	if *value != 4 {
+		t.Fatalf("gauge has value %f, expected 4", *value)
+	}
+	if ops := getTelemetryCounterValue(relativeGauges); ops != 3 {
+		t.Fatalf("counted %f relative gauge operations, expected 3", ops)
+	}
+}
+
+func TestRollups(t *testing.T) {
+	events := make(chan event.Events)
+	testMapper := mapper.MetricMapper{}
+	config := `mappings:
+- match: rollup.*.*
+  name: rollup_requests_total
+  labels:
+    host: "$1"
+    code: "$2"
+  rollups:
+  - name: rollup_requests_by_code_total
+    drop_labels: [host]`
+	err := testMapper.InitFromYAMLString(config)
+	if err != nil {
+		t.Fatalf("Config load error: %s %s", config, err)
+	}
+
+	go func() {
+		ex := NewExporter(prometheus.DefaultRegisterer, &testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+		ex.Listen(events)
+	}()
+
+	c := event.Events{
+		&event.CounterEvent{CMetricName: "rollup.web01.200", CValue: 1, CLabels: map[string]string{}},
+		&event.CounterEvent{CMetricName: "rollup.web02.200", CValue: 2, CLabels: map[string]string{}},
+		&event.CounterEvent{CMetricName: "rollup.web02.500", CValue: 4, CLabels: map[string]string{}},
+	}
+	events <- c
+	// Push empty event so that we block until the first event is consumed.
+	events <- event.Events{}
+	close(events)
+
+	metrics, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("Cannot gather from DefaultGatherer: %v", err)
+	}
+	for _, tc := range []struct {
+		name   string
+		labels prometheus.Labels
+		value  float64
+	}{
+		{"rollup_requests_total", prometheus.Labels{"host": "web02", "code": "200"}, 2},
+		{"rollup_requests_by_code_total", prometheus.Labels{"code": "200"}, 3},
+		{"rollup_requests_by_code_total", prometheus.Labels{"code": "500"}, 4},
+	} {
+		value := getFloat64(metrics, tc.name, tc.labels)
+		if value == nil {
+			t.Fatalf("%s%v should not be nil", tc.name, tc.labels)
+		}
+		if *value != tc.value {
+			t.Fatalf("%s%v has value %f, expected %f", tc.name, tc.labels, *value, tc.value)
+		}
+	}
+}
+
+func TestPreviousNames(t *testing.T) {
+	clock.ClockInstance = &clock.Clock{Instant: time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)}
+	defer func() { clock.ClockInstance = nil }()
+
+	events := make(chan event.Events)
+	testMapper := mapper.MetricMapper{}
+	config := `mappings:
+- match: renamed.*.requests
+  name: renamed_http_requests_total
+  labels:
+    code: "$1"
+  previous_names:
+  - name: renamed_${1}_requests_total
+    until: 2026-12-01
+  - name: renamed_expired_requests_total
+    until: 2026-10-01
+- match: renamed\.queue\.(.*)
+  match_type: regex
+  name: renamed_queue_length
+  labels:
+    queue: "$1"
+  previous_names:
+  - name: renamed_queue_${1}_length
+    until: 2026-12-01T00:00:00Z`
+	if err := testMapper.InitFromYAMLString(config); err != nil {
+		t.Fatalf("Config load error: %s %s", config, err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ex := NewExporter(prometheus.DefaultRegisterer, &testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+		ex.Listen(events)
+		close(done)
+	}()
+
+	events <- event.Events{
+		event.NewCounterEvent("renamed.200.requests", 2, map[string]string{}),
+		event.NewCounterEvent("renamed.200.requests", 3, map[string]string{}),
+		event.NewGaugeEvent("renamed.queue.mail", 7, false, map[string]string{}),
+		event.NewGaugeEvent("renamed.queue.mail", -2, true, map[string]string{}),
+	}
+	close(events)
+	<-done
+
+	metrics, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("Cannot gather from DefaultGatherer: %v", err)
+	}
+	for _, tc := range []struct {
+		name   string
+		labels prometheus.Labels
+		value  float64
+	}{
+		{"renamed_http_requests_total", prometheus.Labels{"code": "200"}, 5},
+		{"renamed_200_requests_total", prometheus.Labels{"code": "200"}, 5},
+		{"renamed_queue_length", prometheus.Labels{"queue": "mail"}, 5},
+		{"renamed_queue_mail_length", prometheus.Labels{"queue": "mail"}, 5},
+	} {
+		value := getFloat64(metrics, tc.name, tc.labels)
+		if value == nil {
+			t.Fatalf("%s%v should not be nil", tc.name, tc.labels)
+		}
+		if *value != tc.value {
+			t.Fatalf("%s%v has value %f, expected %f", tc.name, tc.labels, *value, tc.value)
+		}
+	}
+	if value := getFloat64(metrics, "renamed_expired_requests_total", prometheus.Labels{"code": "200"}); value != nil {
+		t.Fatalf("expired previous name should not be exported, got %v", *value)
+	}
+}
+
+func TestObserverTypeBoth(t *testing.T) {
+	events := make(chan event.Events)
+	testMapper := mapper.MetricMapper{}
+	config := `mappings:
+- match: both.*
+  name: both_request_duration_seconds
+  observer_type: both
+  labels:
+    handler: "$1"
+  histogram_options:
+    buckets: [0.1, 1]
+  rollups:
+  - name: both_request_duration_all_seconds
+    drop_labels: [handler]`
+	err := testMapper.InitFromYAMLString(config)
+	if err != nil {
+		t.Fatalf("Config load error: %s %s", config, err)
+	}
+
+	go func() {
+		ex := NewExporter(prometheus.DefaultRegisterer, &testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+		ex.Listen(events)
+	}()
+
+	c := event.Events{
+		&event.ObserverEvent{OMetricName: "both.index", OValue: 0.5, OLabels: map[string]string{}},
+		&event.ObserverEvent{OMetricName: "both.index", OValue: 2, OLabels: map[string]string{}},
+	}
+	events <- c
+	// Push empty event so that we block until the first event is consumed.
+	events <- event.Events{}
+	close(events)
+
+	metrics, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("Cannot gather from DefaultGatherer: %v", err)
+	}
+	for _, tc := range []struct {
+		name       string
+		labels     prometheus.Labels
+		metricType dto.MetricType
+	}{
+		{"both_request_duration_seconds", prometheus.Labels{"handler": "index"}, dto.MetricType_HISTOGRAM},
+		{"both_request_duration_seconds_summary", prometheus.Labels{"handler": "index"}, dto.MetricType_SUMMARY},
+		{"both_request_duration_all_seconds", prometheus.Labels{}, dto.MetricType_HISTOGRAM},
+		{"both_request_duration_all_seconds_summary", prometheus.Labels{}, dto.MetricType_SUMMARY},
+	} {
+		value := getFloat64(metrics, tc.name, tc.labels)
+		if value == nil {
+			t.Fatalf("%s%v should not be nil", tc.name, tc.labels)
+		}
+		if *value != 2.5 {
+			t.Fatalf("%s%v has sum %f, expected 2.5", tc.name, tc.labels, *value)
+		}
+		for _, mf := range metrics {
+			if mf.GetName() == tc.name && mf.GetType() != tc.metricType {
+				t.Fatalf("%s has type %v, expected %v", tc.name, mf.GetType(), tc.metricType)
+			}
+		}
+	}
+}
+
+func TestHashLabels(t *testing.T) {
+	events := make(chan event.Events)
+	testMapper := mapper.MetricMapper{}
+	config := `mappings:
+- match: hashed.*
+  name: hashed_sessions_total
+  labels:
+    user_id: "$1"
+  hash_labels: [user_id, session_id]`
+	err := testMapper.InitFromYAMLString(config)
+	if err != nil {
+		t.Fatalf("Config load error: %s %s", config, err)
+	}
+
+	table := NewLabelHashTable(10)
+	go func() {
+		ex := NewExporter(prometheus.DefaultRegisterer, &testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+		ex.LabelHashes = table
+		ex.Listen(events)
+	}()
+
+	events <- event.Events{
+		&event.CounterEvent{CMetricName: "hashed.alice", CValue: 1, CLabels: map[string]string{"region": "eu"}},
+	}
+	events <- event.Events{}
+	close(events)
+
+	metrics, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("Cannot gather from DefaultGatherer: %v", err)
+	}
+	hash := hashLabelValue("alice")
+	if getFloat64(metrics, "hashed_sessions_total", prometheus.Labels{"user_id": hash, "region": "eu"}) == nil {
+		t.Fatalf("hashed_sessions_total with hashed user_id %s should be gathered", hash)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /api/v1/label-hash/{hash}", table)
+	for _, tc := range []struct {
+		hash   string
+		status int
+		body   string
+	}{
+		{hash, http.StatusOK, `{"hash":"` + hash + `","label":"user_id","value":"alice"}` + "\n"},
+		{hashLabelValue("bob"), http.StatusNotFound, ""},
+	} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/label-hash/"+tc.hash, nil))
+		if rec.Code != tc.status {
+			t.Fatalf("expected status %d for %s, got %d", tc.status, tc.hash, rec.Code)
+		}
+		if tc.body != "" && rec.Body.String() != tc.body {
+			t.Fatalf("expected body %q, got %q", tc.body, rec.Body.String())
+		}
+	}
+}
+
+func TestDropLabels(t *testing.T) {
+	events := make(chan event.Events)
+	testMapper := mapper.MetricMapper{}
+	config := `mappings:
+- match: dropped.*
+  name: dropped_requests_total
+  labels:
+    handler: "$1"
+  drop_labels: [host, pid]`
+	err := testMapper.InitFromYAMLString(config)
+	if err != nil {
+		t.Fatalf("Config load error: %s %s", config, err)
+	}
+
+	go func() {
+		ex := NewExporter(prometheus.DefaultRegisterer, &testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+		ex.Listen(events)
+	}()
+
+	events <- event.Events{
+		&event.CounterEvent{CMetricName: "dropped.login", CValue: 1, CLabels: map[string]string{"host": "web01", "pid": "123", "region": "eu"}},
+		&event.CounterEvent{CMetricName: "dropped.login", CValue: 2, CLabels: map[string]string{"host": "web02", "pid": "456", "region": "eu"}},
+	}
+	events <- event.Events{}
+	close(events)
+
+	metrics, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("Cannot gather from DefaultGatherer: %v", err)
+	}
+	value := getFloat64(metrics, "dropped_requests_total", prometheus.Labels{"handler": "login", "region": "eu"})
+	if value == nil || *value != 3 {
+		t.Fatalf("expected dropped_requests_total without host and pid to be 3, got %v", value)
+	}
+}
+
+// TestBucketSets validates that clients can select the buckets of a
+// histogram with a hint label.
+func TestBucketSets(t *testing.T) {
+	events := make(chan event.Events)
+	testMapper := mapper.MetricMapper{}
+	config := `mappings:
+- match: hinted.*
+  name: hinted_duration_seconds
+  observer_type: histogram
+  histogram_options:
+    buckets: [1, 2]
+bucket_sets:
+  sets:
+    web: [0.01, 0.1]
+    batch: [10, 100, 1000]`
+	err := testMapper.InitFromYAMLString(config)
+	if err != nil {
+		t.Fatalf("Config load error: %s %s", config, err)
+	}
+
+	go func() {
+		ex := NewExporter(prometheus.DefaultRegisterer, &testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+		ex.Listen(events)
+	}()
+
+	events <- event.Events{
+		&event.ObserverEvent{OMetricName: "hinted.login", OValue: 0.05, OLabels: map[string]string{"buckets": "web"}},
+		&event.ObserverEvent{OMetricName: "hinted.login", OValue: 50, OLabels: map[string]string{"buckets": "batch"}},
+		&event.ObserverEvent{OMetricName: "hinted.login", OValue: 1.5, OLabels: map[string]string{"buckets": "unknown"}},
+		&event.ObserverEvent{OMetricName: "hinted.login", OValue: 1.5, OLabels: map[string]string{}},
+	}
+	events <- event.Events{}
+	close(events)
+
+	metrics, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("Cannot gather from DefaultGatherer: %v", err)
+	}
+	// The unknown hint is removed, so both last observations go to the
+	// histogram without a hint.
+	expected := map[string][]float64{
+		"web":   {0.01, 0.1},
+		"batch": {10, 100, 1000},
+		"":      {1, 2},
+	}
+	seen := 0
+	for _, mf := range metrics {
+		if mf.GetName() != "hinted_duration_seconds" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			set := ""
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "buckets" {
+					set = l.GetValue()
+				}
+			}
+			var bounds []float64
+			for _, b := range m.GetHistogram().GetBucket() {
+				bounds = append(bounds, b.GetUpperBound())
+			}
+			if !reflect.DeepEqual(bounds, expected[set]) {
+				t.Errorf("expected buckets %v for bucket set %q, got %v", expected[set], set, bounds)
+			}
+			if set == "" && m.GetHistogram().GetSampleCount() != 2 {
+				t.Errorf("expected 2 observations without a bucket set, got %d", m.GetHistogram().GetSampleCount())
+			}
+			seen++
+		}
+	}
+	if seen != len(expected) {
+		t.Fatalf("expected %d histograms, got %d", len(expected), seen)
+	}
+}
+
+// TestDigests validates that observers with the digest observer type export
+// the quantiles of the last interval, and serve their digests.
+func TestDigests(t *testing.T) {
+	events := make(chan event.Events)
+	testMapper := mapper.MetricMapper{}
+	config := `mappings:
+- match: digested.*
+  name: digested_duration_seconds
+  observer_type: digest
+  labels:
+    handler: "$1"`
+	err := testMapper.InitFromYAMLString(config)
+	if err != nil {
+		t.Fatalf("Config load error: %s %s", config, err)
+	}
+
+	ex := NewExporter(prometheus.DefaultRegisterer, &testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+	ex.Digests = NewDigestCollector(time.Hour)
+	done := make(chan struct{})
+	go func() {
+		ex.Listen(events)
+		close(done)
+	}()
+
+	var batch event.Events
+	for i := 1; i <= 100; i++ {
+		batch = append(batch, event.NewObserverEvent("digested.login", float64(i), map[string]string{}))
+	}
+	events <- batch
+	close(events)
+	<-done
+	ex.Digests.rotate()
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(ex.Digests)
+	metrics, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Cannot gather digests: %v", err)
+	}
+	for _, tc := range []struct {
+		quantile string
+		value    float64
+	}{
+		{"0.5", 50.5},
+		{"0.9", 90.5},
+		{"0.99", 99.5},
+	} {
+		v := getFloat64(metrics, "digested_duration_seconds", prometheus.Labels{"handler": "login", "quantile": tc.quantile})
+		if v == nil || math.Abs(*v-tc.value) > 1 {
+			t.Errorf("expected quantile %s to be about %v, got %v", tc.quantile, tc.value, v)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	ex.Digests.ServeHTTP(rec, httptest.NewRequest("GET", "/debug/digests?metric=digested_duration_seconds", nil))
+	var report DigestReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Digests) != 1 || report.Digests[0].Count != 100 || report.Digests[0].Min != 1 || report.Digests[0].Max != 100 {
+		t.Fatalf("unexpected digest report %+v", report)
+	}
+
+	// The next interval starts empty.
+	ex.Digests.rotate()
+	metrics, err = reg.Gather()
+	if err != nil {
+		t.Fatalf("Cannot gather digests: %v", err)
+	}
+	if len(metrics) != 0 {
+		t.Fatalf("expected no quantiles for an empty interval, got %v", metrics)
+	}
+}
+
+// TestMappingStage validates that regex mappings are evaluated by workers,
+// and that events of the same metric stay in order.
+func TestMappingStage(t *testing.T) {
+	testMapper := mapper.MetricMapper{}
+	config := `mappings:
+- match: fast.*
+  name: stage_fast
+- match: ^slow\.(.*)$
+  match_type: regex
+  name: stage_slow_${1}`
+	err := testMapper.InitFromYAMLString(config)
+	if err != nil {
+		t.Fatalf("Config load error: %s %s", config, err)
+	}
+
+	stage := &MappingStage{
+		Mapper:      &testMapper,
+		Workers:     2,
+		QueueSize:   1,
+		Events:      prometheus.NewCounterVec(prometheus.CounterOpts{}, []string{"path"}),
+		QueueLength: prometheus.NewGauge(prometheus.GaugeOpts{}),
+	}
+	in := make(chan event.Events)
+	out := make(chan event.Events)
+	go stage.Run(in, out)
+	done := make(chan struct{})
+	go func() {
+		ex := NewExporter(prometheus.DefaultRegisterer, &testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+		ex.Listen(out)
+		close(done)
+	}()
+
+	in <- event.Events{
+		event.NewGaugeEvent("fast.a", 1, false, nil),
+		event.NewGaugeEvent("slow.b", 1, false, nil),
+		event.NewGaugeEvent("fast.a", 2, false, nil),
+	}
+	in <- event.Events{
+		event.NewGaugeEvent("slow.b", 2, false, nil),
+		event.NewGaugeEvent("slow.b", 3, false, nil),
+		event.NewGaugeEvent("other", 1, false, nil),
+	}
+	close(in)
+	<-done
+
+	metrics, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("Cannot gather from DefaultGatherer: %v", err)
+	}
+	for name, want := range map[string]float64{"stage_fast": 2, "stage_slow_b": 3, "other": 1} {
+		if v := getFloat64(metrics, name, prometheus.Labels{}); v == nil || *v != want {
+			t.Fatalf("expected %s to be %v, got %v", name, want, v)
+		}
+	}
+	if v := getTelemetryCounterValue(stage.Events.WithLabelValues("fast")); v != 2 {
+		t.Errorf("expected 2 events on the fast path, got %v", v)
+	}
+	// Without a cache, unmapped events need the regex mappings to be checked.
+	if v := getTelemetryCounterValue(stage.Events.WithLabelValues("slow")); v != 4 {
+		t.Errorf("expected 4 events on the slow path, got %v", v)
+	}
+}
+
+// TestMappingStageShedding validates that events for a worker whose queue is
+// full are shed instead of holding up other events.
+func TestMappingStageShedding(t *testing.T) {
+	testMapper := mapper.MetricMapper{}
+	config := `mappings:
+- match: ^slow\.(.*)$
+  match_type: regex
+  name: stage_slow_${1}`
+	if err := testMapper.InitFromYAMLString(config); err != nil {
+		t.Fatalf("Config load error: %s %s", config, err)
+	}
+
+	stage := &MappingStage{
+		Mapper:      &testMapper,
+		Workers:     1,
+		QueueSize:   1,
+		Events:      prometheus.NewCounterVec(prometheus.CounterOpts{}, []string{"path"}),
+		QueueLength: prometheus.NewGauge(prometheus.GaugeOpts{}),
+		ShedEvents:  prometheus.NewCounter(prometheus.CounterOpts{}),
+	}
+	in := make(chan event.Events)
+	// Nothing reads out until all events are sent, so the worker is stuck
+	// on the first event it takes.
+	out := make(chan event.Events)
+	go stage.Run(in, out)
+
+	for i := 0; i < 4; i++ {
+		in <- event.Events{event.NewGaugeEvent("slow.a", float64(i), false, nil)}
+	}
+	// Wait for the last event to be dispatched.
+	in <- event.Events{}
+	close(in)
+	received := 0
+	for events := range out {
+		received += len(events)
+	}
+
+	shed := getTelemetryCounterValue(stage.ShedEvents)
+	// The worker holds one event and its queue another.
+	if shed < 2 {
+		t.Errorf("expected at least 2 events to be shed, got %v", shed)
+	}
+	if float64(received)+shed != 4 {
+		t.Errorf("expected 4 events to be either passed on or shed, got %d passed on and %v shed", received, shed)
+	}
+	if v := getTelemetryGaugeValue(stage.QueueLength); v != 0 {
+		t.Errorf("expected an empty queue, got %v", v)
+	}
+}
+
+// TestDerivedMetrics validates that derived metrics are computed from the
+// current values of their operands.
+func TestDerivedMetrics(t *testing.T) {
+	events := make(chan event.Events)
+	testMapper := mapper.MetricMapper{}
+	config := `mappings:
+- match: cache.*.hit
+  name: derived_cache_hits_total
+  labels:
+    cache: "$1"
+- match: cache.*.request
+  name: derived_cache_requests_total
+  labels:
+    cache: "$1"
+derived_metrics:
+- name: derived_cache_hit_ratio
+  op: divide
+  left: derived_cache_hits_total
+  right: derived_cache_requests_total
+  by: [cache]
+- name: derived_cache_misses
+  op: subtract
+  left: derived_cache_requests_total
+  right: derived_cache_hits_total`
+	err := testMapper.InitFromYAMLString(config)
+	if err != nil {
+		t.Fatalf("Config load error: %s %s", config, err)
+	}
+
+	ex := NewExporter(prometheus.DefaultRegisterer, &testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+	ex.Derived = &DerivedCollector{Interval: time.Hour}
+	done := make(chan struct{})
+	go func() {
+		ex.Listen(events)
+		close(done)
+	}()
+
+	events <- event.Events{
+		event.NewCounterEvent("cache.users.hit", 3, map[string]string{}),
+		event.NewCounterEvent("cache.users.request", 4, map[string]string{}),
+		event.NewCounterEvent("cache.sessions.hit", 1, map[string]string{}),
+		event.NewCounterEvent("cache.sessions.request", 4, map[string]string{}),
+		// No ratio can be derived without requests.
+		event.NewCounterEvent("cache.pages.hit", 0, map[string]string{}),
+	}
+	close(events)
+	<-done
+	ex.updateDerivedMetrics()
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(ex.Derived)
+	metrics, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Cannot gather derived metrics: %v", err)
+	}
+	for _, tc := range []struct {
+		name   string
+		labels prometheus.Labels
+		value  float64
+	}{
+		{"derived_cache_hit_ratio", prometheus.Labels{"cache": "users"}, 0.75},
+		{"derived_cache_hit_ratio", prometheus.Labels{"cache": "sessions"}, 0.25},
+		{"derived_cache_misses", prometheus.Labels{}, 4},
+	} {
+		if v := getFloat64(metrics, tc.name, tc.labels); v == nil || *v != tc.value {
+			t.Fatalf("expected %s%v to be %v, got %v", tc.name, tc.labels, tc.value, v)
+		}
+	}
+	if v := getFloat64(metrics, "derived_cache_hit_ratio", prometheus.Labels{"cache": "pages"}); v != nil {
+		t.Fatalf("expected no hit ratio for a cache without requests, got %v", *v)
+	}
+}
+
+// TestLatencyProbes validates that the latency of probes is observed once
+// they are handled and a scrape follows.
+func TestLatencyProbes(t *testing.T) {
+	clock.ClockInstance = &clock.Clock{Instant: time.Unix(100, 0), TickerCh: make(chan time.Time)}
+	defer func() { clock.ClockInstance = nil }()
+
+	latency := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "latency", Buckets: []float64{1, 5}})
+	tracker := &LatencyTracker{Latency: latency}
+	events := make(chan event.Events)
+	ex := NewExporter(prometheus.DefaultRegisterer, &mapper.MetricMapper{}, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+	ex.Latency = tracker
+	done := make(chan struct{})
+	go func() {
+		ex.Listen(events)
+		close(done)
+	}()
+
+	h := &ScrapeHandler{
+		Gatherer:       prometheus.NewRegistry(),
+		SlowScrapes:    prometheus.NewCounter(prometheus.CounterOpts{}),
+		AbortedScrapes: prometheus.NewCounter(prometheus.CounterOpts{}),
+		Logger:         promslog.NewNopLogger(),
+		Latency:        tracker,
+	}
+	scrape := func() {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	}
+
+	events <- event.Events{
+		event.NewCounterEvent("latency_probed", 1, map[string]string{}),
+		&event.Probe{ReceivedAt: time.Unix(98, 0)},
+	}
+	events <- event.Events{}
+	close(events)
+	<-done
+
+	// A probe applied after the scrape started is not observed yet.
+	clock.ClockInstance.Instant = time.Unix(99, 0)
+	scrape()
+	var m dto.Metric
+	if err := latency.Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	if n := m.GetHistogram().GetSampleCount(); n != 0 {
+		t.Fatalf("expected no latency to be observed, got %d", n)
+	}
+
+	clock.ClockInstance.Instant = time.Unix(101, 0)
+	scrape()
+	scrape()
+	if err := latency.Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	if n, sum := m.GetHistogram().GetSampleCount(), m.GetHistogram().GetSampleSum(); n != 1 || sum != 3 {
+		t.Fatalf("expected a single latency of 3s, got %d with a sum of %v", n, sum)
+	}
+}
+
+func TestScaledMapping(t *testing.T) {
+	events := make(chan event.Events)
+	testMapper := mapper.MetricMapper{}
+	config := `mappings:
+- match: foo.processed_kilobytes
+  name: processed_bytes
+  scale: 1024
+  labels:
+    service: foo`
+	err := testMapper.InitFromYAMLString(config)
+	if err != nil {
+		t.Fatalf("Config load error: %s %s", config, err)
+	}
+
+	// Start exporter with a synchronous channel
+	go func() {
+		ex := NewExporter(prometheus.DefaultRegisterer, &testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+		ex.Listen(events)
+	}()
+
+	// Synchronously send a statsd event to wait for handleEvent execution.
+	// Then close events channel to stop a listener.
+	statsdName := "foo.processed_kilobytes"
+	statsdLabels := map[string]string{}
+	promName := "processed_bytes"
+	promLabels := map[string]string{"service": "foo"}
+	c := event.Events{
+		&event.CounterEvent{
+			CMetricName: statsdName,
+			CValue:      100,
+			CLabels:     statsdLabels,
+		},
+		&event.CounterEvent{
+			CMetricName: statsdName,
+			CValue:      200,
+			CLabels:     statsdLabels,
+		},
+	}
+	events <- c
+	// Push empty event so that we block until the first event is consumed.
+	events <- event.Events{}
+	close(events)
+
+	// Check counter value
+	metrics, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("Cannot gather from DefaultGatherer: %v", err)
+	}
+	value := getFloat64(metrics, promName, promLabels)
+	if value == nil {
+		t.Fatal("Counter value should not be nil")
+	}
+	if *value != 300*1024 {
+		t.Fatalf("Counter wasn't incremented properly")
+	}
+}
+
+type mockStatsDTCPListener struct {
+	listener.StatsDTCPListener
+	*slog.Logger
+}
+
+func (ml *mockStatsDTCPListener) HandlePacket(packet []byte) {
+	// Forcing IPv4 because the TravisCI build environment does not have IPv6
+	// addresses.
+	lc, err := net.ListenTCP("tcp4", nil)
+	if err != nil {
+		panic(fmt.Sprintf("mockStatsDTCPListener: listen failed: %v", err))
+	}
+
+	defer lc.Close()
+
+	go func() {
+		cc, err := net.DialTCP("tcp", nil, lc.Addr().(*net.TCPAddr))
+		if err != nil {
+			panic(fmt.Sprintf("mockStatsDTCPListener: dial failed: %v", err))
+		}
+
+		defer cc.Close()
+
+		n, err := cc.Write(packet)
+		if err != nil || n != len(packet) {
+			panic(fmt.Sprintf("mockStatsDTCPListener: write failed: %v,%d", err, n))
+		}
+	}()
+
+	sc, err := lc.AcceptTCP()
+	if err != nil {
+		panic(fmt.Sprintf("mockStatsDTCPListener: accept failed: %v", err))
+	}
+	ml.HandleConn(sc)
+}
+
+// TestTtlExpiration validates expiration of time series.
+// foobar metric without mapping should expire with default ttl of 1s
+// bazqux metric should expire with ttl of 2s
+func TestTtlExpiration(t *testing.T) {
+	// Mock a time.NewTicker
+	tickerCh := make(chan time.Time)
+	clock.ClockInstance = &clock.Clock{
+		TickerCh: tickerCh,
+	}
+
+	config := `
+defaults:
+  ttl: 1s
+mappings:
+- match: bazqux.*
+  name: bazqux
+  ttl: 2s
+`
+	// Create mapper from config and start an Exporter with a synchronous channel
+	testMapper := &mapper.MetricMapper{}
+	err := testMapper.InitFromYAMLString(config)
+	if err != nil {
+		t.Fatalf("Config load error: %s %s", config, err)
+	}
+	events := make(chan event.Events)
+	defer close(events)
+	go func() {
+		ex := NewExporter(prometheus.DefaultRegisterer, testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+		ex.Listen(events)
+	}()
+
+	ev := event.Events{
+		// event with default ttl = 1s
+		&event.GaugeEvent{
+			GMetricName: "foobar",
+			GValue:      200,
+		},
+		// event with ttl = 2s from a mapping
+		&event.ObserverEvent{
+			OMetricName: "bazqux.main",
+			OValue:      42,
 		},
+	}
+
+	var metrics []*dto.MetricFamily
+	var foobarValue *float64
+	var bazquxValue *float64
+
+	// Step 1. Send events with statsd metrics.
+	// Send empty Events to wait for events are handled.
+	// saveLabelValues will use fake instant as a lastRegisteredAt time.
+	clock.ClockInstance.Instant = time.Unix(0, 0)
+	events <- ev
+	events <- event.Events{}
+
+	// Check values
+	metrics, err = prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal("Gather should not fail")
+	}
+	foobarValue = getFloat64(metrics, "foobar", prometheus.Labels{})
+	bazquxValue = getFloat64(metrics, "bazqux", prometheus.Labels{})
+	if foobarValue == nil || bazquxValue == nil {
+		t.Fatalf("Gauge `foobar` and Summary `bazqux` should be gathered")
+	}
+	if *foobarValue != 200 {
+		t.Fatalf("Gauge `foobar` observation %f is not expected. Should be 200", *foobarValue)
+	}
+	if *bazquxValue != 42 {
+		t.Fatalf("Summary `bazqux` observation %f is not expected. Should be 42", *bazquxValue)
+	}
+
+	// Step 2. Increase Instant to emulate metrics expiration after 1s
+	clock.ClockInstance.Instant = time.Unix(1, 10)
+	clock.ClockInstance.TickerCh <- time.Unix(0, 0)
+	events <- event.Events{}
+
+	// Check values
+	metrics, err = prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal("Gather should not fail")
+	}
+	foobarValue = getFloat64(metrics, "foobar", prometheus.Labels{})
+	bazquxValue = getFloat64(metrics, "bazqux", prometheus.Labels{})
+	if foobarValue != nil {
+		t.Fatalf("Gauge `foobar` should be expired")
+	}
+	if bazquxValue == nil {
+		t.Fatalf("Summary `bazqux` should be gathered")
+	}
+	if *bazquxValue != 42 {
+		t.Fatalf("Summary `bazqux` observation %f is not expected. Should be 42", *bazquxValue)
+	}
+
+	// Step 3. Increase Instant to emulate metrics expiration after 2s
+	clock.ClockInstance.Instant = time.Unix(2, 200)
+	clock.ClockInstance.TickerCh <- time.Unix(0, 0)
+	events <- event.Events{}
+
+	// Check values
+	metrics, err = prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal("Gather should not fail")
+	}
+	foobarValue = getFloat64(metrics, "foobar", prometheus.Labels{})
+	bazquxValue = getFloat64(metrics, "bazqux", prometheus.Labels{})
+	if bazquxValue != nil {
+		t.Fatalf("Summary `bazqux` should be expired")
+	}
+	if foobarValue != nil {
+		t.Fatalf("Gauge `foobar` should not be gathered after expiration")
+	}
+}
+
+// TestTtlPerMetricType validates that unmapped gauges expire with the gauge
+// default ttl while counters, without a default ttl, do not expire.
+func TestTtlPerMetricType(t *testing.T) {
+	tickerCh := make(chan time.Time)
+	clock.ClockInstance = &clock.Clock{
+		TickerCh: tickerCh,
+	}
+
+	config := `
+defaults:
+  gauge_ttl: 1s
+`
+	testMapper := &mapper.MetricMapper{}
+	err := testMapper.InitFromYAMLString(config)
+	if err != nil {
+		t.Fatalf("Config load error: %s %s", config, err)
+	}
+	events := make(chan event.Events)
+	defer close(events)
+	go func() {
+		ex := NewExporter(prometheus.DefaultRegisterer, testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+		ex.Listen(events)
+	}()
+
+	clock.ClockInstance.Instant = time.Unix(0, 0)
+	events <- event.Events{
 		&event.CounterEvent{
-			CMetricName: statsdName,
-			CValue:      200,
-			CLabels:     statsdLabels,
+			CMetricName: "ttl_type_counter",
+			CValue:      1,
+		},
+		&event.GaugeEvent{
+			GMetricName: "ttl_type_gauge",
+			GValue:      1,
 		},
 	}
-	events <- c
-	// Push empty event so that we block until the first event is consumed.
 	events <- event.Events{}
-	close(events)
 
-	// Check counter value
+	clock.ClockInstance.Instant = time.Unix(1, 10)
+	clock.ClockInstance.TickerCh <- time.Unix(0, 0)
+	events <- event.Events{}
+
 	metrics, err := prometheus.DefaultGatherer.Gather()
 	if err != nil {
-		t.Fatalf("Cannot gather from DefaultGatherer: %v", err)
+		t.Fatal("Gather should not fail")
 	}
-	value := getFloat64(metrics, promName, promLabels)
-	if value == nil {
-		t.Fatal("Counter value should not be nil")
+	if getFloat64(metrics, "ttl_type_gauge", prometheus.Labels{}) != nil {
+		t.Fatalf("Gauge `ttl_type_gauge` should be expired")
 	}
-	if *value != 300*1024 {
-		t.Fatalf("Counter wasn't incremented properly")
+	if getFloat64(metrics, "ttl_type_counter", prometheus.Labels{}) == nil {
+		t.Fatalf("Counter `ttl_type_counter` should not be expired")
 	}
 }
 
-type statsDPacketHandler interface {
-	HandlePacket(packet []byte)
-	SetEventHandler(eh event.EventHandler)
-}
+// TestClockStall validates that metrics don't expire after the exporter
+// stalled for longer than their ttl.
+// TestMemoryProtection validates that no new series are created while the
+// heap exceeds the memory limit.
+func TestCardinalityMonitor(t *testing.T) {
+	clock.ClockInstance = &clock.Clock{Instant: time.Unix(1000, 0)}
+	defer func() { clock.ClockInstance = nil }()
+
+	r := registry.NewRegistry(prometheus.NewRegistry(), &mapper.MetricMapper{})
+	addSeries := func(name string, n int) {
+		for i := 0; i < n; i++ {
+			if _, err := r.GetCounter(name, prometheus.Labels{"id": strconv.Itoa(i)}, "", &mapper.MetricMapping{}, metricsCount); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	warnings := prometheus.NewCounter(prometheus.CounterOpts{})
+	c := &CardinalityMonitor{
+		Window:         time.Minute,
+		MetricNames:    prometheus.NewGauge(prometheus.GaugeOpts{}),
+		Series:         prometheus.NewGauge(prometheus.GaugeOpts{}),
+		GrowthWarning:  100,
+		GrowthWarnings: warnings,
+	}
+	check := func(names, series, warned float64) {
+		t.Helper()
+		c.update(r, promslog.NewNopLogger())
+		for what, v := range map[string][2]float64{
+			"metric names": {getTelemetryGaugeValue(c.MetricNames), names},
+			"series":       {getTelemetryGaugeValue(c.Series), series},
+			"warnings":     {getTelemetryCounterValue(warnings), warned},
+		} {
+			if v[0] != v[1] {
+				t.Fatalf("expected %v %s, got %v", v[1], what, v[0])
+			}
+		}
+	}
 
-type mockStatsDTCPListener struct {
-	listener.StatsDTCPListener
-	*slog.Logger
+	addSeries("cardinality_a", 10)
+	check(1, 10, 0)
+
+	// 20 new series in 15 seconds are not anomalous.
+	clock.ClockInstance.Instant = clock.ClockInstance.Instant.Add(15 * time.Second)
+	addSeries("cardinality_b", 20)
+	check(2, 30, 0)
+
+	// Updates within the interval are skipped.
+	addSeries("cardinality_c", 100)
+	check(2, 30, 0)
+
+	clock.ClockInstance.Instant = clock.ClockInstance.Instant.Add(15 * time.Second)
+	check(3, 130, 1)
+
+	// Series not updated within the window are no longer counted.
+	clock.ClockInstance.Instant = clock.ClockInstance.Instant.Add(50 * time.Second)
+	addSeries("cardinality_a", 5)
+	check(1, 5, 1)
 }
 
-func (ml *mockStatsDTCPListener) HandlePacket(packet []byte) {
-	// Forcing IPv4 because the TravisCI build environment does not have IPv6
-	// addresses.
-	lc, err := net.ListenTCP("tcp4", nil)
-	if err != nil {
-		panic(fmt.Sprintf("mockStatsDTCPListener: listen failed: %v", err))
+func TestLabelCardinality(t *testing.T) {
+	clock.ClockInstance = &clock.Clock{Instant: time.Unix(1000, 0)}
+	defer func() { clock.ClockInstance = nil }()
+
+	r := registry.NewRegistry(prometheus.NewRegistry(), &mapper.MetricMapper{})
+	for i := 0; i < 40000; i++ {
+		labels := prometheus.Labels{"path": "/item/" + strconv.Itoa(i), "status": strconv.Itoa(200 + i%7)}
+		if _, err := r.GetCounter("requests", labels, "", &mapper.MetricMapping{}, metricsCount); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := r.GetCounter("errors", prometheus.Labels{"status": "500"}, "", &mapper.MetricMapping{}, metricsCount); err != nil {
+		t.Fatal(err)
 	}
 
-	defer lc.Close()
+	c := &CardinalityMonitor{
+		Window:      time.Minute,
+		MetricNames: prometheus.NewGauge(prometheus.GaugeOpts{}),
+		Series:      prometheus.NewGauge(prometheus.GaugeOpts{}),
+	}
+	c.update(r, promslog.NewNopLogger())
 
-	go func() {
-		cc, err := net.DialTCP("tcp", nil, lc.Addr().(*net.TCPAddr))
-		if err != nil {
-			panic(fmt.Sprintf("mockStatsDTCPListener: dial failed: %v", err))
+	rec := httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/cardinality", nil))
+	var report CardinalityReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.MetricNames != 2 || report.Series != 40001 || len(report.Labels) != 2 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	path, status := report.Labels[0], report.Labels[1]
+	if path.Label != "path" || path.Series != 40000 || path.MetricNames != 1 {
+		t.Errorf("unexpected cardinality of path %+v", path)
+	}
+	if math.Abs(float64(path.Values)-40000) > 40000*0.05 {
+		t.Errorf("expected about 40000 values of path, got %d", path.Values)
+	}
+	if status.Label != "status" || status.Values != 8 || status.Series != 40001 || status.MetricNames != 2 || status.Bytes != 3*40001 {
+		t.Errorf("unexpected cardinality of status %+v", status)
+	}
+}
+
+func TestOutageDetector(t *testing.T) {
+	clock.ClockInstance = &clock.Clock{Instant: time.Unix(1000, 0)}
+	defer func() { clock.ClockInstance = nil }()
+
+	r := registry.NewRegistry(prometheus.NewRegistry(), &mapper.MetricMapper{})
+	if _, err := r.GetCounter("outage_counter", prometheus.Labels{}, "", &mapper.MetricMapping{Ttl: time.Minute}, metricsCount); err != nil {
+		t.Fatal(err)
+	}
+	paused := prometheus.NewGauge(prometheus.GaugeOpts{})
+	o := NewOutageDetector(30*time.Second, paused)
+	step := func(d time.Duration, expectPaused bool, pausedFor float64, series int) {
+		t.Helper()
+		clock.ClockInstance.Instant = clock.ClockInstance.Instant.Add(d)
+		if o.expiryPaused(r, promslog.NewNopLogger()) != expectPaused {
+			t.Fatalf("expected expiry paused to be %v", expectPaused)
+		}
+		if !expectPaused {
+			r.RemoveStaleMetrics()
+		}
+		if v := getTelemetryGaugeValue(paused); v != pausedFor {
+			t.Fatalf("expected expiry to be paused for %vs, got %v", pausedFor, v)
+		}
+		if _, n := r.ActiveSince(time.Time{}); n != series {
+			t.Fatalf("expected %d series, got %d", series, n)
 		}
+	}
 
-		defer cc.Close()
+	// Without a scrape, expiry is paused after the window.
+	step(5*time.Second, false, 0, 1)
+	step(30*time.Second, true, 0, 1)
+	step(60*time.Second, true, 60, 1)
 
-		n, err := cc.Write(packet)
-		if err != nil || n != len(packet) {
-			panic(fmt.Sprintf("mockStatsDTCPListener: write failed: %v,%d", err, n))
-		}
+	// Once scrapes resume, expiry is delayed by the length of the pause.
+	o.Scraped()
+	step(0, false, 0, 1)
+	step(20*time.Second, false, 0, 1)
+	o.Scraped()
+	step(20*time.Second, false, 0, 0)
+}
+
+func TestMemoryProtection(t *testing.T) {
+	tickerCh := make(chan time.Time)
+	clock.ClockInstance = &clock.Clock{
+		TickerCh: tickerCh,
+	}
+	defer func() { clock.ClockInstance = nil }()
+
+	var heap atomic.Uint64
+	defer func(f func() uint64) { heapBytes = f }(heapBytes)
+	heapBytes = heap.Load
+
+	protection := prometheus.NewGauge(prometheus.GaugeOpts{})
+	events := make(chan event.Events)
+	defer close(events)
+	go func() {
+		ex := NewExporter(prometheus.DefaultRegisterer, &mapper.MetricMapper{}, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+		ex.MemoryLimit = 100
+		ex.MemoryProtection = protection
+		ex.Listen(events)
 	}()
+	rejected := errorEventStats.WithLabelValues("new_series_rejected")
+	prevRejected := getTelemetryCounterValue(rejected)
+
+	steps := []struct {
+		heap      uint64
+		metric    string
+		protected float64
+		rejected  float64
+	}{
+		{heap: 50, metric: "memory_existing", protected: 0},
+		{heap: 200, metric: "memory_existing", protected: 1},
+		{heap: 200, metric: "memory_new", protected: 1, rejected: 1},
+		// Memory protection is only left below the low watermark.
+		{heap: 90, metric: "memory_new", protected: 1, rejected: 2},
+		{heap: 70, metric: "memory_new", protected: 0, rejected: 2},
+	}
+	for i, step := range steps {
+		heap.Store(step.heap)
+		clock.ClockInstance.TickerCh <- time.Unix(int64(i), 0)
+		events <- event.Events{event.NewCounterEvent(step.metric, 1, map[string]string{})}
+		events <- event.Events{}
+
+		var m dto.Metric
+		if err := protection.Write(&m); err != nil {
+			t.Fatal(err)
+		}
+		if v := m.GetGauge().GetValue(); v != step.protected {
+			t.Fatalf("%d: expected memory protection to be %v, got %v", i, step.protected, v)
+		}
+		if v := getTelemetryCounterValue(rejected) - prevRejected; v != step.rejected {
+			t.Fatalf("%d: expected %v rejected series, got %v", i, step.rejected, v)
+		}
+	}
 
-	sc, err := lc.AcceptTCP()
+	metrics, err := prometheus.DefaultGatherer.Gather()
 	if err != nil {
-		panic(fmt.Sprintf("mockStatsDTCPListener: accept failed: %v", err))
+		t.Fatalf("Cannot gather from DefaultGatherer: %v", err)
+	}
+	if v := getFloat64(metrics, "memory_existing", prometheus.Labels{}); v == nil || *v != 2 {
+		t.Fatalf("expected existing series to be updated while protected, got %v", v)
+	}
+	if v := getFloat64(metrics, "memory_new", prometheus.Labels{}); v == nil || *v != 1 {
+		t.Fatalf("expected new series to be created after protection ended, got %v", v)
 	}
-	ml.HandleConn(sc)
 }
 
-// TestTtlExpiration validates expiration of time series.
-// foobar metric without mapping should expire with default ttl of 1s
-// bazqux metric should expire with ttl of 2s
-func TestTtlExpiration(t *testing.T) {
-	// Mock a time.NewTicker
+func TestClockStall(t *testing.T) {
 	tickerCh := make(chan time.Time)
 	clock.ClockInstance = &clock.Clock{
 		TickerCh: tickerCh,
@@ -1065,105 +2747,458 @@ func TestTtlExpiration(t *testing.T) {
 
 	config := `
 defaults:
-  ttl: 1s
-mappings:
-- match: bazqux.*
-  name: bazqux
   ttl: 2s
 `
-	// Create mapper from config and start an Exporter with a synchronous channel
 	testMapper := &mapper.MetricMapper{}
 	err := testMapper.InitFromYAMLString(config)
 	if err != nil {
 		t.Fatalf("Config load error: %s %s", config, err)
 	}
+	clockJumps := prometheus.NewCounterVec(prometheus.CounterOpts{}, []string{"type"})
 	events := make(chan event.Events)
 	defer close(events)
 	go func() {
 		ex := NewExporter(prometheus.DefaultRegisterer, testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+		ex.ClockJumpThreshold = 5 * time.Second
+		ex.ClockJumps = clockJumps
 		ex.Listen(events)
 	}()
 
-	ev := event.Events{
-		// event with default ttl = 1s
+	clock.ClockInstance.Instant = time.Unix(0, 0)
+	events <- event.Events{
 		&event.GaugeEvent{
-			GMetricName: "foobar",
-			GValue:      200,
+			GMetricName: "stall_gauge",
+			GValue:      1,
 		},
-		// event with ttl = 2s from a mapping
-		&event.ObserverEvent{
-			OMetricName: "bazqux.main",
-			OValue:      42,
+	}
+	clock.ClockInstance.TickerCh <- time.Unix(0, 0)
+	events <- event.Events{}
+
+	steps := []struct {
+		instant time.Time
+		present bool
+		stalls  float64
+	}{
+		// The exporter stalled for 9s, expiry is delayed accordingly.
+		{instant: time.Unix(10, 0), present: true, stalls: 1},
+		{instant: time.Unix(11, 0), present: true, stalls: 1},
+		{instant: time.Unix(12, 1), present: false, stalls: 1},
+	}
+	for i, step := range steps {
+		clock.ClockInstance.Instant = step.instant
+		clock.ClockInstance.TickerCh <- step.instant
+		events <- event.Events{}
+
+		metrics, err := prometheus.DefaultGatherer.Gather()
+		if err != nil {
+			t.Fatal("Gather should not fail")
+		}
+		if present := getFloat64(metrics, "stall_gauge", prometheus.Labels{}) != nil; present != step.present {
+			t.Fatalf("%d: expected gauge present to be %v, got %v", i, step.present, present)
+		}
+		if v := getTelemetryCounterValue(clockJumps.WithLabelValues("stall")); v != step.stalls {
+			t.Fatalf("%d: expected %v stalls, got %v", i, step.stalls, v)
+		}
+	}
+}
+
+// TestWarmup validates that metrics don't expire during warm-up and that the
+// size hint is written once warm-up is over.
+func TestWarmup(t *testing.T) {
+	tickerCh := make(chan time.Time)
+	clock.ClockInstance = &clock.Clock{
+		TickerCh: tickerCh,
+	}
+
+	config := `
+defaults:
+  ttl: 1s
+`
+	testMapper := &mapper.MetricMapper{}
+	err := testMapper.InitFromYAMLString(config)
+	if err != nil {
+		t.Fatalf("Config load error: %s %s", config, err)
+	}
+	sizeHintFile := filepath.Join(t.TempDir(), "size-hint.json")
+	events := make(chan event.Events)
+	defer close(events)
+	clock.ClockInstance.Instant = time.Unix(0, 0)
+	go func() {
+		ex := NewExporter(prometheus.DefaultRegisterer, testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+		ex.WarmupUntil = time.Unix(30, 0)
+		ex.SizeHintFile = sizeHintFile
+		ex.Listen(events)
+	}()
+
+	events <- event.Events{
+		&event.GaugeEvent{GMetricName: "warmup_gauge", GValue: 1},
+		&event.GaugeEvent{GMetricName: "warmup_gauge", GValue: 1, GLabels: map[string]string{"a": "b"}},
+		&event.CounterEvent{CMetricName: "warmup_counter", CValue: 1},
+	}
+	// Wait for the events to be applied before moving the clock.
+	events <- event.Events{}
+
+	steps := []struct {
+		instant time.Time
+		present bool
+		hint    SizeHint
+	}{
+		{instant: time.Unix(10, 0), present: true},
+		{instant: time.Unix(30, 0), present: false},
+		// The size hint is only written after the hint interval.
+		{instant: time.Unix(59, 0), present: false},
+		{instant: time.Unix(60, 0), present: false, hint: SizeHint{MetricNames: 2}},
+	}
+	for i, step := range steps {
+		clock.ClockInstance.Instant = step.instant
+		clock.ClockInstance.TickerCh <- step.instant
+		events <- event.Events{}
+
+		metrics, err := prometheus.DefaultGatherer.Gather()
+		if err != nil {
+			t.Fatal("Gather should not fail")
+		}
+		if present := getFloat64(metrics, "warmup_gauge", prometheus.Labels{}) != nil; present != step.present {
+			t.Fatalf("%d: expected gauge present to be %v, got %v", i, step.present, present)
+		}
+		hint, err := ReadSizeHint(sizeHintFile)
+		if step.hint == (SizeHint{}) {
+			if err == nil {
+				t.Fatalf("%d: expected no size hint, got %v", i, hint)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%d: reading size hint: %v", i, err)
+		}
+		if hint != step.hint {
+			t.Fatalf("%d: expected size hint %v, got %v", i, step.hint, hint)
+		}
+	}
+}
+
+// TestScrapeHandler validates that scrapes are aborted once the scrape
+// timeout passes and that slow scrapes are counted.
+func TestScrapeHandler(t *testing.T) {
+	scenarios := []struct {
+		name          string
+		delay         time.Duration
+		timeoutHeader string
+		timeout       time.Duration
+		status        int
+		slow          float64
+		aborted       float64
+	}{
+		{
+			name:   "no deadline",
+			delay:  10 * time.Millisecond,
+			status: http.StatusOK,
 		},
+		{
+			name:          "within soft deadline",
+			timeoutHeader: "10",
+			status:        http.StatusOK,
+		},
+		{
+			name:          "exceeds soft deadline",
+			delay:         120 * time.Millisecond,
+			timeoutHeader: "0.2",
+			status:        http.StatusOK,
+			slow:          1,
+		},
+		{
+			name:          "exceeds scrape timeout",
+			delay:         time.Second,
+			timeoutHeader: "0.05",
+			status:        http.StatusInternalServerError,
+			aborted:       1,
+		},
+		{
+			name:          "timeout flag caps scrape timeout",
+			delay:         time.Second,
+			timeoutHeader: "10",
+			timeout:       50 * time.Millisecond,
+			status:        http.StatusInternalServerError,
+			aborted:       1,
+		},
+		{
+			name:          "invalid scrape timeout",
+			timeoutHeader: "soon",
+			status:        http.StatusOK,
+		},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			reg := prometheus.NewRegistry()
+			reg.MustRegister(prometheus.NewGauge(prometheus.GaugeOpts{Name: "scrape_test"}))
+			h := &ScrapeHandler{
+				Gatherer: prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+					time.Sleep(s.delay)
+					return reg.Gather()
+				}),
+				Timeout:        s.timeout,
+				SoftDeadline:   0.5,
+				SlowScrapes:    prometheus.NewCounter(prometheus.CounterOpts{}),
+				AbortedScrapes: prometheus.NewCounter(prometheus.CounterOpts{}),
+				Logger:         promslog.NewNopLogger(),
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if s.timeoutHeader != "" {
+				req.Header.Set(scrapeTimeoutHeader, s.timeoutHeader)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != s.status {
+				t.Fatalf("expected status %d, got %d", s.status, rec.Code)
+			}
+			if v := getTelemetryCounterValue(h.SlowScrapes); v != s.slow {
+				t.Errorf("expected %v slow scrapes, got %v", s.slow, v)
+			}
+			if v := getTelemetryCounterValue(h.AbortedScrapes); v != s.aborted {
+				t.Errorf("expected %v aborted scrapes, got %v", s.aborted, v)
+			}
+		})
+	}
+}
+
+// TestScrapeHandlerOpenMetrics validates that created timestamps are exposed
+// when OpenMetrics is enabled and negotiated.
+func TestScrapeHandlerOpenMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "om_counter", Help: "help"}, []string{"a"})
+	counter.WithLabelValues("b").Inc()
+	reg.MustRegister(counter)
+
+	scenarios := []struct {
+		name        string
+		enabled     bool
+		accept      string
+		encoding    string
+		contentType string
+		created     bool
+	}{
+		{
+			name:        "disabled",
+			accept:      "application/openmetrics-text;version=1.0.0",
+			contentType: "text/plain",
+		},
+		{
+			name:        "not negotiated",
+			enabled:     true,
+			accept:      "text/plain;version=0.0.4",
+			contentType: "text/plain",
+		},
+		{
+			name:        "negotiated",
+			enabled:     true,
+			accept:      "application/openmetrics-text;version=1.0.0",
+			contentType: "application/openmetrics-text",
+			created:     true,
+		},
+		{
+			name:        "negotiated with gzip",
+			enabled:     true,
+			accept:      "application/openmetrics-text;version=1.0.0",
+			encoding:    "gzip",
+			contentType: "application/openmetrics-text",
+			created:     true,
+		},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			h := &ScrapeHandler{
+				Gatherer:          reg,
+				EnableOpenMetrics: s.enabled,
+				SlowScrapes:       prometheus.NewCounter(prometheus.CounterOpts{}),
+				AbortedScrapes:    prometheus.NewCounter(prometheus.CounterOpts{}),
+				Logger:            promslog.NewNopLogger(),
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			req.Header.Set("Accept", s.accept)
+			req.Header.Set("Accept-Encoding", s.encoding)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", rec.Code)
+			}
+			if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, s.contentType) {
+				t.Fatalf("expected content type %s, got %s", s.contentType, ct)
+			}
+			var body io.Reader = rec.Body
+			if s.encoding == "gzip" {
+				gz, err := gzip.NewReader(rec.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				body = gz
+			}
+			b, err := io.ReadAll(body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if created := strings.Contains(string(b), `om_counter_created{a="b"}`); created != s.created {
+				t.Fatalf("expected _created sample to be present: %v, got body:\n%s", s.created, b)
+			}
+		})
+	}
+}
+
+// TestScrapeHandlerStreaming validates that streaming the registry one
+// metric family at a time serves the same metrics as gathering all of them.
+func TestScrapeHandlerStreaming(t *testing.T) {
+	self := prometheus.NewRegistry()
+	self.MustRegister(prometheus.NewGauge(prometheus.GaugeOpts{Name: "a_self_metric", Help: "help"}))
+	gathered := prometheus.NewRegistry()
+	streamed := NewStreamingRegistry()
+	for _, reg := range []prometheus.Registerer{gathered, streamed} {
+		r := registry.NewRegistry(reg, &mapper.MetricMapper{})
+		for _, labels := range []prometheus.Labels{{"a": "1"}, {"a": "2"}, {"b": "1"}} {
+			if _, err := r.GetCounter("stream_counter", labels, "help", &mapper.MetricMapping{}, metricsCount); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := r.GetGauge("stream_gauge", prometheus.Labels{}, "help", &mapper.MetricMapping{}, metricsCount); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	serve := func(h *ScrapeHandler) string {
+		h.SlowScrapes = prometheus.NewCounter(prometheus.CounterOpts{})
+		h.AbortedScrapes = prometheus.NewCounter(prometheus.CounterOpts{})
+		h.Logger = promslog.NewNopLogger()
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", rec.Code)
+		}
+		return rec.Body.String()
+	}
+	expected := serve(&ScrapeHandler{Gatherer: prometheus.Gatherers{self, gathered}})
+	got := serve(&ScrapeHandler{Gatherer: self, Stream: streamed})
+	if got != expected {
+		t.Fatalf("expected streamed metrics to be\n%s\ngot\n%s", expected, got)
+	}
+	if !strings.Contains(got, `stream_counter{b="1"} 0`) {
+		t.Fatalf("expected counters with different label names to be served, got\n%s", got)
+	}
+}
+
+// TestScrapeHandlerStreamingConstLabels validates that constant labels, such
+// as resource attributes, are served by the streaming registry like labels
+// added by wrapping the Registerer, and that they can't be overridden.
+func TestScrapeHandlerStreamingConstLabels(t *testing.T) {
+	resource := prometheus.Labels{"host_name": "h1"}
+	gathered := prometheus.NewRegistry()
+	wrapped := registry.NewRegistry(prometheus.WrapRegistererWith(resource, gathered), &mapper.MetricMapper{})
+	streamed := NewStreamingRegistry()
+	labeled := registry.NewRegistry(streamed, &mapper.MetricMapper{})
+	labeled.SetConstLabels(resource)
+	for _, r := range []*registry.Registry{wrapped, labeled} {
+		if _, err := r.GetCounter("resource_counter", prometheus.Labels{"a": "1"}, "help", &mapper.MetricMapping{}, metricsCount); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := r.GetHistogram("resource_histogram", prometheus.Labels{}, "help", &mapper.MetricMapping{}, metricsCount); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := labeled.GetGauge("resource_gauge", prometheus.Labels{"host_name": "h2"}, "help", &mapper.MetricMapping{}, metricsCount); err == nil {
+		t.Fatal("expected a label overriding a constant label to be rejected")
 	}
 
-	var metrics []*dto.MetricFamily
-	var foobarValue *float64
-	var bazquxValue *float64
+	serve := func(h *ScrapeHandler) string {
+		h.SlowScrapes = prometheus.NewCounter(prometheus.CounterOpts{})
+		h.AbortedScrapes = prometheus.NewCounter(prometheus.CounterOpts{})
+		h.Logger = promslog.NewNopLogger()
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", rec.Code)
+		}
+		return rec.Body.String()
+	}
+	expected := serve(&ScrapeHandler{Gatherer: gathered})
+	got := serve(&ScrapeHandler{Gatherer: prometheus.NewRegistry(), Stream: streamed})
+	if got != expected {
+		t.Fatalf("expected streamed metrics to be\n%s\ngot\n%s", expected, got)
+	}
+	if !strings.Contains(got, `resource_counter{a="1",host_name="h1"} 0`) {
+		t.Fatalf("expected the constant label to be served, got\n%s", got)
+	}
+}
 
-	// Step 1. Send events with statsd metrics.
-	// Send empty Events to wait for events are handled.
-	// saveLabelValues will use fake instant as a lastRegisteredAt time.
-	clock.ClockInstance.Instant = time.Unix(0, 0)
-	events <- ev
-	events <- event.Events{}
+// TestMagnitudeTracker validates that observed values are bucketed by
+// magnitude and that windows rotate.
+func TestMagnitudeTracker(t *testing.T) {
+	clock.ClockInstance = &clock.Clock{Instant: time.Unix(0, 0)}
+	defer func() { clock.ClockInstance = nil }()
 
-	// Check values
-	metrics, err = prometheus.DefaultGatherer.Gather()
-	if err != nil {
-		t.Fatal("Gather should not fail")
-	}
-	foobarValue = getFloat64(metrics, "foobar", prometheus.Labels{})
-	bazquxValue = getFloat64(metrics, "bazqux", prometheus.Labels{})
-	if foobarValue == nil || bazquxValue == nil {
-		t.Fatalf("Gauge `foobar` and Summary `bazqux` should be gathered")
+	tracker := NewMagnitudeTracker(time.Minute)
+	for _, v := range []float64{0.25, 0.5, 1000, 1500, -3, 0, math.NaN()} {
+		tracker.Observe("foo", v)
 	}
-	if *foobarValue != 200 {
-		t.Fatalf("Gauge `foobar` observation %f is not expected. Should be 200", *foobarValue)
+	tracker.Observe("bar", 1)
+
+	reports := tracker.Report()
+	if len(reports) != 1 {
+		t.Fatalf("expected only the current window, got %d", len(reports))
 	}
-	if *bazquxValue != 42 {
-		t.Fatalf("Summary `bazqux` observation %f is not expected. Should be 42", *bazquxValue)
+	expected := map[string]uint64{"1e-1": 2, "1e3": 2, "-1e0": 1, "0": 1}
+	if !reflect.DeepEqual(reports[0].Metrics["foo"], expected) {
+		t.Fatalf("unexpected magnitudes %v, expected %v", reports[0].Metrics["foo"], expected)
 	}
 
-	// Step 2. Increase Instant to emulate metrics expiration after 1s
-	clock.ClockInstance.Instant = time.Unix(1, 10)
-	clock.ClockInstance.TickerCh <- time.Unix(0, 0)
-	events <- event.Events{}
+	clock.ClockInstance.Instant = time.Unix(90, 0)
+	tracker.Observe("foo", 20)
 
-	// Check values
-	metrics, err = prometheus.DefaultGatherer.Gather()
-	if err != nil {
-		t.Fatal("Gather should not fail")
+	reports = tracker.Report()
+	if len(reports) != 2 {
+		t.Fatalf("expected a completed and a current window, got %d", len(reports))
 	}
-	foobarValue = getFloat64(metrics, "foobar", prometheus.Labels{})
-	bazquxValue = getFloat64(metrics, "bazqux", prometheus.Labels{})
-	if foobarValue != nil {
-		t.Fatalf("Gauge `foobar` should be expired")
+	if !reflect.DeepEqual(reports[0].Metrics["foo"], expected) {
+		t.Fatalf("unexpected magnitudes in completed window %v, expected %v", reports[0].Metrics["foo"], expected)
 	}
-	if bazquxValue == nil {
-		t.Fatalf("Summary `bazqux` should be gathered")
+	if !reflect.DeepEqual(reports[1].Metrics["foo"], map[string]uint64{"1e1": 1}) {
+		t.Fatalf("unexpected magnitudes in current window %v", reports[1].Metrics["foo"])
 	}
-	if *bazquxValue != 42 {
-		t.Fatalf("Summary `bazqux` observation %f is not expected. Should be 42", *bazquxValue)
+	if !reports[1].Start.Equal(time.Unix(60, 0)) {
+		t.Fatalf("unexpected window start %v", reports[1].Start)
 	}
+}
 
-	// Step 3. Increase Instant to emulate metrics expiration after 2s
-	clock.ClockInstance.Instant = time.Unix(2, 200)
-	clock.ClockInstance.TickerCh <- time.Unix(0, 0)
-	events <- event.Events{}
+func TestRateTracker(t *testing.T) {
+	clock.ClockInstance = &clock.Clock{Instant: time.Unix(0, 0)}
+	defer func() { clock.ClockInstance = nil }()
 
-	// Check values
-	metrics, err = prometheus.DefaultGatherer.Gather()
-	if err != nil {
-		t.Fatal("Gather should not fail")
+	tracker := NewRateTracker(time.Minute)
+	tracker.Observe("bar")
+	// foo is received at 2 samples per second for ten minutes.
+	for i := 0; i < 120; i++ {
+		for j := 0; j < 10; j++ {
+			tracker.Observe("foo")
+		}
+		clock.ClockInstance.Instant = clock.ClockInstance.Instant.Add(5 * time.Second)
 	}
-	foobarValue = getFloat64(metrics, "foobar", prometheus.Labels{})
-	bazquxValue = getFloat64(metrics, "bazqux", prometheus.Labels{})
-	if bazquxValue != nil {
-		t.Fatalf("Summary `bazqux` should be expired")
+
+	rates := tracker.Rates()
+	if len(rates) != 1 || rates[0].Name != "foo" {
+		t.Fatalf("expected only foo to be tracked, got %v", rates)
 	}
-	if foobarValue != nil {
-		t.Fatalf("Gauge `foobar` should not be gathered after expiration")
+	if math.Abs(rates[0].SamplesPerSecond-2) > 0.01 {
+		t.Fatalf("expected a rate of 2 samples per second, got %v", rates[0].SamplesPerSecond)
+	}
+
+	// After one window without samples, the rate has decayed by about two
+	// thirds.
+	clock.ClockInstance.Instant = clock.ClockInstance.Instant.Add(time.Minute)
+	rates = tracker.Rates()
+	if expected := 2 * math.Exp(-1); math.Abs(rates[0].SamplesPerSecond-expected) > 0.01 {
+		t.Fatalf("expected a rate of %v samples per second, got %v", expected, rates[0].SamplesPerSecond)
 	}
 }
 
@@ -1274,6 +3309,15 @@ func getTelemetryCounterValue(counter prometheus.Counter) float64 {
 	return metric.Counter.GetValue()
 }
 
+func getTelemetryGaugeValue(gauge prometheus.Gauge) float64 {
+	var metric dto.Metric
+	err := gauge.Write(&metric)
+	if err != nil {
+		return 0.0
+	}
+	return metric.Gauge.GetValue()
+}
+
 func BenchmarkParseDogStatsDTags(b *testing.B) {
 	scenarios := map[string]string{
 		"1 tag w/hash":         "#test:tag",
@@ -1338,3 +3382,486 @@ func BenchmarkHashNameAndLabels(b *testing.B) {
 		})
 	}
 }
+
+// discardResponseWriter discards the response, so that it doesn't count
+// towards the heap size.
+type discardResponseWriter struct {
+	header http.Header
+}
+
+func (w discardResponseWriter) Header() http.Header         { return w.header }
+func (w discardResponseWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w discardResponseWriter) WriteHeader(int)             {}
+
+// BenchmarkScrapeHandler compares the peak heap size while serving a large
+// registry with and without streaming.
+func BenchmarkScrapeHandler(b *testing.B) {
+	for _, streaming := range []bool{false, true} {
+		b.Run(fmt.Sprintf("streaming=%v", streaming), func(b *testing.B) {
+			h := &ScrapeHandler{
+				Gatherer:       prometheus.NewRegistry(),
+				SlowScrapes:    prometheus.NewCounter(prometheus.CounterOpts{}),
+				AbortedScrapes: prometheus.NewCounter(prometheus.CounterOpts{}),
+				Logger:         promslog.NewNopLogger(),
+			}
+			reg := prometheus.NewRegistry()
+			var r *registry.Registry
+			if streaming {
+				h.Stream = NewStreamingRegistry()
+				r = registry.NewRegistry(h.Stream, &mapper.MetricMapper{})
+			} else {
+				h.Gatherer = reg
+				r = registry.NewRegistry(reg, &mapper.MetricMapper{})
+			}
+			for i := 0; i < 100; i++ {
+				for j := 0; j < 2000; j++ {
+					labels := prometheus.Labels{"instance": strconv.Itoa(j)}
+					if _, err := r.GetCounter(fmt.Sprintf("bench_counter_%d", i), labels, "help", &mapper.MetricMapping{}, metricsCount); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+
+			samples := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+			var peak atomic.Uint64
+			done := make(chan struct{})
+			defer close(done)
+			go func() {
+				for {
+					select {
+					case <-done:
+						return
+					case <-time.After(time.Millisecond):
+					}
+					metrics.Read(samples)
+					if v := samples[0].Value.Uint64(); v > peak.Load() {
+						peak.Store(v)
+					}
+				}
+			}()
+
+			runtime.GC()
+			metrics.Read(samples)
+			base := samples[0].Value.Uint64()
+			peak.Store(base)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				h.ServeHTTP(discardResponseWriter{http.Header{}}, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+			}
+			b.StopTimer()
+			b.ReportMetric(float64(peak.Load()-base)/(1<<20), "peak-heap-MiB")
+		})
+	}
+}
+
+// TestRegistryChildCache validates that existing series are returned from
+// the child cache regardless of the order of their labels, and that the cache
+// is invalidated when series expire.
+func TestRegistryChildCache(t *testing.T) {
+	clock.ClockInstance = &clock.Clock{Instant: time.Unix(0, 0)}
+	defer func() { clock.ClockInstance = nil }()
+
+	r := registry.NewRegistry(prometheus.NewRegistry(), &mapper.MetricMapper{})
+	mapping := &mapper.MetricMapping{Ttl: time.Second}
+	counter, err := r.GetCounter("cached_counter", prometheus.Labels{"a": "1", "b": "2"}, "help", mapping, metricsCount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counter.Add(1)
+
+	clock.ClockInstance.Instant = time.Unix(0, int64(900*time.Millisecond))
+	cached, err := r.GetCounter("cached_counter", prometheus.Labels{"b": "2", "a": "1"}, "help", mapping, metricsCount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cached != counter {
+		t.Fatal("expected the existing counter to be returned")
+	}
+	if _, err := r.GetGauge("cached_counter", prometheus.Labels{"a": "1", "b": "2"}, "help", mapping, metricsCount); err == nil {
+		t.Fatal("expected a gauge with the name of a counter to be rejected")
+	}
+
+	// The lookup from the cache kept the counter alive.
+	clock.ClockInstance.Instant = time.Unix(1, int64(500*time.Millisecond))
+	r.RemoveStaleMetrics()
+	if _, series := r.Size(); series != 1 {
+		t.Fatal("expected the counter to be kept alive by the cached lookup")
+	}
+
+	clock.ClockInstance.Instant = time.Unix(3, 0)
+	r.RemoveStaleMetrics()
+	recreated, err := r.GetCounter("cached_counter", prometheus.Labels{"a": "1", "b": "2"}, "help", mapping, metricsCount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recreated == counter {
+		t.Fatal("expected the expired counter to be recreated")
+	}
+	if v := getTelemetryCounterValue(recreated); v != 0 {
+		t.Fatalf("expected the recreated counter to start at 0, got %v", v)
+	}
+}
+
+func BenchmarkRegistryGetCounter(b *testing.B) {
+	labels := map[string]string{
+		"label0": "value",
+		"label1": "value",
+		"label2": "value",
+		"label3": "value",
+		"label4": "value",
+	}
+	mapping := &mapper.MetricMapping{}
+
+	r := registry.NewRegistry(prometheus.NewRegistry(), &mapper.MetricMapper{})
+	if _, err := r.GetCounter("foo_counter", labels, "help", mapping, metricsCount); err != nil {
+		b.Fatalf("Unable to create counter: %v", err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if _, err := r.GetCounter("foo_counter", labels, "help", mapping, metricsCount); err != nil {
+			b.Fatalf("Unable to get counter: %v", err)
+		}
+	}
+}
+
+// TestQuarantine validates that events for new metric names are dropped
+// until the names are approved, or the quarantine period has passed.
+func TestQuarantine(t *testing.T) {
+	clock.ClockInstance = &clock.Clock{Instant: time.Unix(1000, 0)}
+	defer func() { clock.ClockInstance = nil }()
+
+	events := make(chan event.Events)
+	testMapper := mapper.MetricMapper{}
+	if err := testMapper.InitFromYAMLString(""); err != nil {
+		t.Fatal(err)
+	}
+	ex := NewExporter(prometheus.DefaultRegisterer, &testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+	heldEvents := prometheus.NewCounter(prometheus.CounterOpts{})
+	ex.Quarantine = NewQuarantine(time.Minute)
+	ex.Quarantine.HeldEvents = heldEvents
+	go ex.Listen(events)
+
+	send := func(name string) {
+		events <- event.Events{event.NewCounterEvent(name, 1, map[string]string{})}
+		events <- event.Events{}
+	}
+	value := func(name string) *float64 {
+		metrics, err := prometheus.DefaultGatherer.Gather()
+		if err != nil {
+			t.Fatalf("Cannot gather from DefaultGatherer: %v", err)
+		}
+		return getFloat64(metrics, name, prometheus.Labels{})
+	}
+
+	send("quarantined_approved")
+	send("quarantined_expired")
+	if v := value("quarantined_approved"); v != nil {
+		t.Fatalf("expected quarantined metric to not be exported, got %v", *v)
+	}
+	if v := getTelemetryCounterValue(heldEvents); v != 2 {
+		t.Fatalf("expected 2 held events, got %v", v)
+	}
+
+	rec := httptest.NewRecorder()
+	ex.Quarantine.ServeHTTP(rec, httptest.NewRequest("GET", "/debug/quarantine", nil))
+	var held []QuarantinedName
+	if err := json.Unmarshal(rec.Body.Bytes(), &held); err != nil {
+		t.Fatal(err)
+	}
+	if len(held) != 2 || held[0].Name != "quarantined_approved" || held[0].Events != 1 || held[0].ReleaseAt == nil {
+		t.Fatalf("unexpected held names %+v", held)
+	}
+
+	handler := &QuarantineApprovalHandler{Quarantine: ex.Quarantine, Logger: promslog.NewNopLogger()}
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/api/v1/quarantine/approve", strings.NewReader(`{"names": ["quarantined_approved"]}`)))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected approval to succeed, got %d: %s", rec.Code, rec.Body)
+	}
+	send("quarantined_approved")
+	if v := value("quarantined_approved"); v == nil || *v != 1 {
+		t.Fatalf("expected approved metric to be 1, got %v", v)
+	}
+
+	send("quarantined_expired")
+	if v := value("quarantined_expired"); v != nil {
+		t.Fatalf("expected quarantined metric to not be exported before the period passed, got %v", *v)
+	}
+	clock.ClockInstance.Instant = clock.ClockInstance.Instant.Add(time.Minute)
+	send("quarantined_expired")
+	if v := value("quarantined_expired"); v == nil || *v != 1 {
+		t.Fatalf("expected metric to be 1 after the quarantine period, got %v", v)
+	}
+	close(events)
+
+	if held := ex.Quarantine.Held(); len(held) != 0 {
+		t.Fatalf("expected no held names, got %+v", held)
+	}
+}
+
+// TestQuarantineFull validates that names whose quarantine period has passed
+// are approved to make room for new names once the list is full.
+func TestQuarantineFull(t *testing.T) {
+	clock.ClockInstance = &clock.Clock{Instant: time.Unix(1000, 0)}
+	defer func() { clock.ClockInstance = nil }()
+
+	q := NewQuarantine(time.Minute)
+	for i := 0; i < maxQuarantinedNames; i++ {
+		q.admit(fmt.Sprintf("name_%d", i))
+	}
+	if q.admit("overflow") {
+		t.Fatalf("expected new name to be quarantined")
+	}
+	if held := q.Held(); len(held) != maxQuarantinedNames {
+		t.Fatalf("expected %d quarantined names, got %d", maxQuarantinedNames, len(held))
+	}
+
+	clock.ClockInstance.Instant = clock.ClockInstance.Instant.Add(time.Minute)
+	if q.admit("overflow") {
+		t.Fatalf("expected name that was not listed to be quarantined")
+	}
+	held := q.Held()
+	if len(held) != 1 || held[0].Name != "overflow" {
+		t.Fatalf("expected only the new name to be quarantined, got %d names", len(held))
+	}
+	if !q.admit("name_0") {
+		t.Fatalf("expected name to be approved after the quarantine period")
+	}
+}
+
+func TestDeadLetters(t *testing.T) {
+	events := make(chan event.Events)
+	testMapper := mapper.MetricMapper{}
+	if err := testMapper.InitFromYAMLString(""); err != nil {
+		t.Fatal(err)
+	}
+	rejected := prometheus.NewCounterVec(prometheus.CounterOpts{}, []string{"reason"})
+	deadLetters := NewDeadLetters(2, rejected)
+
+	done := make(chan struct{})
+	go func() {
+		ex := NewExporter(prometheus.NewRegistry(), &testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+		ex.DeadLetters = deadLetters
+		ex.Listen(events)
+		close(done)
+	}()
+
+	events <- event.Events{
+		event.NewCounterEvent("dead_letter_negative", -1, map[string]string{}),
+		event.NewCounterEvent("dead_letter_conflict", 1, map[string]string{"a": "1"}),
+		event.NewGaugeEvent("dead_letter_conflict", 1, false, map[string]string{"a": "2"}),
+		event.NewGaugeEvent("dead_letter_conflict", 2, false, map[string]string{"a": "3"}),
+	}
+	close(events)
+	<-done
+
+	// The buffer only holds the two most recent events.
+	letters := deadLetters.Letters()
+	if len(letters) != 2 {
+		t.Fatalf("expected 2 dead letters, got %v", letters)
+	}
+	for i, value := range []float64{1, 2} {
+		l := letters[i]
+		if l.Reason != DeadLetterConflict || l.MetricName != "dead_letter_conflict" || l.Type != "gauge" || l.Value != value || l.Error == "" {
+			t.Errorf("unexpected dead letter %+v", l)
+		}
+	}
+	for reason, count := range map[string]float64{DeadLetterNegativeCounter: 1, DeadLetterConflict: 2} {
+		if v := getTelemetryCounterValue(rejected.WithLabelValues(reason)); v != count {
+			t.Errorf("expected %v events dead-lettered for %s, got %v", count, reason, v)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	deadLetters.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/dead-letters?reason="+DeadLetterNegativeCounter, nil))
+	if body := strings.TrimSpace(rec.Body.String()); body != "[]" {
+		t.Errorf("expected evicted events to not be served, got %s", body)
+	}
+}
+
+// TestCounterWindow validates that counter increments are accumulated and
+// applied once their window has passed.
+func TestCounterWindow(t *testing.T) {
+	clock.ClockInstance = &clock.Clock{Instant: time.Unix(100, 0), TickerCh: make(chan time.Time)}
+	defer func() { clock.ClockInstance = nil }()
+
+	testMapper := mapper.MetricMapper{}
+	config := `mappings:
+- match: hot.*
+  name: hot_total
+  counter_window: 10s
+  labels:
+    kind: $1
+  rollups:
+  - name: hot_all_total
+    drop_labels: [kind]
+`
+	if err := testMapper.InitFromYAMLString(config); err != nil {
+		t.Fatalf("Config load error: %s %s", config, err)
+	}
+
+	reg := prometheus.NewRegistry()
+	windows := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "windows"}, []string{"metric"})
+	events := make(chan event.Events)
+	ex := NewExporter(reg, &testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+	ex.CounterWindows = windows
+	done := make(chan struct{})
+	go func() {
+		ex.Listen(events)
+		close(done)
+	}()
+
+	value := func(name string, labels prometheus.Labels) float64 {
+		t.Helper()
+		metrics, err := reg.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		v := getFloat64(metrics, name, labels)
+		if v == nil {
+			t.Fatalf("%s%v not found", name, labels)
+		}
+		return *v
+	}
+
+	events <- event.Events{
+		event.NewCounterEvent("hot.a", 1, map[string]string{}),
+		event.NewCounterEvent("hot.a", 2, map[string]string{}),
+		event.NewCounterEvent("hot.b", 4, map[string]string{}),
+	}
+	// Wait for the events to be handled.
+	events <- event.Events{}
+	if v := value("hot_total", prometheus.Labels{"kind": "a"}); v != 0 {
+		t.Fatalf("expected increments to be pending, got %v", v)
+	}
+
+	clock.ClockInstance.Instant = time.Unix(109, 0)
+	clock.ClockInstance.TickerCh <- time.Unix(109, 0)
+	events <- event.Events{}
+	if v := value("hot_total", prometheus.Labels{"kind": "a"}); v != 0 {
+		t.Fatalf("expected increments to be pending before the window passed, got %v", v)
+	}
+
+	clock.ClockInstance.Instant = time.Unix(110, 0)
+	clock.ClockInstance.TickerCh <- time.Unix(110, 0)
+	events <- event.Events{
+		event.NewCounterEvent("hot.a", 8, map[string]string{}),
+	}
+	events <- event.Events{}
+	if v := value("hot_total", prometheus.Labels{"kind": "a"}); v != 3 {
+		t.Fatalf("expected the increments of the first window, got %v", v)
+	}
+	if v := value("hot_all_total", prometheus.Labels{}); v != 7 {
+		t.Fatalf("expected the rollup to be applied with the first window, got %v", v)
+	}
+
+	// Pending increments are applied when the exporter stops.
+	close(events)
+	<-done
+	if v := value("hot_total", prometheus.Labels{"kind": "a"}); v != 11 {
+		t.Fatalf("expected pending increments to be applied on stop, got %v", v)
+	}
+
+	var m dto.Metric
+	if err := windows.WithLabelValues("hot_total").Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	if v := m.GetGauge().GetValue(); v != 10 {
+		t.Fatalf("expected a window of 10s, got %v", v)
+	}
+}
+
+// TestSets validates that sets are exported as the number of unique members
+// in the last window.
+func TestSets(t *testing.T) {
+	events := make(chan event.Events)
+	testMapper := mapper.MetricMapper{}
+	reg := prometheus.NewRegistry()
+	ex := NewExporter(reg, &testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+	ex.SetWindow = time.Hour
+	done := make(chan struct{})
+	go func() {
+		ex.Listen(events)
+		close(done)
+	}()
+
+	events <- event.Events{
+		event.NewSetEvent("users_online", "abc", map[string]string{}),
+		event.NewSetEvent("users_online", "def", map[string]string{}),
+		event.NewSetEvent("users_online", "abc", map[string]string{}),
+		event.NewSetEvent("users_online", "ghi", map[string]string{"region": "eu"}),
+	}
+	close(events)
+	<-done
+
+	value := func(labels prometheus.Labels) float64 {
+		t.Helper()
+		metrics, err := reg.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		v := getFloat64(metrics, "users_online", labels)
+		if v == nil {
+			t.Fatalf("users_online%v not found", labels)
+		}
+		return *v
+	}
+
+	if v := value(prometheus.Labels{}); v != 0 {
+		t.Fatalf("expected the set to be 0 before the window ended, got %v", v)
+	}
+	ex.rotateSets()
+	if v := value(prometheus.Labels{}); v != 2 {
+		t.Fatalf("expected 2 unique members, got %v", v)
+	}
+	if v := value(prometheus.Labels{"region": "eu"}); v != 1 {
+		t.Fatalf("expected 1 unique member, got %v", v)
+	}
+
+	ex.rotateSets()
+	if v := value(prometheus.Labels{}); v != 0 {
+		t.Fatalf("expected an empty window to reset the set, got %v", v)
+	}
+	if len(ex.sets) != 0 {
+		t.Fatalf("expected empty sets to be forgotten, got %d", len(ex.sets))
+	}
+}
+
+// TestMultiObserverEvent validates that the values of packed samples are
+// observed one by one, as often as their sample rate stands for.
+func TestMultiObserverEvent(t *testing.T) {
+	events := make(chan event.Events)
+	testMapper := mapper.MetricMapper{}
+	config := `mappings:
+- match: dist
+  name: dist
+  observer_type: histogram`
+	if err := testMapper.InitFromYAMLString(config); err != nil {
+		t.Fatalf("Config load error: %s %s", config, err)
+	}
+
+	reg := prometheus.NewRegistry()
+	ex := NewExporter(reg, &testMapper, promslog.NewNopLogger(), eventsActions, eventsUnmapped, errorEventStats, eventStats, conflictingEventStats, metricsCount)
+	done := make(chan struct{})
+	go func() {
+		ex.Listen(events)
+		close(done)
+	}()
+
+	events <- event.Events{
+		event.NewMultiObserverEvent("dist", []float64{1, 2, 3.5}, 0.5, map[string]string{"tag": "v"}),
+	}
+	close(events)
+	<-done
+
+	metrics, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := getFloat64(metrics, "dist", prometheus.Labels{"tag": "v"}); v == nil || *v != 13 {
+		t.Fatalf("expected a sum of 13, got %v", v)
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"sync"
+
+	"github.com/golang/groupcache/lru"
+)
+
+// hashLabelValue returns the short hash that replaces the value of a label
+// listed in a mapping's hash_labels.
+func hashLabelValue(value string) string {
+	h := fnv.New64a()
+	h.Write([]byte(value))
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// LabelHashTable remembers the values of the most recently hashed label
+// values, so that hashes can be looked up when debugging.
+type LabelHashTable struct {
+	mutex  sync.Mutex
+	values *lru.Cache
+}
+
+type labelHashEntry struct {
+	Hash  string `json:"hash"`
+	Label string `json:"label"`
+	Value string `json:"value"`
+}
+
+// NewLabelHashTable returns a table that holds up to size hashes.
+func NewLabelHashTable(size int) *LabelHashTable {
+	return &LabelHashTable{values: lru.New(size)}
+}
+
+// Hash returns the hash of the value of the given label and records it.
+func (t *LabelHashTable) Hash(label, value string) string {
+	hash := hashLabelValue(value)
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.values.Add(hash, labelHashEntry{Hash: hash, Label: label, Value: value})
+	return hash
+}
+
+// Lookup returns the label and value a hash was computed from, if it is still
+// in the table.
+func (t *LabelHashTable) Lookup(hash string) (label, value string, ok bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	e, ok := t.values.Get(hash)
+	if !ok {
+		return "", "", false
+	}
+	entry := e.(labelHashEntry)
+	return entry.Label, entry.Value, true
+}
+
+// ServeHTTP looks up the hash in the "hash" path value and writes the label
+// and value it was computed from as JSON.
+func (t *LabelHashTable) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	hash := r.PathValue("hash")
+	label, value, ok := t.Lookup(hash)
+	if !ok {
+		http.Error(w, fmt.Sprintf("hash %q not found", hash), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(labelHashEntry{Hash: hash, Label: label, Value: value}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
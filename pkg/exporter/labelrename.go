@@ -0,0 +1,40 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// renameLabels renames labels in place, from the keys of renames to their
+// values. A label that is already present under the new name keeps its
+// value, and the renamed label is dropped. All labels are renamed at once, so
+// that renames can swap the names of labels.
+func renameLabels(labels prometheus.Labels, renames map[string]string) {
+	var renamed prometheus.Labels
+	for from, to := range renames {
+		if value, ok := labels[from]; ok {
+			if renamed == nil {
+				renamed = prometheus.Labels{}
+			}
+			renamed[to] = value
+		}
+	}
+	for from := range renames {
+		delete(labels, from)
+	}
+	for to, value := range renamed {
+		if _, ok := labels[to]; !ok {
+			labels[to] = value
+		}
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/prometheus/statsd_exporter/pkg/clock"
+)
+
+// LatencyTracker measures the time from receiving sampled events until
+// their values are first served to a scraper. The Exporter reports when a
+// sample has been applied, and the ScrapeHandler when a scrape starts.
+type LatencyTracker struct {
+	Latency prometheus.Observer
+
+	mtx     sync.Mutex
+	applied []appliedSample
+}
+
+type appliedSample struct {
+	receivedAt, appliedAt time.Time
+}
+
+// maxAppliedSamples bounds the number of samples waiting for a scrape, in
+// case the exporter is not scraped.
+const maxAppliedSamples = 1000
+
+// Applied records that a sample received at receivedAt has been applied.
+func (l *LatencyTracker) Applied(receivedAt time.Time) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	if len(l.applied) >= maxAppliedSamples {
+		l.applied = l.applied[1:]
+	}
+	l.applied = append(l.applied, appliedSample{receivedAt: receivedAt, appliedAt: clock.Now()})
+}
+
+// Scraped observes the latency of all samples applied before a scrape that
+// started at start.
+func (l *LatencyTracker) Scraped(start time.Time) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	pending := l.applied[:0]
+	for _, s := range l.applied {
+		if s.appliedAt.After(start) {
+			pending = append(pending, s)
+			continue
+		}
+		l.Latency.Observe(start.Sub(s.receivedAt).Seconds())
+	}
+	l.applied = pending
+}
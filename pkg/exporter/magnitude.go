@@ -0,0 +1,172 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/statsd_exporter/pkg/clock"
+)
+
+// MagnitudeTracker records a coarse base-10 magnitude histogram of the values
+// observed per observer metric. It is a diagnostic aid for choosing histogram
+// buckets or native histogram settings, and is not exported as metrics.
+//
+// Counts are kept for the current window and the last completed one.
+type MagnitudeTracker struct {
+	window time.Duration
+
+	mutex       sync.Mutex
+	windowStart time.Time
+	current     map[string]map[string]uint64
+	previous    map[string]map[string]uint64
+}
+
+// MagnitudeReport is the JSON representation of one window of magnitudes.
+type MagnitudeReport struct {
+	Start   time.Time                    `json:"start"`
+	End     time.Time                    `json:"end"`
+	Metrics map[string]map[string]uint64 `json:"metrics"`
+}
+
+// NewMagnitudeTracker returns a tracker whose windows last the given
+// duration, starting now.
+func NewMagnitudeTracker(window time.Duration) *MagnitudeTracker {
+	return &MagnitudeTracker{
+		window:      window,
+		windowStart: clock.Now(),
+		current:     make(map[string]map[string]uint64),
+	}
+}
+
+// magnitudeKey returns the bucket a value falls into. Buckets are powers of
+// ten, so 0.25 falls into "1e-1" and 1500 into "1e3". Negative values are
+// kept apart from positive values of the same magnitude.
+func magnitudeKey(value float64) string {
+	if value == 0 {
+		return "0"
+	}
+	abs := math.Abs(value)
+	exp := int(math.Floor(math.Log10(abs)))
+	// Log10 is not exact for all powers of ten, correct for rounding.
+	if math.Pow10(exp+1) <= abs {
+		exp++
+	} else if math.Pow10(exp) > abs {
+		exp--
+	}
+	if value < 0 {
+		return fmt.Sprintf("-1e%d", exp)
+	}
+	return fmt.Sprintf("1e%d", exp)
+}
+
+// rotate starts a new window if the current one has elapsed. The caller must
+// hold the mutex.
+func (t *MagnitudeTracker) rotate(now time.Time) {
+	if now.Sub(t.windowStart) < t.window {
+		return
+	}
+	// If more than one window has passed without observations, the last
+	// completed window is empty.
+	if now.Sub(t.windowStart) >= 2*t.window {
+		t.previous = make(map[string]map[string]uint64)
+	} else {
+		t.previous = t.current
+	}
+	t.current = make(map[string]map[string]uint64)
+	t.windowStart = t.windowStart.Add(now.Sub(t.windowStart).Truncate(t.window))
+}
+
+// Observe records the magnitude of a value for the given metric. Non-finite
+// values are ignored.
+func (t *MagnitudeTracker) Observe(metricName string, value float64) {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.rotate(clock.Now())
+	counts, ok := t.current[metricName]
+	if !ok {
+		counts = make(map[string]uint64)
+		t.current[metricName] = counts
+	}
+	counts[magnitudeKey(value)]++
+}
+
+// Report returns the last completed window, if any, followed by the current
+// window.
+func (t *MagnitudeTracker) Report() []MagnitudeReport {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	now := clock.Now()
+	t.rotate(now)
+
+	reports := make([]MagnitudeReport, 0, 2)
+	if t.previous != nil {
+		reports = append(reports, MagnitudeReport{
+			Start:   t.windowStart.Add(-t.window),
+			End:     t.windowStart,
+			Metrics: copyMagnitudes(t.previous),
+		})
+	}
+	reports = append(reports, MagnitudeReport{
+		Start:   t.windowStart,
+		End:     now,
+		Metrics: copyMagnitudes(t.current),
+	})
+	return reports
+}
+
+func copyMagnitudes(in map[string]map[string]uint64) map[string]map[string]uint64 {
+	out := make(map[string]map[string]uint64, len(in))
+	for metric, counts := range in {
+		c := make(map[string]uint64, len(counts))
+		for k, v := range counts {
+			c[k] = v
+		}
+		out[metric] = c
+	}
+	return out
+}
+
+// ServeHTTP writes the magnitude report as JSON. The optional "metric" query
+// parameter restricts the output to a single metric.
+func (t *MagnitudeTracker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	reports := t.Report()
+	if metric := r.URL.Query().Get("metric"); metric != "" {
+		for i := range reports {
+			filtered := map[string]map[string]uint64{}
+			if counts, ok := reports[i].Metrics[metric]; ok {
+				filtered[metric] = counts
+			}
+			reports[i].Metrics = filtered
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(reports); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"hash/fnv"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/prometheus/statsd_exporter/pkg/event"
+	"github.com/prometheus/statsd_exporter/pkg/mapper"
+)
+
+// mappedEvent is an event whose mapping has already been looked up.
+type mappedEvent struct {
+	event.Event
+	mapping *mapper.MetricMapping
+	labels  prometheus.Labels
+	present bool
+}
+
+// MappingStage looks up the mappings of events before they are handled by
+// the Exporter. Events whose mapping is cached or matches a glob are passed
+// on right away. Events that need regex mappings to be evaluated are handed
+// to a pool of workers, so that an expensive regular expression doesn't hold
+// up all other events.
+//
+// Events of the same metric are always handled by the same worker, and are
+// not passed on right away while some of them are still queued for a worker,
+// so they reach the Exporter in the order they were received. Latency probes
+// are always passed on right away, and don't wait for the workers. Events for
+// a worker whose queue is full wait for room in the queue, unless they are
+// shed.
+type MappingStage struct {
+	Mapper *mapper.MetricMapper
+	// Workers is the number of workers evaluating regex mappings, each with
+	// a queue of QueueSize events.
+	Workers   int
+	QueueSize int
+	// Events counts events by the path they took, "fast" or "slow".
+	Events *prometheus.CounterVec
+	// QueueLength is the number of events queued for the workers.
+	QueueLength prometheus.Gauge
+	// ShedEvents, if set, counts the events that were dropped because the
+	// queue of their worker was full, so that a worker stuck on an expensive
+	// regular expression doesn't hold up the other events either. Without
+	// it, such events wait for room in the queue.
+	ShedEvents prometheus.Counter
+
+	mtx     sync.Mutex
+	pending map[string]int
+}
+
+// Run looks up the mappings of events from in and sends them to out. It
+// closes out once in is closed and all events are passed on.
+func (s *MappingStage) Run(in <-chan event.Events, out chan<- event.Events) {
+	s.pending = map[string]int{}
+	queues := make([]chan *mappedEvent, s.Workers)
+	var wg sync.WaitGroup
+	for i := range queues {
+		queues[i] = make(chan *mappedEvent, s.QueueSize)
+		wg.Add(1)
+		go func(queue <-chan *mappedEvent) {
+			defer wg.Done()
+			s.work(queue, out)
+		}(queues[i])
+	}
+
+	for events := range in {
+		fast := make(event.Events, 0, len(events))
+		for _, e := range events {
+			if _, ok := e.(*event.Probe); ok {
+				fast = append(fast, e)
+				continue
+			}
+			key := pendingKey(e)
+			s.mtx.Lock()
+			pending := s.pending[key] > 0
+			s.mtx.Unlock()
+
+			if !pending {
+				mapping, labels, present, ok := s.Mapper.GetMappingFast(e.MetricName(), e.MetricType())
+				if ok {
+					fast = append(fast, &mappedEvent{Event: e, mapping: mapping, labels: labels, present: present})
+					s.Events.WithLabelValues("fast").Inc()
+					continue
+				}
+			}
+
+			// Pass on earlier events first, the worker might otherwise
+			// overtake them.
+			if len(fast) > 0 {
+				out <- fast
+				fast = make(event.Events, 0, len(events))
+			}
+			s.mtx.Lock()
+			s.pending[key]++
+			s.mtx.Unlock()
+			s.QueueLength.Inc()
+			queue := queues[shard(key, len(queues))]
+			if s.ShedEvents == nil {
+				queue <- &mappedEvent{Event: e}
+				s.Events.WithLabelValues("slow").Inc()
+				continue
+			}
+			select {
+			case queue <- &mappedEvent{Event: e}:
+				s.Events.WithLabelValues("slow").Inc()
+			default:
+				s.QueueLength.Dec()
+				s.done(key)
+				s.ShedEvents.Inc()
+			}
+		}
+		if len(fast) > 0 {
+			out <- fast
+		}
+	}
+
+	for _, queue := range queues {
+		close(queue)
+	}
+	wg.Wait()
+	close(out)
+}
+
+func (s *MappingStage) work(queue <-chan *mappedEvent, out chan<- event.Events) {
+	for e := range queue {
+		s.QueueLength.Dec()
+		e.mapping, e.labels, e.present = s.Mapper.GetMapping(e.MetricName(), e.MetricType())
+		out <- event.Events{e}
+		s.done(pendingKey(e.Event))
+	}
+}
+
+// done marks an event of the metric with the given key as no longer queued.
+func (s *MappingStage) done(key string) {
+	s.mtx.Lock()
+	if s.pending[key]--; s.pending[key] <= 0 {
+		delete(s.pending, key)
+	}
+	s.mtx.Unlock()
+}
+
+func pendingKey(e event.Event) string {
+	return string(e.MetricType()) + "." + e.MetricName()
+}
+
+func shard(key string, n int) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(n))
+}
@@ -0,0 +1,66 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"runtime/debug"
+	"runtime/metrics"
+)
+
+// memoryLowWatermark is the fraction of MemoryLimit the heap has to shrink
+// below to leave memory protection.
+const memoryLowWatermark = 0.8
+
+// heapSample is the runtime metric compared to MemoryLimit: the memory
+// occupied by live objects and dead objects that haven't been swept yet.
+const heapSample = "/memory/classes/heap/objects:bytes"
+
+// heapBytes returns the current size of the heap. It is a variable so that
+// tests can replace it.
+var heapBytes = func() uint64 {
+	sample := []metrics.Sample{{Name: heapSample}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return sample[0].Value.Uint64()
+}
+
+// checkMemory enters memory protection once the heap grows beyond
+// MemoryLimit, and leaves it once the heap shrank below the low watermark.
+// While protected, no new series are created, so the exporter keeps serving
+// the metrics it has instead of being killed for running out of memory.
+func (b *Exporter) checkMemory() {
+	heap := heapBytes()
+	switch {
+	case !b.memoryProtected && heap > b.MemoryLimit:
+		b.Logger.Warn("Heap exceeds memory limit, rejecting new series", "heap", heap, "limit", b.MemoryLimit)
+		b.memoryProtected = true
+		b.Registry.RejectNewSeries(true)
+		b.Mapper.ResetCache()
+		debug.FreeOSMemory()
+	case b.memoryProtected && float64(heap) < float64(b.MemoryLimit)*memoryLowWatermark:
+		b.Logger.Info("Heap is back below memory limit, accepting new series", "heap", heap, "limit", b.MemoryLimit)
+		b.memoryProtected = false
+		b.Registry.RejectNewSeries(false)
+	}
+
+	if b.MemoryProtection != nil {
+		if b.memoryProtected {
+			b.MemoryProtection.Set(1)
+		} else {
+			b.MemoryProtection.Set(0)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/prometheus/statsd_exporter/pkg/clock"
+)
+
+// OutageDetector pauses the expiry of metrics while the exporter is not
+// scraped, so that series are not lost while Prometheus is down. Expiry is
+// paused once no scrape succeeded for Window, and delayed by the length of the
+// pause once scrapes resume.
+type OutageDetector struct {
+	Window time.Duration
+	// Paused, if set, is the number of seconds expiry has been paused for,
+	// or 0 while it is not paused.
+	Paused prometheus.Gauge
+
+	mu          sync.Mutex
+	lastScrape  time.Time
+	pausedSince time.Time
+}
+
+// NewOutageDetector returns an OutageDetector that considers the exporter
+// scraped when it is created.
+func NewOutageDetector(window time.Duration, paused prometheus.Gauge) *OutageDetector {
+	return &OutageDetector{
+		Window:     window,
+		Paused:     paused,
+		lastScrape: clock.Now(),
+	}
+}
+
+// Scraped records a successful scrape.
+func (o *OutageDetector) Scraped() {
+	o.mu.Lock()
+	o.lastScrape = clock.Now()
+	o.mu.Unlock()
+}
+
+// expiryPaused reports whether metrics must not be expired. When scrapes
+// resume after a pause, the expiry of all metrics in r is delayed by the
+// length of the pause.
+func (o *OutageDetector) expiryPaused(r Registry, logger *slog.Logger) bool {
+	now := clock.Now()
+	o.mu.Lock()
+	lastScrape := o.lastScrape
+	o.mu.Unlock()
+
+	if now.Sub(lastScrape) <= o.Window {
+		if !o.pausedSince.IsZero() {
+			pause := now.Sub(o.pausedSince)
+			logger.Info("Scrapes resumed, resuming metric expiry", "paused", pause)
+			r.DelayExpiry(pause)
+			o.pausedSince = time.Time{}
+		}
+		o.setPaused(0)
+		return false
+	}
+
+	if o.pausedSince.IsZero() {
+		logger.Warn("Exporter has not been scraped, pausing metric expiry", "last_scrape", lastScrape)
+		o.pausedSince = now
+	}
+	o.setPaused(now.Sub(o.pausedSince).Seconds())
+	return true
+}
+
+func (o *OutageDetector) setPaused(seconds float64) {
+	if o.Paused != nil {
+		o.Paused.Set(seconds)
+	}
+}
@@ -0,0 +1,207 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/prometheus/statsd_exporter/pkg/clock"
+)
+
+// maxQuarantinedNames bounds the number of quarantined metric names that are
+// tracked, so that a client sending random names can't exhaust memory.
+// Events for further names are still dropped, but the names are not listed,
+// and their quarantine period only starts once there is room to track them.
+const maxQuarantinedNames = 10000
+
+// Quarantine drops events for metric names the exporter hasn't seen before,
+// so that no series are created for them until they are approved. Names are
+// approved through the admin API, or once they have been quarantined for
+// Period, if it is set. Approvals are not persisted across restarts.
+type Quarantine struct {
+	Period time.Duration
+	// HeldEvents, if set, counts the events that were dropped.
+	HeldEvents prometheus.Counter
+	// HeldNames, if set, is the number of metric names currently quarantined.
+	HeldNames prometheus.Gauge
+
+	mtx      sync.Mutex
+	approved map[string]bool
+	held     map[string]*QuarantinedName
+	// nextRelease is the earliest time at which a tracked name is due for
+	// approval.
+	nextRelease time.Time
+}
+
+// QuarantinedName is a metric name whose events are dropped.
+type QuarantinedName struct {
+	Name      string    `json:"name"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	Events    uint64    `json:"events"`
+	// ReleaseAt is when the name is approved automatically, if ever.
+	ReleaseAt *time.Time `json:"release_at,omitempty"`
+}
+
+func NewQuarantine(period time.Duration) *Quarantine {
+	return &Quarantine{
+		Period:   period,
+		approved: make(map[string]bool),
+		held:     make(map[string]*QuarantinedName),
+	}
+}
+
+// admit reports whether series may be created for metricName. Otherwise the
+// event is dropped.
+func (q *Quarantine) admit(metricName string) bool {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	if q.approved[metricName] {
+		return true
+	}
+	now := clock.Now()
+	h, ok := q.held[metricName]
+	if !ok && len(q.held) >= maxQuarantinedNames {
+		// Make room by approving the names whose period has passed, even if
+		// they are not seen again.
+		q.releaseExpiredLocked(now)
+	}
+	if !ok && len(q.held) < maxQuarantinedNames {
+		h = &QuarantinedName{Name: metricName, FirstSeen: now}
+		q.held[metricName] = h
+		q.updateHeldNames()
+	}
+	if h != nil {
+		if q.Period > 0 && now.Sub(h.FirstSeen) >= q.Period {
+			q.approveLocked(metricName)
+			return true
+		}
+		h.LastSeen = now
+		h.Events++
+	}
+	if q.HeldEvents != nil {
+		q.HeldEvents.Inc()
+	}
+	return false
+}
+
+// Approve releases metric names from the quarantine, or approves them before
+// they are seen.
+func (q *Quarantine) Approve(names ...string) {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+	for _, name := range names {
+		q.approveLocked(name)
+	}
+}
+
+func (q *Quarantine) releaseExpiredLocked(now time.Time) {
+	if q.Period <= 0 || now.Before(q.nextRelease) {
+		return
+	}
+	var oldest time.Time
+	for name, h := range q.held {
+		if now.Sub(h.FirstSeen) >= q.Period {
+			q.approveLocked(name)
+		} else if oldest.IsZero() || h.FirstSeen.Before(oldest) {
+			oldest = h.FirstSeen
+		}
+	}
+	q.nextRelease = oldest.Add(q.Period)
+}
+
+func (q *Quarantine) approveLocked(name string) {
+	q.approved[name] = true
+	delete(q.held, name)
+	q.updateHeldNames()
+}
+
+func (q *Quarantine) updateHeldNames() {
+	if q.HeldNames != nil {
+		q.HeldNames.Set(float64(len(q.held)))
+	}
+}
+
+// Held returns the quarantined metric names, ordered by name.
+func (q *Quarantine) Held() []QuarantinedName {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	held := make([]QuarantinedName, 0, len(q.held))
+	for _, h := range q.held {
+		n := *h
+		if q.Period > 0 {
+			releaseAt := h.FirstSeen.Add(q.Period)
+			n.ReleaseAt = &releaseAt
+		}
+		held = append(held, n)
+	}
+	sort.Slice(held, func(i, j int) bool { return held[i].Name < held[j].Name })
+	return held
+}
+
+// ServeHTTP lists the quarantined metric names as JSON.
+func (q *Quarantine) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(q.Held()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// quarantineApproval is the body of a request to approve metric names.
+type quarantineApproval struct {
+	Names []string `json:"names"`
+}
+
+// QuarantineApprovalHandler approves the metric names in the JSON body of
+// POST requests. Approvals are logged.
+type QuarantineApprovalHandler struct {
+	Quarantine *Quarantine
+	Logger     *slog.Logger
+}
+
+func (h *QuarantineApprovalHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var approval quarantineApproval
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&approval); err != nil {
+		http.Error(w, fmt.Sprintf("invalid approval: %v", err), http.StatusBadRequest)
+		return
+	}
+	if len(approval.Names) == 0 {
+		http.Error(w, "no metric names to approve", http.StatusBadRequest)
+		return
+	}
+
+	h.Quarantine.Approve(approval.Names...)
+	h.Logger.Info("Approved quarantined metric names", "names", approval.Names, "remote_addr", r.RemoteAddr)
+	w.WriteHeader(http.StatusNoContent)
+}
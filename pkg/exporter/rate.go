@@ -0,0 +1,136 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/statsd_exporter/pkg/clock"
+)
+
+// rateUpdateInterval is how often the ingest rates are updated.
+const rateUpdateInterval = 5 * time.Second
+
+// RateTracker estimates the rate of samples per second received for each
+// exported metric family, as an exponentially weighted moving average. It is
+// a diagnostic aid for finding metrics to aggregate, sample or drop, and is
+// not exported as metrics.
+type RateTracker struct {
+	// window is the time constant of the moving average. A change in rate
+	// is reflected by about two thirds after one window.
+	window time.Duration
+
+	mutex      sync.Mutex
+	lastUpdate time.Time
+	counts     map[string]uint64
+	rates      map[string]float64
+}
+
+// MetricRate is the JSON representation of the ingest rate of a metric.
+type MetricRate struct {
+	Name             string  `json:"name"`
+	SamplesPerSecond float64 `json:"samples_per_second"`
+}
+
+func NewRateTracker(window time.Duration) *RateTracker {
+	return &RateTracker{
+		window:     window,
+		lastUpdate: clock.Now(),
+		counts:     make(map[string]uint64),
+		rates:      make(map[string]float64),
+	}
+}
+
+// update folds the counts since the last update into the moving averages, if
+// the update interval has elapsed. The caller must hold the mutex.
+func (t *RateTracker) update(now time.Time) {
+	elapsed := now.Sub(t.lastUpdate)
+	if elapsed < rateUpdateInterval {
+		return
+	}
+	alpha := 1 - math.Exp(-elapsed.Seconds()/t.window.Seconds())
+	for name, rate := range t.rates {
+		rate += alpha * (float64(t.counts[name])/elapsed.Seconds() - rate)
+		// Forget metrics that are no longer received.
+		if rate < 1e-3 {
+			delete(t.rates, name)
+			continue
+		}
+		t.rates[name] = rate
+	}
+	for name, count := range t.counts {
+		if _, ok := t.rates[name]; !ok {
+			t.rates[name] = alpha * float64(count) / elapsed.Seconds()
+		}
+	}
+	t.counts = make(map[string]uint64, len(t.counts))
+	t.lastUpdate = now
+}
+
+// Observe records a sample for the given metric family.
+func (t *RateTracker) Observe(metricName string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.update(clock.Now())
+	t.counts[metricName]++
+}
+
+// Rates returns the estimated ingest rates, highest first.
+func (t *RateTracker) Rates() []MetricRate {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.update(clock.Now())
+	rates := make([]MetricRate, 0, len(t.rates))
+	for name, rate := range t.rates {
+		rates = append(rates, MetricRate{Name: name, SamplesPerSecond: rate})
+	}
+	sort.Slice(rates, func(i, j int) bool {
+		if rates[i].SamplesPerSecond != rates[j].SamplesPerSecond {
+			return rates[i].SamplesPerSecond > rates[j].SamplesPerSecond
+		}
+		return rates[i].Name < rates[j].Name
+	})
+	return rates
+}
+
+// ServeHTTP writes the ingest rates as JSON. The optional "limit" query
+// parameter restricts the output to the metrics with the highest rates.
+func (t *RateTracker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rates := t.Rates()
+	if l := r.URL.Query().Get("limit"); l != "" {
+		limit, err := strconv.Atoi(l)
+		if err != nil || limit < 0 {
+			http.Error(w, "invalid limit "+strconv.Quote(l), http.StatusBadRequest)
+			return
+		}
+		if limit < len(rates) {
+			rates = rates[:limit]
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(rates); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
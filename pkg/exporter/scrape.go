@@ -0,0 +1,255 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+
+	"github.com/prometheus/statsd_exporter/pkg/clock"
+)
+
+// scrapeTimeoutHeader is set by Prometheus to the scrape timeout in seconds.
+const scrapeTimeoutHeader = "X-Prometheus-Scrape-Timeout-Seconds"
+
+// ScrapeHandler serves metrics within the scrape deadline. The deadline is
+// taken from the scrape timeout sent by Prometheus, less TimeoutOffset, and
+// capped at Timeout. Scrapes that cannot be gathered in time fail instead of
+// running into the scraper's timeout without a trace.
+type ScrapeHandler struct {
+	Gatherer      prometheus.Gatherer
+	Timeout       time.Duration
+	TimeoutOffset time.Duration
+	// EnableOpenMetrics serves the OpenMetrics format to scrapers that ask
+	// for it, including _created samples with the time counters, histograms
+	// and summaries were created or last reset.
+	EnableOpenMetrics bool
+	// SoftDeadline is the fraction of the scrape deadline after which a
+	// scrape is counted as slow.
+	SoftDeadline   float64
+	SlowScrapes    prometheus.Counter
+	AbortedScrapes prometheus.Counter
+	Logger         *slog.Logger
+	// Latency, if set, is notified of successful scrapes.
+	Latency *LatencyTracker
+	// Outage, if set, is notified of successful scrapes.
+	Outage *OutageDetector
+	// Scrapes, if set, records successful scrapes.
+	Scrapes *ScrapeRecorder
+	// Stream, if set, is served after the Gatherer one metric family at a
+	// time, to bound the memory needed to serve large registries.
+	Stream *StreamingRegistry
+}
+
+// scrapeTimeout returns the time available to serve the request, or 0 if it
+// is not limited.
+func (h *ScrapeHandler) scrapeTimeout(r *http.Request) time.Duration {
+	timeout := h.Timeout
+	if v := r.Header.Get(scrapeTimeoutHeader); v != "" {
+		seconds, err := strconv.ParseFloat(v, 64)
+		if err != nil || seconds <= 0 {
+			h.Logger.Debug("Ignoring invalid scrape timeout", "value", v)
+			return timeout
+		}
+		t := time.Duration(seconds*float64(time.Second)) - h.TimeoutOffset
+		if t <= 0 {
+			t = time.Duration(seconds * float64(time.Second))
+		}
+		if timeout == 0 || t < timeout {
+			timeout = t
+		}
+	}
+	return timeout
+}
+
+func (h *ScrapeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	gatherStart := clock.Now()
+	ctx := r.Context()
+	timeout := h.scrapeTimeout(r)
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	g := &contextGatherer{ctx: ctx, gatherer: h.Gatherer}
+	format := expfmt.Negotiate(r.Header)
+	if h.EnableOpenMetrics {
+		format = expfmt.NegotiateIncludingOpenMetrics(r.Header)
+	}
+	switch {
+	case h.Stream != nil:
+		h.serveStreaming(w, r, g, format)
+	case format.FormatType() == expfmt.TypeOpenMetrics:
+		h.serveOpenMetrics(w, r, g, format)
+	default:
+		promhttp.HandlerFor(g, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+	}
+
+	elapsed := time.Since(start)
+	if g.aborted {
+		h.AbortedScrapes.Inc()
+		h.Logger.Warn("Aborted scrape that exceeded its deadline", "timeout", timeout, "elapsed", elapsed)
+		if h.Stream != nil {
+			// The response has already started, so the scraper can only
+			// tell that it is incomplete if the connection is aborted.
+			panic(http.ErrAbortHandler)
+		}
+		return
+	}
+	if h.Latency != nil && g.err == nil {
+		h.Latency.Scraped(gatherStart)
+	}
+	if h.Outage != nil && g.err == nil {
+		h.Outage.Scraped()
+	}
+	if h.Scrapes != nil && g.err == nil {
+		h.Scrapes.Scraped(gatherStart)
+	}
+	if timeout > 0 && h.SoftDeadline > 0 && elapsed > time.Duration(float64(timeout)*h.SoftDeadline) {
+		h.SlowScrapes.Inc()
+		h.Logger.Warn("Scrape exceeded soft deadline", "timeout", timeout, "elapsed", elapsed)
+	}
+}
+
+// serveOpenMetrics writes the OpenMetrics format with _created samples, which
+// promhttp can't be asked to include.
+func (h *ScrapeHandler) serveOpenMetrics(w http.ResponseWriter, r *http.Request, g prometheus.Gatherer, format expfmt.Format) {
+	mfs, err := g.Gather()
+	if err != nil {
+		http.Error(w, "An error has occurred while serving metrics:\n\n"+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.writeFamilies(w, r, format, func(enc expfmt.Encoder) error {
+		return encodeFamilies(enc, mfs)
+	})
+}
+
+// serveStreaming writes the metrics of the Gatherer followed by those of
+// Stream, which are gathered one metric family at a time. Once the response
+// has started, errors can't be reported to the scraper anymore. They are
+// logged instead, and the scrape is aborted once the deadline is exceeded.
+func (h *ScrapeHandler) serveStreaming(w http.ResponseWriter, r *http.Request, g *contextGatherer, format expfmt.Format) {
+	mfs, err := g.Gather()
+	if err != nil {
+		http.Error(w, "An error has occurred while serving metrics:\n\n"+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.writeFamilies(w, r, format, func(enc expfmt.Encoder) error {
+		if err := encodeFamilies(enc, mfs); err != nil {
+			return err
+		}
+		for _, name := range h.Stream.names() {
+			if g.ctx.Err() != nil {
+				g.aborted = true
+				return nil
+			}
+			mfs, err := h.Stream.gatherFamily(name)
+			if err != nil {
+				h.Logger.Error("Error gathering metric family", "name", name, "error", err)
+				g.err = err
+			}
+			if err := encodeFamilies(enc, mfs); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// writeFamilies sets up the encoder for the response and calls write with it.
+func (h *ScrapeHandler) writeFamilies(w http.ResponseWriter, r *http.Request, format expfmt.Format, write func(enc expfmt.Encoder) error) {
+	w.Header().Set("Content-Type", string(format))
+	var out io.Writer = w
+	if gzipAccepted(r.Header) {
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		defer gz.Close()
+		out = gz
+	}
+
+	enc := expfmt.NewEncoder(out, format, expfmt.WithCreatedLines())
+	if err := write(enc); err != nil {
+		h.Logger.Error("Error encoding metric family", "error", err)
+		return
+	}
+	if closer, ok := enc.(expfmt.Closer); ok {
+		if err := closer.Close(); err != nil {
+			h.Logger.Error("Error encoding metrics", "error", err)
+		}
+	}
+}
+
+func encodeFamilies(enc expfmt.Encoder, mfs []*dto.MetricFamily) error {
+	for _, mf := range mfs {
+		if err := enc.Encode(mf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func gzipAccepted(header http.Header) bool {
+	for _, part := range strings.Split(header.Get("Accept-Encoding"), ",") {
+		part = strings.TrimSpace(part)
+		if part == "gzip" || strings.HasPrefix(part, "gzip;") {
+			return true
+		}
+	}
+	return false
+}
+
+// contextGatherer stops waiting for a gatherer once the context is done. The
+// underlying collection can't be interrupted and finishes in the background.
+type contextGatherer struct {
+	ctx      context.Context
+	gatherer prometheus.Gatherer
+	aborted  bool
+	err      error
+}
+
+type gatherResult struct {
+	mfs []*dto.MetricFamily
+	err error
+}
+
+func (g *contextGatherer) Gather() ([]*dto.MetricFamily, error) {
+	result := make(chan gatherResult, 1)
+	go func() {
+		mfs, err := g.gatherer.Gather()
+		result <- gatherResult{mfs: mfs, err: err}
+	}()
+
+	select {
+	case r := <-result:
+		g.err = r.err
+		return r.mfs, r.err
+	case <-g.ctx.Done():
+		g.aborted = true
+		return nil, fmt.Errorf("gathering metrics: %w", g.ctx.Err())
+	}
+}
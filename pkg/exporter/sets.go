@@ -0,0 +1,45 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// addSetMember adds member to the set counted by gauge in the current
+// window.
+func (b *Exporter) addSetMember(gauge prometheus.Gauge, member string) {
+	if b.sets == nil {
+		b.sets = map[prometheus.Gauge]map[string]struct{}{}
+	}
+	members, ok := b.sets[gauge]
+	if !ok {
+		members = map[string]struct{}{}
+		b.sets[gauge] = members
+	}
+	members[member] = struct{}{}
+}
+
+// rotateSets sets the gauge of every set to the number of unique members it
+// received in the window that ended, and starts the next window. Sets that
+// received no members are set to 0 once, and then forgotten until they
+// receive members again.
+func (b *Exporter) rotateSets() {
+	for gauge, members := range b.sets {
+		gauge.Set(float64(len(members)))
+		if len(members) == 0 {
+			delete(b.sets, gauge)
+			continue
+		}
+		clear(members)
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"sync"
+	"time"
+)
+
+// ScrapeRecorder records the start of successful scrapes, so that the series
+// of mappings with sparse_scrapes can be removed once they have been exported
+// by enough scrapes.
+type ScrapeRecorder struct {
+	mtx    sync.Mutex
+	starts []time.Time
+}
+
+// Scraped records a successful scrape that started at start.
+func (s *ScrapeRecorder) Scraped(start time.Time) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.starts = append(s.starts, start)
+}
+
+// take returns the starts of the scrapes recorded since it was last called.
+func (s *ScrapeRecorder) take() []time.Time {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	starts := s.starts
+	s.starts = nil
+	return starts
+}
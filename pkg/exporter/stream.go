@@ -0,0 +1,116 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// namedCollector is a collector of a single metric family, such as the
+// collectors the registry registers for the vectors it creates.
+type namedCollector interface {
+	prometheus.Collector
+	MetricName() string
+}
+
+// StreamingRegistry is a prometheus.Registerer for the metrics created from
+// StatsD events. Unlike a prometheus.Registry, it is gathered one metric
+// family at a time, so that serving millions of series doesn't require
+// holding all of them in memory at once. Only collectors with a MetricName
+// method can be registered.
+type StreamingRegistry struct {
+	mu       sync.RWMutex
+	families map[string][]prometheus.Collector
+}
+
+func NewStreamingRegistry() *StreamingRegistry {
+	return &StreamingRegistry{families: map[string][]prometheus.Collector{}}
+}
+
+// Register adds a collector. Like unchecked collectors of a
+// prometheus.Registry, it is not checked for consistency with collectors
+// already registered.
+func (s *StreamingRegistry) Register(c prometheus.Collector) error {
+	n, ok := c.(namedCollector)
+	if !ok {
+		return fmt.Errorf("collector %T does not name its metric family", c)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.families[n.MetricName()] = append(s.families[n.MetricName()], c)
+	return nil
+}
+
+func (s *StreamingRegistry) MustRegister(cs ...prometheus.Collector) {
+	for _, c := range cs {
+		if err := s.Register(c); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func (s *StreamingRegistry) Unregister(c prometheus.Collector) bool {
+	n, ok := c.(namedCollector)
+	if !ok {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	name := n.MetricName()
+	i := slices.Index(s.families[name], c)
+	if i < 0 {
+		return false
+	}
+	s.families[name] = slices.Delete(s.families[name], i, i+1)
+	if len(s.families[name]) == 0 {
+		delete(s.families, name)
+	}
+	return true
+}
+
+// names returns the names of all registered metric families in order.
+func (s *StreamingRegistry) names() []string {
+	s.mu.RLock()
+	names := make([]string, 0, len(s.families))
+	for name := range s.families {
+		names = append(names, name)
+	}
+	s.mu.RUnlock()
+	sort.Strings(names)
+	return names
+}
+
+// gatherFamily gathers the metric family with the given name. It is gathered
+// with a prometheus.Registry of its own, which merges and checks the metrics
+// of its collectors like it would if they were registered with the default
+// registry.
+func (s *StreamingRegistry) gatherFamily(name string) ([]*dto.MetricFamily, error) {
+	s.mu.RLock()
+	collectors := slices.Clone(s.families[name])
+	s.mu.RUnlock()
+
+	reg := prometheus.NewRegistry()
+	for _, c := range collectors {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return reg.Gather()
+}
@@ -0,0 +1,146 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"math"
+	"sort"
+)
+
+// defaultCompression bounds the number of centroids of a t-digest to
+// roughly twice its value.
+const defaultCompression = 100
+
+// Centroid is the mean of Count observed values.
+type Centroid struct {
+	Mean  float64 `json:"mean"`
+	Count float64 `json:"count"`
+}
+
+// tDigest estimates quantiles of observed values with a bounded number of
+// centroids, which are small near the extremes and large near the median. It
+// implements the merging variant of Dunning's t-digest with the k1 scale
+// function. Digests of the same metric can be merged by combining their
+// centroids, which makes their quantiles aggregatable across instances.
+type tDigest struct {
+	compression float64
+	centroids   []Centroid
+	buffer      []Centroid
+	count       float64
+	min, max    float64
+}
+
+func newTDigest(compression float64) *tDigest {
+	return &tDigest{
+		compression: compression,
+		min:         math.Inf(1),
+		max:         math.Inf(-1),
+	}
+}
+
+// Add observes a value. Non-finite values are ignored.
+func (d *tDigest) Add(value float64) {
+	d.AddWeighted(value, 1)
+}
+
+// AddWeighted observes a value that stands for weight observations.
+func (d *tDigest) AddWeighted(value, weight float64) {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return
+	}
+	d.buffer = append(d.buffer, Centroid{Mean: value, Count: weight})
+	d.count += weight
+	d.min = math.Min(d.min, value)
+	d.max = math.Max(d.max, value)
+	if len(d.buffer) >= int(5*d.compression) {
+		d.compress()
+	}
+}
+
+// k maps a quantile to the scale on which centroids have a size of at most 1.
+func (d *tDigest) k(q float64) float64 {
+	return d.compression / (2 * math.Pi) * math.Asin(2*q-1)
+}
+
+func (d *tDigest) kInverse(k float64) float64 {
+	return (math.Sin(k*2*math.Pi/d.compression) + 1) / 2
+}
+
+// compress merges the buffered values into the centroids.
+func (d *tDigest) compress() {
+	if len(d.buffer) == 0 {
+		return
+	}
+	all := append(d.centroids, d.buffer...)
+	sort.Slice(all, func(i, j int) bool { return all[i].Mean < all[j].Mean })
+
+	merged := make([]Centroid, 0, len(d.centroids)+1)
+	merged = append(merged, all[0])
+	before := 0.0
+	limit := d.kInverse(d.k(0) + 1)
+	for _, c := range all[1:] {
+		last := &merged[len(merged)-1]
+		if (before+last.Count+c.Count)/d.count <= limit {
+			last.Count += c.Count
+			last.Mean += (c.Mean - last.Mean) * c.Count / last.Count
+			continue
+		}
+		before += last.Count
+		limit = d.kInverse(d.k(before/d.count) + 1)
+		merged = append(merged, c)
+	}
+	d.centroids = merged
+	d.buffer = d.buffer[:0]
+}
+
+// Quantile returns the estimated q-quantile of the observed values, or NaN if
+// there are none.
+func (d *tDigest) Quantile(q float64) float64 {
+	d.compress()
+	if len(d.centroids) == 0 {
+		return math.NaN()
+	}
+	if len(d.centroids) == 1 {
+		return d.centroids[0].Mean
+	}
+
+	// Each centroid is centered on its mean, the extremes are known exactly.
+	target := q * d.count
+	first, last := d.centroids[0], d.centroids[len(d.centroids)-1]
+	if target <= first.Count/2 {
+		return interpolate(d.min, first.Mean, target/(first.Count/2))
+	}
+	if target >= d.count-last.Count/2 {
+		return interpolate(last.Mean, d.max, (target-(d.count-last.Count/2))/(last.Count/2))
+	}
+	center := first.Count / 2
+	for i := 1; i < len(d.centroids); i++ {
+		next := center + d.centroids[i-1].Count/2 + d.centroids[i].Count/2
+		if target <= next {
+			return interpolate(d.centroids[i-1].Mean, d.centroids[i].Mean, (target-center)/(next-center))
+		}
+		center = next
+	}
+	return last.Mean
+}
+
+func interpolate(from, to, fraction float64) float64 {
+	return from + (to-from)*fraction
+}
+
+// Centroids returns the merged centroids of the digest, ordered by mean.
+func (d *tDigest) Centroids() []Centroid {
+	d.compress()
+	return append([]Centroid(nil), d.centroids...)
+}
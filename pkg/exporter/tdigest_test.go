@@ -0,0 +1,59 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestTDigestQuantiles(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	d := newTDigest(defaultCompression)
+	values := make([]float64, 100000)
+	for i := range values {
+		values[i] = r.ExpFloat64()
+		d.Add(values[i])
+	}
+	d.Add(math.NaN())
+	sort.Float64s(values)
+
+	for _, q := range []float64{0.001, 0.01, 0.5, 0.9, 0.99, 0.999} {
+		// Quantiles are accurate in terms of rank, more so near the extremes.
+		got := d.Quantile(q)
+		rank := float64(sort.SearchFloat64s(values, got)) / float64(len(values))
+		if math.Abs(rank-q) > 0.01*math.Min(q, 1-q)+0.0005 {
+			t.Errorf("expected quantile %v, got the value at quantile %v", q, rank)
+		}
+	}
+	if n := len(d.Centroids()); n > 2*defaultCompression {
+		t.Errorf("expected at most %d centroids, got %d", 2*defaultCompression, n)
+	}
+	if d.Quantile(0) != values[0] || d.Quantile(1) != values[len(values)-1] {
+		t.Errorf("expected the extreme quantiles to be the minimum and maximum")
+	}
+}
+
+func TestTDigestEmpty(t *testing.T) {
+	d := newTDigest(defaultCompression)
+	if !math.IsNaN(d.Quantile(0.5)) {
+		t.Errorf("expected NaN for an empty digest")
+	}
+	d.Add(3)
+	if d.Quantile(0.1) != 3 || d.Quantile(0.9) != 3 {
+		t.Errorf("expected all quantiles of a single value to be that value")
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/prometheus/statsd_exporter/pkg/clock"
+)
+
+// sizeHintInterval is how often the size hint file is updated.
+const sizeHintInterval = time.Minute
+
+// SizeHint is the number of metric names and series the registry held,
+// persisted so that the next start can pre-size the registry.
+type SizeHint struct {
+	MetricNames int `json:"metric_names"`
+	Series      int `json:"series"`
+}
+
+// ReadSizeHint reads a size hint written by a previous run.
+func ReadSizeHint(path string) (SizeHint, error) {
+	var hint SizeHint
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return hint, err
+	}
+	err = json.Unmarshal(data, &hint)
+	return hint, err
+}
+
+// writeSizeHint replaces the size hint file with the current registry size.
+func (b *Exporter) writeSizeHint() error {
+	var hint SizeHint
+	hint.MetricNames, hint.Series = b.Registry.Size()
+	data, err := json.Marshal(hint)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so that the hint is never truncated.
+	tmp, err := os.CreateTemp(filepath.Dir(b.SizeHintFile), filepath.Base(b.SizeHintFile)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), b.SizeHintFile)
+}
+
+// warmingUp reports whether non-essential work should be deferred.
+func (b *Exporter) warmingUp() bool {
+	return clock.Now().Before(b.WarmupUntil)
+}
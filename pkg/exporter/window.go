@@ -0,0 +1,68 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/prometheus/statsd_exporter/pkg/clock"
+	"github.com/prometheus/statsd_exporter/pkg/mapper"
+)
+
+// pendingIncrement is the sum of the increments of a counter in the current
+// window.
+type pendingIncrement struct {
+	value float64
+	due   time.Time
+}
+
+// addCounter adds value to counter, or to its pending increment if the
+// mapping has a counter window. The increments of a window are applied
+// together once it has passed, which saves updating very hot counters for
+// every event.
+func (b *Exporter) addCounter(counter prometheus.Counter, metricName string, mapping *mapper.MetricMapping, value float64) {
+	if mapping.CounterWindow <= 0 {
+		counter.Add(value)
+		return
+	}
+	if b.pendingIncrements == nil {
+		b.pendingIncrements = map[prometheus.Counter]*pendingIncrement{}
+	}
+	p, ok := b.pendingIncrements[counter]
+	if !ok {
+		p = &pendingIncrement{due: clock.Now().Add(mapping.CounterWindow)}
+		b.pendingIncrements[counter] = p
+		if b.CounterWindows != nil {
+			b.CounterWindows.WithLabelValues(metricName).Set(mapping.CounterWindow.Seconds())
+		}
+	}
+	p.value += value
+}
+
+// flushCounterWindows applies the pending increments of the windows that
+// have passed, or of all windows if all is set.
+func (b *Exporter) flushCounterWindows(all bool) {
+	if len(b.pendingIncrements) == 0 {
+		return
+	}
+	now := clock.Now()
+	for counter, p := range b.pendingIncrements {
+		if all || !now.Before(p.due) {
+			counter.Add(p.value)
+			delete(b.pendingIncrements, counter)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package line
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/prometheus/statsd_exporter/pkg/event"
+)
+
+// DogStatsDEventPrefix starts the lines of DogStatsD events, such as
+// `_e{5,4}:title|text|t:error|#env:prod`.
+// https://docs.datadoghq.com/developers/dogstatsd/datagram_shell/#events
+const DogStatsDEventPrefix = "_e{"
+
+// DefaultEventMetricName is the default name of the counter DogStatsD events
+// are counted in.
+const DefaultEventMetricName = "statsd_events_total"
+
+// EnableDogStatsDEvents option to count DogStatsD events in a counter with
+// the given name, labeled with their title, alert type and tags
+func (p *Parser) EnableDogStatsDEvents(metricName string) {
+	p.EventMetricName = metricName
+}
+
+// dogStatsDEventToEvents turns a DogStatsD event into an increment of the
+// event counter, and logs its payload to the EventLogger of the parser.
+func (p *Parser) dogStatsDEventToEvents(line string, sampleErrors prometheus.CounterVec, samplesReceived prometheus.Counter, tagErrors prometheus.Counter, tagsReceived prometheus.Counter, logger *slog.Logger) event.Events {
+	samplesReceived.Inc()
+	if !utf8.ValidString(line) {
+		p.sampleError(line, ErrMalformedLine, sampleErrors, logger)
+		return event.Events{}
+	}
+	title, text, fields, err := splitDogStatsDEvent(line)
+	if err != nil {
+		p.sampleError(line, err, sampleErrors, logger)
+		return event.Events{}
+	}
+
+	labels := map[string]string{}
+	attrs := []any{"title", title, "text", strings.ReplaceAll(text, `\n`, "\n")}
+	alertType := "info"
+	for _, field := range fields {
+		if field == "" {
+			p.sampleError(line, fmt.Errorf("%w: empty component", ErrMalformedComponent), sampleErrors, logger)
+			return event.Events{}
+		}
+		key, value, _ := strings.Cut(field, ":")
+		switch {
+		case field[0] == '#':
+			p.ParseDogStatsDTags(field[1:], labels, tagErrors, logger)
+			attrs = append(attrs, "tags", field[1:])
+		case key == "t":
+			alertType = value
+		case key == "d":
+			attrs = append(attrs, "timestamp", value)
+		case key == "h":
+			attrs = append(attrs, "hostname", value)
+		case key == "k":
+			attrs = append(attrs, "aggregation_key", value)
+		case key == "p":
+			attrs = append(attrs, "priority", value)
+		case key == "s":
+			attrs = append(attrs, "source_type_name", value)
+		default:
+			p.sampleError(line, fmt.Errorf("%w %q", ErrUnknownComponent, field), sampleErrors, logger)
+		}
+	}
+	attrs = append(attrs, "alert_type", alertType)
+
+	if len(labels) > 0 {
+		tagsReceived.Inc()
+	}
+	labels["title"] = title
+	labels["alert_type"] = alertType
+	if p.MaxLabels > 0 && len(labels) > p.MaxLabels {
+		p.sampleError(line, fmt.Errorf("%w: %d labels", ErrTooManyLabels, len(labels)), sampleErrors, logger)
+		return event.Events{}
+	}
+
+	if p.EventLogger != nil {
+		p.EventLogger.Info("DogStatsD event", attrs...)
+	}
+	return event.Events{event.NewCounterEvent(p.EventMetricName, 1, labels)}
+}
+
+// splitDogStatsDEvent splits a DogStatsD event into its title, its text and
+// the remaining '|'-delimited fields. The title and the text are delimited by
+// their lengths in bytes, since they may contain '|'.
+func splitDogStatsDEvent(line string) (title, text string, fields []string, err error) {
+	header, rest, ok := strings.Cut(line[len(DogStatsDEventPrefix):], "}:")
+	if !ok {
+		return "", "", nil, fmt.Errorf("%w: DogStatsD event without lengths", ErrMalformedLine)
+	}
+	titleLenStr, textLenStr, ok := strings.Cut(header, ",")
+	if !ok {
+		return "", "", nil, fmt.Errorf("%w: DogStatsD event without text length", ErrMalformedLine)
+	}
+	titleLen, err := strconv.Atoi(titleLenStr)
+	if err != nil || titleLen <= 0 {
+		return "", "", nil, fmt.Errorf("%w: invalid DogStatsD event title length %q", ErrMalformedLine, titleLenStr)
+	}
+	textLen, err := strconv.Atoi(textLenStr)
+	if err != nil || textLen < 0 {
+		return "", "", nil, fmt.Errorf("%w: invalid DogStatsD event text length %q", ErrMalformedLine, textLenStr)
+	}
+	if len(rest) < titleLen+1+textLen || rest[titleLen] != '|' {
+		return "", "", nil, fmt.Errorf("%w: DogStatsD event shorter than its lengths", ErrMalformedLine)
+	}
+
+	title, text, rest = rest[:titleLen], rest[titleLen+1:titleLen+1+textLen], rest[titleLen+1+textLen:]
+	if rest == "" {
+		return title, text, nil, nil
+	}
+	if rest[0] != '|' {
+		return "", "", nil, fmt.Errorf("%w: DogStatsD event longer than its lengths", ErrMalformedLine)
+	}
+	return title, text, strings.Split(rest[1:], "|"), nil
+}
@@ -0,0 +1,89 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package line
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/prometheus/statsd_exporter/pkg/event"
+)
+
+// DogStatsDServiceCheckPrefix starts the lines of DogStatsD service checks,
+// such as `_sc|redis.can_connect|2|#env:prod|m:timeout`.
+// https://docs.datadoghq.com/developers/dogstatsd/datagram_shell/#service-checks
+const DogStatsDServiceCheckPrefix = "_sc|"
+
+// DefaultServiceCheckMetricName is the default name of the gauge DogStatsD
+// service checks are recorded in.
+const DefaultServiceCheckMetricName = "statsd_service_check_status"
+
+// EnableDogStatsDServiceChecks option to record DogStatsD service checks in a
+// gauge with the given name, set to their status and labeled with the name of
+// the check and its tags
+func (p *Parser) EnableDogStatsDServiceChecks(metricName string) {
+	p.ServiceCheckMetricName = metricName
+}
+
+// dogStatsDServiceCheckToEvents turns a DogStatsD service check into an
+// update of the service check gauge to its status: 0 for OK, 1 for warning,
+// 2 for critical and 3 for unknown.
+func (p *Parser) dogStatsDServiceCheckToEvents(line string, sampleErrors prometheus.CounterVec, samplesReceived prometheus.Counter, tagErrors prometheus.Counter, tagsReceived prometheus.Counter, logger *slog.Logger) event.Events {
+	samplesReceived.Inc()
+	if !utf8.ValidString(line) {
+		p.sampleError(line, ErrMalformedLine, sampleErrors, logger)
+		return event.Events{}
+	}
+	// The message is the last field and may contain '|'.
+	rest, _, _ := strings.Cut(line[len(DogStatsDServiceCheckPrefix):], "|m:")
+	fields := strings.Split(rest, "|")
+	if len(fields) < 2 || fields[0] == "" {
+		p.sampleError(line, fmt.Errorf("%w: DogStatsD service check without name or status", ErrMalformedLine), sampleErrors, logger)
+		return event.Events{}
+	}
+	name, status := fields[0], fields[1]
+	if len(status) != 1 || status[0] < '0' || status[0] > '3' {
+		p.sampleError(line, fmt.Errorf("%w: invalid DogStatsD service check status %q", ErrBadValue, status), sampleErrors, logger)
+		return event.Events{}
+	}
+
+	labels := map[string]string{}
+	for _, field := range fields[2:] {
+		if field == "" {
+			p.sampleError(line, fmt.Errorf("%w: empty component", ErrMalformedComponent), sampleErrors, logger)
+			return event.Events{}
+		}
+		switch {
+		case field[0] == '#':
+			p.ParseDogStatsDTags(field[1:], labels, tagErrors, logger)
+		case strings.HasPrefix(field, "d:"), strings.HasPrefix(field, "h:"):
+		default:
+			p.sampleError(line, fmt.Errorf("%w %q", ErrUnknownComponent, field), sampleErrors, logger)
+		}
+	}
+
+	if len(labels) > 0 {
+		tagsReceived.Inc()
+	}
+	labels["check"] = name
+	if p.MaxLabels > 0 && len(labels) > p.MaxLabels {
+		p.sampleError(line, fmt.Errorf("%w: %d labels", ErrTooManyLabels, len(labels)), sampleErrors, logger)
+		return event.Events{}
+	}
+	return event.Events{event.NewGaugeEvent(p.ServiceCheckMetricName, float64(status[0]-'0'), false, labels)}
+}
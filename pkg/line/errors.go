@@ -0,0 +1,88 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package line
+
+import (
+	"errors"
+	"log/slog"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// SampleError is a reason for rejecting a line or one of its samples. The
+// Err variables below are all the reasons; match them with errors.Is, or get
+// the Reason of a wrapped error with errors.As.
+type SampleError struct {
+	// Reason is the value of the "reason" label of the sample error
+	// counter passed to LineToEvents.
+	Reason string
+	msg    string
+}
+
+func (e *SampleError) Error() string {
+	return e.msg
+}
+
+var (
+	// ErrMalformedLine is returned for lines without a name, without a ':'
+	// after the name, or that are not valid UTF-8.
+	ErrMalformedLine = &SampleError{Reason: "malformed_line", msg: "malformed line"}
+	// ErrMixedTagStyles is returned for lines with DogStatsD tags as well
+	// as tags in the metric name.
+	ErrMixedTagStyles = &SampleError{Reason: "mixed_tagging_styles", msg: "multiple tagging styles"}
+	// ErrMissingType is returned for lines without a '|' after the value.
+	ErrMissingType = &SampleError{Reason: "not_enough_parts_after_colon", msg: "not enough '|'-delimited parts after first ':'"}
+	// ErrInvalidAggregateType is returned for lines with multiple values of
+	// a type that doesn't accept them.
+	ErrInvalidAggregateType = &SampleError{Reason: "invalid_extended_aggregate_type", msg: "invalid extended aggregate type"}
+	// ErrMalformedComponent is returned for samples without a type, or with
+	// an empty '|'-delimited component.
+	ErrMalformedComponent = &SampleError{Reason: "malformed_component", msg: "malformed component"}
+	// ErrBadValue is returned for samples whose value is not a number, and
+	// for set members that are empty.
+	ErrBadValue = &SampleError{Reason: "malformed_value", msg: "malformed value"}
+	// ErrBadSampleRate is returned for sample rates that are not a number
+	// greater than 0 and at most 1. Unless the parser rejects such samples,
+	// the sample is still accepted, as if it was not sampled.
+	ErrBadSampleRate = &SampleError{Reason: "invalid_sample_factor", msg: "invalid sample rate"}
+	// ErrUnknownComponent is returned for components of a sample that are
+	// not known. The component is ignored.
+	ErrUnknownComponent = &SampleError{Reason: "unknown_component", msg: "unknown component"}
+	// ErrTooManyLabels is returned for samples with more labels than the
+	// MaxLabels of the parser.
+	ErrTooManyLabels = &SampleError{Reason: "too_many_labels", msg: "too many labels"}
+	// ErrTooManySamples is returned for lines with more samples than the
+	// MaxSamples of the parser. The samples up to the limit are accepted.
+	ErrTooManySamples = &SampleError{Reason: "too_many_samples", msg: "too many samples"}
+	// ErrUnsupportedType is returned for samples of unknown types, and for
+	// statsite frames of sets.
+	ErrUnsupportedType = &SampleError{Reason: "illegal_event", msg: "unsupported metric type"}
+	// ErrMalformedFrame is returned for statsite frames with an invalid key.
+	ErrMalformedFrame = &SampleError{Reason: "malformed_frame", msg: "malformed statsite frame"}
+)
+
+// sampleError records err for line in sampleErrors, labeled with its reason,
+// and passes it to the OnError function of the parser.
+func (p *Parser) sampleError(line string, err error, sampleErrors prometheus.CounterVec, logger *slog.Logger) {
+	reason := "unknown"
+	var se *SampleError
+	if errors.As(err, &se) {
+		reason = se.Reason
+	}
+	sampleErrors.WithLabelValues(reason).Inc()
+	logger.Debug("Bad line", "line", line, "error", err)
+	if p.OnError != nil {
+		p.OnError(line, err)
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package line
+
+import "strings"
+
+// etsyNamespaces are the prefixes Etsy StatsD puts in front of metric names
+// in Graphite, with the type of the metrics in each.
+var etsyNamespaces = []struct {
+	prefix   string
+	statType string
+}{
+	{prefix: "stats.counters.", statType: "c"},
+	{prefix: "stats_counts.", statType: "c"},
+	{prefix: "stats.timers.", statType: "ms"},
+	{prefix: "stats.gauges.", statType: "g"},
+}
+
+// EnableEtsyNamespaces option to strip the Graphite namespaces of Etsy StatsD
+// from metric names, and to take the type of samples without one from the
+// namespace
+func (p *Parser) EnableEtsyNamespaces() {
+	p.EtsyNamespacesEnabled = true
+}
+
+// stripEtsyNamespace returns metric without its Etsy StatsD namespace, and
+// the type of metrics in the namespace. The type is empty if metric is not
+// in a namespace.
+func stripEtsyNamespace(metric string) (string, string) {
+	for _, ns := range etsyNamespaces {
+		if name, ok := strings.CutPrefix(metric, ns.prefix); ok {
+			return name, ns.statType
+		}
+	}
+	return metric, ""
+}
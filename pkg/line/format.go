@@ -0,0 +1,181 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package line
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/statsd_exporter/pkg/event"
+)
+
+// TagFormat selects the tagging style used when formatting events.
+type TagFormat int
+
+const (
+	DogStatsDTags TagFormat = iota
+	InfluxDBTags
+	LibratoTags
+	SignalFXTags
+)
+
+// Characters that can't appear in metric names, since they delimit tags or
+// values when all tagging styles are enabled.
+const reservedNameChars = ":|\n#,[]"
+
+// tagValueEscaper escapes the characters of tag values that the parser
+// unescapes.
+var tagValueEscaper = strings.NewReplacer(`\`, `\\`, `,`, `\,`, `"`, `\"`)
+
+// Format serializes an event into StatsD lines using DogStatsD tags. See
+// FormatWithTags.
+func Format(e event.Event) (string, error) {
+	return FormatWithTags(e, DogStatsDTags)
+}
+
+// FormatWithTags serializes an event into StatsD lines that a Parser with the
+// given tagging style enabled parses back into an equivalent event.
+//
+// Usually a single line is returned. Setting a gauge to a negative value
+// requires two lines separated by a newline, since a leading sign marks a
+// relative change. Observer values are formatted as histograms (`h`), which
+// are not subject to unit conversion, and set members as sets (`s`). Sampled
+// observer values keep their sample rate.
+func FormatWithTags(e event.Event, tagFormat TagFormat) (string, error) {
+	name := e.MetricName()
+	if name == "" {
+		return "", fmt.Errorf("empty metric name")
+	}
+	if strings.ContainsAny(name, reservedNameChars) {
+		return "", fmt.Errorf("metric name %q contains one of the reserved characters %q", name, reservedNameChars)
+	}
+
+	if set, ok := e.(*event.SetEvent); ok {
+		return formatSet(set, tagFormat)
+	}
+
+	var values []float64
+	var statType string
+	relative := event.IsRelative(e)
+	switch ev := e.(type) {
+	case *event.CounterEvent:
+		values, statType = ev.Values(), "c"
+	case *event.GaugeEvent:
+		values, statType = ev.Values(), "g"
+	case *event.ObserverEvent, *event.MultiObserverEvent:
+		values, statType = ev.(event.MultiValueEvent).Values(), "h"
+		if rate := event.SampleRateOf(e); rate < 1 {
+			statType += "|@" + strconv.FormatFloat(rate, 'g', -1, 64)
+		}
+	default:
+		return "", fmt.Errorf("unsupported event type %T", e)
+	}
+
+	for _, v := range values {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return "", fmt.Errorf("value %v of %s cannot be formatted", v, name)
+		}
+	}
+
+	prefix, suffix, err := formatTags(name, e.Labels(), tagFormat)
+	if err != nil {
+		return "", err
+	}
+
+	lines := make([]string, 0, len(values))
+	for _, v := range values {
+		value := strconv.FormatFloat(v, 'g', -1, 64)
+		switch {
+		case relative && v >= 0:
+			value = "+" + value
+		case statType == "g" && !relative && v < 0:
+			// Reset the gauge first, then decrement it.
+			lines = append(lines, prefix+":0|g"+suffix)
+		}
+		lines = append(lines, prefix+":"+value+"|"+statType+suffix)
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// formatSet formats the member of a set event.
+func formatSet(e *event.SetEvent, tagFormat TagFormat) (string, error) {
+	member := e.Member()
+	if member == "" || strings.ContainsAny(member, ":|\n") {
+		return "", fmt.Errorf("set member %q of %s is empty or contains one of the reserved characters %q", member, e.MetricName(), ":|\n")
+	}
+	prefix, suffix, err := formatTags(e.MetricName(), e.Labels(), tagFormat)
+	if err != nil {
+		return "", err
+	}
+	return prefix + ":" + member + "|s" + suffix, nil
+}
+
+// formatTags returns the metric name with any tags that belong in it, and the
+// suffix to append after the stat type.
+func formatTags(name string, labels map[string]string, tagFormat TagFormat) (string, string, error) {
+	if len(labels) == 0 {
+		return name, "", nil
+	}
+
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	// Commas in DogStatsD and InfluxDB tag values are escaped, but can't
+	// appear in tag names.
+	var separator, reserved string
+	escaped := true
+	switch tagFormat {
+	case DogStatsDTags:
+		separator, reserved = ":", "|\n"
+	case InfluxDBTags:
+		separator, reserved = "=", ":|\n[]"
+	case LibratoTags, SignalFXTags:
+		separator, reserved, escaped = "=", ":|\n[],", false
+	default:
+		return "", "", fmt.Errorf("unknown tag format %d", tagFormat)
+	}
+
+	tags := make([]string, 0, len(keys))
+	for _, k := range keys {
+		v := labels[k]
+		if k == "" || v == "" {
+			return "", "", fmt.Errorf("tag %q=%q of %s has an empty name or value", k, v, name)
+		}
+		if strings.ContainsAny(k, reserved+separator+",") || strings.ContainsAny(v, reserved) {
+			return "", "", fmt.Errorf("tag %q=%q of %s contains one of the reserved characters %q", k, v, name, reserved+separator+",")
+		}
+		if escaped {
+			v = tagValueEscaper.Replace(v)
+		}
+		tags = append(tags, k+separator+v)
+	}
+	joined := strings.Join(tags, ",")
+
+	switch tagFormat {
+	case InfluxDBTags:
+		return name + "," + joined, "", nil
+	case LibratoTags:
+		return name + "#" + joined, "", nil
+	case SignalFXTags:
+		return name + "[" + joined + "]", "", nil
+	default:
+		return name, "|#" + joined, nil
+	}
+}
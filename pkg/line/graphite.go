@@ -0,0 +1,92 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package line
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/trace"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/prometheus/statsd_exporter/pkg/event"
+)
+
+// GraphiteParser parses Graphite plaintext lines, such as
+// `servers.web-1.load 0.5 1700000000`, into gauge events. Tagged paths such as
+// `load;host=web-1;dc=eu` are supported, and their tags become labels.
+// https://graphite.readthedocs.io/en/latest/feeding-carbon.html#the-plaintext-protocol
+//
+// The timestamp is optional and validated, but the value is applied when it
+// is received, like any StatsD sample. MaxLabels and OnError of the embedded
+// Parser apply.
+type GraphiteParser struct {
+	*Parser
+}
+
+// NewGraphiteParser returns a Graphite parser sharing the configuration of p.
+func NewGraphiteParser(p *Parser) *GraphiteParser {
+	return &GraphiteParser{Parser: p}
+}
+
+func (p *GraphiteParser) LineToEvents(line string, sampleErrors prometheus.CounterVec, samplesReceived prometheus.Counter, tagErrors prometheus.Counter, tagsReceived prometheus.Counter, logger *slog.Logger) event.Events {
+	defer trace.StartRegion(context.Background(), event.TraceRegionParse).End()
+	events := event.Events{}
+	if line == "" {
+		return events
+	}
+	samplesReceived.Inc()
+
+	fields := strings.Fields(line)
+	if len(fields) < 2 || len(fields) > 3 || !utf8.ValidString(line) {
+		p.sampleError(line, fmt.Errorf("%w: expected path, value and optional timestamp", ErrMalformedLine), sampleErrors, logger)
+		return events
+	}
+	value, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil {
+		p.sampleError(line, fmt.Errorf("%w: %w", ErrBadValue, err), sampleErrors, logger)
+		return events
+	}
+	if len(fields) == 3 {
+		if _, err := strconv.ParseFloat(fields[2], 64); err != nil {
+			p.sampleError(line, fmt.Errorf("%w: invalid timestamp %q", ErrMalformedLine, fields[2]), sampleErrors, logger)
+			return events
+		}
+	}
+
+	name, tags, tagged := strings.Cut(fields[0], ";")
+	if name == "" {
+		p.sampleError(line, ErrMalformedLine, sampleErrors, logger)
+		return events
+	}
+	labels := map[string]string{}
+	if tagged {
+		for _, tag := range strings.Split(tags, ";") {
+			p.parseTag(tags, tag, '=', false, labels, tagErrors, logger)
+		}
+		if len(labels) > 0 {
+			tagsReceived.Inc()
+		}
+	}
+	if p.MaxLabels > 0 && len(labels) > p.MaxLabels {
+		p.sampleError(line, fmt.Errorf("%w: %d labels", ErrTooManyLabels, len(labels)), sampleErrors, logger)
+		return events
+	}
+
+	return append(events, event.NewGaugeEvent(name, value, false, labels))
+}
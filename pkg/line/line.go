@@ -14,8 +14,10 @@
 package line
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
+	"runtime/trace"
 	"strconv"
 	"strings"
 	"unicode/utf8"
@@ -32,6 +34,53 @@ type Parser struct {
 	InfluxdbTagsEnabled  bool
 	LibratoTagsEnabled   bool
 	SignalFXTagsEnabled  bool
+	// LenientNumbersEnabled accepts a comma as the decimal separator.
+	LenientNumbersEnabled bool
+	// UTF8NamesEnabled keeps tag names that are valid UTF-8 as label names,
+	// instead of escaping them to legacy Prometheus label names.
+	UTF8NamesEnabled bool
+	// EtsyNamespacesEnabled strips Etsy StatsD namespaces such as
+	// "stats.counters." from metric names.
+	EtsyNamespacesEnabled bool
+	// ClientTelemetry is one of the ClientTelemetry constants, and decides
+	// how the telemetry metrics of DogStatsD clients are handled. Empty
+	// means ClientTelemetryMap.
+	ClientTelemetry string
+	// InvalidSampleRates is one of the InvalidSampleRate constants, and
+	// decides how samples with a sample rate that is not a number greater
+	// than 0 and at most 1 are handled. Empty means InvalidSampleRateClamp.
+	InvalidSampleRates string
+	// LenientValues, if set, counts values that were only accepted because
+	// lenient numbers are enabled.
+	LenientValues prometheus.Counter
+	// ValuelessTagValue, if not empty, is the label value of DogStatsD tags
+	// without a value, such as "#shipping". They are errors otherwise.
+	ValuelessTagValue string
+	// MaxLabels, if positive, is the maximum number of labels of a sample.
+	// Samples with more labels are rejected.
+	MaxLabels int
+	// MaxSamples, if positive, is the maximum number of samples of a line.
+	// Further samples are dropped, so that a line with many colon-separated
+	// values can't monopolize parsing.
+	MaxSamples int
+	// PrefixLabels are default labels added to metrics by name prefix,
+	// ordered from the shortest to the longest prefix.
+	PrefixLabels []PrefixLabels
+	// EventMetricName, if not empty, is the name of the counter that
+	// DogStatsD events are counted in. DogStatsD events are rejected
+	// otherwise.
+	EventMetricName string
+	// EventLogger, if set, logs the payload of every DogStatsD event that
+	// is counted.
+	EventLogger *slog.Logger
+	// ServiceCheckMetricName, if not empty, is the name of the gauge that
+	// DogStatsD service checks are recorded in. DogStatsD service checks
+	// are rejected otherwise.
+	ServiceCheckMetricName string
+	// OnError, if set, is called with every line that is rejected, or that
+	// has a sample or component rejected, and the reason. The error wraps
+	// one of the SampleError variables of this package.
+	OnError func(line string, err error)
 }
 
 // NewParser returns a new line parser
@@ -45,6 +94,12 @@ func (p *Parser) EnableDogstatsdParsing() {
 	p.DogstatsdTagsEnabled = true
 }
 
+// EnableValuelessTags option to turn DogStatsD tags without a value into
+// labels with the given value
+func (p *Parser) EnableValuelessTags(value string) {
+	p.ValuelessTagValue = value
+}
+
 // EnableInfluxdbParsing option to enable influxdb tag parsing
 func (p *Parser) EnableInfluxdbParsing() {
 	p.InfluxdbTagsEnabled = true
@@ -60,41 +115,97 @@ func (p *Parser) EnableSignalFXParsing() {
 	p.SignalFXTagsEnabled = true
 }
 
-func buildEvent(statType, metric string, value float64, relative bool, labels map[string]string) (event.Event, error) {
+// EnableUTF8Names option to keep dotted and Unicode tag names as label names
+func (p *Parser) EnableUTF8Names() {
+	p.UTF8NamesEnabled = true
+}
+
+// labelName returns the label name of a tag name. Tag names are escaped like
+// metric names, unless UTF-8 names are enabled and the tag name is valid
+// UTF-8.
+func (p *Parser) labelName(tagName string) string {
+	if p.UTF8NamesEnabled && utf8.ValidString(tagName) {
+		return tagName
+	}
+	return mapper.EscapeMetricName(tagName)
+}
+
+func buildEvent(statType, metric, valueStr string, value float64, relative bool, sampleRate float64, labels map[string]string) (event.Event, error) {
 	switch statType {
 	case "c":
-		return &event.CounterEvent{
-			CMetricName: metric,
-			CValue:      float64(value),
-			CLabels:     labels,
-		}, nil
+		return event.NewCounterEvent(metric, value, labels), nil
 	case "g":
-		return &event.GaugeEvent{
-			GMetricName: metric,
-			GValue:      float64(value),
-			GRelative:   relative,
-			GLabels:     labels,
-		}, nil
+		return event.NewGaugeEvent(metric, value, relative, labels), nil
 	case "ms":
-		return &event.ObserverEvent{
-			OMetricName: metric,
-			OValue:      float64(value) / 1000, // prometheus presumes seconds, statsd millisecond
-			OLabels:     labels,
-		}, nil
+		// prometheus presumes seconds, statsd millisecond
+		return event.NewSampledObserverEvent(metric, value/1000, sampleRate, labels), nil
 	case "h", "d":
-		return &event.ObserverEvent{
-			OMetricName: metric,
-			OValue:      float64(value),
-			OLabels:     labels,
-		}, nil
+		return event.NewSampledObserverEvent(metric, value, sampleRate, labels).WithObserverKind(event.ObserverKind(statType)), nil
 	case "s":
-		return nil, fmt.Errorf("no support for StatsD sets")
+		return event.NewSetEvent(metric, valueStr, labels), nil
 	default:
-		return nil, fmt.Errorf("bad stat type %s", statType)
+		return nil, fmt.Errorf("%w: bad stat type %s", ErrUnsupportedType, statType)
+	}
+}
+
+// cutTag cuts the first tag of a comma-separated list of tags from
+// component. Commas that are escaped with a backslash, or that are inside a
+// tag value in double quotes, don't separate tags.
+func cutTag(component string, separator rune) (tag, rest string) {
+	valueStarted, quoted := false, false
+	for i := 0; i < len(component); i++ {
+		switch c := component[i]; {
+		case c == '\\':
+			// The escaped character is skipped.
+			i++
+		case quoted:
+			quoted = c != '"'
+		case c == ',':
+			return component[:i], component[i+1:]
+		case rune(c) == separator && !valueStarted:
+			valueStarted = true
+			quoted = i+1 < len(component) && component[i+1] == '"'
+			if quoted {
+				i++
+			}
+		}
+	}
+	return component, ""
+}
+
+// unescapeTagValue removes the double quotes around a quoted tag value, and
+// the backslashes that escape commas, double quotes and backslashes. Other
+// backslashes are kept. A quoted value without a closing quote, or with
+// anything following it, is malformed.
+func unescapeTagValue(v string) (string, bool) {
+	quoted := strings.HasPrefix(v, `"`)
+	if !quoted && !strings.Contains(v, `\`) {
+		return v, true
 	}
+	if quoted {
+		v = v[1:]
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(v))
+	for i := 0; i < len(v); i++ {
+		switch c := v[i]; {
+		case c == '\\' && i+1 < len(v) && strings.IndexByte(`,"\`, v[i+1]) >= 0:
+			i++
+			sb.WriteByte(v[i])
+		case c == '"' && quoted:
+			return sb.String(), i == len(v)-1
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String(), !quoted
 }
 
-func parseTag(component, tag string, separator rune, labels map[string]string, tagErrors prometheus.Counter, logger *slog.Logger) {
+// parseTag adds the label of a tag, which separates its name and value with
+// separator. If escaped is set, the value may be quoted or escaped as
+// described for unescapeTagValue.
+func (p *Parser) parseTag(component, tag string, separator rune, escaped bool, labels map[string]string, tagErrors prometheus.Counter, logger *slog.Logger) {
 	// Entirely empty tag is an error
 	if len(tag) == 0 {
 		tagErrors.Inc()
@@ -107,12 +218,20 @@ func parseTag(component, tag string, separator rune, labels map[string]string, t
 			k := tag[:i]
 			v := tag[i+1:]
 
+			if escaped {
+				var ok bool
+				if v, ok = unescapeTagValue(v); !ok {
+					tagErrors.Inc()
+					logger.Debug("Malformed quoted tag value", "tag", tag, "component", component)
+					return
+				}
+			}
 			if len(k) == 0 || len(v) == 0 {
 				// Empty key or value is an error
 				tagErrors.Inc()
 				logger.Debug("Malformed name tag", "k", k, "v", v, "component", component)
 			} else {
-				labels[mapper.EscapeMetricName(k)] = v
+				labels[p.labelName(k)] = v
 			}
 			return
 		}
@@ -123,20 +242,18 @@ func parseTag(component, tag string, separator rune, labels map[string]string, t
 	logger.Debug("Malformed name tag", "tag", tag, "component", component)
 }
 
-func parseNameTags(component string, labels map[string]string, tagErrors prometheus.Counter, logger *slog.Logger) {
-	lastTagEndIndex := 0
-	for i, c := range component {
-		if c == ',' {
-			tag := component[lastTagEndIndex:i]
-			lastTagEndIndex = i + 1
-			parseTag(component, tag, '=', labels, tagErrors, logger)
+// parseNameTags parses the tags in a metric name. Only InfluxDB tags may have
+// escaped or quoted values, Librato and SignalFX tags are split at every comma.
+func (p *Parser) parseNameTags(component string, escaped bool, labels map[string]string, tagErrors prometheus.Counter, logger *slog.Logger) {
+	// A trailing comma doesn't add an empty tag.
+	for rest := component; rest != ""; {
+		var tag string
+		if escaped {
+			tag, rest = cutTag(rest, '=')
+		} else {
+			tag, rest, _ = strings.Cut(rest, ",")
 		}
-	}
-
-	// If we're not off the end of the string, add the last tag
-	if lastTagEndIndex < len(component) {
-		tag := component[lastTagEndIndex:]
-		parseTag(component, tag, '=', labels, tagErrors, logger)
+		p.parseTag(component, tag, '=', escaped, labels, tagErrors, logger)
 	}
 }
 
@@ -147,21 +264,21 @@ func trimLeftHash(s string) string {
 	return s
 }
 
+func (p *Parser) parseDogStatsDTag(component, tag string, labels map[string]string, tagErrors prometheus.Counter, logger *slog.Logger) {
+	if p.ValuelessTagValue != "" && tag != "" && !strings.ContainsRune(tag, ':') {
+		labels[p.labelName(tag)] = p.ValuelessTagValue
+		return
+	}
+	p.parseTag(component, tag, ':', true, labels, tagErrors, logger)
+}
+
 func (p *Parser) ParseDogStatsDTags(component string, labels map[string]string, tagErrors prometheus.Counter, logger *slog.Logger) {
 	if p.DogstatsdTagsEnabled {
-		lastTagEndIndex := 0
-		for i, c := range component {
-			if c == ',' {
-				tag := component[lastTagEndIndex:i]
-				lastTagEndIndex = i + 1
-				parseTag(component, trimLeftHash(tag), ':', labels, tagErrors, logger)
-			}
-		}
-
-		// If we're not off the end of the string, add the last tag
-		if lastTagEndIndex < len(component) {
-			tag := component[lastTagEndIndex:]
-			parseTag(component, trimLeftHash(tag), ':', labels, tagErrors, logger)
+		// A trailing comma doesn't add an empty tag.
+		for rest := component; rest != ""; {
+			var tag string
+			tag, rest = cutTag(rest, ':')
+			p.parseDogStatsDTag(component, trimLeftHash(tag), labels, tagErrors, logger)
 		}
 	}
 }
@@ -178,7 +295,7 @@ func (p *Parser) parseNameAndTags(name string, labels map[string]string, tagErro
 		switch {
 		case startIdx != -1 && endIdx != -1:
 			// good signalfx tags
-			parseNameTags(name[startIdx+1:endIdx], labels, tagErrors, logger)
+			p.parseNameTags(name[startIdx+1:endIdx], false, labels, tagErrors, logger)
 			return name[:startIdx] + name[endIdx+1:]
 		case (startIdx != -1) != (endIdx != -1):
 			// only one bracket, return unparsed
@@ -194,58 +311,124 @@ func (p *Parser) parseNameAndTags(name string, labels map[string]string, tagErro
 		// `,` delimits start of tags by InfluxDB
 		// https://www.influxdata.com/blog/getting-started-with-sending-statsd-metrics-to-telegraf-influxdb/#introducing-influx-statsd
 		if (c == '#' && p.LibratoTagsEnabled) || (c == ',' && p.InfluxdbTagsEnabled) {
-			parseNameTags(name[i+1:], labels, tagErrors, logger)
+			p.parseNameTags(name[i+1:], c == ',', labels, tagErrors, logger)
 			return name[:i]
 		}
 	}
 	return name
 }
 
+// isDogStatsDField reports whether a component is one of the DogStatsD
+// container ID (`c:`), external data (`e:`) or timestamp (`T`) fields. These
+// are accepted but do not affect the sample.
+// https://docs.datadoghq.com/developers/dogstatsd/datagram_shell/
+func isDogStatsDField(component string) bool {
+	switch component[0] {
+	case 'c', 'e':
+		return len(component) > 1 && component[1] == ':'
+	case 'T':
+		_, err := strconv.ParseInt(component[1:], 10, 64)
+		return err == nil
+	}
+	return false
+}
+
+// splitSamples splits s into its colon-separated samples. Samples beyond
+// MaxSamples are dropped, without splitting them.
+func (p *Parser) splitSamples(line, s string, sampleErrors prometheus.CounterVec, logger *slog.Logger) []string {
+	if p.MaxSamples <= 0 {
+		return strings.Split(s, ":")
+	}
+	samples := strings.SplitN(s, ":", p.MaxSamples+1)
+	if len(samples) > p.MaxSamples {
+		p.sampleError(line, fmt.Errorf("%w: more than %d samples", ErrTooManySamples, p.MaxSamples), sampleErrors, logger)
+		samples = samples[:p.MaxSamples]
+	}
+	return samples
+}
+
 func (p *Parser) LineToEvents(line string, sampleErrors prometheus.CounterVec, samplesReceived prometheus.Counter, tagErrors prometheus.Counter, tagsReceived prometheus.Counter, logger *slog.Logger) event.Events {
+	defer trace.StartRegion(context.Background(), event.TraceRegionParse).End()
 	events := event.Events{}
 	if line == "" {
 		return events
 	}
+	if p.EventMetricName != "" && strings.HasPrefix(line, DogStatsDEventPrefix) {
+		return p.dogStatsDEventToEvents(line, sampleErrors, samplesReceived, tagErrors, tagsReceived, logger)
+	}
+	if p.ServiceCheckMetricName != "" && strings.HasPrefix(line, DogStatsDServiceCheckPrefix) {
+		return p.dogStatsDServiceCheckToEvents(line, sampleErrors, samplesReceived, tagErrors, tagsReceived, logger)
+	}
 
 	elements := strings.SplitN(line, ":", 2)
 	if len(elements) < 2 || len(elements[0]) == 0 || !utf8.ValidString(line) {
-		sampleErrors.WithLabelValues("malformed_line").Inc()
-		logger.Debug("bad line", "line", line)
+		p.sampleError(line, ErrMalformedLine, sampleErrors, logger)
 		return events
 	}
 
-	labels := map[string]string{}
+	// The labels are sized for every comma to separate tags, which avoids
+	// growing the map while parsing.
+	labels := make(map[string]string, strings.Count(line, ",")+1)
 	metric := p.parseNameAndTags(elements[0], labels, tagErrors, logger)
+	var namespaceType string
+	if p.EtsyNamespacesEnabled {
+		metric, namespaceType = stripEtsyNamespace(metric)
+		if metric == "" {
+			p.sampleError(line, ErrMalformedLine, sampleErrors, logger)
+			return events
+		}
+		// The namespace only provides the type of samples without one.
+		if namespaceType != "" && !strings.Contains(elements[1], "|") {
+			elements[1] += "|" + namespaceType
+		}
+	}
+	if p.ClientTelemetry == ClientTelemetryExpose || p.ClientTelemetry == ClientTelemetryDrop {
+		if name, ok := clientTelemetryName(metric); ok {
+			if p.ClientTelemetry == ClientTelemetryDrop {
+				logger.Debug("Dropping DogStatsD client telemetry", "line", line)
+				return events
+			}
+			metric = name
+		}
+	}
+	defaultLabels := p.prefixLabels(metric)
 	usingDogStatsDTags := strings.Contains(elements[1], "|#")
 	if usingDogStatsDTags && len(labels) > 0 {
 		// using DogStatsD tags
 
 		// don't allow mixed tagging styles
-		sampleErrors.WithLabelValues("mixed_tagging_styles").Inc()
-		logger.Debug("bad line: multiple tagging styles", "line", line)
+		p.sampleError(line, ErrMixedTagStyles, sampleErrors, logger)
 		return events
 	}
 
 	var samples []string
 	lineParts := strings.SplitN(elements[1], "|", 3)
 	if len(lineParts) < 2 {
-		sampleErrors.WithLabelValues("not_enough_parts_after_colon").Inc()
-		logger.Debug("bad line: not enough '|'-delimited parts after first ':'", "line", line)
+		p.sampleError(line, ErrMissingType, sampleErrors, logger)
 		return events
 	}
+	// The values of a counter with multiple values are summed into
+	// packedCounter, so that they result in a single event. The values of
+	// an observer are collected into packedObserver.
+	var sumCounter, packObserver bool
+	var packedCounter *event.CounterEvent
+	var packedObserver *event.MultiObserverEvent
 	if strings.Contains(lineParts[0], ":") {
-		// handle DogStatsD extended aggregation
+		// handle DogStatsD extended aggregation, and multiple values of
+		// counters and gauges
 		isValidAggType := false
 		switch lineParts[1] {
 		case
 			"ms", // timer
 			"h",  // histogram
-			"d":  // distribution
+			"d",  // distribution
+			"c",  // counter, values are summed
+			"g":  // gauge, values are applied in order
 			isValidAggType = true
 		}
 
 		if isValidAggType {
-			aggValues := strings.Split(lineParts[0], ":")
+			aggValues := p.splitSamples(line, lineParts[0], sampleErrors, logger)
 			aggLines := make([]string, len(aggValues))
 			_, aggLineSuffix, _ := strings.Cut(elements[1], "|")
 
@@ -253,25 +436,38 @@ func (p *Parser) LineToEvents(line string, sampleErrors prometheus.CounterVec, s
 				aggLines[i] = strings.Join([]string{aggValue, aggLineSuffix}, "|")
 			}
 			samples = aggLines
+			sumCounter = lineParts[1] == "c"
+			packObserver = !sumCounter && lineParts[1] != "g"
 		} else {
-			sampleErrors.WithLabelValues("invalid_extended_aggregate_type").Inc()
-			logger.Debug("bad line: invalid extended aggregate type", "line", line)
+			p.sampleError(line, fmt.Errorf("%w %q", ErrInvalidAggregateType, lineParts[1]), sampleErrors, logger)
 			return events
 		}
 	} else if usingDogStatsDTags {
 		// disable multi-metrics
 		samples = elements[1:]
 	} else {
-		samples = strings.Split(elements[1], ":")
+		samples = p.splitSamples(line, elements[1], sampleErrors, logger)
+	}
+
+	// Events don't share their labels, since the exporter modifies them: the
+	// first event of the line takes the labels, and later ones a copy. All
+	// samples of a line have the same tags, so parsing them again leaves the
+	// labels of the first event unchanged.
+	labelsTaken := false
+	takeLabels := func() map[string]string {
+		if labelsTaken {
+			return copyLabels(labels)
+		}
+		labelsTaken = true
+		return labels
 	}
 
 samples:
 	for _, sample := range samples {
 		samplesReceived.Inc()
 		components := strings.Split(sample, "|")
-		if len(components) < 2 || len(components) > 4 {
-			sampleErrors.WithLabelValues("malformed_component").Inc()
-			logger.Debug("bad component", "line", line)
+		if len(components) < 2 {
+			p.sampleError(line, ErrMalformedComponent, sampleErrors, logger)
 			continue
 		}
 		valueStr, statType := components[0], components[1]
@@ -281,19 +477,32 @@ samples:
 			relative = true
 		}
 
-		value, err := strconv.ParseFloat(valueStr, 64)
-		if err != nil {
-			logger.Debug("bad value", "value", valueStr, "line", line)
-			sampleErrors.WithLabelValues("malformed_value").Inc()
-			continue
+		// The members of sets are strings, and not parsed.
+		var value float64
+		if statType == "s" {
+			if valueStr == "" {
+				p.sampleError(line, fmt.Errorf("%w: empty set member", ErrBadValue), sampleErrors, logger)
+				continue
+			}
+			relative = false
+		} else {
+			var lenient bool
+			var err error
+			value, lenient, err = p.parseValue(valueStr)
+			if err != nil {
+				p.sampleError(line, err, sampleErrors, logger)
+				continue
+			}
+			if lenient && p.LenientValues != nil {
+				p.LenientValues.Inc()
+			}
 		}
 
-		multiplyEvents := 1
+		var sampleRate float64
 		if len(components) >= 3 {
 			for _, component := range components[2:] {
 				if len(component) == 0 {
-					logger.Debug("Empty component", "line", line)
-					sampleErrors.WithLabelValues("malformed_component").Inc()
+					p.sampleError(line, fmt.Errorf("%w: empty component", ErrMalformedComponent), sampleErrors, logger)
 					continue samples
 				}
 			}
@@ -301,28 +510,35 @@ samples:
 			for _, component := range components[2:] {
 				switch component[0] {
 				case '@':
-
-					samplingFactor, err := strconv.ParseFloat(component[1:], 64)
+					samplingFactor, err := parseSampleRate(component[1:])
 					if err != nil {
-						logger.Debug("Invalid sampling factor", "component", component[1:], "line", line)
-						sampleErrors.WithLabelValues("invalid_sample_factor").Inc()
-					}
-					if samplingFactor == 0 {
-						samplingFactor = 1
+						p.sampleError(line, err, sampleErrors, logger)
+						if p.InvalidSampleRates == InvalidSampleRateReject {
+							continue samples
+						}
 					}
 
-					if statType == "g" {
+					// Absolute gauges ignore the sample rate, but a
+					// sampled relative gauge stands for 1/rate changes
+					// like a counter increment. Observed values are
+					// weighted by the exporter.
+					if statType == "g" && !relative {
 						continue
-					} else if statType == "c" {
+					} else if statType == "c" || statType == "g" {
 						value /= samplingFactor
 					} else if statType == "ms" || statType == "h" || statType == "d" {
-						multiplyEvents = int(1 / samplingFactor)
+						sampleRate = samplingFactor
 					}
 				case '#':
 					p.ParseDogStatsDTags(component[1:], labels, tagErrors, logger)
 				default:
-					logger.Debug("Invalid sampling factor or tag section", "component", components[2], "line", line)
-					sampleErrors.WithLabelValues("invalid_sample_factor").Inc()
+					if isDogStatsDField(component) {
+						logger.Debug("Ignoring DogStatsD field", "component", component, "line", line)
+						continue
+					}
+					// Skip fields we don't know, so that newer client protocol
+					// versions don't break parsing.
+					p.sampleError(line, fmt.Errorf("%w %q", ErrUnknownComponent, component), sampleErrors, logger)
 					continue
 				}
 			}
@@ -331,16 +547,50 @@ samples:
 		if len(labels) > 0 {
 			tagsReceived.Inc()
 		}
+		for k, v := range defaultLabels {
+			if _, ok := labels[k]; !ok {
+				labels[k] = v
+			}
+		}
+		if p.MaxLabels > 0 && len(labels) > p.MaxLabels {
+			p.sampleError(line, fmt.Errorf("%w: %d labels", ErrTooManyLabels, len(labels)), sampleErrors, logger)
+			continue
+		}
 
-		for i := 0; i < multiplyEvents; i++ {
-			event, err := buildEvent(statType, metric, value, relative, labels)
+		// Negative values are left to be rejected by the exporter.
+		if sumCounter && packedCounter != nil && value >= 0 {
+			packedCounter.Add(value)
+			continue
+		}
+
+		// Packed observer values keep the sample rate of the event when it
+		// is expanded.
+		if packObserver {
+			e, err := buildEvent(statType, metric, valueStr, value, relative, sampleRate, labels)
 			if err != nil {
-				logger.Debug("Error building event", "line", line, "error", err)
-				sampleErrors.WithLabelValues("illegal_event").Inc()
+				p.sampleError(line, err, sampleErrors, logger)
 				continue
 			}
-			events = append(events, event)
+			if packedObserver == nil {
+				packedObserver = event.NewMultiObserverEvent(metric, nil, sampleRate, takeLabels())
+				if statType != "ms" {
+					packedObserver.WithObserverKind(event.ObserverKind(statType))
+				}
+				events = append(events, packedObserver)
+			}
+			packedObserver.AddValue(e.Value())
+			continue
+		}
+
+		e, err := buildEvent(statType, metric, valueStr, value, relative, sampleRate, takeLabels())
+		if err != nil {
+			p.sampleError(line, err, sampleErrors, logger)
+			continue
+		}
+		if c, ok := e.(*event.CounterEvent); ok && sumCounter && value >= 0 {
+			packedCounter = c
 		}
+		events = append(events, e)
 	}
 	return events
 }
@@ -14,10 +14,18 @@
 package line
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math"
 	"reflect"
+	"slices"
+	"strings"
 	"testing"
 
 	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/prometheus/common/promslog"
 
 	"github.com/prometheus/statsd_exporter/pkg/event"
@@ -80,6 +88,26 @@ func TestLineToEvents(t *testing.T) {
 				},
 			},
 		},
+		"simple set": {
+			in: "users_online:abc123|s",
+			out: event.Events{
+				&event.SetEvent{
+					SMetricName: "users_online",
+					SMember:     "abc123",
+					SLabels:     map[string]string{},
+				},
+			},
+		},
+		"set with a signed member": {
+			in: "users_online:-42|s|#region:eu",
+			out: event.Events{
+				&event.SetEvent{
+					SMetricName: "users_online",
+					SMember:     "-42",
+					SLabels:     map[string]string{"region": "eu"},
+				},
+			},
+		},
 		"gauge with sampling": {
 			in: "foo:3|g|@0.2",
 			out: event.Events{
@@ -90,6 +118,39 @@ func TestLineToEvents(t *testing.T) {
 				},
 			},
 		},
+		"relative gauge with sampling": {
+			in: "foo:+3|g|@0.5",
+			out: event.Events{
+				&event.GaugeEvent{
+					GMetricName: "foo",
+					GValue:      6,
+					GRelative:   true,
+					GLabels:     map[string]string{},
+				},
+			},
+		},
+		"gauge with relative samples": {
+			in: "foo:5|g:+1|g|@0.5:-2|g",
+			out: event.Events{
+				&event.GaugeEvent{
+					GMetricName: "foo",
+					GValue:      5,
+					GLabels:     map[string]string{},
+				},
+				&event.GaugeEvent{
+					GMetricName: "foo",
+					GValue:      2,
+					GRelative:   true,
+					GLabels:     map[string]string{},
+				},
+				&event.GaugeEvent{
+					GMetricName: "foo",
+					GValue:      -2,
+					GRelative:   true,
+					GLabels:     map[string]string{},
+				},
+			},
+		},
 		"gauge decrement": {
 			in: "foo:-10|g",
 			out: event.Events{
@@ -118,6 +179,7 @@ func TestLineToEvents(t *testing.T) {
 					OMetricName: "foo",
 					OValue:      200,
 					OLabels:     map[string]string{},
+					OKind:       event.ObserverKindHistogram,
 				},
 			},
 		},
@@ -128,6 +190,7 @@ func TestLineToEvents(t *testing.T) {
 					OMetricName: "foo",
 					OValue:      200,
 					OLabels:     map[string]string{},
+					OKind:       event.ObserverKindDistribution,
 				},
 			},
 		},
@@ -138,26 +201,8 @@ func TestLineToEvents(t *testing.T) {
 					OMetricName: "foo",
 					OValue:      0.01,
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo",
-					OValue:      0.01,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo",
-					OValue:      0.01,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo",
-					OValue:      0.01,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo",
-					OValue:      0.01,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
+					OSampleRate: 0.2,
+					OKind:       event.ObserverKindDistribution,
 				},
 			},
 		},
@@ -364,26 +409,8 @@ func TestLineToEvents(t *testing.T) {
 					OMetricName: "foo",
 					OValue:      0.01,
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo",
-					OValue:      0.01,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo",
-					OValue:      0.01,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo",
-					OValue:      0.01,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo",
-					OValue:      0.01,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
+					OSampleRate: 0.2,
+					OKind:       event.ObserverKindHistogram,
 				},
 			},
 		},
@@ -406,34 +433,9 @@ func TestLineToEvents(t *testing.T) {
 		"datadog timings with extended aggregation values": {
 			in: "foo_timing:0.5:120:3000:10:20000:0.01|ms|#tag1:bar,tag2:baz",
 			out: event.Events{
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.120,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      3,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.01,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      20,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
+				&event.MultiObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.00001,
+					OValues:     []float64{0.0005, 0.120, 3, 0.01, 20, 0.00001},
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
 			},
@@ -441,34 +443,9 @@ func TestLineToEvents(t *testing.T) {
 		"datadog timings with extended aggregation values without tags": {
 			in: "foo_timing:0.5:120:3000:10:20000:0.01|ms",
 			out: event.Events{
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.120,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      3,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.01,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      20,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
+				&event.MultiObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.00001,
+					OValues:     []float64{0.0005, 0.120, 3, 0.01, 20, 0.00001},
 					OLabels:     map[string]string{},
 				},
 			},
@@ -476,217 +453,126 @@ func TestLineToEvents(t *testing.T) {
 		"datadog timings with extended aggregation values and sampling but without tags": {
 			in: "foo_timing:0.5:120:3000:10:20000:0.01|ms|@0.5",
 			out: event.Events{
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.120,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.120,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      3,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      3,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.01,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.01,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      20,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
+				&event.MultiObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      20,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.00001,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.00001,
+					OValues:     []float64{0.0005, 0.120, 3, 0.01, 20, 0.00001},
 					OLabels:     map[string]string{},
+					SampleRate:  0.5,
 				},
 			},
 		},
 		"datadog timings with extended aggregation values, sampling, and tags": {
 			in: "foo_timing:0.5:120:3000:10:20000:0.01|ms|@0.5|#tag1:bar,tag2:baz",
 			out: event.Events{
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.120,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.120,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      3,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      3,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.01,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.01,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      20,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      20,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.00001,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
+				&event.MultiObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.00001,
+					OValues:     []float64{0.0005, 0.120, 3, 0.01, 20, 0.00001},
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
+					SampleRate:  0.5,
 				},
 			},
 		},
 		"datadog histogram with extended aggregation values and tags": {
 			in: "foo_histogram:0.5:120:3000:10:20000:0.01|h|#tag1:bar,tag2:baz",
 			out: event.Events{
-				&event.ObserverEvent{
-					OMetricName: "foo_histogram",
-					OValue:      0.5,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_histogram",
-					OValue:      120,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_histogram",
-					OValue:      3000,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_histogram",
-					OValue:      10,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_histogram",
-					OValue:      20000,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
+				&event.MultiObserverEvent{
 					OMetricName: "foo_histogram",
-					OValue:      0.01,
+					OValues:     []float64{0.5, 120, 3000, 10, 20000, 0.01},
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
+					OKind:       event.ObserverKindHistogram,
 				},
 			},
 		},
 		"datadog distribution with extended aggregation values": {
 			in: "foo_distribution:0.5:120:3000:10:20000:0.01|d|#tag1:bar,tag2:baz",
 			out: event.Events{
-				&event.ObserverEvent{
+				&event.MultiObserverEvent{
 					OMetricName: "foo_distribution",
-					OValue:      0.5,
+					OValues:     []float64{0.5, 120, 3000, 10, 20000, 0.01},
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
+					OKind:       event.ObserverKindDistribution,
 				},
-				&event.ObserverEvent{
-					OMetricName: "foo_distribution",
-					OValue:      120,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
+			},
+		},
+		"datadog counter with multiple values": {
+			in: "foo_counter:0.5:120:3000:10:20000:0.01|c|#tag1:bar,tag2:baz",
+			out: event.Events{
+				&event.CounterEvent{
+					CMetricName: "foo_counter",
+					CValue:      23130.51,
+					CLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
-				&event.ObserverEvent{
-					OMetricName: "foo_distribution",
-					OValue:      3000,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
+			},
+		},
+		"datadog gauge with multiple values": {
+			in: "foo_gauge:0.5:+120:-3000|g|#tag1:bar,tag2:baz",
+			out: event.Events{
+				&event.GaugeEvent{
+					GMetricName: "foo_gauge",
+					GValue:      0.5,
+					GLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
-				&event.ObserverEvent{
-					OMetricName: "foo_distribution",
-					OValue:      10,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_distribution",
-					OValue:      20000,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
+				&event.GaugeEvent{
+					GMetricName: "foo_gauge",
+					GValue:      120,
+					GRelative:   true,
+					GLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
-				&event.ObserverEvent{
-					OMetricName: "foo_distribution",
-					OValue:      0.01,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
+				&event.GaugeEvent{
+					GMetricName: "foo_gauge",
+					GValue:      -3000,
+					GRelative:   true,
+					GLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
 			},
 		},
-		"datadog counter with invalid extended aggregation values": {
-			in: "foo_counter:0.5:120:3000:10:20000:0.01|c|#tag1:bar,tag2:baz",
+		"counter with multiple values and sample rate": {
+			in: "foo:1:2:3|c|@0.5",
+			out: event.Events{
+				&event.CounterEvent{
+					CMetricName: "foo",
+					CValue:      12,
+					CLabels:     map[string]string{},
+				},
+			},
 		},
-		"datadog gauge with invalid extended aggregation values": {
-			in: "foo_gauge:0.5:120:3000:10:20000:0.01|g|#tag1:bar,tag2:baz",
+		"counter with multiple values and a negative value": {
+			in: "foo:1:-2:3|c",
+			out: event.Events{
+				&event.CounterEvent{
+					CMetricName: "foo",
+					CValue:      4,
+					CLabels:     map[string]string{},
+				},
+				&event.CounterEvent{
+					CMetricName: "foo",
+					CValue:      -2,
+					CLabels:     map[string]string{},
+				},
+			},
 		},
 		"datadog timing with extended aggregation values and invalid signalfx tags": {
 			in: "foo.[tag1=bar,tag2=baz]test:0.5:120:3000:10:20000:0.01|ms",
 		},
-		"SignalFX counter with invalid Datadog style extended aggregation values": {
+		"SignalFX counter with multiple values": {
 			in: "foo.[tag1=bar,tag2=baz]test:0.5:120:3000:10:20000:0.01|c",
+			out: event.Events{
+				&event.CounterEvent{
+					CMetricName: "foo.test",
+					CValue:      23130.51,
+					CLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
+				},
+			},
 		},
-		"SignalFX no tags counter with invalid Datadog style extended aggregation values": {
+		"SignalFX no tags counter with multiple values": {
 			in: "foo.[]test:0.5:120:3000:10:20000:0.01|c",
+			out: event.Events{
+				&event.CounterEvent{
+					CMetricName: "foo.test",
+					CValue:      23130.51,
+					CLabels:     map[string]string{},
+				},
+			},
 		},
 		"SignalFX no tags with invalid Datadog style extended aggregation values and timings type": {
 			in: "foo.[]test:0.5:120:3000:10:20000:0.01|ms",
@@ -704,51 +590,7 @@ func TestLineToEvents(t *testing.T) {
 					OMetricName: "foo.timing",
 					OValue:      0.0005,
 					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo.timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo.timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo.timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo.timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo.timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo.timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo.timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo.timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo.timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{},
+					OSampleRate: 0.1,
 				},
 			},
 		},
@@ -810,6 +652,7 @@ func TestLineToEvents(t *testing.T) {
 					OMetricName: "foo",
 					OValue:      200,
 					OLabels:     map[string]string{},
+					OKind:       event.ObserverKindHistogram,
 				},
 			},
 		},
@@ -820,6 +663,27 @@ func TestLineToEvents(t *testing.T) {
 					OMetricName: "foo",
 					OValue:      200,
 					OLabels:     map[string]string{},
+					OKind:       event.ObserverKindDistribution,
+				},
+			},
+		},
+		"dogstatsd container id, external data and timestamp": {
+			in: "foo:100|c|@0.5|#tag:value|c:83c0a99c0a54|e:it-false,cn-nginx|T1656581400",
+			out: event.Events{
+				&event.CounterEvent{
+					CMetricName: "foo",
+					CValue:      200,
+					CLabels:     map[string]string{"tag": "value"},
+				},
+			},
+		},
+		"unknown component is skipped": {
+			in: "foo:100|c|x:something|#tag:value",
+			out: event.Events{
+				&event.CounterEvent{
+					CMetricName: "foo",
+					CValue:      100,
+					CLabels:     map[string]string{"tag": "value"},
 				},
 			},
 		},
@@ -850,6 +714,781 @@ func TestLineToEvents(t *testing.T) {
 	}
 }
 
+func TestNumericValues(t *testing.T) {
+	testCases := []struct {
+		in      string
+		lenient bool
+		value   float64
+		valid   bool
+		counted bool
+	}{
+		{in: "foo:1.5|g", value: 1.5, valid: true},
+		{in: "foo:1e3|g", value: 1000, valid: true},
+		{in: "foo:.5E-1|g", value: 0.05, valid: true},
+		{in: "foo:-1.5|g", value: -1.5, valid: true},
+		{in: "foo:0x1p4|g"},
+		{in: "foo:1_000|g"},
+		{in: "foo:1,5|g"},
+		{in: "foo:1,5|g", lenient: true, value: 1.5, valid: true, counted: true},
+		{in: "foo:-1,5e1|g", lenient: true, value: -15, valid: true, counted: true},
+		{in: "foo:1.5|g", lenient: true, value: 1.5, valid: true},
+		{in: "foo:1,000.5|g", lenient: true},
+		{in: "foo:1,0,0|g", lenient: true},
+		{in: "foo:0x1,5|g", lenient: true},
+	}
+
+	for _, tc := range testCases {
+		parser := NewParser()
+		lenientValues := prometheus.NewCounter(prometheus.CounterOpts{})
+		if tc.lenient {
+			parser.EnableLenientNumbers()
+			parser.LenientValues = lenientValues
+		}
+
+		events := parser.LineToEvents(tc.in, *nopSampleErrors, nopSamplesReceived, nopTagErrors, nopTagsReceived, nopLogger)
+		if !tc.valid {
+			if len(events) != 0 {
+				t.Errorf("%s (lenient %v): expected value to be rejected, got %v", tc.in, tc.lenient, events)
+			}
+			continue
+		}
+		if len(events) != 1 || events[0].Value() != tc.value {
+			t.Errorf("%s (lenient %v): expected value %v, got %v", tc.in, tc.lenient, tc.value, events)
+		}
+
+		var m dto.Metric
+		if err := lenientValues.Write(&m); err != nil {
+			t.Fatal(err)
+		}
+		if counted := m.Counter.GetValue() == 1; counted != tc.counted {
+			t.Errorf("%s: expected lenient value to be counted %v, got %v", tc.in, tc.counted, counted)
+		}
+	}
+}
+
+func TestPrefixLabels(t *testing.T) {
+	parser := NewParser()
+	parser.EnableDogstatsdParsing()
+	for _, rule := range []string{"api.checkout.:team=payments", "api.:team=platform,tier=frontend"} {
+		pl, err := ParsePrefixLabels(rule)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", rule, err)
+		}
+		parser.AddPrefixLabels(pl)
+	}
+
+	testCases := []struct {
+		in     string
+		labels map[string]string
+	}{
+		{in: "db.queries:1|c", labels: map[string]string{}},
+		{in: "api.requests:1|c", labels: map[string]string{"team": "platform", "tier": "frontend"}},
+		{in: "api.checkout.requests:1|c", labels: map[string]string{"team": "payments", "tier": "frontend"}},
+		{in: "api.requests:1|c|#team:search", labels: map[string]string{"team": "search", "tier": "frontend"}},
+	}
+	for _, tc := range testCases {
+		events := parser.LineToEvents(tc.in, *nopSampleErrors, nopSamplesReceived, nopTagErrors, nopTagsReceived, nopLogger)
+		if len(events) != 1 {
+			t.Fatalf("%s: expected one event, got %v", tc.in, events)
+		}
+		if !reflect.DeepEqual(events[0].Labels(), tc.labels) {
+			t.Errorf("%s: expected labels %v, got %v", tc.in, tc.labels, events[0].Labels())
+		}
+	}
+
+	for _, rule := range []string{"api.", ":team=platform", "api.:team", "api.:team=platform,"} {
+		if _, err := ParsePrefixLabels(rule); err == nil {
+			t.Errorf("%s: expected error", rule)
+		}
+	}
+}
+
+func TestEtsyNamespaces(t *testing.T) {
+	parser := NewParser()
+	parser.EnableInfluxdbParsing()
+	parser.EnableEtsyNamespaces()
+
+	testCases := []struct {
+		in  string
+		out event.Events
+	}{
+		{
+			in:  "stats.counters.requests:1",
+			out: event.Events{event.NewCounterEvent("requests", 1, map[string]string{})},
+		},
+		{
+			in:  "stats_counts.requests:2:3",
+			out: event.Events{event.NewCounterEvent("requests", 5, map[string]string{})},
+		},
+		{
+			in:  "stats.timers.latency,host=a:320",
+			out: event.Events{event.NewObserverEvent("latency", 0.32, map[string]string{"host": "a"})},
+		},
+		{
+			in:  "stats.gauges.queue:-2",
+			out: event.Events{event.NewGaugeEvent("queue", -2, true, map[string]string{})},
+		},
+		{
+			// The type of the sample takes precedence over the namespace.
+			in:  "stats.gauges.queue:3|c",
+			out: event.Events{event.NewCounterEvent("queue", 3, map[string]string{})},
+		},
+		{
+			in:  "stats.sets.users:1|c",
+			out: event.Events{event.NewCounterEvent("stats.sets.users", 1, map[string]string{})},
+		},
+		{
+			in: "stats.counters.:1",
+		},
+		{
+			in: "requests:1",
+		},
+	}
+	for _, tc := range testCases {
+		events := parser.LineToEvents(tc.in, *nopSampleErrors, nopSamplesReceived, nopTagErrors, nopTagsReceived, nopLogger)
+		if len(events) != len(tc.out) || (len(tc.out) > 0 && !reflect.DeepEqual(events, tc.out)) {
+			t.Errorf("%s: expected %v, got %v", tc.in, tc.out, events)
+		}
+	}
+}
+
+func TestClientTelemetry(t *testing.T) {
+	in := "datadog.dogstatsd.client.packets_dropped:3|c|#client:go,client_version:5.1.0,client_transport:udp"
+	labels := map[string]string{"client": "go", "client_version": "5.1.0", "client_transport": "udp"}
+
+	testCases := []struct {
+		mode string
+		out  event.Events
+	}{
+		{
+			mode: "",
+			out:  event.Events{event.NewCounterEvent("datadog.dogstatsd.client.packets_dropped", 3, labels)},
+		},
+		{
+			mode: ClientTelemetryMap,
+			out:  event.Events{event.NewCounterEvent("datadog.dogstatsd.client.packets_dropped", 3, labels)},
+		},
+		{
+			mode: ClientTelemetryExpose,
+			out:  event.Events{event.NewCounterEvent("dogstatsd_client_packets_dropped", 3, labels)},
+		},
+		{
+			mode: ClientTelemetryDrop,
+		},
+	}
+	for _, tc := range testCases {
+		parser := NewParser()
+		parser.EnableDogstatsdParsing()
+		parser.ClientTelemetry = tc.mode
+
+		events := parser.LineToEvents(in, *nopSampleErrors, nopSamplesReceived, nopTagErrors, nopTagsReceived, nopLogger)
+		if len(events) != len(tc.out) || (len(tc.out) > 0 && !reflect.DeepEqual(events, tc.out)) {
+			t.Errorf("%q: expected %v, got %v", tc.mode, tc.out, events)
+		}
+
+		// Other metrics are not affected.
+		events = parser.LineToEvents("datadog.requests:1|c", *nopSampleErrors, nopSamplesReceived, nopTagErrors, nopTagsReceived, nopLogger)
+		if len(events) != 1 || events[0].MetricName() != "datadog.requests" {
+			t.Errorf("%q: expected datadog.requests to be unchanged, got %v", tc.mode, events)
+		}
+	}
+}
+
+func TestValuelessTags(t *testing.T) {
+	testCases := []struct {
+		in        string
+		value     string
+		labels    map[string]string
+		tagErrors float64
+	}{
+		{in: "foo:1|c|#shipping,env:prod", labels: map[string]string{"env": "prod"}, tagErrors: 1},
+		{in: "foo:1|c|#shipping,env:prod", value: "true", labels: map[string]string{"shipping": "true", "env": "prod"}},
+		{in: "foo:1|c|#env:prod,#express-mail", value: "true", labels: map[string]string{"env": "prod", "express_mail": "true"}},
+		{in: "foo:1|c|#env:,:prod", value: "true", labels: map[string]string{}, tagErrors: 2},
+	}
+	for _, tc := range testCases {
+		parser := NewParser()
+		parser.EnableDogstatsdParsing()
+		if tc.value != "" {
+			parser.EnableValuelessTags(tc.value)
+		}
+		tagErrors := prometheus.NewCounter(prometheus.CounterOpts{})
+		events := parser.LineToEvents(tc.in, *nopSampleErrors, nopSamplesReceived, tagErrors, nopTagsReceived, nopLogger)
+		if len(events) != 1 {
+			t.Fatalf("%s: expected one event, got %v", tc.in, events)
+		}
+		if !reflect.DeepEqual(events[0].Labels(), tc.labels) {
+			t.Errorf("%s (value %q): expected labels %v, got %v", tc.in, tc.value, tc.labels, events[0].Labels())
+		}
+		var m dto.Metric
+		if err := tagErrors.Write(&m); err != nil {
+			t.Fatal(err)
+		}
+		if v := m.GetCounter().GetValue(); v != tc.tagErrors {
+			t.Errorf("%s (value %q): expected %v tag errors, got %v", tc.in, tc.value, tc.tagErrors, v)
+		}
+	}
+}
+
+func TestEscapedTagValues(t *testing.T) {
+	testCases := []struct {
+		in        string
+		labels    map[string]string
+		tagErrors float64
+	}{
+		{in: `query:1|c|#query:SELECT a\,b FROM t,env:prod`, labels: map[string]string{"query": "SELECT a,b FROM t", "env": "prod"}},
+		{in: `query:1|c|#query:"SELECT a,b FROM t",env:prod`, labels: map[string]string{"query": "SELECT a,b FROM t", "env": "prod"}},
+		{in: `query:1|c|#query:"say \"hi\", then go"`, labels: map[string]string{"query": `say "hi", then go`}},
+		{in: `query,query="SELECT a,b FROM t",env=prod:1|c`, labels: map[string]string{"query": "SELECT a,b FROM t", "env": "prod"}},
+		{in: `query,query=a\,b:1|c`, labels: map[string]string{"query": "a,b"}},
+		// Librato and SignalFX tags are split at every comma, and keep
+		// backslashes and quotes.
+		{in: `query#query=a\,b,env=prod:1|c`, labels: map[string]string{"query": `a\`, "env": "prod"}, tagErrors: 1},
+		{in: `query#query="a",env=prod:1|c`, labels: map[string]string{"query": `"a"`, "env": "prod"}},
+		{in: `query[query="a,b",env=prod]:1|c`, labels: map[string]string{"query": `"a`, "env": "prod"}, tagErrors: 1},
+		{in: `query[query=a\,b]:1|c`, labels: map[string]string{"query": `a\`}, tagErrors: 1},
+		// Backslashes that don't escape anything, and quotes that don't
+		// start the value, are kept.
+		{in: `query:1|c|#path:C:\dir\\sub,size:5"`, labels: map[string]string{"path": `C:\dir\sub`, "size": `5"`}},
+		{in: `query:1|c|#query:"a,b,env:prod`, labels: map[string]string{}, tagErrors: 1},
+		{in: `query:1|c|#query:"a"b,env:prod`, labels: map[string]string{"env": "prod"}, tagErrors: 1},
+		{in: `query:1|c|#query:"",env:prod`, labels: map[string]string{"env": "prod"}, tagErrors: 1},
+	}
+	for _, tc := range testCases {
+		parser := NewParser()
+		parser.EnableDogstatsdParsing()
+		parser.EnableInfluxdbParsing()
+		parser.EnableLibratoParsing()
+		parser.EnableSignalFXParsing()
+		tagErrors := prometheus.NewCounter(prometheus.CounterOpts{})
+		events := parser.LineToEvents(tc.in, *nopSampleErrors, nopSamplesReceived, tagErrors, nopTagsReceived, nopLogger)
+		if len(events) != 1 {
+			t.Fatalf("%s: expected one event, got %v", tc.in, events)
+		}
+		if !reflect.DeepEqual(events[0].Labels(), tc.labels) {
+			t.Errorf("%s: expected labels %v, got %v", tc.in, tc.labels, events[0].Labels())
+		}
+		var m dto.Metric
+		if err := tagErrors.Write(&m); err != nil {
+			t.Fatal(err)
+		}
+		if v := m.GetCounter().GetValue(); v != tc.tagErrors {
+			t.Errorf("%s: expected %v tag errors, got %v", tc.in, tc.tagErrors, v)
+		}
+	}
+}
+
+func TestUTF8Names(t *testing.T) {
+	testCases := []struct {
+		in     string
+		utf8   bool
+		name   string
+		labels map[string]string
+	}{
+		{in: "api.requests:1|c|#service.name:users,région:eu", name: "api.requests", labels: map[string]string{"service_name": "users", "r_gion": "eu"}},
+		{in: "api.requests:1|c|#service.name:users,région:eu", utf8: true, name: "api.requests", labels: map[string]string{"service.name": "users", "région": "eu"}},
+		{in: "api.requests,service.name=users:1|c", utf8: true, name: "api.requests", labels: map[string]string{"service.name": "users"}},
+	}
+	for _, tc := range testCases {
+		parser := NewParser()
+		parser.EnableDogstatsdParsing()
+		parser.EnableInfluxdbParsing()
+		if tc.utf8 {
+			parser.EnableUTF8Names()
+		}
+		events := parser.LineToEvents(tc.in, *nopSampleErrors, nopSamplesReceived, nopTagErrors, nopTagsReceived, nopLogger)
+		if len(events) != 1 {
+			t.Fatalf("%s: expected one event, got %v", tc.in, events)
+		}
+		if events[0].MetricName() != tc.name {
+			t.Errorf("%s (utf8 %v): expected name %s, got %s", tc.in, tc.utf8, tc.name, events[0].MetricName())
+		}
+		if !reflect.DeepEqual(events[0].Labels(), tc.labels) {
+			t.Errorf("%s (utf8 %v): expected labels %v, got %v", tc.in, tc.utf8, tc.labels, events[0].Labels())
+		}
+	}
+}
+
+func TestMaxLabels(t *testing.T) {
+	parser := NewParser()
+	parser.EnableDogstatsdParsing()
+	parser.EnableInfluxdbParsing()
+	parser.MaxLabels = 2
+	sampleErrors := prometheus.NewCounterVec(prometheus.CounterOpts{}, []string{"reason"})
+
+	for in, valid := range map[string]bool{
+		"foo:1|c|#a:1,b:2":                 true,
+		"foo:1|c|#a:1,b:2,c:3":             false,
+		"foo,a=1,b=2:1|c":                  true,
+		"foo,a=1,b=2,c=3:1|c":              false,
+		"foo:1|c|#a:1,b:2,a:3,b:4,a:5,b:6": true,
+	} {
+		events := parser.LineToEvents(in, *sampleErrors, nopSamplesReceived, nopTagErrors, nopTagsReceived, nopLogger)
+		if valid != (len(events) == 1) {
+			t.Errorf("%s: expected line to be valid: %v, got %v", in, valid, events)
+		}
+	}
+	var m dto.Metric
+	if err := sampleErrors.WithLabelValues("too_many_labels").Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	if v := m.GetCounter().GetValue(); v != 2 {
+		t.Fatalf("expected 2 samples with too many labels, got %v", v)
+	}
+}
+
+func TestMaxSamples(t *testing.T) {
+	parser := NewParser()
+	parser.MaxSamples = 2
+	sampleErrors := prometheus.NewCounterVec(prometheus.CounterOpts{}, []string{"reason"})
+
+	for in, expected := range map[string]int{
+		"foo:1|c:2|c":     2,
+		"foo:1|c:2|c:3|c": 2,
+		"foo:1:2|ms":      2,
+		"foo:1:2:3:4|ms":  2,
+	} {
+		events := parser.LineToEvents(in, *sampleErrors, nopSamplesReceived, nopTagErrors, nopTagsReceived, nopLogger)
+		var values int
+		for _, e := range events {
+			values += len(e.(event.MultiValueEvent).Values())
+		}
+		if values != expected {
+			t.Errorf("%s: expected %d values, got %v", in, expected, events)
+		}
+	}
+	var m dto.Metric
+	if err := sampleErrors.WithLabelValues("too_many_samples").Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	if v := m.GetCounter().GetValue(); v != 2 {
+		t.Fatalf("expected 2 lines with too many samples, got %v", v)
+	}
+}
+
+func TestDogStatsDEvents(t *testing.T) {
+	parser := NewParser()
+	parser.EnableDogstatsdParsing()
+	parser.EnableDogStatsDEvents(DefaultEventMetricName)
+	var log bytes.Buffer
+	parser.EventLogger = slog.New(slog.NewJSONHandler(&log, nil))
+	var errs []error
+	parser.OnError = func(_ string, err error) {
+		errs = append(errs, err)
+	}
+	sampleErrors := prometheus.NewCounterVec(prometheus.CounterOpts{}, []string{"reason"})
+
+	testCases := map[string]struct {
+		in     string
+		labels map[string]string
+		err    error
+	}{
+		"minimal": {
+			in:     "_e{5,4}:title|text",
+			labels: map[string]string{"title": "title", "alert_type": "info"},
+		},
+		"with fields and tags": {
+			in:     "_e{6,14}:deploy|shipped a|b\\nc|d:1700000000|h:web-1|p:low|t:success|k:deploys|s:ci|#env:prod,team:web",
+			labels: map[string]string{"title": "deploy", "alert_type": "success", "env": "prod", "team": "web"},
+		},
+		"empty text": {
+			in:     "_e{5,0}:title||t:error",
+			labels: map[string]string{"title": "title", "alert_type": "error"},
+		},
+		"unknown field": {
+			in:     "_e{5,4}:title|text|x:y",
+			labels: map[string]string{"title": "title", "alert_type": "info"},
+			err:    ErrUnknownComponent,
+		},
+		"title longer than the line": {
+			in:  "_e{50,4}:title|text",
+			err: ErrMalformedLine,
+		},
+		"text longer than its length": {
+			in:  "_e{5,2}:title|text",
+			err: ErrMalformedLine,
+		},
+		"invalid length": {
+			in:  "_e{x,4}:title|text",
+			err: ErrMalformedLine,
+		},
+		"missing lengths": {
+			in:  "_e{5}:title|text",
+			err: ErrMalformedLine,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			errs = errs[:0]
+			events := parser.LineToEvents(testCase.in, *sampleErrors, nopSamplesReceived, nopTagErrors, nopTagsReceived, nopLogger)
+			if testCase.err != nil && (len(errs) != 1 || !errors.Is(errs[0], testCase.err)) {
+				t.Fatalf("expected %v, got %v", testCase.err, errs)
+			}
+			if testCase.err == nil && len(errs) != 0 {
+				t.Fatalf("expected no errors, got %v", errs)
+			}
+			if testCase.labels == nil {
+				if len(events) != 0 {
+					t.Fatalf("expected no events, got %v", events)
+				}
+				return
+			}
+			expected := event.Events{event.NewCounterEvent(DefaultEventMetricName, 1, testCase.labels)}
+			if !reflect.DeepEqual(events, expected) {
+				t.Fatalf("expected %#v, got %#v", expected, events)
+			}
+		})
+	}
+
+	var logged map[string]interface{}
+	for _, l := range bytes.Split(bytes.TrimSpace(log.Bytes()), []byte("\n")) {
+		if err := json.Unmarshal(l, &logged); err != nil {
+			t.Fatal(err)
+		}
+		if logged["title"] == "deploy" {
+			break
+		}
+	}
+	if logged["title"] != "deploy" || logged["text"] != "shipped a|b\nc" || logged["hostname"] != "web-1" || logged["tags"] != "env:prod,team:web" {
+		t.Fatalf("expected the payload of the event to be logged, got %v", logged)
+	}
+}
+
+func TestDogStatsDServiceChecks(t *testing.T) {
+	parser := NewParser()
+	parser.EnableDogstatsdParsing()
+	parser.EnableDogStatsDServiceChecks(DefaultServiceCheckMetricName)
+	var errs []error
+	parser.OnError = func(_ string, err error) {
+		errs = append(errs, err)
+	}
+	sampleErrors := prometheus.NewCounterVec(prometheus.CounterOpts{}, []string{"reason"})
+
+	testCases := map[string]struct {
+		in     string
+		value  float64
+		labels map[string]string
+		err    error
+	}{
+		"minimal": {
+			in:     "_sc|redis.can_connect|0",
+			value:  0,
+			labels: map[string]string{"check": "redis.can_connect"},
+		},
+		"with fields, tags and message": {
+			in:     "_sc|redis.can_connect|2|d:1700000000|h:web-1|#env:prod,team:web|m:timeout | retrying",
+			value:  2,
+			labels: map[string]string{"check": "redis.can_connect", "env": "prod", "team": "web"},
+		},
+		"unknown field": {
+			in:     "_sc|db|3|x:y",
+			value:  3,
+			labels: map[string]string{"check": "db"},
+			err:    ErrUnknownComponent,
+		},
+		"invalid status": {
+			in:  "_sc|db|4",
+			err: ErrBadValue,
+		},
+		"missing status": {
+			in:  "_sc|db",
+			err: ErrMalformedLine,
+		},
+		"missing name": {
+			in:  "_sc||1",
+			err: ErrMalformedLine,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			errs = errs[:0]
+			events := parser.LineToEvents(testCase.in, *sampleErrors, nopSamplesReceived, nopTagErrors, nopTagsReceived, nopLogger)
+			if testCase.err != nil && (len(errs) != 1 || !errors.Is(errs[0], testCase.err)) {
+				t.Fatalf("expected %v, got %v", testCase.err, errs)
+			}
+			if testCase.err == nil && len(errs) != 0 {
+				t.Fatalf("expected no errors, got %v", errs)
+			}
+			if testCase.labels == nil {
+				if len(events) != 0 {
+					t.Fatalf("expected no events, got %v", events)
+				}
+				return
+			}
+			expected := event.Events{event.NewGaugeEvent(DefaultServiceCheckMetricName, testCase.value, false, testCase.labels)}
+			if !reflect.DeepEqual(events, expected) {
+				t.Fatalf("expected %#v, got %#v", expected, events)
+			}
+		})
+	}
+}
+
+func TestInvalidSampleRates(t *testing.T) {
+	for _, policy := range []string{InvalidSampleRateClamp, InvalidSampleRateReject} {
+		parser := NewParser()
+		parser.InvalidSampleRates = policy
+		var errs []error
+		parser.OnError = func(_ string, err error) {
+			errs = append(errs, err)
+		}
+
+		for _, testCase := range []struct {
+			in    string
+			value float64
+			valid bool
+		}{
+			{"foo:2|c|@0.5", 4, true},
+			{"foo:2|c|@1", 2, true},
+			{"foo:2|c|@0", 2, false},
+			{"foo:2|c|@-0.5", 2, false},
+			{"foo:2|c|@2", 2, false},
+			{"foo:2|c|@NaN", 2, false},
+			{"foo:2|c|@x", 2, false},
+		} {
+			errs = errs[:0]
+			events := parser.LineToEvents(testCase.in, *nopSampleErrors, nopSamplesReceived, nopTagErrors, nopTagsReceived, nopLogger)
+			if testCase.valid {
+				if len(errs) != 0 {
+					t.Fatalf("%s %s: expected no errors, got %v", policy, testCase.in, errs)
+				}
+			} else if len(errs) != 1 || !errors.Is(errs[0], ErrBadSampleRate) {
+				t.Fatalf("%s %s: expected %v, got %v", policy, testCase.in, ErrBadSampleRate, errs)
+			}
+			if !testCase.valid && policy == InvalidSampleRateReject {
+				if len(events) != 0 {
+					t.Fatalf("%s %s: expected the sample to be dropped, got %v", policy, testCase.in, events)
+				}
+				continue
+			}
+			if len(events) != 1 || events[0].Value() != testCase.value {
+				t.Fatalf("%s %s: expected a counter event of %v, got %v", policy, testCase.in, testCase.value, events)
+			}
+		}
+
+		// Timers with an invalid sample rate are not multiplied.
+		errs = errs[:0]
+		events := parser.LineToEvents("foo:2|ms|@0", *nopSampleErrors, nopSamplesReceived, nopTagErrors, nopTagsReceived, nopLogger)
+		expected := 1
+		if policy == InvalidSampleRateReject {
+			expected = 0
+		}
+		if len(events) != expected {
+			t.Fatalf("%s: expected %d timer events, got %v", policy, expected, events)
+		}
+	}
+}
+
+func TestWithTagFormats(t *testing.T) {
+	parser := NewParser()
+	parser.EnableDogstatsdParsing()
+	parser.EnableInfluxdbParsing()
+	dogstatsdOnly, err := parser.WithTagFormats([]string{TagFormatDogStatsD})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parser.WithTagFormats([]string{"graphite"}); err == nil {
+		t.Fatalf("expected unknown tag format to be an error")
+	}
+
+	for _, testCase := range []struct {
+		parser *Parser
+		in     string
+		out    event.Event
+	}{
+		{parser, "foo,tag=bar:1|c", event.NewCounterEvent("foo", 1, map[string]string{"tag": "bar"})},
+		{dogstatsdOnly, "foo,tag=bar:1|c", event.NewCounterEvent("foo,tag=bar", 1, map[string]string{})},
+		{dogstatsdOnly, "foo:1|c|#tag:bar", event.NewCounterEvent("foo", 1, map[string]string{"tag": "bar"})},
+	} {
+		events := testCase.parser.LineToEvents(testCase.in, *nopSampleErrors, nopSamplesReceived, nopTagErrors, nopTagsReceived, nopLogger)
+		if !reflect.DeepEqual(events, event.Events{testCase.out}) {
+			t.Fatalf("%s: expected %#v, got %#v", testCase.in, testCase.out, events)
+		}
+	}
+}
+
+// prefixedParser is a custom line format that parses StatsD lines with a
+// "ns/" namespace prefix.
+type prefixedParser struct {
+	*Parser
+}
+
+func (p prefixedParser) LineToEvents(line string, sampleErrors prometheus.CounterVec, samplesReceived prometheus.Counter, tagErrors prometheus.Counter, tagsReceived prometheus.Counter, logger *slog.Logger) event.Events {
+	return p.Parser.LineToEvents(strings.TrimPrefix(line, "ns/"), sampleErrors, samplesReceived, tagErrors, tagsReceived, logger)
+}
+
+func TestRegisterFormat(t *testing.T) {
+	RegisterFormat("test-prefixed", func(p *Parser) LineParser { return prefixedParser{p} })
+	if !slices.Contains(Formats(), "test-prefixed") || !slices.Contains(Formats(), FormatGraphite) {
+		t.Fatalf("expected registered formats, got %v", Formats())
+	}
+
+	lp, err := NewLineParser("test-prefixed", NewParser())
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := lp.LineToEvents("ns/foo:1|c", *nopSampleErrors, nopSamplesReceived, nopTagErrors, nopTagsReceived, nopLogger)
+	if len(events) != 1 || events[0].MetricName() != "foo" {
+		t.Fatalf("expected the custom format to parse the line, got %v", events)
+	}
+
+	if _, err := NewLineParser("unknown", NewParser()); err == nil {
+		t.Fatalf("expected unknown format to be an error")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected registering a format twice to panic")
+		}
+	}()
+	RegisterFormat(FormatStatsD, func(p *Parser) LineParser { return p })
+}
+
+func TestGraphiteParser(t *testing.T) {
+	parser := NewParser()
+	parser.MaxLabels = 2
+	graphite := NewGraphiteParser(parser)
+	var errs []error
+	parser.OnError = func(_ string, err error) {
+		errs = append(errs, err)
+	}
+	sampleErrors := prometheus.NewCounterVec(prometheus.CounterOpts{}, []string{"reason"})
+
+	testCases := map[string]struct {
+		in  string
+		out event.Events
+		err error
+	}{
+		"with timestamp": {
+			in:  "servers.web-1.load 0.5 1700000000",
+			out: event.Events{event.NewGaugeEvent("servers.web-1.load", 0.5, false, map[string]string{})},
+		},
+		"without timestamp": {
+			in:  "servers.web-1.load\t-2",
+			out: event.Events{event.NewGaugeEvent("servers.web-1.load", -2, false, map[string]string{})},
+		},
+		"tagged": {
+			in:  "load;host=web-1;dc=eu 0.5 -1",
+			out: event.Events{event.NewGaugeEvent("load", 0.5, false, map[string]string{"host": "web-1", "dc": "eu"})},
+		},
+		"malformed tag": {
+			in:  "load;host 0.5",
+			out: event.Events{event.NewGaugeEvent("load", 0.5, false, map[string]string{})},
+		},
+		"too many tags": {
+			in:  "load;a=1;b=2;c=3 0.5",
+			err: ErrTooManyLabels,
+		},
+		"missing value": {
+			in:  "servers.web-1.load",
+			err: ErrMalformedLine,
+		},
+		"bad value": {
+			in:  "servers.web-1.load high 1700000000",
+			err: ErrBadValue,
+		},
+		"bad timestamp": {
+			in:  "servers.web-1.load 0.5 yesterday",
+			err: ErrMalformedLine,
+		},
+		"statsd line": {
+			in:  "foo:1|c",
+			err: ErrMalformedLine,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			errs = errs[:0]
+			events := graphite.LineToEvents(testCase.in, *sampleErrors, nopSamplesReceived, nopTagErrors, nopTagsReceived, nopLogger)
+			if testCase.err != nil && (len(errs) != 1 || !errors.Is(errs[0], testCase.err)) {
+				t.Fatalf("expected %v, got %v", testCase.err, errs)
+			}
+			if testCase.err == nil && len(errs) != 0 {
+				t.Fatalf("expected no errors, got %v", errs)
+			}
+			if len(events) != len(testCase.out) || (len(events) > 0 && !reflect.DeepEqual(events, testCase.out)) {
+				t.Fatalf("expected %#v, got %#v", testCase.out, events)
+			}
+		})
+	}
+}
+
+func TestSampleErrors(t *testing.T) {
+	parser := NewParser()
+	parser.EnableDogstatsdParsing()
+	parser.EnableInfluxdbParsing()
+	var got []error
+	parser.OnError = func(_ string, err error) {
+		got = append(got, err)
+	}
+	parser.MaxLabels = 2
+	parser.MaxSamples = 2
+	sampleErrors := prometheus.NewCounterVec(prometheus.CounterOpts{}, []string{"reason"})
+
+	for in, expected := range map[string]error{
+		"foo":                  ErrMalformedLine,
+		"foo,a=1:1|c|#b:2":     ErrMixedTagStyles,
+		"foo:1":                ErrMissingType,
+		"foo:1:2|s":            ErrInvalidAggregateType,
+		"foo:1|c:2":            ErrMalformedComponent,
+		"foo:1|c||x":           ErrMalformedComponent,
+		"foo:bar|c":            ErrBadValue,
+		"foo:0x1|c":            ErrBadValue,
+		"foo:1|c|@x":           ErrBadSampleRate,
+		"foo:1|c|x":            ErrUnknownComponent,
+		"foo:|s":               ErrBadValue,
+		"foo:1|x":              ErrUnsupportedType,
+		"foo:1|c|#a:1,b:2,c:3": ErrTooManyLabels,
+		"foo:1|c:2|c:3|c":      ErrTooManySamples,
+	} {
+		got = got[:0]
+		parser.LineToEvents(in, *sampleErrors, nopSamplesReceived, nopTagErrors, nopTagsReceived, nopLogger)
+		if len(got) != 1 || !errors.Is(got[0], expected) {
+			t.Errorf("%s: expected %v, got %v", in, expected, got)
+			continue
+		}
+		var se *SampleError
+		if !errors.As(got[0], &se) {
+			t.Fatalf("%s: expected a SampleError, got %T", in, got[0])
+		}
+		var m dto.Metric
+		if err := sampleErrors.WithLabelValues(se.Reason).Write(&m); err != nil {
+			t.Fatal(err)
+		}
+		if m.GetCounter().GetValue() == 0 {
+			t.Errorf("%s: expected the error to be counted with reason %s", in, se.Reason)
+		}
+	}
+}
+
+// TestLabelsNotShared validates that events don't share the label maps that
+// lines are parsed into, since the exporter modifies them, not even events of
+// the same line.
+func TestLabelsNotShared(t *testing.T) {
+	parser := NewParser()
+	parser.EnableDogstatsdParsing()
+
+	first := parser.LineToEvents("foo:1|c|#a:1", *nopSampleErrors, nopSamplesReceived, nopTagErrors, nopTagsReceived, nopLogger)
+	second := parser.LineToEvents("bar:1|c|#b:2", *nopSampleErrors, nopSamplesReceived, nopTagErrors, nopTagsReceived, nopLogger)
+	first[0].Labels()["mapped"] = "x"
+	if !reflect.DeepEqual(first[0].Labels(), map[string]string{"a": "1", "mapped": "x"}) {
+		t.Fatalf("unexpected labels of the first event: %v", first[0].Labels())
+	}
+	if !reflect.DeepEqual(second[0].Labels(), map[string]string{"b": "2"}) {
+		t.Fatalf("unexpected labels of the second event: %v", second[0].Labels())
+	}
+
+	gauges := parser.LineToEvents("baz:1:2|g|#c:3", *nopSampleErrors, nopSamplesReceived, nopTagErrors, nopTagsReceived, nopLogger)
+	if len(gauges) != 2 {
+		t.Fatalf("expected 2 events, got %v", gauges)
+	}
+	gauges[0].Labels()["mapped"] = "x"
+	if !reflect.DeepEqual(gauges[1].Labels(), map[string]string{"c": "3"}) {
+		t.Fatalf("unexpected labels of the second event of the line: %v", gauges[1].Labels())
+	}
+}
+
 func TestDisableParsingLineToEvents(t *testing.T) {
 	type testCase struct {
 		in  string
@@ -1017,293 +1656,87 @@ func TestDisableParsingLineToEvents(t *testing.T) {
 				},
 			},
 		},
-		"datadog tag extension with empty tags (edge case)": {
-			in: "foo:100|c|#tag:value,,",
-			out: event.Events{
-				&event.CounterEvent{
-					CMetricName: "foo",
-					CValue:      100,
-					CLabels:     map[string]string{},
-				},
-			},
-		},
-		"datadog tag extension with sampling": {
-			in: "foo:100|c|@0.1|#tag1:bar,#tag2:baz",
-			out: event.Events{
-				&event.CounterEvent{
-					CMetricName: "foo",
-					CValue:      1000,
-					CLabels:     map[string]string{},
-				},
-			},
-		},
-		"datadog timings with extended aggregation values": {
-			in: "foo_timing:0.5:120:3000:10:20000:0.01|ms|#tag1:bar,tag2:baz",
-			out: event.Events{
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.120,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      3,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.01,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      20,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.00001,
-					OLabels:     map[string]string{},
-				},
-			},
-		},
-		"datadog timings with extended aggregation values without tags": {
-			in: "foo_timing:0.5:120:3000:10:20000:0.01|ms",
-			out: event.Events{
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.120,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      3,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.01,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      20,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.00001,
-					OLabels:     map[string]string{},
-				},
-			},
-		},
-		"datadog timings with extended aggregation values and sampling but without tags": {
-			in: "foo_timing:0.5:120:3000:10:20000:0.01|ms|@0.5",
-			out: event.Events{
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.120,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.120,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      3,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      3,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.01,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.01,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      20,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      20,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.00001,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.00001,
-					OLabels:     map[string]string{},
-				},
-			},
-		},
-		"datadog timings with extended aggregation values, sampling, and tags": {
-			in: "foo_timing:0.5:120:3000:10:20000:0.01|ms|@0.5|#tag1:bar,tag2:baz",
-			out: event.Events{
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.120,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.120,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      3,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      3,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.01,
-					OLabels:     map[string]string{},
+		"datadog tag extension with empty tags (edge case)": {
+			in: "foo:100|c|#tag:value,,",
+			out: event.Events{
+				&event.CounterEvent{
+					CMetricName: "foo",
+					CValue:      100,
+					CLabels:     map[string]string{},
 				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.01,
-					OLabels:     map[string]string{},
+			},
+		},
+		"datadog tag extension with sampling": {
+			in: "foo:100|c|@0.1|#tag1:bar,#tag2:baz",
+			out: event.Events{
+				&event.CounterEvent{
+					CMetricName: "foo",
+					CValue:      1000,
+					CLabels:     map[string]string{},
 				},
-				&event.ObserverEvent{
+			},
+		},
+		"datadog timings with extended aggregation values": {
+			in: "foo_timing:0.5:120:3000:10:20000:0.01|ms|#tag1:bar,tag2:baz",
+			out: event.Events{
+				&event.MultiObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      20,
+					OValues:     []float64{0.0005, 0.120, 3, 0.01, 20, 0.00001},
 					OLabels:     map[string]string{},
 				},
-				&event.ObserverEvent{
+			},
+		},
+		"datadog timings with extended aggregation values without tags": {
+			in: "foo_timing:0.5:120:3000:10:20000:0.01|ms",
+			out: event.Events{
+				&event.MultiObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      20,
+					OValues:     []float64{0.0005, 0.120, 3, 0.01, 20, 0.00001},
 					OLabels:     map[string]string{},
 				},
-				&event.ObserverEvent{
+			},
+		},
+		"datadog timings with extended aggregation values and sampling but without tags": {
+			in: "foo_timing:0.5:120:3000:10:20000:0.01|ms|@0.5",
+			out: event.Events{
+				&event.MultiObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.00001,
+					OValues:     []float64{0.0005, 0.120, 3, 0.01, 20, 0.00001},
 					OLabels:     map[string]string{},
+					SampleRate:  0.5,
 				},
-				&event.ObserverEvent{
+			},
+		},
+		"datadog timings with extended aggregation values, sampling, and tags": {
+			in: "foo_timing:0.5:120:3000:10:20000:0.01|ms|@0.5|#tag1:bar,tag2:baz",
+			out: event.Events{
+				&event.MultiObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.00001,
+					OValues:     []float64{0.0005, 0.120, 3, 0.01, 20, 0.00001},
 					OLabels:     map[string]string{},
+					SampleRate:  0.5,
 				},
 			},
 		},
 		"datadog histogram with extended aggregation values and tags": {
 			in: "foo_histogram:0.5:120:3000:10:20000:0.01|h|#tag1:bar,tag2:baz",
 			out: event.Events{
-				&event.ObserverEvent{
-					OMetricName: "foo_histogram",
-					OValue:      0.5,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_histogram",
-					OValue:      120,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_histogram",
-					OValue:      3000,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_histogram",
-					OValue:      10,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_histogram",
-					OValue:      20000,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
+				&event.MultiObserverEvent{
 					OMetricName: "foo_histogram",
-					OValue:      0.01,
+					OValues:     []float64{0.5, 120, 3000, 10, 20000, 0.01},
 					OLabels:     map[string]string{},
+					OKind:       event.ObserverKindHistogram,
 				},
 			},
 		},
 		"datadog distribution with extended aggregation values": {
 			in: "foo_distribution:0.5:120:3000:10:20000:0.01|d|#tag1:bar,tag2:baz",
 			out: event.Events{
-				&event.ObserverEvent{
-					OMetricName: "foo_distribution",
-					OValue:      0.5,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_distribution",
-					OValue:      120,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_distribution",
-					OValue:      3000,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_distribution",
-					OValue:      10,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_distribution",
-					OValue:      20000,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
+				&event.MultiObserverEvent{
 					OMetricName: "foo_distribution",
-					OValue:      0.01,
+					OValues:     []float64{0.5, 120, 3000, 10, 20000, 0.01},
 					OLabels:     map[string]string{},
+					OKind:       event.ObserverKindDistribution,
 				},
 			},
 		},
@@ -1551,289 +1984,83 @@ func TestDisableParsingDogstatsdLineToEvents(t *testing.T) {
 			in: "foo:100|c|#tag:value,,",
 			out: event.Events{
 				&event.CounterEvent{
-					CMetricName: "foo",
-					CValue:      100,
-					CLabels:     map[string]string{},
-				},
-			},
-		},
-		"datadog tag extension with sampling": {
-			in: "foo:100|c|@0.1|#tag1:bar,#tag2:baz",
-			out: event.Events{
-				&event.CounterEvent{
-					CMetricName: "foo",
-					CValue:      1000,
-					CLabels:     map[string]string{},
-				},
-			},
-		},
-		"datadog timings with extended aggregation values": {
-			in: "foo_timing:0.5:120:3000:10:20000:0.01|ms|#tag1:bar,tag2:baz",
-			out: event.Events{
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.120,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      3,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.01,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      20,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.00001,
-					OLabels:     map[string]string{},
-				},
-			},
-		},
-		"datadog timings with extended aggregation values without tags": {
-			in: "foo_timing:0.5:120:3000:10:20000:0.01|ms",
-			out: event.Events{
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.120,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      3,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.01,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      20,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.00001,
-					OLabels:     map[string]string{},
-				},
-			},
-		},
-		"datadog timings with extended aggregation values and sampling but without tags": {
-			in: "foo_timing:0.5:120:3000:10:20000:0.01|ms|@0.5",
-			out: event.Events{
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.120,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.120,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      3,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      3,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.01,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.01,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      20,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      20,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.00001,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.00001,
-					OLabels:     map[string]string{},
-				},
-			},
-		},
-		"datadog timings with extended aggregation values, sampling, and tags": {
-			in: "foo_timing:0.5:120:3000:10:20000:0.01|ms|@0.5|#tag1:bar,tag2:baz",
-			out: event.Events{
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.120,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.120,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      3,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      3,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.01,
-					OLabels:     map[string]string{},
+					CMetricName: "foo",
+					CValue:      100,
+					CLabels:     map[string]string{},
 				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.01,
-					OLabels:     map[string]string{},
+			},
+		},
+		"datadog tag extension with sampling": {
+			in: "foo:100|c|@0.1|#tag1:bar,#tag2:baz",
+			out: event.Events{
+				&event.CounterEvent{
+					CMetricName: "foo",
+					CValue:      1000,
+					CLabels:     map[string]string{},
 				},
-				&event.ObserverEvent{
+			},
+		},
+		"datadog timings with extended aggregation values": {
+			in: "foo_timing:0.5:120:3000:10:20000:0.01|ms|#tag1:bar,tag2:baz",
+			out: event.Events{
+				&event.MultiObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      20,
+					OValues:     []float64{0.0005, 0.120, 3, 0.01, 20, 0.00001},
 					OLabels:     map[string]string{},
 				},
-				&event.ObserverEvent{
+			},
+		},
+		"datadog timings with extended aggregation values without tags": {
+			in: "foo_timing:0.5:120:3000:10:20000:0.01|ms",
+			out: event.Events{
+				&event.MultiObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      20,
+					OValues:     []float64{0.0005, 0.120, 3, 0.01, 20, 0.00001},
 					OLabels:     map[string]string{},
 				},
-				&event.ObserverEvent{
+			},
+		},
+		"datadog timings with extended aggregation values and sampling but without tags": {
+			in: "foo_timing:0.5:120:3000:10:20000:0.01|ms|@0.5",
+			out: event.Events{
+				&event.MultiObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.00001,
+					OValues:     []float64{0.0005, 0.120, 3, 0.01, 20, 0.00001},
 					OLabels:     map[string]string{},
+					SampleRate:  0.5,
 				},
-				&event.ObserverEvent{
+			},
+		},
+		"datadog timings with extended aggregation values, sampling, and tags": {
+			in: "foo_timing:0.5:120:3000:10:20000:0.01|ms|@0.5|#tag1:bar,tag2:baz",
+			out: event.Events{
+				&event.MultiObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.00001,
+					OValues:     []float64{0.0005, 0.120, 3, 0.01, 20, 0.00001},
 					OLabels:     map[string]string{},
+					SampleRate:  0.5,
 				},
 			},
 		},
 		"datadog histogram with extended aggregation values and tags": {
 			in: "foo_histogram:0.5:120:3000:10:20000:0.01|h|#tag1:bar,tag2:baz",
 			out: event.Events{
-				&event.ObserverEvent{
-					OMetricName: "foo_histogram",
-					OValue:      0.5,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_histogram",
-					OValue:      120,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_histogram",
-					OValue:      3000,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_histogram",
-					OValue:      10,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_histogram",
-					OValue:      20000,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
+				&event.MultiObserverEvent{
 					OMetricName: "foo_histogram",
-					OValue:      0.01,
+					OValues:     []float64{0.5, 120, 3000, 10, 20000, 0.01},
 					OLabels:     map[string]string{},
+					OKind:       event.ObserverKindHistogram,
 				},
 			},
 		},
 		"datadog distribution with extended aggregation values": {
 			in: "foo_distribution:0.5:120:3000:10:20000:0.01|d|#tag1:bar,tag2:baz",
 			out: event.Events{
-				&event.ObserverEvent{
-					OMetricName: "foo_distribution",
-					OValue:      0.5,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_distribution",
-					OValue:      120,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_distribution",
-					OValue:      3000,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_distribution",
-					OValue:      10,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_distribution",
-					OValue:      20000,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
+				&event.MultiObserverEvent{
 					OMetricName: "foo_distribution",
-					OValue:      0.01,
+					OValues:     []float64{0.5, 120, 3000, 10, 20000, 0.01},
 					OLabels:     map[string]string{},
+					OKind:       event.ObserverKindDistribution,
 				},
 			},
 		},
@@ -2085,288 +2312,82 @@ func TestDisableParsingInfluxdbLineToEvents(t *testing.T) {
 			out: event.Events{
 				&event.CounterEvent{
 					CMetricName: "foo",
-					CValue:      100,
-					CLabels:     map[string]string{"tag": "value"},
-				},
-			},
-		},
-		"datadog tag extension with sampling": {
-			in: "foo:100|c|@0.1|#tag1:bar,#tag2:baz",
-			out: event.Events{
-				&event.CounterEvent{
-					CMetricName: "foo",
-					CValue:      1000,
-					CLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-			},
-		},
-		"datadog timings with extended aggregation values": {
-			in: "foo_timing:0.5:120:3000:10:20000:0.01|ms|#tag1:bar,tag2:baz",
-			out: event.Events{
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.120,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      3,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.01,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      20,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.00001,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-			},
-		},
-		"datadog timings with extended aggregation values without tags": {
-			in: "foo_timing:0.5:120:3000:10:20000:0.01|ms",
-			out: event.Events{
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.120,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      3,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.01,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      20,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.00001,
-					OLabels:     map[string]string{},
-				},
-			},
-		},
-		"datadog timings with extended aggregation values and sampling but without tags": {
-			in: "foo_timing:0.5:120:3000:10:20000:0.01|ms|@0.5",
-			out: event.Events{
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.120,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.120,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      3,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      3,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.01,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.01,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      20,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      20,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.00001,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.00001,
-					OLabels:     map[string]string{},
-				},
-			},
-		},
-		"datadog timings with extended aggregation values, sampling, and tags": {
-			in: "foo_timing:0.5:120:3000:10:20000:0.01|ms|@0.5|#tag1:bar,tag2:baz",
-			out: event.Events{
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.120,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.120,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      3,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      3,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.01,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.01,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
+					CValue:      100,
+					CLabels:     map[string]string{"tag": "value"},
 				},
-				&event.ObserverEvent{
+			},
+		},
+		"datadog tag extension with sampling": {
+			in: "foo:100|c|@0.1|#tag1:bar,#tag2:baz",
+			out: event.Events{
+				&event.CounterEvent{
+					CMetricName: "foo",
+					CValue:      1000,
+					CLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
+				},
+			},
+		},
+		"datadog timings with extended aggregation values": {
+			in: "foo_timing:0.5:120:3000:10:20000:0.01|ms|#tag1:bar,tag2:baz",
+			out: event.Events{
+				&event.MultiObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      20,
+					OValues:     []float64{0.0005, 0.120, 3, 0.01, 20, 0.00001},
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
-				&event.ObserverEvent{
+			},
+		},
+		"datadog timings with extended aggregation values without tags": {
+			in: "foo_timing:0.5:120:3000:10:20000:0.01|ms",
+			out: event.Events{
+				&event.MultiObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      20,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
+					OValues:     []float64{0.0005, 0.120, 3, 0.01, 20, 0.00001},
+					OLabels:     map[string]string{},
 				},
-				&event.ObserverEvent{
+			},
+		},
+		"datadog timings with extended aggregation values and sampling but without tags": {
+			in: "foo_timing:0.5:120:3000:10:20000:0.01|ms|@0.5",
+			out: event.Events{
+				&event.MultiObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.00001,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
+					OValues:     []float64{0.0005, 0.120, 3, 0.01, 20, 0.00001},
+					OLabels:     map[string]string{},
+					SampleRate:  0.5,
 				},
-				&event.ObserverEvent{
+			},
+		},
+		"datadog timings with extended aggregation values, sampling, and tags": {
+			in: "foo_timing:0.5:120:3000:10:20000:0.01|ms|@0.5|#tag1:bar,tag2:baz",
+			out: event.Events{
+				&event.MultiObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.00001,
+					OValues:     []float64{0.0005, 0.120, 3, 0.01, 20, 0.00001},
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
+					SampleRate:  0.5,
 				},
 			},
 		},
 		"datadog histogram with extended aggregation values and tags": {
 			in: "foo_histogram:0.5:120:3000:10:20000:0.01|h|#tag1:bar,tag2:baz",
 			out: event.Events{
-				&event.ObserverEvent{
-					OMetricName: "foo_histogram",
-					OValue:      0.5,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_histogram",
-					OValue:      120,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_histogram",
-					OValue:      3000,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_histogram",
-					OValue:      10,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_histogram",
-					OValue:      20000,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
+				&event.MultiObserverEvent{
 					OMetricName: "foo_histogram",
-					OValue:      0.01,
+					OValues:     []float64{0.5, 120, 3000, 10, 20000, 0.01},
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
+					OKind:       event.ObserverKindHistogram,
 				},
 			},
 		},
 		"datadog distribution with extended aggregation values": {
 			in: "foo_distribution:0.5:120:3000:10:20000:0.01|d|#tag1:bar,tag2:baz",
 			out: event.Events{
-				&event.ObserverEvent{
-					OMetricName: "foo_distribution",
-					OValue:      0.5,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_distribution",
-					OValue:      120,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_distribution",
-					OValue:      3000,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_distribution",
-					OValue:      10,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_distribution",
-					OValue:      20000,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
+				&event.MultiObserverEvent{
 					OMetricName: "foo_distribution",
-					OValue:      0.01,
+					OValues:     []float64{0.5, 120, 3000, 10, 20000, 0.01},
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
+					OKind:       event.ObserverKindDistribution,
 				},
 			},
 		},
@@ -2636,34 +2657,9 @@ func TestDisableParsingSignalfxLineToEvents(t *testing.T) {
 		"datadog timings with extended aggregation values": {
 			in: "foo_timing:0.5:120:3000:10:20000:0.01|ms|#tag1:bar,tag2:baz",
 			out: event.Events{
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.120,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      3,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.01,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      20,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
+				&event.MultiObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.00001,
+					OValues:     []float64{0.0005, 0.120, 3, 0.01, 20, 0.00001},
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
 			},
@@ -2671,34 +2667,9 @@ func TestDisableParsingSignalfxLineToEvents(t *testing.T) {
 		"datadog timings with extended aggregation values without tags": {
 			in: "foo_timing:0.5:120:3000:10:20000:0.01|ms",
 			out: event.Events{
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.120,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      3,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.01,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      20,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
+				&event.MultiObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.00001,
+					OValues:     []float64{0.0005, 0.120, 3, 0.01, 20, 0.00001},
 					OLabels:     map[string]string{},
 				},
 			},
@@ -2706,200 +2677,44 @@ func TestDisableParsingSignalfxLineToEvents(t *testing.T) {
 		"datadog timings with extended aggregation values and sampling but without tags": {
 			in: "foo_timing:0.5:120:3000:10:20000:0.01|ms|@0.5",
 			out: event.Events{
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.120,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.120,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      3,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      3,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.01,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.01,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      20,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      20,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.00001,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
+				&event.MultiObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.00001,
+					OValues:     []float64{0.0005, 0.120, 3, 0.01, 20, 0.00001},
 					OLabels:     map[string]string{},
+					SampleRate:  0.5,
 				},
 			},
 		},
 		"datadog timings with extended aggregation values, sampling, and tags": {
-			in: "foo_timing:0.5:120:3000:10:20000:0.01|ms|@0.5|#tag1:bar,tag2:baz",
-			out: event.Events{
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.120,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.120,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      3,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      3,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.01,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.01,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      20,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      20,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.00001,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.00001,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-			},
-		},
-		"datadog histogram with extended aggregation values and tags": {
-			in: "foo_histogram:0.5:120:3000:10:20000:0.01|h|#tag1:bar,tag2:baz",
-			out: event.Events{
-				&event.ObserverEvent{
-					OMetricName: "foo_histogram",
-					OValue:      0.5,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_histogram",
-					OValue:      120,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_histogram",
-					OValue:      3000,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_histogram",
-					OValue:      10,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_histogram",
-					OValue:      20000,
+			in: "foo_timing:0.5:120:3000:10:20000:0.01|ms|@0.5|#tag1:bar,tag2:baz",
+			out: event.Events{
+				&event.MultiObserverEvent{
+					OMetricName: "foo_timing",
+					OValues:     []float64{0.0005, 0.120, 3, 0.01, 20, 0.00001},
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
+					SampleRate:  0.5,
 				},
-				&event.ObserverEvent{
+			},
+		},
+		"datadog histogram with extended aggregation values and tags": {
+			in: "foo_histogram:0.5:120:3000:10:20000:0.01|h|#tag1:bar,tag2:baz",
+			out: event.Events{
+				&event.MultiObserverEvent{
 					OMetricName: "foo_histogram",
-					OValue:      0.01,
+					OValues:     []float64{0.5, 120, 3000, 10, 20000, 0.01},
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
+					OKind:       event.ObserverKindHistogram,
 				},
 			},
 		},
 		"datadog distribution with extended aggregation values": {
 			in: "foo_distribution:0.5:120:3000:10:20000:0.01|d|#tag1:bar,tag2:baz",
 			out: event.Events{
-				&event.ObserverEvent{
-					OMetricName: "foo_distribution",
-					OValue:      0.5,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_distribution",
-					OValue:      120,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_distribution",
-					OValue:      3000,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_distribution",
-					OValue:      10,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_distribution",
-					OValue:      20000,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
+				&event.MultiObserverEvent{
 					OMetricName: "foo_distribution",
-					OValue:      0.01,
+					OValues:     []float64{0.5, 120, 3000, 10, 20000, 0.01},
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
+					OKind:       event.ObserverKindDistribution,
 				},
 			},
 		},
@@ -3169,34 +2984,9 @@ func TestDisableParsingLibratoLineToEvents(t *testing.T) {
 		"datadog timings with extended aggregation values": {
 			in: "foo_timing:0.5:120:3000:10:20000:0.01|ms|#tag1:bar,tag2:baz",
 			out: event.Events{
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.120,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      3,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.01,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      20,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
+				&event.MultiObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.00001,
+					OValues:     []float64{0.0005, 0.120, 3, 0.01, 20, 0.00001},
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
 				},
 			},
@@ -3204,34 +2994,9 @@ func TestDisableParsingLibratoLineToEvents(t *testing.T) {
 		"datadog timings with extended aggregation values without tags": {
 			in: "foo_timing:0.5:120:3000:10:20000:0.01|ms",
 			out: event.Events{
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.120,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      3,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.01,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      20,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
+				&event.MultiObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.00001,
+					OValues:     []float64{0.0005, 0.120, 3, 0.01, 20, 0.00001},
 					OLabels:     map[string]string{},
 				},
 			},
@@ -3239,200 +3004,44 @@ func TestDisableParsingLibratoLineToEvents(t *testing.T) {
 		"datadog timings with extended aggregation values and sampling but without tags": {
 			in: "foo_timing:0.5:120:3000:10:20000:0.01|ms|@0.5",
 			out: event.Events{
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.120,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.120,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      3,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      3,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.01,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.01,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      20,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      20,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.00001,
-					OLabels:     map[string]string{},
-				},
-				&event.ObserverEvent{
+				&event.MultiObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.00001,
+					OValues:     []float64{0.0005, 0.120, 3, 0.01, 20, 0.00001},
 					OLabels:     map[string]string{},
+					SampleRate:  0.5,
 				},
 			},
 		},
 		"datadog timings with extended aggregation values, sampling, and tags": {
 			in: "foo_timing:0.5:120:3000:10:20000:0.01|ms|@0.5|#tag1:bar,tag2:baz",
 			out: event.Events{
-				&event.ObserverEvent{
+				&event.MultiObserverEvent{
 					OMetricName: "foo_timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.0005,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.120,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.120,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      3,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      3,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.01,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.01,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      20,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      20,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.00001,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_timing",
-					OValue:      0.00001,
+					OValues:     []float64{0.0005, 0.120, 3, 0.01, 20, 0.00001},
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
+					SampleRate:  0.5,
 				},
 			},
 		},
 		"datadog histogram with extended aggregation values and tags": {
 			in: "foo_histogram:0.5:120:3000:10:20000:0.01|h|#tag1:bar,tag2:baz",
 			out: event.Events{
-				&event.ObserverEvent{
-					OMetricName: "foo_histogram",
-					OValue:      0.5,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_histogram",
-					OValue:      120,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_histogram",
-					OValue:      3000,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_histogram",
-					OValue:      10,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_histogram",
-					OValue:      20000,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
+				&event.MultiObserverEvent{
 					OMetricName: "foo_histogram",
-					OValue:      0.01,
+					OValues:     []float64{0.5, 120, 3000, 10, 20000, 0.01},
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
+					OKind:       event.ObserverKindHistogram,
 				},
 			},
 		},
 		"datadog distribution with extended aggregation values": {
 			in: "foo_distribution:0.5:120:3000:10:20000:0.01|d|#tag1:bar,tag2:baz",
 			out: event.Events{
-				&event.ObserverEvent{
-					OMetricName: "foo_distribution",
-					OValue:      0.5,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_distribution",
-					OValue:      120,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_distribution",
-					OValue:      3000,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_distribution",
-					OValue:      10,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
-					OMetricName: "foo_distribution",
-					OValue:      20000,
-					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
-				},
-				&event.ObserverEvent{
+				&event.MultiObserverEvent{
 					OMetricName: "foo_distribution",
-					OValue:      0.01,
+					OValues:     []float64{0.5, 120, 3000, 10, 20000, 0.01},
 					OLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
+					OKind:       event.ObserverKindDistribution,
 				},
 			},
 		},
@@ -3511,3 +3120,209 @@ func TestDisableParsingLibratoLineToEvents(t *testing.T) {
 		})
 	}
 }
+
+func TestFormatRoundTrip(t *testing.T) {
+	events := event.Events{
+		&event.CounterEvent{
+			CMetricName: "foo.counter",
+			CValue:      2.5,
+			CLabels:     map[string]string{},
+		},
+		&event.CounterEvent{
+			CMetricName: "foo.counter",
+			CValue:      1e-9,
+			CLabels:     map[string]string{"tag1": "bar", "tag2": "baz"},
+		},
+		&event.GaugeEvent{
+			GMetricName: "foo.gauge",
+			GValue:      3,
+			GLabels:     map[string]string{"tag": "value"},
+		},
+		&event.GaugeEvent{
+			GMetricName: "foo.gauge",
+			GValue:      10,
+			GRelative:   true,
+			GLabels:     map[string]string{"tag": "value"},
+		},
+		&event.GaugeEvent{
+			GMetricName: "foo.gauge",
+			GValue:      -10,
+			GRelative:   true,
+			GLabels:     map[string]string{"tag": "value"},
+		},
+		&event.ObserverEvent{
+			OMetricName: "foo.observer",
+			OValue:      0.2,
+			OLabels:     map[string]string{"tag": "value"},
+			OKind:       event.ObserverKindHistogram,
+		},
+		&event.ObserverEvent{
+			OMetricName: "foo.observer",
+			OValue:      0.2,
+			OLabels:     map[string]string{"tag": "value"},
+			OSampleRate: 0.25,
+			OKind:       event.ObserverKindHistogram,
+		},
+		&event.SetEvent{
+			SMetricName: "foo.set",
+			SMember:     "user-42",
+			SLabels:     map[string]string{"tag": "value"},
+		},
+	}
+	// Tag values with commas can only be escaped in DogStatsD and InfluxDB
+	// tags.
+	escaped := &event.CounterEvent{
+		CMetricName: "foo.counter",
+		CValue:      1,
+		CLabels:     map[string]string{"query": `SELECT a,b FROM "t"`, "path": `\dir\`, "quoted": `"x"`},
+	}
+
+	parser := NewParser()
+	parser.EnableDogstatsdParsing()
+	parser.EnableInfluxdbParsing()
+	parser.EnableLibratoParsing()
+	parser.EnableSignalFXParsing()
+
+	for _, tagFormat := range []TagFormat{DogStatsDTags, InfluxDBTags, LibratoTags, SignalFXTags} {
+		events := events
+		if tagFormat == DogStatsDTags || tagFormat == InfluxDBTags {
+			events = append(events[:len(events):len(events)], escaped)
+		} else if l, err := FormatWithTags(escaped, tagFormat); err == nil {
+			t.Fatalf("Expected tag values with commas to be rejected with tag format %d, got %q", tagFormat, l)
+		}
+		for _, e := range events {
+			l, err := FormatWithTags(e, tagFormat)
+			if err != nil {
+				t.Fatalf("Unable to format %#v: %v", e, err)
+			}
+			parsed := parser.LineToEvents(l, *nopSampleErrors, nopSamplesReceived, nopTagErrors, nopTagsReceived, nopLogger)
+			if len(parsed) != 1 || !reflect.DeepEqual(parsed[0], e) {
+				t.Fatalf("Line %q with tag format %d parsed into %#v, expected %#v", l, tagFormat, parsed, e)
+			}
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	scenarios := []struct {
+		name string
+		in   event.Event
+		out  string
+		err  bool
+	}{
+		{
+			name: "negative gauge",
+			in:   &event.GaugeEvent{GMetricName: "foo", GValue: -3},
+			out:  "foo:0|g\nfoo:-3|g",
+		}, {
+			name: "reserved character in name",
+			in:   &event.CounterEvent{CMetricName: "foo:bar", CValue: 1},
+			err:  true,
+		}, {
+			name: "reserved character in tag",
+			in:   &event.CounterEvent{CMetricName: "foo", CValue: 1, CLabels: map[string]string{"tag": "a|b"}},
+			err:  true,
+		}, {
+			name: "comma in tag name",
+			in:   &event.CounterEvent{CMetricName: "foo", CValue: 1, CLabels: map[string]string{"a,b": "c"}},
+			err:  true,
+		}, {
+			name: "escaped tag value",
+			in:   &event.CounterEvent{CMetricName: "foo", CValue: 1, CLabels: map[string]string{"query": `SELECT a,b FROM "t"`, "path": `C:\dir`}},
+			out:  `foo:1|c|#path:C:\\dir,query:SELECT a\,b FROM \"t\"`,
+		}, {
+			name: "empty tag value",
+			in:   &event.CounterEvent{CMetricName: "foo", CValue: 1, CLabels: map[string]string{"tag": ""}},
+			err:  true,
+		}, {
+			name: "set",
+			in:   &event.SetEvent{SMetricName: "foo", SMember: "abc123", SLabels: map[string]string{"tag": "value"}},
+			out:  "foo:abc123|s|#tag:value",
+		}, {
+			name: "reserved character in set member",
+			in:   &event.SetEvent{SMetricName: "foo", SMember: "a:b"},
+			err:  true,
+		}, {
+			name: "infinite value",
+			in:   &event.CounterEvent{CMetricName: "foo", CValue: math.Inf(1)},
+			err:  true,
+		},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			out, err := Format(s.in)
+			if s.err {
+				if err == nil {
+					t.Fatalf("Expected error, got %q", out)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if out != s.out {
+				t.Fatalf("Expected %q, got %q", s.out, out)
+			}
+		})
+	}
+}
+
+func TestStatsiteFrames(t *testing.T) {
+	testCases := []struct {
+		statType string
+		relative bool
+		out      event.Event
+	}{
+		{statType: "c", out: event.NewCounterEvent("foo", 2, map[string]string{})},
+		{statType: "g", out: event.NewGaugeEvent("foo", 2, false, map[string]string{})},
+		{statType: "g", relative: true, out: event.NewGaugeEvent("foo", 2, true, map[string]string{})},
+		{statType: "ms", out: event.NewObserverEvent("foo", 0.002, map[string]string{})},
+	}
+	for _, tc := range testCases {
+		frame, err := AppendStatsiteFrame(nil, tc.statType, "foo", 2, tc.relative)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.statType, err)
+		}
+		e, n, err := DecodeStatsiteFrame(append(frame, StatsiteMagic))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.statType, err)
+		}
+		if n != len(frame) {
+			t.Errorf("%s: expected frame length %d, got %d", tc.statType, len(frame), n)
+		}
+		if !reflect.DeepEqual(e, tc.out) {
+			t.Errorf("%s: expected %#v, got %#v", tc.statType, tc.out, e)
+		}
+	}
+
+	frame, _ := AppendStatsiteFrame(nil, "c", "foo", 1, false)
+	if _, _, err := DecodeStatsiteFrame(frame[:len(frame)-1]); err != ErrIncompleteFrame {
+		t.Errorf("expected incomplete frame, got %v", err)
+	}
+	if _, _, err := DecodeStatsiteFrame(frame[:4]); err != ErrIncompleteFrame {
+		t.Errorf("expected incomplete frame, got %v", err)
+	}
+	if _, _, err := DecodeStatsiteFrame(append([]byte{'f'}, frame[1:]...)); err != ErrBadMagic {
+		t.Errorf("expected bad magic byte, got %v", err)
+	}
+
+	// Malformed frames can be skipped.
+	for name, modify := range map[string]func([]byte){
+		"set":            func(b []byte) { b[1] = 0x4 },
+		"unknown type":   func(b []byte) { b[1] = 0x9 },
+		"not terminated": func(b []byte) { b[len(b)-1] = 'o' },
+		"invalid UTF-8":  func(b []byte) { b[StatsiteHeaderLength] = 0xff },
+		"empty key":      func(b []byte) { b[2], b[3] = 1, 0 },
+	} {
+		bad := append([]byte{}, frame...)
+		modify(bad)
+		_, n, err := DecodeStatsiteFrame(bad)
+		if err == nil || err == ErrIncompleteFrame {
+			t.Errorf("%s: expected error, got %v", name, err)
+		}
+		if name != "empty key" && n != len(frame) {
+			t.Errorf("%s: expected frame length %d, got %d", name, len(frame), n)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package line
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// EnableLenientNumbers option to accept a comma as the decimal separator in
+// sample values
+func (p *Parser) EnableLenientNumbers() {
+	p.LenientNumbersEnabled = true
+}
+
+// parseValue parses a sample value. Values are decimal numbers with an
+// optional sign and exponent, such as "-1.5" or "1e3", or one of "NaN", "Inf"
+// and "Infinity". Hexadecimal values and digits separated by underscores,
+// which strconv.ParseFloat accepts, are rejected.
+//
+// If lenient numbers are enabled, a single comma is accepted as the decimal
+// separator in values without a dot, so "1,5" parses as 1.5. The second
+// return value reports whether this was necessary.
+func (p *Parser) parseValue(s string) (float64, bool, error) {
+	if strings.ContainsAny(s, "_xX") {
+		return 0, false, fmt.Errorf("%w: %q is not a decimal number", ErrBadValue, s)
+	}
+
+	value, err := strconv.ParseFloat(s, 64)
+	if err == nil {
+		return value, false, nil
+	}
+	if !p.LenientNumbersEnabled || strings.Count(s, ",") != 1 || strings.Contains(s, ".") {
+		return value, false, fmt.Errorf("%w: %w", ErrBadValue, err)
+	}
+	value, err = strconv.ParseFloat(strings.Replace(s, ",", ".", 1), 64)
+	if err != nil {
+		return value, false, fmt.Errorf("%w: %w", ErrBadValue, err)
+	}
+	return value, true, nil
+}
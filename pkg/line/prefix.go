@@ -0,0 +1,76 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package line
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/statsd_exporter/pkg/mapper"
+)
+
+// PrefixLabels are default labels for metrics whose name starts with Prefix.
+type PrefixLabels struct {
+	Prefix string
+	Labels map[string]string
+}
+
+// ParsePrefixLabels parses a rule of the form
+// `<prefix>:<label>=<value>[,<label>=<value>...]`. Label names are escaped
+// like tag names.
+func ParsePrefixLabels(rule string) (PrefixLabels, error) {
+	prefix, rest, ok := strings.Cut(rule, ":")
+	if !ok || prefix == "" || rest == "" {
+		return PrefixLabels{}, fmt.Errorf("prefix labels %q are not of the form <prefix>:<label>=<value>[,...]", rule)
+	}
+	labels := map[string]string{}
+	for _, pair := range strings.Split(rest, ",") {
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok || k == "" || v == "" {
+			return PrefixLabels{}, fmt.Errorf("malformed label %q in prefix labels %q", pair, rule)
+		}
+		labels[mapper.EscapeMetricName(k)] = v
+	}
+	return PrefixLabels{Prefix: prefix, Labels: labels}, nil
+}
+
+// AddPrefixLabels adds default labels for metrics whose name starts with a
+// prefix. Labels for longer prefixes take precedence over those for shorter
+// ones, and tags sent with a line take precedence over all of them.
+func (p *Parser) AddPrefixLabels(pl PrefixLabels) {
+	p.PrefixLabels = append(p.PrefixLabels, pl)
+	// Apply the longest prefix last so that its labels win.
+	sort.SliceStable(p.PrefixLabels, func(i, j int) bool {
+		return len(p.PrefixLabels[i].Prefix) < len(p.PrefixLabels[j].Prefix)
+	})
+}
+
+// prefixLabels returns the default labels for a metric name, or nil if no
+// prefix matches.
+func (p *Parser) prefixLabels(metric string) map[string]string {
+	var labels map[string]string
+	for _, pl := range p.PrefixLabels {
+		if !strings.HasPrefix(metric, pl.Prefix) {
+			continue
+		}
+		if labels == nil {
+			labels = make(map[string]string, len(pl.Labels))
+		}
+		for k, v := range pl.Labels {
+			labels[k] = v
+		}
+	}
+	return labels
+}
@@ -0,0 +1,91 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package line
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/prometheus/statsd_exporter/pkg/event"
+)
+
+// LineParser parses the lines of a StatsD dialect into events. Parser and
+// GraphiteParser are line parsers.
+type LineParser interface {
+	LineToEvents(line string, sampleErrors prometheus.CounterVec, samplesReceived prometheus.Counter, tagErrors prometheus.Counter, tagsReceived prometheus.Counter, logger *slog.Logger) event.Events
+}
+
+// NewLineParserFunc returns a line parser for a format, configured like p.
+// Sample errors should be reported through p, so that its OnError function is
+// called.
+type NewLineParserFunc func(p *Parser) LineParser
+
+// Formats that are registered by default.
+const (
+	// FormatStatsD is StatsD with the extensions enabled on the Parser.
+	FormatStatsD = "statsd"
+	// FormatGraphite is the Graphite plaintext protocol.
+	FormatGraphite = "graphite"
+)
+
+var (
+	formatsMtx sync.RWMutex
+	formats    = map[string]NewLineParserFunc{
+		FormatStatsD:   func(p *Parser) LineParser { return p },
+		FormatGraphite: func(p *Parser) LineParser { return NewGraphiteParser(p) },
+	}
+)
+
+// RegisterFormat makes a line format available by name, so that programs
+// embedding the exporter can add their own StatsD dialects. Like
+// database/sql.Register, it panics if the name is already registered.
+func RegisterFormat(name string, newParser NewLineParserFunc) {
+	formatsMtx.Lock()
+	defer formatsMtx.Unlock()
+	if newParser == nil {
+		panic("line: RegisterFormat parser is nil")
+	}
+	if _, dup := formats[name]; dup {
+		panic("line: RegisterFormat called twice for format " + name)
+	}
+	formats[name] = newParser
+}
+
+// NewLineParser returns a line parser for the format with the given name,
+// configured like p.
+func NewLineParser(format string, p *Parser) (LineParser, error) {
+	formatsMtx.RLock()
+	newParser, ok := formats[format]
+	formatsMtx.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown line format %q", format)
+	}
+	return newParser(p), nil
+}
+
+// Formats returns the names of the registered line formats in order.
+func Formats() []string {
+	formatsMtx.RLock()
+	defer formatsMtx.RUnlock()
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
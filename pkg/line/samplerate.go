@@ -0,0 +1,41 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package line
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// How samples with an invalid sample rate are handled. Either way, the sample
+// rate is counted as ErrBadSampleRate.
+const (
+	// InvalidSampleRateClamp accepts the sample as if it was not sampled.
+	InvalidSampleRateClamp = "clamp"
+	// InvalidSampleRateReject drops the sample.
+	InvalidSampleRateReject = "reject"
+)
+
+// parseSampleRate parses the sample rate of a sample, which must be a number
+// greater than 0 and at most 1. Invalid sample rates are returned as 1.
+func parseSampleRate(s string) (float64, error) {
+	rate, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 1, fmt.Errorf("%w %q", ErrBadSampleRate, s)
+	}
+	if !(rate > 0 && rate <= 1) {
+		return 1, fmt.Errorf("%w %q: must be greater than 0 and at most 1", ErrBadSampleRate, s)
+	}
+	return rate, nil
+}
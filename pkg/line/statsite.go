@@ -0,0 +1,138 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package line
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"unicode/utf8"
+
+	"github.com/prometheus/statsd_exporter/pkg/event"
+)
+
+// The statsite binary protocol encodes each sample as a frame of
+//
+//	uint8   magic byte 0xaa
+//	uint8   metric type
+//	uint16  key length, including the terminating null byte
+//	float64 value
+//	char[]  null terminated key
+//
+// with integers and floats in little endian byte order.
+// https://github.com/statsite/statsite#binary-protocol
+const (
+	StatsiteMagic          = 0xaa
+	StatsiteHeaderLength   = 12
+	statsiteTypeKeyValue   = 0x1
+	statsiteTypeCounter    = 0x2
+	statsiteTypeTimer      = 0x3
+	statsiteTypeSet        = 0x4
+	statsiteTypeGauge      = 0x5
+	statsiteTypeGaugeDelta = 0x6
+)
+
+var (
+	// ErrIncompleteFrame is returned for a buffer that does not hold a
+	// complete statsite frame yet.
+	ErrIncompleteFrame = errors.New("incomplete statsite frame")
+	// ErrBadMagic is returned for a buffer that does not start with a
+	// statsite frame. The length of the frame cannot be determined, so the
+	// remainder of the stream cannot be decoded.
+	ErrBadMagic = errors.New("statsite frame does not start with the magic byte")
+)
+
+// StatsiteFrameLength returns the length of the statsite frame at the start
+// of b, or ErrIncompleteFrame if b is shorter than the frame header.
+func StatsiteFrameLength(b []byte) (int, error) {
+	if len(b) < StatsiteHeaderLength {
+		return 0, ErrIncompleteFrame
+	}
+	if b[0] != StatsiteMagic {
+		return 0, ErrBadMagic
+	}
+	return StatsiteHeaderLength + int(binary.LittleEndian.Uint16(b[2:4])), nil
+}
+
+// DecodeStatsiteFrame decodes the statsite frame at the start of b into an
+// event, without going through the text format. It returns the length of the
+// frame, so that a malformed frame can be skipped, unless the error is
+// ErrIncompleteFrame or ErrBadMagic.
+//
+// Key/value pairs and gauges set gauges, gauge deltas change them, and timers
+// are observed in seconds like "ms" lines. Sets are not supported.
+func DecodeStatsiteFrame(b []byte) (event.Event, int, error) {
+	n, err := StatsiteFrameLength(b)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(b) < n {
+		return nil, 0, ErrIncompleteFrame
+	}
+
+	keyLength := n - StatsiteHeaderLength
+	if keyLength < 2 || b[n-1] != 0 {
+		return nil, n, fmt.Errorf("%w: key is empty or not null terminated", ErrMalformedFrame)
+	}
+	key := b[StatsiteHeaderLength : n-1]
+	if !utf8.Valid(key) {
+		return nil, n, fmt.Errorf("%w: key is not valid UTF-8", ErrMalformedFrame)
+	}
+	value := math.Float64frombits(binary.LittleEndian.Uint64(b[4:12]))
+	labels := map[string]string{}
+
+	switch b[1] {
+	case statsiteTypeKeyValue, statsiteTypeGauge:
+		return event.NewGaugeEvent(string(key), value, false, labels), n, nil
+	case statsiteTypeGaugeDelta:
+		return event.NewGaugeEvent(string(key), value, true, labels), n, nil
+	case statsiteTypeCounter:
+		return event.NewCounterEvent(string(key), value, labels), n, nil
+	case statsiteTypeTimer:
+		// prometheus presumes seconds, statsd millisecond
+		return event.NewObserverEvent(string(key), value/1000, labels), n, nil
+	case statsiteTypeSet:
+		return nil, n, fmt.Errorf("%w: no support for StatsD sets", ErrUnsupportedType)
+	}
+	return nil, n, fmt.Errorf("%w: bad statsite metric type %#x", ErrUnsupportedType, b[1])
+}
+
+// AppendStatsiteFrame appends the statsite frame for a sample to b. The
+// metric type is one of "c", "g" or "ms", and relative is only used for
+// gauges.
+func AppendStatsiteFrame(b []byte, statType, metric string, value float64, relative bool) ([]byte, error) {
+	var t byte
+	switch statType {
+	case "c":
+		t = statsiteTypeCounter
+	case "g":
+		t = statsiteTypeGauge
+		if relative {
+			t = statsiteTypeGaugeDelta
+		}
+	case "ms":
+		t = statsiteTypeTimer
+	default:
+		return b, fmt.Errorf("%w: bad stat type %s", ErrUnsupportedType, statType)
+	}
+	if len(metric)+1 > math.MaxUint16 {
+		return b, fmt.Errorf("metric name of length %d is too long", len(metric))
+	}
+	b = append(b, StatsiteMagic, t)
+	b = binary.LittleEndian.AppendUint16(b, uint16(len(metric)+1))
+	b = binary.LittleEndian.AppendUint64(b, math.Float64bits(value))
+	b = append(b, metric...)
+	return append(b, 0), nil
+}
@@ -0,0 +1,50 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package line
+
+import "fmt"
+
+// Tag formats that can be enabled with WithTagFormats.
+const (
+	TagFormatDogStatsD = "dogstatsd"
+	TagFormatInfluxDB  = "influxdb"
+	TagFormatLibrato   = "librato"
+	TagFormatSignalFX  = "signalfx"
+)
+
+// WithTagFormats returns a copy of the parser that parses exactly the given
+// tag formats, such as for a listener whose clients use commas in metric
+// names, which would otherwise be taken for InfluxDB tags.
+func (p *Parser) WithTagFormats(formats []string) (*Parser, error) {
+	c := *p
+	c.DogstatsdTagsEnabled = false
+	c.InfluxdbTagsEnabled = false
+	c.LibratoTagsEnabled = false
+	c.SignalFXTagsEnabled = false
+	for _, format := range formats {
+		switch format {
+		case TagFormatDogStatsD:
+			c.DogstatsdTagsEnabled = true
+		case TagFormatInfluxDB:
+			c.InfluxdbTagsEnabled = true
+		case TagFormatLibrato:
+			c.LibratoTagsEnabled = true
+		case TagFormatSignalFX:
+			c.SignalFXTagsEnabled = true
+		default:
+			return nil, fmt.Errorf("unknown tag format %q", format)
+		}
+	}
+	return &c, nil
+}
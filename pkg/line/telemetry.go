@@ -0,0 +1,46 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package line
+
+import "strings"
+
+// DogStatsDClientTelemetryPrefix is the prefix of the telemetry metrics that
+// Datadog clients periodically send about themselves, such as
+// datadog.dogstatsd.client.packets_dropped. They are tagged with the client
+// library, its version and transport.
+const DogStatsDClientTelemetryPrefix = "datadog.dogstatsd.client."
+
+// ClientTelemetryNamespace is the prefix of exposed client telemetry metrics.
+const ClientTelemetryNamespace = "dogstatsd_client_"
+
+// How client telemetry is handled.
+const (
+	// ClientTelemetryMap handles client telemetry like any other metric.
+	ClientTelemetryMap = "map"
+	// ClientTelemetryExpose moves client telemetry into
+	// ClientTelemetryNamespace.
+	ClientTelemetryExpose = "expose"
+	// ClientTelemetryDrop drops client telemetry.
+	ClientTelemetryDrop = "drop"
+)
+
+// clientTelemetryName returns the name of a client telemetry metric in
+// ClientTelemetryNamespace, and whether metric is client telemetry at all.
+func clientTelemetryName(metric string) (string, bool) {
+	name, ok := strings.CutPrefix(metric, DogStatsDClientTelemetryPrefix)
+	if !ok || name == "" {
+		return metric, false
+	}
+	return ClientTelemetryNamespace + strings.ReplaceAll(name, ".", "_"), true
+}
@@ -0,0 +1,51 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package listener
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	minAcceptBackoff = 5 * time.Millisecond
+	maxAcceptBackoff = time.Second
+)
+
+// isTransientAcceptError reports whether accepting a connection failed for a
+// reason that can go away, such as running out of file descriptors or the
+// client resetting the connection before it was accepted.
+func isTransientAcceptError(err error) bool {
+	return errors.Is(err, errProcessFiles) || errors.Is(err, errSystemFiles) || errors.Is(err, errConnAborted)
+}
+
+// retryAccept waits before the next accept after err, if err is transient,
+// and reports whether it did. The wait doubles with every consecutive
+// failure, from minAcceptBackoff up to maxAcceptBackoff, and backoff is reset
+// by the caller after a successful accept.
+func retryAccept(err error, backoff *time.Duration, retries prometheus.Counter, logger *slog.Logger, proto string) bool {
+	if !isTransientAcceptError(err) {
+		return false
+	}
+	*backoff = min(max(2**backoff, minAcceptBackoff), maxAcceptBackoff)
+	logger.Warn("Accepting connection failed, retrying", "proto", proto, "error", err, "backoff", *backoff)
+	if retries != nil {
+		retries.Inc()
+	}
+	time.Sleep(*backoff)
+	return true
+}
@@ -0,0 +1,57 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package listener
+
+import (
+	"errors"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/promslog"
+)
+
+func TestRetryAccept(t *testing.T) {
+	retries := prometheus.NewCounter(prometheus.CounterOpts{})
+	emfile := &net.OpError{Op: "accept", Net: "tcp", Err: os.NewSyscallError("accept", syscall.EMFILE)}
+
+	var backoff time.Duration
+	for _, expected := range []time.Duration{5 * time.Millisecond, 10 * time.Millisecond, 20 * time.Millisecond} {
+		if !retryAccept(emfile, &backoff, retries, promslog.NewNopLogger(), "tcp") {
+			t.Fatalf("expected %v to be retried", emfile)
+		}
+		if backoff != expected {
+			t.Fatalf("expected a backoff of %v, got %v", expected, backoff)
+		}
+	}
+	if v := metricValue(t, retries); v != 3 {
+		t.Fatalf("expected 3 retries, got %v", v)
+	}
+
+	backoff = time.Minute
+	if !retryAccept(syscall.ECONNABORTED, &backoff, nil, promslog.NewNopLogger(), "tcp") || backoff != maxAcceptBackoff {
+		t.Fatalf("expected the backoff to be capped at %v, got %v", maxAcceptBackoff, backoff)
+	}
+
+	backoff = 0
+	if retryAccept(errors.New("fatal"), &backoff, retries, promslog.NewNopLogger(), "tcp") {
+		t.Fatalf("expected other errors to not be retried")
+	}
+	if backoff != 0 || metricValue(t, retries) != 3 {
+		t.Fatalf("expected a fatal error to not be counted as a retry")
+	}
+}
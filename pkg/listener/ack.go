@@ -0,0 +1,46 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package listener
+
+import "io"
+
+// AckBatchTerminator is the line that ends a batch of lines on TCP
+// connections in ack mode. The listener replies to it with AckOK once the
+// events of the batch are queued, or with a line starting with AckErr if
+// they might not have been. Clients that need at-least-once delivery resend
+// batches until they are acknowledged with AckOK.
+const AckBatchTerminator = "."
+
+const (
+	AckOK  = "OK"
+	AckErr = "ERR"
+)
+
+// closedEventHandler is implemented by event handlers that drop events once
+// they are closed, such as event.EventQueue.
+type closedEventHandler interface {
+	Closed() bool
+}
+
+// ack replies to the end of a batch. Events queued before the event handler
+// was closed are applied, so checking after queueing the batch tells whether
+// all of it was accepted.
+func (l *StatsDTCPListener) ack(w io.Writer) error {
+	reply := AckOK + "\n"
+	if h, ok := l.EventHandler.(closedEventHandler); ok && h.Closed() {
+		reply = AckErr + " shutting down\n"
+	}
+	_, err := io.WriteString(w, reply)
+	return err
+}
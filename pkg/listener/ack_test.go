@@ -0,0 +1,87 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package listener
+
+import (
+	"bufio"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/promslog"
+
+	"github.com/prometheus/statsd_exporter/pkg/event"
+	"github.com/prometheus/statsd_exporter/pkg/line"
+)
+
+func TestTCPListenerAck(t *testing.T) {
+	conn, err := net.ListenTCP("tcp4", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	defer conn.Close()
+
+	events := make(chan event.Events, 10)
+	queue := event.NewEventQueue(events, 1, time.Hour, prometheus.NewCounter(prometheus.CounterOpts{}))
+	counter := prometheus.NewCounter(prometheus.CounterOpts{})
+	l := &StatsDTCPListener{
+		Conn:            conn,
+		EventHandler:    queue,
+		Logger:          promslog.NewNopLogger(),
+		LineParser:      line.NewParser(),
+		LinesReceived:   counter,
+		SampleErrors:    *prometheus.NewCounterVec(prometheus.CounterOpts{}, []string{"reason"}),
+		SamplesReceived: counter,
+		TagErrors:       counter,
+		TagsReceived:    counter,
+		TCPConnections:  counter,
+		TCPErrors:       counter,
+		TCPLineTooLong:  counter,
+		Ack:             true,
+	}
+	go l.Listen()
+
+	c, err := net.Dial("tcp4", conn.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	r := bufio.NewReader(c)
+	send := func(batch, expected string) {
+		t.Helper()
+		if _, err := c.Write([]byte(batch)); err != nil {
+			t.Fatal(err)
+		}
+		reply, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reply != expected {
+			t.Fatalf("expected reply %q, got %q", expected, reply)
+		}
+	}
+
+	send("foo:1|c\nbar:2|g\n.\n", "OK\n")
+	for _, name := range []string{"foo", "bar"} {
+		if e := <-events; len(e) != 1 || e[0].MetricName() != name {
+			t.Fatalf("expected event for %s, got %v", name, e)
+		}
+	}
+
+	// Events can't be queued while shutting down, so the batch has to be
+	// sent again elsewhere.
+	queue.Close()
+	send("baz:1|c\n.\n", "ERR shutting down\n")
+}
@@ -0,0 +1,111 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package listener
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/statsd_exporter/pkg/address"
+)
+
+// BindPolicy controls how listen addresses are bound. Each address is tried
+// Retries+1 times, waiting RetryInterval between attempts, before moving on
+// to the next one.
+type BindPolicy struct {
+	Retries       int
+	RetryInterval time.Duration
+	Logger        *slog.Logger
+}
+
+// bindHint explains common bind failures that are otherwise hard to diagnose,
+// especially in containers.
+func bindHint(port int, err error) string {
+	switch {
+	case errors.Is(err, syscall.EACCES) && port > 0 && port < 1024:
+		return "binding a privileged port requires root or the CAP_NET_BIND_SERVICE capability"
+	case errors.Is(err, syscall.EACCES):
+		return "permission denied"
+	case errors.Is(err, errAddrInUse):
+		return "address is already in use by another process"
+	case errors.Is(err, errAddrNotAvail):
+		return "address is not available on this host"
+	}
+	return ""
+}
+
+func (p BindPolicy) bind(proto string, addrs []string, listen func(ip net.IP, port int, zone string) error) error {
+	var errs []error
+	for i, addr := range addrs {
+		ip, port, err := address.IPPortFromString(addr)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		for attempt := 0; attempt <= p.Retries; attempt++ {
+			if attempt > 0 {
+				time.Sleep(p.RetryInterval)
+			}
+
+			err = listen(ip.IP, port, ip.Zone)
+			if err == nil {
+				if i > 0 {
+					p.Logger.Warn("Bound fallback address", "proto", proto, "address", addr, "preferred", addrs[0])
+				}
+				return nil
+			}
+
+			p.Logger.Warn("Failed to bind address", "proto", proto, "address", addr, "attempt", attempt+1, "error", err, "hint", bindHint(port, err))
+			// Permission errors don't go away by retrying.
+			if errors.Is(err, syscall.EACCES) {
+				break
+			}
+		}
+
+		if hint := bindHint(port, err); hint != "" {
+			err = fmt.Errorf("%s %s: %w (%s)", proto, addr, err, hint)
+		} else {
+			err = fmt.Errorf("%s %s: %w", proto, addr, err)
+		}
+		errs = append(errs, err)
+	}
+	return fmt.Errorf("unable to bind any %s address: %w", proto, errors.Join(errs...))
+}
+
+// ListenUDP binds the first UDP address out of addrs that can be bound.
+func (p BindPolicy) ListenUDP(addrs []string) (*net.UDPConn, error) {
+	var conn *net.UDPConn
+	err := p.bind("udp", addrs, func(ip net.IP, port int, zone string) error {
+		var err error
+		conn, err = net.ListenUDP("udp", &net.UDPAddr{IP: ip, Port: port, Zone: zone})
+		return err
+	})
+	return conn, err
+}
+
+// ListenTCP binds the first TCP address out of addrs that can be bound.
+func (p BindPolicy) ListenTCP(addrs []string) (*net.TCPListener, error) {
+	var conn *net.TCPListener
+	err := p.bind("tcp", addrs, func(ip net.IP, port int, zone string) error {
+		var err error
+		conn, err = net.ListenTCP("tcp", &net.TCPAddr{IP: ip, Port: port, Zone: zone})
+		return err
+	})
+	return conn, err
+}
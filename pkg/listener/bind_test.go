@@ -0,0 +1,47 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package listener
+
+import (
+	"errors"
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/prometheus/common/promslog"
+)
+
+func TestBindPolicyFallback(t *testing.T) {
+	taken, err := net.ListenTCP("tcp4", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	defer taken.Close()
+
+	p := BindPolicy{Logger: promslog.NewNopLogger()}
+
+	_, err = p.ListenTCP([]string{taken.Addr().String()})
+	if !errors.Is(err, syscall.EADDRINUSE) {
+		t.Fatalf("expected address in use error, got %v", err)
+	}
+
+	conn, err := p.ListenTCP([]string{taken.Addr().String(), "127.0.0.1:0"})
+	if err != nil {
+		t.Fatalf("expected fallback address to be bound, got %v", err)
+	}
+	defer conn.Close()
+	if conn.Addr().String() == taken.Addr().String() {
+		t.Fatalf("expected fallback address, got %s", conn.Addr())
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package listener
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// Limits of a PacketBudget, used as the "limit" label of dropped lines.
+const (
+	BudgetLines = "lines"
+	BudgetBytes = "bytes"
+)
+
+// PacketBudget bounds the work spent on a single datagram, so that a crafted
+// packet can't monopolize parsing. Lines beyond the budget are dropped
+// without being parsed.
+type PacketBudget struct {
+	// MaxLines, if positive, is the maximum number of lines parsed.
+	MaxLines int
+	// MaxBytes, if positive, is the maximum number of bytes parsed. A line
+	// that doesn't fit in full is dropped.
+	MaxBytes int
+	// Dropped counts the dropped non-empty lines by the limit they
+	// exceeded.
+	Dropped *prometheus.CounterVec
+}
+
+// lines returns the lines of a packet that fit in the budget.
+func (b *PacketBudget) lines(lines []string) []string {
+	n, limit := len(lines), ""
+	if b.MaxLines > 0 && countLines(lines) > b.MaxLines {
+		n, limit = 0, BudgetLines
+		for seen := 0; seen < b.MaxLines; n++ {
+			if len(lines[n]) > 0 {
+				seen++
+			}
+		}
+	}
+	if b.MaxBytes > 0 {
+		size := 0
+		for i, line := range lines[:n] {
+			size += len(line)
+			if size > b.MaxBytes {
+				n, limit = i, BudgetBytes
+				break
+			}
+		}
+	}
+	if limit != "" {
+		b.Dropped.WithLabelValues(limit).Add(float64(countLines(lines[n:])))
+	}
+	return lines[:n]
+}
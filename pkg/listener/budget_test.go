@@ -0,0 +1,72 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package listener
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestPacketBudget(t *testing.T) {
+	for _, tc := range []struct {
+		budget   PacketBudget
+		packet   string
+		expected string
+		dropped  map[string]float64
+	}{
+		{
+			budget:   PacketBudget{},
+			packet:   "a:1|c\nb:1|c\n",
+			expected: "a:1|c\nb:1|c\n",
+		},
+		{
+			budget:   PacketBudget{MaxLines: 2},
+			packet:   "a:1|c\n\nb:1|c\nc:1|c\nd:1|c\n",
+			expected: "a:1|c\n\nb:1|c",
+			dropped:  map[string]float64{BudgetLines: 2},
+		},
+		{
+			budget:   PacketBudget{MaxLines: 2},
+			packet:   "a:1|c\nb:1|c\n",
+			expected: "a:1|c\nb:1|c\n",
+		},
+		{
+			budget:   PacketBudget{MaxBytes: 12},
+			packet:   "a:1|c\nb:1|c\nc:1|c\n",
+			expected: "a:1|c\nb:1|c",
+			dropped:  map[string]float64{BudgetBytes: 1},
+		},
+		{
+			budget:   PacketBudget{MaxLines: 1, MaxBytes: 3},
+			packet:   "a:1|c\nb:1|c\n",
+			expected: "",
+			dropped:  map[string]float64{BudgetBytes: 2},
+		},
+	} {
+		dropped := prometheus.NewCounterVec(prometheus.CounterOpts{}, []string{"limit"})
+		tc.budget.Dropped = dropped
+
+		lines := tc.budget.lines(strings.Split(tc.packet, "\n"))
+		if got := strings.Join(lines, "\n"); got != tc.expected {
+			t.Errorf("%+v %q: expected %q, got %q", tc.budget, tc.packet, tc.expected, got)
+		}
+		for _, limit := range []string{BudgetLines, BudgetBytes} {
+			if v := metricValue(t, dropped.WithLabelValues(limit)); v != tc.dropped[limit] {
+				t.Errorf("%+v %q: expected %v lines dropped by %s, got %v", tc.budget, tc.packet, tc.dropped[limit], limit, v)
+			}
+		}
+	}
+}
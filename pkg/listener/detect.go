@@ -0,0 +1,214 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package listener
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/statsd_exporter/pkg/line"
+)
+
+// Protocols that can be detected on a TCP connection.
+const (
+	ProtocolStatsD = "statsd"
+	ProtocolHTTP   = "http"
+	ProtocolProxy  = "proxy"
+)
+
+// proxyV2Signature starts a PROXY protocol version 2 header.
+// https://www.haproxy.org/download/2.9/doc/proxy-protocol.txt
+var proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// peekLine returns the first line buffered in r, including the line break,
+// without consuming it. It blocks until a full line has been received or the
+// connection is closed. If the line does not fit into the buffer of r, the
+// buffered part is returned.
+func peekLine(r *bufio.Reader) []byte {
+	n := 1
+	for {
+		_, err := r.Peek(n)
+		b, _ := r.Peek(r.Buffered())
+		if i := bytes.IndexByte(b, '\n'); i >= 0 {
+			return b[:i+1]
+		}
+		if err != nil {
+			return b
+		}
+		n = len(b) + 1
+	}
+}
+
+// detectProtocol determines the protocol of a connection from its first line,
+// without consuming it. Statsite frames are recognized by their first byte,
+// which cannot start a valid StatsD line. Anything that is neither a PROXY
+// protocol header nor an HTTP request line is treated as StatsD.
+func detectProtocol(r *bufio.Reader) string {
+	b, err := r.Peek(1)
+	if err != nil {
+		return ProtocolStatsD
+	}
+	if b[0] == line.StatsiteMagic {
+		return ProtocolStatsiteBinary
+	}
+	if b[0] == proxyV2Signature[0] {
+		if b, err := r.Peek(len(proxyV2Signature)); err == nil && bytes.Equal(b, proxyV2Signature) {
+			return ProtocolProxy
+		}
+	}
+
+	line := strings.TrimRight(string(peekLine(r)), "\r\n")
+	if strings.HasPrefix(line, "PROXY ") {
+		return ProtocolProxy
+	}
+	// An HTTP request line is "<method> <target> HTTP/1.x". StatsD lines
+	// cannot end like this, as they end with the metric type or a tag.
+	if fields := strings.Fields(line); len(fields) == 3 && strings.HasPrefix(fields[2], "HTTP/1.") {
+		return ProtocolHTTP
+	}
+	return ProtocolStatsD
+}
+
+// readProxyHeader consumes a PROXY protocol header of version 1 or 2 from r
+// and returns the source address it carries, or nil if the header does not
+// carry one.
+func readProxyHeader(r *bufio.Reader) (net.Addr, error) {
+	if b, err := r.Peek(len(proxyV2Signature)); err == nil && bytes.Equal(b, proxyV2Signature) {
+		return readProxyV2Header(r)
+	}
+
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("reading PROXY header: %w", err)
+	}
+	fields := strings.Fields(line)
+	if len(fields) < 2 || fields[0] != "PROXY" {
+		return nil, fmt.Errorf("malformed PROXY header %q", line)
+	}
+	switch fields[1] {
+	case "UNKNOWN":
+		return nil, nil
+	case "TCP4", "TCP6":
+		if len(fields) != 6 {
+			return nil, fmt.Errorf("malformed PROXY header %q", line)
+		}
+		ip := net.ParseIP(fields[2])
+		port, err := strconv.ParseUint(fields[4], 10, 16)
+		if ip == nil || err != nil {
+			return nil, fmt.Errorf("malformed PROXY header %q", line)
+		}
+		return &net.TCPAddr{IP: ip, Port: int(port)}, nil
+	}
+	return nil, fmt.Errorf("unsupported PROXY protocol %q", fields[1])
+}
+
+func readProxyV2Header(r *bufio.Reader) (net.Addr, error) {
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("reading PROXY header: %w", err)
+	}
+	if header[12]>>4 != 2 {
+		return nil, fmt.Errorf("unsupported PROXY protocol version %d", header[12]>>4)
+	}
+	body := make([]byte, binary.BigEndian.Uint16(header[14:16]))
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, fmt.Errorf("reading PROXY header: %w", err)
+	}
+
+	// LOCAL connections, e.g. health checks of the proxy, and unknown
+	// address families carry no source address.
+	if header[12]&0x0f == 0 {
+		return nil, nil
+	}
+	switch header[13] >> 4 {
+	case 1: // AF_INET
+		if len(body) < 12 {
+			return nil, errors.New("PROXY header too short for IPv4 addresses")
+		}
+		return &net.TCPAddr{IP: net.IP(body[0:4]), Port: int(binary.BigEndian.Uint16(body[8:10]))}, nil
+	case 2: // AF_INET6
+		if len(body) < 36 {
+			return nil, errors.New("PROXY header too short for IPv6 addresses")
+		}
+		return &net.TCPAddr{IP: net.IP(body[0:16]), Port: int(binary.BigEndian.Uint16(body[32:34]))}, nil
+	}
+	return nil, nil
+}
+
+// bufferedConn is a connection whose first bytes have already been read into
+// a buffer while detecting its protocol.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) { return c.r.Read(b) }
+
+// connListener is a net.Listener that accepts a single connection, and then
+// blocks until that connection is closed.
+type connListener struct {
+	conn     net.Conn
+	accepted bool
+	closed   chan struct{}
+	once     sync.Once
+}
+
+func (l *connListener) Accept() (net.Conn, error) {
+	if !l.accepted {
+		l.accepted = true
+		return &closeNotifyConn{Conn: l.conn, l: l}, nil
+	}
+	<-l.closed
+	return nil, net.ErrClosed
+}
+
+func (l *connListener) Close() error {
+	l.once.Do(func() { close(l.closed) })
+	return nil
+}
+
+func (l *connListener) Addr() net.Addr { return l.conn.LocalAddr() }
+
+type closeNotifyConn struct {
+	net.Conn
+	l *connListener
+}
+
+func (c *closeNotifyConn) Close() error {
+	err := c.Conn.Close()
+	c.l.Close()
+	return err
+}
+
+// serveHTTPConn serves HTTP requests on a single connection with handler,
+// and returns once the connection is closed.
+func serveHTTPConn(c net.Conn, handler http.Handler) {
+	l := &connListener{conn: c, closed: make(chan struct{})}
+	srv := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       time.Minute,
+	}
+	srv.Serve(l)
+}
@@ -0,0 +1,169 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package listener
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/promslog"
+
+	"github.com/prometheus/statsd_exporter/pkg/event"
+	"github.com/prometheus/statsd_exporter/pkg/line"
+)
+
+func TestDetectProtocol(t *testing.T) {
+	testCases := []struct {
+		in    string
+		proto string
+	}{
+		{in: "foo:1|c\n", proto: ProtocolStatsD},
+		{in: "foo:1|c", proto: ProtocolStatsD},
+		{in: "", proto: ProtocolStatsD},
+		{in: "POST.requests:1|c\n", proto: ProtocolStatsD},
+		{in: "POST / HTTP/1.1\r\nHost: localhost\r\n\r\n", proto: ProtocolHTTP},
+		{in: "PROXY TCP4 192.0.2.1 192.0.2.2 5000 9125\r\nfoo:1|c\n", proto: ProtocolProxy},
+		{in: string(proxyV2Signature) + "\x21\x11\x00\x0c", proto: ProtocolProxy},
+		{in: "\xaa\x02\x04\x00", proto: ProtocolStatsiteBinary},
+	}
+	for _, tc := range testCases {
+		if proto := detectProtocol(bufio.NewReader(strings.NewReader(tc.in))); proto != tc.proto {
+			t.Errorf("%q: expected protocol %s, got %s", tc.in, tc.proto, proto)
+		}
+	}
+}
+
+func TestReadProxyHeader(t *testing.T) {
+	testCases := []struct {
+		in   string
+		addr string
+		err  bool
+	}{
+		{in: "PROXY TCP4 192.0.2.1 192.0.2.2 5000 9125\r\n", addr: "192.0.2.1:5000"},
+		{in: "PROXY TCP6 2001:db8::1 2001:db8::2 5000 9125\r\n", addr: "[2001:db8::1]:5000"},
+		{in: "PROXY UNKNOWN\r\n"},
+		{in: "PROXY TCP4 192.0.2.1\r\n", err: true},
+		{in: "PROXY UDP4 192.0.2.1 192.0.2.2 5000 9125\r\n", err: true},
+		{
+			// PROXY command over TCP4 from 192.0.2.1:5000 to 192.0.2.2:9125.
+			in:   string(proxyV2Signature) + "\x21\x11\x00\x0c" + "\xc0\x00\x02\x01" + "\xc0\x00\x02\x02" + "\x13\x88\x23\x9d",
+			addr: "192.0.2.1:5000",
+		},
+		{in: string(proxyV2Signature) + "\x20\x00\x00\x00"},
+		{in: string(proxyV2Signature) + "\x21\x11\x00\x0c\xc0", err: true},
+	}
+	for _, tc := range testCases {
+		r := bufio.NewReader(strings.NewReader(tc.in + "foo:1|c\n"))
+		addr, err := readProxyHeader(r)
+		if tc.err {
+			if err == nil {
+				t.Errorf("%q: expected error", tc.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tc.in, err)
+			continue
+		}
+		if (addr == nil && tc.addr != "") || (addr != nil && addr.String() != tc.addr) {
+			t.Errorf("%q: expected address %q, got %v", tc.in, tc.addr, addr)
+		}
+		if rest, _ := r.ReadString('\n'); rest != "foo:1|c\n" {
+			t.Errorf("%q: expected header to be consumed, got %q", tc.in, rest)
+		}
+	}
+}
+
+func TestTCPListenerDetectProtocol(t *testing.T) {
+	conn, err := net.ListenTCP("tcp4", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	defer conn.Close()
+
+	events := make(chan event.Events, 10)
+	handler := &event.UnbufferedEventHandler{C: events}
+	parser := line.NewParser()
+	linesReceived := prometheus.NewCounter(prometheus.CounterOpts{})
+	sampleErrors := *prometheus.NewCounterVec(prometheus.CounterOpts{}, []string{"reason"})
+	counter := prometheus.NewCounter(prometheus.CounterOpts{})
+	protocols := prometheus.NewCounterVec(prometheus.CounterOpts{}, []string{"protocol"})
+	l := &StatsDTCPListener{
+		Conn:            conn,
+		EventHandler:    handler,
+		Logger:          promslog.NewNopLogger(),
+		LineParser:      parser,
+		LinesReceived:   linesReceived,
+		SampleErrors:    sampleErrors,
+		SamplesReceived: counter,
+		TagErrors:       counter,
+		TagsReceived:    counter,
+		TCPConnections:  counter,
+		TCPErrors:       counter,
+		TCPLineTooLong:  counter,
+		DetectProtocol:  true,
+		HTTPHandler: &StatsDHTTPHandler{
+			EventHandler:    handler,
+			Logger:          promslog.NewNopLogger(),
+			LineParser:      parser,
+			LinesReceived:   linesReceived,
+			SampleErrors:    sampleErrors,
+			SamplesReceived: counter,
+			TagErrors:       counter,
+			TagsReceived:    counter,
+		},
+		DetectedProtocols: protocols,
+	}
+	go l.Listen()
+
+	for _, in := range []string{"plain:1|c\n", "PROXY TCP4 192.0.2.1 192.0.2.2 5000 9125\r\nproxied:1|c\n"} {
+		c, err := net.Dial("tcp4", conn.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.Write([]byte(in)); err != nil {
+			t.Fatal(err)
+		}
+		c.Close()
+	}
+	resp, err := http.Post("http://"+conn.Addr().String()+"/", "text/plain", strings.NewReader("http:1|c\nhttp:2|c\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d", resp.StatusCode)
+	}
+
+	received := map[string]int{}
+	for i := 0; i < 4; i++ {
+		for _, e := range <-events {
+			received[e.MetricName()]++
+		}
+	}
+	for name, n := range map[string]int{"plain": 1, "proxied": 1, "http": 2} {
+		if received[name] != n {
+			t.Errorf("expected %d events for %s, got %d", n, name, received[name])
+		}
+	}
+	for proto, n := range map[string]float64{ProtocolStatsD: 2, ProtocolProxy: 1, ProtocolHTTP: 1} {
+		if v := metricValue(t, protocols.WithLabelValues(proto)); v != n {
+			t.Errorf("expected %v %s connections, got %v", n, proto, v)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package listener
+
+import "errors"
+
+// Plan 9 reports these errors as strings that differ between file servers,
+// so they are never matched. Bind failures are reported without a hint,
+// and accept errors are not retried.
+var (
+	errAddrInUse    = errors.New("address already in use")
+	errAddrNotAvail = errors.New("address not available")
+	errConnRefused  = errors.New("connection refused")
+	errConnAborted  = errors.New("connection aborted")
+	errProcessFiles = errors.New("too many open files")
+	errSystemFiles  = errors.New("too many open files in system")
+)
@@ -0,0 +1,202 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package listener
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// HealthMetrics are the metric vectors tracking the health of listeners,
+// partitioned by a "listener" label.
+type HealthMetrics struct {
+	Up         *prometheus.GaugeVec
+	LastRead   *prometheus.GaugeVec
+	ReadErrors *prometheus.CounterVec
+	Restarts   *prometheus.CounterVec
+}
+
+// Health tracks the health of a single listener and restarts it if its read
+// loop exits unexpectedly.
+type Health struct {
+	Name string
+	// RestartInterval is the delay before the first restart. It doubles
+	// with every restart up to MaxRestartInterval, and is reset once the
+	// read loop ran for MaxRestartInterval without failing.
+	RestartInterval    time.Duration
+	MaxRestartInterval time.Duration
+	Logger             *slog.Logger
+
+	up         prometheus.Gauge
+	lastRead   prometheus.Gauge
+	readErrors prometheus.Counter
+	restarts   prometheus.Counter
+
+	mtx       sync.Mutex
+	status    HealthStatus
+	lastError time.Time
+}
+
+// HealthStatus is the state of a listener as reported by the debug API.
+type HealthStatus struct {
+	Listener      string     `json:"listener"`
+	Up            bool       `json:"up"`
+	Restarts      int        `json:"restarts"`
+	LastError     string     `json:"last_error,omitempty"`
+	LastErrorTime *time.Time `json:"last_error_time,omitempty"`
+}
+
+func NewHealth(name string, metrics HealthMetrics, logger *slog.Logger) *Health {
+	return &Health{
+		Name:               name,
+		RestartInterval:    time.Second,
+		MaxRestartInterval: time.Minute,
+		Logger:             logger,
+		up:                 metrics.Up.WithLabelValues(name),
+		lastRead:           metrics.LastRead.WithLabelValues(name),
+		readErrors:         metrics.ReadErrors.WithLabelValues(name),
+		restarts:           metrics.Restarts.WithLabelValues(name),
+	}
+}
+
+// Read records a successful read.
+func (h *Health) Read() {
+	h.lastRead.SetToCurrentTime()
+}
+
+// ReadError records a failed read.
+func (h *Health) ReadError() {
+	h.readErrors.Inc()
+}
+
+// Run runs the read loop until it returns without an error, which it does
+// when the listener is shut down. If the read loop fails or panics, it is
+// restarted with an exponential backoff.
+func (h *Health) Run(loop func() error) {
+	backoff := h.RestartInterval
+	for {
+		h.setUp(true)
+		start := time.Now()
+		err := h.runOnce(loop)
+		h.setUp(false)
+		if err == nil {
+			return
+		}
+		h.setError(err)
+
+		if time.Since(start) >= h.MaxRestartInterval {
+			backoff = h.RestartInterval
+		}
+		h.Logger.Error("Listener stopped unexpectedly, restarting", "listener", h.Name, "error", err, "restart_interval", backoff)
+		time.Sleep(backoff)
+		backoff = min(2*backoff, max(h.MaxRestartInterval, h.RestartInterval))
+
+		h.mtx.Lock()
+		h.status.Restarts++
+		h.mtx.Unlock()
+		h.restarts.Inc()
+	}
+}
+
+// Status returns the current state of the listener.
+func (h *Health) Status() HealthStatus {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	status := h.status
+	status.Listener = h.Name
+	if !h.lastError.IsZero() {
+		t := h.lastError
+		status.LastErrorTime = &t
+	}
+	return status
+}
+
+func (h *Health) setUp(up bool) {
+	h.mtx.Lock()
+	h.status.Up = up
+	h.mtx.Unlock()
+	if up {
+		h.up.Set(1)
+	} else {
+		h.up.Set(0)
+	}
+}
+
+func (h *Health) setError(err error) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	h.status.LastError = err.Error()
+	h.lastError = time.Now()
+}
+
+func (h *Health) runOnce(loop func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return loop()
+}
+
+// isClosedErr reports whether err is returned from reading a connection that
+// was closed, which happens during shutdown.
+func isClosedErr(err error) bool {
+	// https://github.com/golang/go/issues/4373
+	return strings.HasSuffix(err.Error(), "use of closed network connection")
+}
+
+// HealthReport collects the health of all listeners for the debug API.
+type HealthReport struct {
+	mtx       sync.Mutex
+	listeners []*Health
+}
+
+// Add adds h to the report and returns it.
+func (r *HealthReport) Add(h *Health) *Health {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.listeners = append(r.listeners, h)
+	return h
+}
+
+// Statuses returns the status of every listener, in the order they were
+// added.
+func (r *HealthReport) Statuses() []HealthStatus {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	statuses := make([]HealthStatus, 0, len(r.listeners))
+	for _, h := range r.listeners {
+		statuses = append(statuses, h.Status())
+	}
+	return statuses
+}
+
+// ServeHTTP writes the status of every listener as JSON.
+func (r *HealthReport) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	statuses := r.Statuses()
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(statuses); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,201 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package listener
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/promslog"
+
+	"github.com/prometheus/statsd_exporter/pkg/event"
+	"github.com/prometheus/statsd_exporter/pkg/line"
+)
+
+func newTestHealthMetrics() HealthMetrics {
+	return HealthMetrics{
+		Up:         prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "up"}, []string{"listener"}),
+		LastRead:   prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "last_read"}, []string{"listener"}),
+		ReadErrors: prometheus.NewCounterVec(prometheus.CounterOpts{Name: "read_errors"}, []string{"listener"}),
+		Restarts:   prometheus.NewCounterVec(prometheus.CounterOpts{Name: "restarts"}, []string{"listener"}),
+	}
+}
+
+func metricValue(t *testing.T, m prometheus.Metric) float64 {
+	var metric dto.Metric
+	if err := m.Write(&metric); err != nil {
+		t.Fatal(err)
+	}
+	if metric.Gauge != nil {
+		return metric.Gauge.GetValue()
+	}
+	return metric.Counter.GetValue()
+}
+
+func TestHealthRestarts(t *testing.T) {
+	metrics := newTestHealthMetrics()
+	h := NewHealth("test", metrics, promslog.NewNopLogger())
+	h.RestartInterval = 0
+
+	runs := 0
+	h.Run(func() error {
+		runs++
+		if v := metricValue(t, metrics.Up.WithLabelValues("test")); v != 1 {
+			t.Fatalf("expected listener to be up while running, got %v", v)
+		}
+		switch runs {
+		case 1:
+			panic("read loop panicked")
+		case 2:
+			return errors.New("read failed")
+		}
+		return nil
+	})
+
+	if runs != 3 {
+		t.Fatalf("expected 3 runs, got %d", runs)
+	}
+	if v := metricValue(t, metrics.Restarts.WithLabelValues("test")); v != 2 {
+		t.Fatalf("expected 2 restarts, got %v", v)
+	}
+	if v := metricValue(t, metrics.Up.WithLabelValues("test")); v != 0 {
+		t.Fatalf("expected listener to be down after shutdown, got %v", v)
+	}
+	status := h.Status()
+	if status.Up || status.Restarts != 2 || status.LastError != "read failed" || status.LastErrorTime == nil {
+		t.Fatalf("unexpected status %+v", status)
+	}
+}
+
+func TestUDPListenerReopen(t *testing.T) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	addr := conn.LocalAddr().(*net.UDPAddr)
+	// A read deadline in the past fails the first read.
+	conn.SetReadDeadline(time.Now())
+
+	metrics := newTestHealthMetrics()
+	health := NewHealth("udp", metrics, promslog.NewNopLogger())
+	health.RestartInterval = 0
+	reopened := make(chan *net.UDPConn, 1)
+	events := make(chan event.Events, 1)
+	l := &StatsDUDPListener{
+		Conn:            conn,
+		EventHandler:    &event.UnbufferedEventHandler{C: events},
+		Logger:          promslog.NewNopLogger(),
+		LineParser:      line.NewParser(),
+		UDPPackets:      prometheus.NewCounter(prometheus.CounterOpts{}),
+		UDPPacketDrops:  prometheus.NewCounter(prometheus.CounterOpts{}),
+		LinesReceived:   prometheus.NewCounter(prometheus.CounterOpts{}),
+		SampleErrors:    *prometheus.NewCounterVec(prometheus.CounterOpts{}, []string{"reason"}),
+		SamplesReceived: prometheus.NewCounter(prometheus.CounterOpts{}),
+		TagErrors:       prometheus.NewCounter(prometheus.CounterOpts{}),
+		TagsReceived:    prometheus.NewCounter(prometheus.CounterOpts{}),
+		UdpPacketQueue:  make(chan []byte, 1),
+		Health:          health,
+		Reopen: func() (*net.UDPConn, error) {
+			c, err := net.ListenUDP("udp4", addr)
+			if err == nil {
+				reopened <- c
+			}
+			return c, err
+		},
+	}
+	done := make(chan struct{})
+	go func() {
+		l.Listen()
+		close(done)
+	}()
+	newConn := <-reopened
+
+	client, err := net.DialUDP("udp4", nil, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if _, err := client.Write([]byte("foo:1|c")); err != nil {
+		t.Fatal(err)
+	}
+	<-events
+
+	newConn.Close()
+	<-done
+	status := health.Status()
+	if status.Restarts != 1 || status.LastError == "" {
+		t.Fatalf("expected a restart after the read error, got %+v", status)
+	}
+	if v := metricValue(t, metrics.ReadErrors.WithLabelValues("udp")); v != 1 {
+		t.Fatalf("expected 1 read error, got %v", v)
+	}
+}
+
+func TestUDPListenerHealth(t *testing.T) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+
+	metrics := newTestHealthMetrics()
+	events := make(chan event.Events, 1)
+	l := &StatsDUDPListener{
+		Conn:            conn,
+		EventHandler:    &event.UnbufferedEventHandler{C: events},
+		Logger:          promslog.NewNopLogger(),
+		LineParser:      line.NewParser(),
+		UDPPackets:      prometheus.NewCounter(prometheus.CounterOpts{}),
+		UDPPacketDrops:  prometheus.NewCounter(prometheus.CounterOpts{}),
+		LinesReceived:   prometheus.NewCounter(prometheus.CounterOpts{}),
+		SampleErrors:    *prometheus.NewCounterVec(prometheus.CounterOpts{}, []string{"reason"}),
+		SamplesReceived: prometheus.NewCounter(prometheus.CounterOpts{}),
+		TagErrors:       prometheus.NewCounter(prometheus.CounterOpts{}),
+		TagsReceived:    prometheus.NewCounter(prometheus.CounterOpts{}),
+		UdpPacketQueue:  make(chan []byte, 1),
+		Health:          NewHealth("udp", metrics, promslog.NewNopLogger()),
+	}
+	done := make(chan struct{})
+	go func() {
+		l.Listen()
+		close(done)
+	}()
+
+	client, err := net.DialUDP("udp4", nil, conn.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if _, err := client.Write([]byte("foo:1|c")); err != nil {
+		t.Fatal(err)
+	}
+	<-events
+
+	if v := metricValue(t, metrics.LastRead.WithLabelValues("udp")); v == 0 {
+		t.Fatalf("expected last read timestamp to be set")
+	}
+
+	// Closing the connection shuts the listener down without a restart.
+	conn.Close()
+	<-done
+	if v := metricValue(t, metrics.Up.WithLabelValues("udp")); v != 0 {
+		t.Fatalf("expected listener to be down after shutdown, got %v", v)
+	}
+	if v := metricValue(t, metrics.Restarts.WithLabelValues("udp")); v != 0 {
+		t.Fatalf("expected no restarts, got %v", v)
+	}
+}
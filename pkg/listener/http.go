@@ -0,0 +1,65 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package listener
+
+import (
+	"bufio"
+	"log/slog"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/prometheus/statsd_exporter/pkg/event"
+	"github.com/prometheus/statsd_exporter/pkg/relay"
+)
+
+// StatsDHTTPHandler accepts newline separated StatsD lines in the body of
+// POST requests, for clients that can only talk HTTP.
+type StatsDHTTPHandler struct {
+	EventHandler    event.EventHandler
+	Logger          *slog.Logger
+	LineParser      Parser
+	LinesReceived   prometheus.Counter
+	Relay           *relay.Relay
+	SampleErrors    prometheus.CounterVec
+	SamplesReceived prometheus.Counter
+	TagErrors       prometheus.Counter
+	TagsReceived    prometheus.Counter
+}
+
+func (h *StatsDHTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "StatsD lines have to be sent with POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	s := bufio.NewScanner(r.Body)
+	for s.Scan() {
+		line := s.Text()
+		h.Logger.Debug("Incoming line", "proto", "http", "line", line)
+		h.LinesReceived.Inc()
+		events := h.LineParser.LineToEvents(line, h.SampleErrors, h.SamplesReceived, h.TagErrors, h.TagsReceived, h.Logger)
+		if h.Relay != nil {
+			h.Relay.RelayEvents(events)
+		}
+		h.EventHandler.Queue(events)
+	}
+	if err := s.Err(); err != nil {
+		h.Logger.Debug("Reading request body failed", "addr", r.RemoteAddr, "error", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
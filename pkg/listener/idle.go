@@ -0,0 +1,93 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package listener
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/prometheus/statsd_exporter/pkg/clock"
+)
+
+// IdleWatchdog flags a listener as idle while it receives fewer lines per
+// minute than expected. Without it, a client that stopped sending because of
+// a broken configuration looks the same as one that has nothing to report.
+type IdleWatchdog struct {
+	Name              string
+	MinLinesPerMinute float64
+	Logger            *slog.Logger
+
+	idle   prometheus.Gauge
+	lines  atomic.Int64
+	isIdle bool
+}
+
+func NewIdleWatchdog(name string, minLinesPerMinute float64, idle *prometheus.GaugeVec, logger *slog.Logger) *IdleWatchdog {
+	return &IdleWatchdog{
+		Name:              name,
+		MinLinesPerMinute: minLinesPerMinute,
+		Logger:            logger,
+		idle:              idle.WithLabelValues(name),
+	}
+}
+
+// Lines records n received lines.
+func (w *IdleWatchdog) Lines(n int) {
+	w.lines.Add(int64(n))
+}
+
+// Run compares the lines received in each minute with the expectation.
+func (w *IdleWatchdog) Run() {
+	ticker := clock.NewTicker(time.Minute)
+	for range ticker.C {
+		w.check()
+	}
+}
+
+func (w *IdleWatchdog) check() {
+	lines := w.lines.Swap(0)
+	idle := float64(lines) < w.MinLinesPerMinute
+	if idle {
+		w.idle.Set(1)
+	} else {
+		w.idle.Set(0)
+	}
+
+	if idle && !w.isIdle {
+		w.Logger.Warn("Listener receives fewer lines than expected, check the client configuration", "listener", w.Name, "lines_per_minute", lines, "min_lines_per_minute", w.MinLinesPerMinute)
+	} else if !idle && w.isIdle {
+		w.Logger.Info("Listener receives the expected lines again", "listener", w.Name, "lines_per_minute", lines)
+	}
+	w.isIdle = idle
+}
+
+// ParseIdleExpectation parses an expectation of the form
+// <listener>=<lines per minute>.
+func ParseIdleExpectation(s string) (string, float64, error) {
+	name, value, ok := strings.Cut(s, "=")
+	if !ok || name == "" {
+		return "", 0, fmt.Errorf("expectation %q is not of the form <listener>=<lines per minute>", s)
+	}
+	lines, err := strconv.ParseFloat(value, 64)
+	if err != nil || lines <= 0 {
+		return "", 0, fmt.Errorf("expectation %q has an invalid number of lines per minute", s)
+	}
+	return name, lines, nil
+}
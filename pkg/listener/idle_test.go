@@ -0,0 +1,84 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package listener
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/promslog"
+
+	"github.com/prometheus/statsd_exporter/pkg/event"
+	"github.com/prometheus/statsd_exporter/pkg/line"
+)
+
+func TestIdleWatchdog(t *testing.T) {
+	idle := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "idle"}, []string{"listener"})
+	w := NewIdleWatchdog("udp", 10, idle, promslog.NewNopLogger())
+
+	for _, tc := range []struct {
+		lines int
+		idle  float64
+	}{
+		{lines: 0, idle: 1},
+		{lines: 9, idle: 1},
+		{lines: 10, idle: 0},
+		{lines: 25, idle: 0},
+		{lines: 3, idle: 1},
+	} {
+		w.Lines(tc.lines)
+		w.check()
+		if v := metricValue(t, idle.WithLabelValues("udp")); v != tc.idle {
+			t.Fatalf("expected idle to be %v after %d lines, got %v", tc.idle, tc.lines, v)
+		}
+	}
+}
+
+func TestUDPListenerIdle(t *testing.T) {
+	idle := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "idle"}, []string{"listener"})
+	events := make(chan event.Events, 10)
+	l := &StatsDUDPListener{
+		EventHandler:    &event.UnbufferedEventHandler{C: events},
+		Logger:          promslog.NewNopLogger(),
+		LineParser:      line.NewParser(),
+		LinesReceived:   prometheus.NewCounter(prometheus.CounterOpts{}),
+		SampleErrors:    *prometheus.NewCounterVec(prometheus.CounterOpts{}, []string{"reason"}),
+		SamplesReceived: prometheus.NewCounter(prometheus.CounterOpts{}),
+		TagErrors:       prometheus.NewCounter(prometheus.CounterOpts{}),
+		TagsReceived:    prometheus.NewCounter(prometheus.CounterOpts{}),
+		Idle:            NewIdleWatchdog("udp", 2, idle, promslog.NewNopLogger()),
+	}
+
+	l.HandlePacket([]byte("foo:1|c\n\nbar:2|c\n"))
+	l.Idle.check()
+	if v := metricValue(t, idle.WithLabelValues("udp")); v != 0 {
+		t.Fatalf("expected listener with 2 lines to not be idle, got %v", v)
+	}
+	l.Idle.check()
+	if v := metricValue(t, idle.WithLabelValues("udp")); v != 1 {
+		t.Fatalf("expected listener without lines to be idle, got %v", v)
+	}
+}
+
+func TestParseIdleExpectation(t *testing.T) {
+	name, lines, err := ParseIdleExpectation("udp=100")
+	if err != nil || name != "udp" || lines != 100 {
+		t.Fatalf("unexpected result %q, %v, %v", name, lines, err)
+	}
+	for _, bad := range []string{"udp", "=100", "udp=", "udp=0", "udp=many"} {
+		if _, _, err := ParseIdleExpectation(bad); err == nil {
+			t.Errorf("expected %q to be invalid", bad)
+		}
+	}
+}
@@ -15,18 +15,31 @@ package listener
 
 import (
 	"bufio"
+	"context"
+	"fmt"
+	"hash/fnv"
 	"io"
 	"log/slog"
 	"net"
+	"net/http"
+	"net/netip"
 	"os"
+	"runtime/trace"
 	"strings"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 
 	"github.com/prometheus/statsd_exporter/pkg/event"
+	"github.com/prometheus/statsd_exporter/pkg/line"
 	"github.com/prometheus/statsd_exporter/pkg/relay"
 )
 
+// ProtocolGraphite is the Graphite plaintext protocol. Listeners read it line
+// by line like StatsD, with a line.GraphiteParser as their LineParser. Other
+// line formats registered with line.RegisterFormat are read the same way.
+const ProtocolGraphite = line.FormatGraphite
+
 type Parser interface {
 	LineToEvents(line string, sampleErrors prometheus.CounterVec, samplesReceived prometheus.Counter, tagErrors prometheus.Counter, tagsReceived prometheus.Counter, logger *slog.Logger) event.Events
 }
@@ -46,6 +59,34 @@ type StatsDUDPListener struct {
 	TagErrors       prometheus.Counter
 	TagsReceived    prometheus.Counter
 	UdpPacketQueue  chan []byte
+	// Health, if set, tracks the listener and restarts it on errors.
+	Health *Health
+	// Reopen, if set, is called to replace Conn when the listener is
+	// restarted after a read error. Conn is closed before.
+	Reopen func() (*net.UDPConn, error)
+	// Protocol is ProtocolStatsiteBinary to read statsite frames instead
+	// of StatsD lines.
+	Protocol string
+	// Sources, if set, drops packets from rejected source addresses.
+	Sources *SourceFilter
+	// Receive, if set, records the size and lines of received packets.
+	Receive *ReceiveStats
+	// MaxPacketSize is the size of the largest packet read in full. Lines
+	// beyond it are dropped. 0 means DefaultMaxPacketSize.
+	MaxPacketSize int
+	// Idle, if set, flags the listener when it receives fewer lines than
+	// expected.
+	Idle *IdleWatchdog
+	// Budget, if set, limits the lines parsed per packet.
+	Budget *PacketBudget
+	// Shards, if above 1, is the number of goroutines handling packets.
+	// Packets are assigned to them by their source address and port, so
+	// that the packets of each client are still handled in order. The
+	// capacity of UdpPacketQueue is split between them.
+	Shards int
+
+	shardQueues []chan []byte
+	failed      bool
 }
 
 func (l *StatsDUDPListener) SetEventHandler(eh event.EventHandler) {
@@ -53,52 +94,151 @@ func (l *StatsDUDPListener) SetEventHandler(eh event.EventHandler) {
 }
 
 func (l *StatsDUDPListener) Listen() {
-	buf := make([]byte, 65535)
-	go l.ProcessUdpPacketQueue()
+	if l.Shards > 1 {
+		l.shardQueues = make([]chan []byte, l.Shards)
+		for i := range l.shardQueues {
+			l.shardQueues[i] = make(chan []byte, max(cap(l.UdpPacketQueue)/l.Shards, 1))
+			go l.processQueue(l.shardQueues[i])
+		}
+	} else {
+		go l.ProcessUdpPacketQueue()
+	}
+	if l.Health != nil {
+		l.Health.Run(l.readLoop)
+		return
+	}
+	if err := l.readLoop(); err != nil {
+		l.Logger.Error("error reading from UDP connection", "err", err)
+	}
+}
+
+func (l *StatsDUDPListener) readLoop() error {
+	if l.failed && l.Reopen != nil {
+		l.Conn.Close()
+		conn, err := l.Reopen()
+		if err != nil {
+			return fmt.Errorf("reopening UDP socket: %w", err)
+		}
+		l.Logger.Info("Reopened UDP socket", "addr", conn.LocalAddr())
+		l.Conn = conn
+	}
+	l.failed = false
+
+	buf := newPacketBuffer(l.MaxPacketSize)
 	for {
-		n, _, err := l.Conn.ReadFromUDP(buf)
+		region := trace.StartRegion(context.Background(), event.TraceRegionRead)
+		n, addr, err := l.Conn.ReadFromUDPAddrPort(buf)
+		region.End()
 		if err != nil {
-			// https://github.com/golang/go/issues/4373
 			// ignore net: errClosing error as it will occur during shutdown
-			if strings.HasSuffix(err.Error(), "use of closed network connection") {
-				return
+			if isClosedErr(err) {
+				return nil
 			}
-			l.Logger.Error("error reading from UDP connection", "err", err)
-			return
+			if l.Health != nil {
+				l.Health.ReadError()
+			}
+			l.failed = true
+			return err
+		}
+		if l.Health != nil {
+			l.Health.Read()
+		}
+		if l.Sources != nil && !l.Sources.Allowed(addr.Addr()) {
+			continue
 		}
 
-		l.EnqueueUdpPacket(buf, n)
+		packet, truncated := trimPacket(buf, n, l.Protocol != ProtocolStatsiteBinary)
+		if truncated {
+			l.Logger.Debug("Truncated packet", "proto", "udp", "max_size", len(buf)-1)
+			if l.Receive != nil {
+				l.Receive.Truncated()
+			}
+			if len(packet) == 0 {
+				continue
+			}
+		}
+		l.enqueue(l.queueFor(addr), packet, len(packet))
 	}
 }
 
+// EnqueueUdpPacket queues the first n bytes of packet for handling. With
+// several shards, packets enqueued this way have no source address, and are
+// all handled by the same shard, in order.
 func (l *StatsDUDPListener) EnqueueUdpPacket(packet []byte, n int) {
+	l.enqueue(l.queueFor(netip.AddrPort{}), packet, n)
+}
+
+func (l *StatsDUDPListener) enqueue(queue chan []byte, packet []byte, n int) {
 	l.UDPPackets.Inc()
 	packetCopy := make([]byte, n)
 	copy(packetCopy, packet)
 	select {
-	case l.UdpPacketQueue <- packetCopy:
+	case queue <- packetCopy:
 		// do nothing
 	default:
 		l.UDPPacketDrops.Inc()
 	}
 }
 
+// queueFor returns the queue of the shard handling the packets from addr.
+func (l *StatsDUDPListener) queueFor(addr netip.AddrPort) chan []byte {
+	if len(l.shardQueues) == 0 {
+		return l.UdpPacketQueue
+	}
+	h := fnv.New32a()
+	ip := addr.Addr().As16()
+	h.Write(ip[:])
+	h.Write([]byte{byte(addr.Port() >> 8), byte(addr.Port())})
+	return l.shardQueues[h.Sum32()%uint32(len(l.shardQueues))]
+}
+
 func (l *StatsDUDPListener) ProcessUdpPacketQueue() {
+	l.processQueue(l.UdpPacketQueue)
+}
+
+func (l *StatsDUDPListener) processQueue(queue chan []byte) {
 	for {
-		packet := <-l.UdpPacketQueue
+		packet := <-queue
 		l.HandlePacket(packet)
 	}
 }
 
 func (l *StatsDUDPListener) HandlePacket(packet []byte) {
+	if l.Receive != nil {
+		l.Receive.Packet(len(packet))
+	}
+	if l.Protocol == ProtocolStatsiteBinary {
+		l.statsiteDecoder().handlePacket(packet)
+		return
+	}
 	lines := strings.Split(string(packet), "\n")
+	if l.Receive != nil {
+		l.Receive.Lines(countLines(lines))
+	}
+	if l.Idle != nil {
+		l.Idle.Lines(countLines(lines))
+	}
+	if l.Budget != nil {
+		lines = l.Budget.lines(lines)
+	}
 	for _, line := range lines {
 		l.Logger.Debug("Incoming line", "proto", "udp", "line", line)
 		l.LinesReceived.Inc()
-		if l.Relay != nil && len(line) > 0 {
-			l.Relay.RelayLine(line)
+		events := l.LineParser.LineToEvents(line, l.SampleErrors, l.SamplesReceived, l.TagErrors, l.TagsReceived, l.Logger)
+		if l.Relay != nil {
+			l.Relay.RelayEvents(events)
 		}
-		l.EventHandler.Queue(l.LineParser.LineToEvents(line, l.SampleErrors, l.SamplesReceived, l.TagErrors, l.TagsReceived, l.Logger))
+		l.EventHandler.Queue(events)
+	}
+}
+
+func (l *StatsDUDPListener) statsiteDecoder() statsiteDecoder {
+	return statsiteDecoder{
+		eventHandler:    l.EventHandler,
+		logger:          l.Logger,
+		relay:           l.Relay,
+		sampleErrors:    l.SampleErrors,
+		samplesReceived: l.SamplesReceived,
 	}
 }
 
@@ -117,6 +257,31 @@ type StatsDTCPListener struct {
 	TCPConnections  prometheus.Counter
 	TCPErrors       prometheus.Counter
 	TCPLineTooLong  prometheus.Counter
+	// Health, if set, tracks the listener and restarts it on errors.
+	Health *Health
+	// DetectProtocol sniffs the first line of each connection to accept
+	// PROXY protocol headers, and HTTP requests which are served by
+	// HTTPHandler. Detected protocols are counted in DetectedProtocols.
+	DetectProtocol    bool
+	HTTPHandler       http.Handler
+	DetectedProtocols *prometheus.CounterVec
+	// Protocol is ProtocolStatsiteBinary to read statsite frames instead
+	// of StatsD lines. Statsite frames are also detected by DetectProtocol.
+	Protocol string
+	// Sources, if set, closes connections from rejected source addresses.
+	// With DetectProtocol, the source address of a PROXY protocol header is
+	// checked as well.
+	Sources *SourceFilter
+	// Receive, if set, records the size of reads from connections.
+	Receive *ReceiveStats
+	// Ack acknowledges batches of lines ended by AckBatchTerminator.
+	Ack bool
+	// Idle, if set, flags the listener when it receives fewer lines than
+	// expected.
+	Idle *IdleWatchdog
+	// AcceptRetries, if set, counts accepts retried after transient
+	// errors, such as running out of file descriptors.
+	AcceptRetries prometheus.Counter
 }
 
 func (l *StatsDTCPListener) SetEventHandler(eh event.EventHandler) {
@@ -124,17 +289,34 @@ func (l *StatsDTCPListener) SetEventHandler(eh event.EventHandler) {
 }
 
 func (l *StatsDTCPListener) Listen() {
+	if l.Health != nil {
+		l.Health.Run(l.acceptLoop)
+		return
+	}
+	if err := l.acceptLoop(); err != nil {
+		l.Logger.Error("AcceptTCP failed", "error", err)
+		os.Exit(1)
+	}
+}
+
+func (l *StatsDTCPListener) acceptLoop() error {
+	var backoff time.Duration
 	for {
 		c, err := l.Conn.AcceptTCP()
 		if err != nil {
-			// https://github.com/golang/go/issues/4373
 			// ignore net: errClosing error as it will occur during shutdown
-			if strings.HasSuffix(err.Error(), "use of closed network connection") {
-				return
+			if isClosedErr(err) {
+				return nil
 			}
-			l.Logger.Error("AcceptTCP failed", "error", err)
-			os.Exit(1)
+			if l.Health != nil {
+				l.Health.ReadError()
+			}
+			if retryAccept(err, &backoff, l.AcceptRetries, l.Logger, "tcp") {
+				continue
+			}
+			return err
 		}
+		backoff = 0
 		go l.HandleConn(c)
 	}
 }
@@ -142,11 +324,46 @@ func (l *StatsDTCPListener) Listen() {
 func (l *StatsDTCPListener) HandleConn(c *net.TCPConn) {
 	defer c.Close()
 
+	if l.Sources != nil && !l.Sources.AllowedAddr(c.RemoteAddr()) {
+		l.Logger.Debug("Rejected connection", "addr", c.RemoteAddr())
+		return
+	}
 	l.TCPConnections.Inc()
 
-	r := bufio.NewReader(c)
+	var src io.Reader = c
+	if l.Receive != nil {
+		src = l.Receive.Reader(c)
+	}
+	r := bufio.NewReader(src)
+	proto := l.Protocol
+	if l.DetectProtocol {
+		var ok bool
+		if proto, ok = l.detectProtocol(c, r); !ok {
+			return
+		}
+	}
+	if proto == ProtocolStatsiteBinary {
+		d := statsiteDecoder{
+			eventHandler:    l.EventHandler,
+			logger:          l.Logger,
+			relay:           l.Relay,
+			sampleErrors:    l.SampleErrors,
+			samplesReceived: l.SamplesReceived,
+		}
+		var read func()
+		if l.Health != nil {
+			read = l.Health.Read
+		}
+		if err := d.handleStream(r, read); err != nil {
+			l.TCPErrors.Inc()
+			l.Logger.Debug("Read failed", "addr", c.RemoteAddr(), "error", err)
+		}
+		return
+	}
 	for {
+		region := trace.StartRegion(context.Background(), event.TraceRegionRead)
 		line, isPrefix, err := r.ReadLine()
+		region.End()
 		if err != nil {
 			if err != io.EOF {
 				l.TCPErrors.Inc()
@@ -158,13 +375,70 @@ func (l *StatsDTCPListener) HandleConn(c *net.TCPConn) {
 		if isPrefix {
 			l.TCPLineTooLong.Inc()
 			l.Logger.Debug("Read failed: line too long", "addr", c.RemoteAddr())
+			if l.Ack {
+				io.WriteString(c, AckErr+" line too long\n")
+			}
 			break
 		}
+		if l.Health != nil {
+			l.Health.Read()
+		}
+		if l.Ack && string(line) == AckBatchTerminator {
+			if err := l.ack(c); err != nil {
+				l.TCPErrors.Inc()
+				l.Logger.Debug("Write failed", "addr", c.RemoteAddr(), "error", err)
+				break
+			}
+			continue
+		}
 		l.LinesReceived.Inc()
-		if l.Relay != nil && len(line) > 0 {
-			l.Relay.RelayLine(string(line))
+		if l.Idle != nil {
+			l.Idle.Lines(1)
+		}
+		events := l.LineParser.LineToEvents(string(line), l.SampleErrors, l.SamplesReceived, l.TagErrors, l.TagsReceived, l.Logger)
+		if l.Relay != nil {
+			l.Relay.RelayEvents(events)
+		}
+		l.EventHandler.Queue(events)
+	}
+}
+
+// detectProtocol consumes a PROXY protocol header from the connection and
+// serves HTTP requests. It returns the protocol of the connection, and
+// reports whether the connection remains to be read from r.
+func (l *StatsDTCPListener) detectProtocol(c *net.TCPConn, r *bufio.Reader) (string, bool) {
+	proto := detectProtocol(r)
+	if proto == ProtocolProxy {
+		l.countProtocol(proto)
+		addr, err := readProxyHeader(r)
+		if err != nil {
+			l.TCPErrors.Inc()
+			l.Logger.Debug("Invalid PROXY header", "addr", c.RemoteAddr(), "error", err)
+			return "", false
 		}
-		l.EventHandler.Queue(l.LineParser.LineToEvents(string(line), l.SampleErrors, l.SamplesReceived, l.TagErrors, l.TagsReceived, l.Logger))
+		l.Logger.Debug("Connection from proxy", "addr", c.RemoteAddr(), "source", addr)
+		if l.Sources != nil && addr != nil && !l.Sources.AllowedAddr(addr) {
+			l.Logger.Debug("Rejected connection from proxy", "addr", c.RemoteAddr(), "source", addr)
+			return "", false
+		}
+		proto = detectProtocol(r)
+	}
+	l.countProtocol(proto)
+
+	if proto != ProtocolHTTP {
+		return proto, true
+	}
+	if l.HTTPHandler == nil {
+		l.Logger.Debug("HTTP request on StatsD port without an HTTP handler", "addr", c.RemoteAddr())
+		return proto, false
+	}
+	serveHTTPConn(&bufferedConn{Conn: c, r: r}, l.HTTPHandler)
+	return proto, false
+}
+
+func (l *StatsDTCPListener) countProtocol(proto string) {
+	if l.DetectedProtocols != nil {
+		l.DetectedProtocols.WithLabelValues(proto).Inc()
 	}
 }
 
@@ -181,6 +455,18 @@ type StatsDUnixgramListener struct {
 	SamplesReceived prometheus.Counter
 	TagErrors       prometheus.Counter
 	TagsReceived    prometheus.Counter
+	// Health, if set, tracks the listener and restarts it on errors.
+	Health *Health
+	// Receive, if set, records the size and lines of received packets.
+	Receive *ReceiveStats
+	// MaxPacketSize is the size of the largest packet read in full. Lines
+	// beyond it are dropped. 0 means DefaultMaxPacketSize.
+	MaxPacketSize int
+	// Idle, if set, flags the listener when it receives fewer lines than
+	// expected.
+	Idle *IdleWatchdog
+	// Budget, if set, limits the lines parsed per packet.
+	Budget *PacketBudget
 }
 
 func (l *StatsDUnixgramListener) SetEventHandler(eh event.EventHandler) {
@@ -188,31 +474,175 @@ func (l *StatsDUnixgramListener) SetEventHandler(eh event.EventHandler) {
 }
 
 func (l *StatsDUnixgramListener) Listen() {
-	buf := make([]byte, 65535)
+	if l.Health != nil {
+		l.Health.Run(l.readLoop)
+		return
+	}
+	if err := l.readLoop(); err != nil {
+		l.Logger.Error("error reading from unixgram connection", "err", err)
+		os.Exit(1)
+	}
+}
+
+func (l *StatsDUnixgramListener) readLoop() error {
+	buf := newPacketBuffer(l.MaxPacketSize)
 	for {
+		region := trace.StartRegion(context.Background(), event.TraceRegionRead)
 		n, _, err := l.Conn.ReadFromUnix(buf)
+		region.End()
 		if err != nil {
-			// https://github.com/golang/go/issues/4373
 			// ignore net: errClosing error as it will occur during shutdown
-			if strings.HasSuffix(err.Error(), "use of closed network connection") {
-				return
+			if isClosedErr(err) {
+				return nil
 			}
-			l.Logger.Error("error reading from unixgram connection", "err", err)
-			os.Exit(1)
+			if l.Health != nil {
+				l.Health.ReadError()
+			}
+			return err
+		}
+		if l.Health != nil {
+			l.Health.Read()
 		}
-		l.HandlePacket(buf[:n])
+		packet, truncated := trimPacket(buf, n, true)
+		if truncated {
+			l.Logger.Debug("Truncated packet", "proto", "unixgram", "max_size", len(buf)-1)
+			if l.Receive != nil {
+				l.Receive.Truncated()
+			}
+			if len(packet) == 0 {
+				continue
+			}
+		}
+		l.HandlePacket(packet)
 	}
 }
 
 func (l *StatsDUnixgramListener) HandlePacket(packet []byte) {
 	l.UnixgramPackets.Inc()
 	lines := strings.Split(string(packet), "\n")
+	if l.Receive != nil {
+		l.Receive.Packet(len(packet))
+		l.Receive.Lines(countLines(lines))
+	}
+	if l.Idle != nil {
+		l.Idle.Lines(countLines(lines))
+	}
+	if l.Budget != nil {
+		lines = l.Budget.lines(lines)
+	}
 	for _, line := range lines {
 		l.Logger.Debug("Incoming line", "proto", "unixgram", "line", line)
 		l.LinesReceived.Inc()
-		if l.Relay != nil && len(line) > 0 {
-			l.Relay.RelayLine(line)
+		events := l.LineParser.LineToEvents(line, l.SampleErrors, l.SamplesReceived, l.TagErrors, l.TagsReceived, l.Logger)
+		if l.Relay != nil {
+			l.Relay.RelayEvents(events)
+		}
+		l.EventHandler.Queue(events)
+	}
+}
+
+type StatsDUnixListener struct {
+	Conn            *net.UnixListener
+	EventHandler    event.EventHandler
+	Logger          *slog.Logger
+	LineParser      Parser
+	LinesReceived   prometheus.Counter
+	EventsFlushed   prometheus.Counter
+	Relay           *relay.Relay
+	SampleErrors    prometheus.CounterVec
+	SamplesReceived prometheus.Counter
+	TagErrors       prometheus.Counter
+	TagsReceived    prometheus.Counter
+	UnixConnections prometheus.Counter
+	UnixErrors      prometheus.Counter
+	UnixLineTooLong prometheus.Counter
+	// Health, if set, tracks the listener and restarts it on errors.
+	Health *Health
+	// Receive, if set, records the size of reads from connections.
+	Receive *ReceiveStats
+	// Idle, if set, flags the listener when it receives fewer lines than
+	// expected.
+	Idle *IdleWatchdog
+	// AcceptRetries, if set, counts accepts retried after transient
+	// errors, such as running out of file descriptors.
+	AcceptRetries prometheus.Counter
+}
+
+func (l *StatsDUnixListener) SetEventHandler(eh event.EventHandler) {
+	l.EventHandler = eh
+}
+
+func (l *StatsDUnixListener) Listen() {
+	if l.Health != nil {
+		l.Health.Run(l.acceptLoop)
+		return
+	}
+	if err := l.acceptLoop(); err != nil {
+		l.Logger.Error("AcceptUnix failed", "error", err)
+		os.Exit(1)
+	}
+}
+
+func (l *StatsDUnixListener) acceptLoop() error {
+	var backoff time.Duration
+	for {
+		c, err := l.Conn.AcceptUnix()
+		if err != nil {
+			// ignore net: errClosing error as it will occur during shutdown
+			if isClosedErr(err) {
+				return nil
+			}
+			if l.Health != nil {
+				l.Health.ReadError()
+			}
+			if retryAccept(err, &backoff, l.AcceptRetries, l.Logger, "unix") {
+				continue
+			}
+			return err
+		}
+		backoff = 0
+		go l.HandleConn(c)
+	}
+}
+
+func (l *StatsDUnixListener) HandleConn(c *net.UnixConn) {
+	defer c.Close()
+
+	l.UnixConnections.Inc()
+
+	var src io.Reader = c
+	if l.Receive != nil {
+		src = l.Receive.Reader(c)
+	}
+	r := bufio.NewReader(src)
+	for {
+		region := trace.StartRegion(context.Background(), event.TraceRegionRead)
+		line, isPrefix, err := r.ReadLine()
+		region.End()
+		if err != nil {
+			if err != io.EOF {
+				l.UnixErrors.Inc()
+				l.Logger.Debug("Read failed", "addr", l.Conn.Addr(), "error", err)
+			}
+			break
+		}
+		l.Logger.Debug("Incoming line", "proto", "unix", "line", string(line))
+		if isPrefix {
+			l.UnixLineTooLong.Inc()
+			l.Logger.Debug("Read failed: line too long", "addr", l.Conn.Addr())
+			break
+		}
+		if l.Health != nil {
+			l.Health.Read()
+		}
+		l.LinesReceived.Inc()
+		if l.Idle != nil {
+			l.Idle.Lines(1)
+		}
+		events := l.LineParser.LineToEvents(string(line), l.SampleErrors, l.SamplesReceived, l.TagErrors, l.TagsReceived, l.Logger)
+		if l.Relay != nil {
+			l.Relay.RelayEvents(events)
 		}
-		l.EventHandler.Queue(l.LineParser.LineToEvents(line, l.SampleErrors, l.SamplesReceived, l.TagErrors, l.TagsReceived, l.Logger))
+		l.EventHandler.Queue(events)
 	}
 }
@@ -0,0 +1,84 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package listener
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/promslog"
+
+	"github.com/prometheus/statsd_exporter/pkg/event"
+)
+
+// PacketHandler is implemented by the listeners that handle packets, such as
+// StatsDUDPListener and StatsDUnixgramListener. Packets can be passed to
+// HandlePacket directly, without a socket.
+type PacketHandler interface {
+	HandlePacket(packet []byte)
+	SetEventHandler(eh event.EventHandler)
+}
+
+// MockListener is an in-memory PacketHandler for testing code that consumes
+// the events of a listener without opening a socket. Packets passed to
+// HandlePacket are parsed like by a StatsDUDPListener, and their events are
+// kept until they are taken with Events, unless another event handler is
+// set.
+type MockListener struct {
+	StatsDUDPListener
+	recorder eventRecorder
+}
+
+// NewMockListener returns a mock listener that parses lines with parser.
+func NewMockListener(parser Parser) *MockListener {
+	m := &MockListener{}
+	m.StatsDUDPListener = StatsDUDPListener{
+		EventHandler:    &m.recorder,
+		Logger:          promslog.NewNopLogger(),
+		LineParser:      parser,
+		UDPPackets:      prometheus.NewCounter(prometheus.CounterOpts{Name: "udp_packets"}),
+		UDPPacketDrops:  prometheus.NewCounter(prometheus.CounterOpts{Name: "udp_packet_drops"}),
+		LinesReceived:   prometheus.NewCounter(prometheus.CounterOpts{Name: "lines"}),
+		EventsFlushed:   prometheus.NewCounter(prometheus.CounterOpts{Name: "events_flushed"}),
+		SampleErrors:    *prometheus.NewCounterVec(prometheus.CounterOpts{Name: "sample_errors"}, []string{"reason"}),
+		SamplesReceived: prometheus.NewCounter(prometheus.CounterOpts{Name: "samples"}),
+		TagErrors:       prometheus.NewCounter(prometheus.CounterOpts{Name: "tag_errors"}),
+		TagsReceived:    prometheus.NewCounter(prometheus.CounterOpts{Name: "tags"}),
+	}
+	return m
+}
+
+// Events returns the events of the packets handled since the last call.
+func (m *MockListener) Events() event.Events {
+	return m.recorder.take()
+}
+
+type eventRecorder struct {
+	mtx    sync.Mutex
+	events event.Events
+}
+
+func (r *eventRecorder) Queue(events event.Events) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.events = append(r.events, events...)
+}
+
+func (r *eventRecorder) take() event.Events {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	events := r.events
+	r.events = nil
+	return events
+}
@@ -0,0 +1,34 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package listener
+
+import (
+	"testing"
+
+	"github.com/prometheus/statsd_exporter/pkg/line"
+)
+
+func TestMockListener(t *testing.T) {
+	var l PacketHandler = NewMockListener(line.NewParser())
+	m := l.(*MockListener)
+
+	l.HandlePacket([]byte("foo:1|c\nbar:2|g"))
+	events := m.Events()
+	if len(events) != 2 || events[0].MetricName() != "foo" || events[1].MetricName() != "bar" {
+		t.Fatalf("expected events for foo and bar, got %v", events)
+	}
+	if events := m.Events(); len(events) != 0 {
+		t.Fatalf("expected events to be taken, got %v", events)
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package listener
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/prometheus/statsd_exporter/pkg/address"
+)
+
+// ListenMulticastUDP joins the multicast group at groupAddr (e.g.
+// "239.1.2.3:9125") on the named network interface, or on the system default
+// interface if ifaceName is empty. The socket is bound with SO_REUSEADDR, so
+// other processes on the host can join the same group and port.
+func ListenMulticastUDP(groupAddr, ifaceName string) (*net.UDPConn, error) {
+	ip, port, err := address.IPPortFromString(groupAddr)
+	if err != nil {
+		return nil, err
+	}
+	if !ip.IP.IsMulticast() {
+		return nil, fmt.Errorf("%s is not a multicast group address", ip.IP)
+	}
+
+	var iface *net.Interface
+	if ifaceName != "" {
+		iface, err = net.InterfaceByName(ifaceName)
+		if err != nil {
+			return nil, fmt.Errorf("multicast interface %s: %w", ifaceName, err)
+		}
+		if iface.Flags&net.FlagMulticast == 0 {
+			return nil, fmt.Errorf("interface %s does not support multicast", ifaceName)
+		}
+	}
+
+	network := "udp6"
+	if ip.IP.To4() != nil {
+		network = "udp4"
+	}
+	conn, err := net.ListenMulticastUDP(network, iface, &net.UDPAddr{IP: ip.IP, Port: port, Zone: ip.Zone})
+	if err != nil {
+		return nil, fmt.Errorf("joining multicast group %s: %w", groupAddr, err)
+	}
+	return conn, nil
+}
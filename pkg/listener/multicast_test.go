@@ -0,0 +1,62 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package listener
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestListenMulticastUDPErrors(t *testing.T) {
+	if _, err := ListenMulticastUDP("127.0.0.1:0", ""); err == nil {
+		t.Fatalf("expected error for unicast address")
+	}
+	if _, err := ListenMulticastUDP("239.255.0.1:0", "does-not-exist0"); err == nil {
+		t.Fatalf("expected error for unknown interface")
+	}
+}
+
+func TestListenMulticastUDPLoopback(t *testing.T) {
+	conn, err := ListenMulticastUDP("239.255.0.1:0", "")
+	if err != nil {
+		t.Skipf("multicast is not available: %v", err)
+	}
+	defer conn.Close()
+
+	// Multicast packets sent from the same host are looped back to members
+	// of the group by default.
+	group := &net.UDPAddr{IP: net.IPv4(239, 255, 0, 1), Port: conn.LocalAddr().(*net.UDPAddr).Port}
+	sender, err := net.DialUDP("udp4", nil, group)
+	if err != nil {
+		t.Skipf("multicast is not available: %v", err)
+	}
+	defer sender.Close()
+
+	if _, err := sender.Write([]byte("foo:1|c")); err != nil {
+		t.Skipf("multicast is not available: %v", err)
+	}
+
+	if err := conn.SetReadDeadline(time.Now().Add(2 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 64)
+	n, _, err := conn.ReadFromUDP(buf)
+	if err != nil {
+		t.Skipf("multicast loopback is not available: %v", err)
+	}
+	if string(buf[:n]) != "foo:1|c" {
+		t.Fatalf("expected foo:1|c, got %q", buf[:n])
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package listener
+
+import "bytes"
+
+// DefaultMaxPacketSize is the largest UDP payload.
+const DefaultMaxPacketSize = 65535
+
+// newPacketBuffer returns a buffer to read datagrams of up to maxSize bytes
+// into. It has room for one more byte, so that larger datagrams, which the
+// kernel silently truncates to the size of the buffer, can be told apart.
+func newPacketBuffer(maxSize int) []byte {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxPacketSize
+	}
+	return make([]byte, maxSize+1)
+}
+
+// trimPacket returns the n bytes read into buf, and whether the datagram was
+// larger than the buffer allows. The line following the last newline of a
+// truncated datagram is incomplete, so it is dropped if lines is set.
+func trimPacket(buf []byte, n int, lines bool) ([]byte, bool) {
+	maxSize := len(buf) - 1
+	if n <= maxSize {
+		return buf[:n], false
+	}
+	packet := buf[:maxSize]
+	if lines {
+		packet = packet[:bytes.LastIndexByte(packet, '\n')+1]
+	}
+	return packet, true
+}
@@ -0,0 +1,94 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package listener
+
+import (
+	"io"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ReceiveMetrics are the metric vectors describing what listeners receive,
+// partitioned by a "listener" label.
+type ReceiveMetrics struct {
+	Bytes          *prometheus.CounterVec
+	Packets        *prometheus.CounterVec
+	LinesPerPacket *prometheus.HistogramVec
+	Truncated      *prometheus.CounterVec
+}
+
+// ReceiveStats records the packets, or reads for stream listeners, of a
+// single listener. Comparing them tells few large batched packets apart from
+// many small ones.
+type ReceiveStats struct {
+	bytes          prometheus.Counter
+	packets        prometheus.Counter
+	linesPerPacket prometheus.Observer
+	truncated      prometheus.Counter
+}
+
+func NewReceiveStats(name string, metrics ReceiveMetrics) *ReceiveStats {
+	return &ReceiveStats{
+		bytes:          metrics.Bytes.WithLabelValues(name),
+		packets:        metrics.Packets.WithLabelValues(name),
+		linesPerPacket: metrics.LinesPerPacket.WithLabelValues(name),
+		truncated:      metrics.Truncated.WithLabelValues(name),
+	}
+}
+
+// Packet records a received packet of size bytes.
+func (s *ReceiveStats) Packet(size int) {
+	s.packets.Inc()
+	s.bytes.Add(float64(size))
+}
+
+// Lines records the number of non-empty lines in a packet.
+func (s *ReceiveStats) Lines(lines int) {
+	s.linesPerPacket.Observe(float64(lines))
+}
+
+// Truncated records a datagram that was larger than the maximum packet size
+// of the listener.
+func (s *ReceiveStats) Truncated() {
+	s.truncated.Inc()
+}
+
+// Reader returns a reader that records each read from r as a packet, since
+// stream listeners don't see the packets themselves.
+func (s *ReceiveStats) Reader(r io.Reader) io.Reader {
+	return &countingReader{r: r, stats: s}
+}
+
+type countingReader struct {
+	r     io.Reader
+	stats *ReceiveStats
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	if n > 0 {
+		c.stats.Packet(n)
+	}
+	return n, err
+}
+
+func countLines(lines []string) int {
+	n := 0
+	for _, line := range lines {
+		if len(line) > 0 {
+			n++
+		}
+	}
+	return n
+}
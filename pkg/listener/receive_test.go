@@ -0,0 +1,170 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package listener
+
+import (
+	"io"
+	"net"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/promslog"
+
+	"github.com/prometheus/statsd_exporter/pkg/event"
+	"github.com/prometheus/statsd_exporter/pkg/line"
+)
+
+func newTestReceiveMetrics() ReceiveMetrics {
+	return ReceiveMetrics{
+		Bytes:          prometheus.NewCounterVec(prometheus.CounterOpts{Name: "bytes"}, []string{"listener"}),
+		Packets:        prometheus.NewCounterVec(prometheus.CounterOpts{Name: "packets"}, []string{"listener"}),
+		LinesPerPacket: prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "lines"}, []string{"listener"}),
+		Truncated:      prometheus.NewCounterVec(prometheus.CounterOpts{Name: "truncated"}, []string{"listener"}),
+	}
+}
+
+func TestReceiveStatsPackets(t *testing.T) {
+	metrics := newTestReceiveMetrics()
+	events := make(chan event.Events, 10)
+	l := &StatsDUDPListener{
+		EventHandler:    &event.UnbufferedEventHandler{C: events},
+		Logger:          promslog.NewNopLogger(),
+		LineParser:      line.NewParser(),
+		LinesReceived:   prometheus.NewCounter(prometheus.CounterOpts{}),
+		SampleErrors:    *prometheus.NewCounterVec(prometheus.CounterOpts{}, []string{"reason"}),
+		SamplesReceived: prometheus.NewCounter(prometheus.CounterOpts{}),
+		TagErrors:       prometheus.NewCounter(prometheus.CounterOpts{}),
+		TagsReceived:    prometheus.NewCounter(prometheus.CounterOpts{}),
+		Receive:         NewReceiveStats("udp", metrics),
+	}
+
+	l.HandlePacket([]byte("foo:1|c\nbar:2|g\n"))
+	l.HandlePacket([]byte("baz:3|ms"))
+
+	if v := metricValue(t, metrics.Packets.WithLabelValues("udp")); v != 2 {
+		t.Errorf("expected 2 packets, got %v", v)
+	}
+	if v := metricValue(t, metrics.Bytes.WithLabelValues("udp")); v != 24 {
+		t.Errorf("expected 24 bytes, got %v", v)
+	}
+	var m dto.Metric
+	if err := metrics.LinesPerPacket.WithLabelValues("udp").(prometheus.Metric).Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	if m.GetHistogram().GetSampleCount() != 2 || m.GetHistogram().GetSampleSum() != 3 {
+		t.Errorf("expected 2 packets with 3 lines in total, got %v", m.GetHistogram())
+	}
+}
+
+func TestReceiveStatsReader(t *testing.T) {
+	metrics := newTestReceiveMetrics()
+	s := NewReceiveStats("tcp", metrics)
+
+	b, err := io.ReadAll(s.Reader(strings.NewReader("foo:1|c\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := metricValue(t, metrics.Bytes.WithLabelValues("tcp")); v != float64(len(b)) {
+		t.Errorf("expected %d bytes, got %v", len(b), v)
+	}
+	if v := metricValue(t, metrics.Packets.WithLabelValues("tcp")); v != 1 {
+		t.Errorf("expected 1 read, got %v", v)
+	}
+}
+
+func TestTrimPacket(t *testing.T) {
+	buf := newPacketBuffer(10)
+	for _, tc := range []struct {
+		in        string
+		lines     bool
+		out       string
+		truncated bool
+	}{
+		{in: "foo:1|c\n", lines: true, out: "foo:1|c\n"},
+		{in: "foo:1|c\nba", lines: true, out: "foo:1|c\nba"},
+		{in: "foo:1|c\nbar:2|g", lines: true, out: "foo:1|c\n", truncated: true},
+		{in: "foo:1|c\nbar:2|g", lines: false, out: "foo:1|c\nba", truncated: true},
+		{in: "foobarbaz:1|c", lines: true, out: "", truncated: true},
+	} {
+		// The kernel fills the buffer with as much of the datagram as fits.
+		n := copy(buf, tc.in)
+		packet, truncated := trimPacket(buf, n, tc.lines)
+		if string(packet) != tc.out || truncated != tc.truncated {
+			t.Errorf("%q: expected %q (truncated %v), got %q (truncated %v)", tc.in, tc.out, tc.truncated, packet, truncated)
+		}
+	}
+}
+
+func TestUnixgramMaxPacketSize(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unixgram sockets are not supported on windows")
+	}
+	path := filepath.Join(t.TempDir(), "statsd.sock")
+	conn, err := ListenUnixgram(path, UnixSocketOptions{})
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+
+	metrics := newTestReceiveMetrics()
+	events := make(chan event.Events, 10)
+	l := &StatsDUnixgramListener{
+		Conn:            conn,
+		EventHandler:    &event.UnbufferedEventHandler{C: events},
+		Logger:          promslog.NewNopLogger(),
+		LineParser:      line.NewParser(),
+		UnixgramPackets: prometheus.NewCounter(prometheus.CounterOpts{}),
+		LinesReceived:   prometheus.NewCounter(prometheus.CounterOpts{}),
+		SampleErrors:    *prometheus.NewCounterVec(prometheus.CounterOpts{}, []string{"reason"}),
+		SamplesReceived: prometheus.NewCounter(prometheus.CounterOpts{}),
+		TagErrors:       prometheus.NewCounter(prometheus.CounterOpts{}),
+		TagsReceived:    prometheus.NewCounter(prometheus.CounterOpts{}),
+		Receive:         NewReceiveStats("unixgram", metrics),
+		MaxPacketSize:   10,
+	}
+	done := make(chan struct{})
+	go func() {
+		l.Listen()
+		close(done)
+	}()
+
+	client, err := net.Dial("unixgram", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	for _, packet := range []string{"foo:1|c\nbar:2|g\n", "baz:3|c"} {
+		if _, err := client.Write([]byte(packet)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"foo", "baz"} {
+		e := <-events
+		for len(e) == 0 {
+			e = <-events
+		}
+		if len(e) != 1 || e[0].MetricName() != name {
+			t.Fatalf("expected event for %s, got %v", name, e)
+		}
+	}
+	conn.Close()
+	<-done
+
+	if v := metricValue(t, metrics.Truncated.WithLabelValues("unixgram")); v != 1 {
+		t.Errorf("expected 1 truncated packet, got %v", v)
+	}
+}
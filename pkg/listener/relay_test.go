@@ -0,0 +1,80 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package listener
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/promslog"
+
+	"github.com/prometheus/statsd_exporter/pkg/event"
+	"github.com/prometheus/statsd_exporter/pkg/line"
+	"github.com/prometheus/statsd_exporter/pkg/relay"
+)
+
+// TestRelayFormatsLines validates that lines are relayed as StatsD lines with
+// DogStatsD tags, whatever protocol and tagging format they were received in.
+func TestRelayFormatsLines(t *testing.T) {
+	target, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer target.Close()
+
+	parser := line.NewParser()
+	parser.EnableDogstatsdParsing()
+	parser.EnableInfluxdbParsing()
+	for _, tc := range []struct {
+		name     string
+		parser   line.LineParser
+		packet   string
+		expected string
+	}{
+		{name: "influxdb", parser: parser, packet: "requests,env=prod:1|c", expected: "requests:1|c|#env:prod\n"},
+		{name: "graphite", parser: line.NewGraphiteParser(parser), packet: "requests;env=prod 2 1700000000", expected: "requests:2|g|#env:prod\n"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := relay.NewRelay(promslog.NewNopLogger(), target.LocalAddr().String(), 1400)
+			if err != nil {
+				t.Fatal(err)
+			}
+			l := &StatsDUDPListener{
+				EventHandler:    &event.UnbufferedEventHandler{C: make(chan event.Events, 1)},
+				Logger:          promslog.NewNopLogger(),
+				LineParser:      tc.parser,
+				LinesReceived:   prometheus.NewCounter(prometheus.CounterOpts{}),
+				SampleErrors:    *prometheus.NewCounterVec(prometheus.CounterOpts{}, []string{"reason"}),
+				SamplesReceived: prometheus.NewCounter(prometheus.CounterOpts{}),
+				TagErrors:       prometheus.NewCounter(prometheus.CounterOpts{}),
+				TagsReceived:    prometheus.NewCounter(prometheus.CounterOpts{}),
+				Relay:           r,
+			}
+			l.HandlePacket([]byte(tc.packet))
+
+			// The relay sends its buffer every second.
+			target.SetReadDeadline(time.Now().Add(5 * time.Second))
+			buf := make([]byte, 1400)
+			n, err := target.Read(buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(buf[:n]); got != tc.expected {
+				t.Fatalf("expected %q to be relayed, got %q", tc.expected, got)
+			}
+		})
+	}
+}
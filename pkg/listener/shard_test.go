@@ -0,0 +1,114 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package listener
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/promslog"
+
+	"github.com/prometheus/statsd_exporter/pkg/event"
+	"github.com/prometheus/statsd_exporter/pkg/line"
+)
+
+func TestUDPShards(t *testing.T) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	const clients, packets = 3, 50
+	events := make(chan event.Events, clients*packets)
+	l := &StatsDUDPListener{
+		Conn:            conn,
+		EventHandler:    &event.UnbufferedEventHandler{C: events},
+		Logger:          promslog.NewNopLogger(),
+		LineParser:      line.NewParser(),
+		UDPPackets:      prometheus.NewCounter(prometheus.CounterOpts{}),
+		UDPPacketDrops:  prometheus.NewCounter(prometheus.CounterOpts{}),
+		LinesReceived:   prometheus.NewCounter(prometheus.CounterOpts{}),
+		SampleErrors:    *prometheus.NewCounterVec(prometheus.CounterOpts{}, []string{"reason"}),
+		SamplesReceived: prometheus.NewCounter(prometheus.CounterOpts{}),
+		TagErrors:       prometheus.NewCounter(prometheus.CounterOpts{}),
+		TagsReceived:    prometheus.NewCounter(prometheus.CounterOpts{}),
+		UdpPacketQueue:  make(chan []byte, clients*packets*4),
+		Shards:          4,
+	}
+	go l.Listen()
+
+	for c := 0; c < clients; c++ {
+		client, err := net.DialUDP("udp4", nil, conn.LocalAddr().(*net.UDPAddr))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer client.Close()
+		for i := 0; i < packets; i++ {
+			if _, err := fmt.Fprintf(client, "client%d:%d|g", c, i); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// The packets of each client are handled in the order they were sent.
+	next := map[string]float64{}
+	timeout := time.After(5 * time.Second)
+	for n := 0; n < clients*packets; n++ {
+		select {
+		case e := <-events:
+			name := e[0].MetricName()
+			if v := e[0].Value(); v != next[name] {
+				t.Fatalf("expected %s to be %v, got %v", name, next[name], v)
+			}
+			next[name]++
+		case <-timeout:
+			t.Fatalf("expected %d events, got %d", clients*packets, n)
+		}
+	}
+}
+
+func TestUDPShardsQueueFor(t *testing.T) {
+	l := &StatsDUDPListener{Shards: 8, UdpPacketQueue: make(chan []byte, 8)}
+	l.shardQueues = make([]chan []byte, l.Shards)
+	for i := range l.shardQueues {
+		l.shardQueues[i] = make(chan []byte, 1)
+	}
+
+	queues := map[chan []byte]bool{}
+	for port := uint16(10000); port < 10100; port++ {
+		addr := netip.AddrPortFrom(netip.MustParseAddr("192.0.2.1"), port)
+		q := l.queueFor(addr)
+		if l.queueFor(addr) != q {
+			t.Fatalf("expected %v to always be assigned to the same shard", addr)
+		}
+		queues[q] = true
+	}
+	if len(queues) != l.Shards {
+		t.Fatalf("expected clients to be spread over all %d shards, got %d", l.Shards, len(queues))
+	}
+
+	// Packets without a source address go to a shard, not to the unused
+	// UdpPacketQueue.
+	l.UDPPackets = prometheus.NewCounter(prometheus.CounterOpts{})
+	l.UDPPacketDrops = prometheus.NewCounter(prometheus.CounterOpts{})
+	l.EnqueueUdpPacket([]byte("foo:1|c"), 7)
+	if len(l.UdpPacketQueue) != 0 || len(l.queueFor(netip.AddrPort{})) != 1 {
+		t.Fatal("expected the packet to be queued for a shard")
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package listener
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// RuleNotAllowed is the rule that rejects sources not matching any prefix of
+// a non-empty allowlist.
+const RuleNotAllowed = "not_allowed"
+
+// SourceFilter rejects packets and connections by source address before they
+// are parsed. Sources matching a Deny prefix are rejected. If Allow is not
+// empty, sources matching none of its prefixes are rejected as well.
+type SourceFilter struct {
+	Allow []netip.Prefix
+	Deny  []netip.Prefix
+	// Rejected, if set, counts rejected sources by the "rule" that rejected
+	// them: the denied prefix, or RuleNotAllowed.
+	Rejected *prometheus.CounterVec
+}
+
+// ParseSourceFilter returns a filter for the given allowed and denied
+// prefixes. Prefixes are given in CIDR notation, or as single addresses.
+// Comma-separated lists are accepted as well. It returns nil if both lists
+// are empty.
+func ParseSourceFilter(allow, deny []string) (*SourceFilter, error) {
+	var f SourceFilter
+	var err error
+	if f.Allow, err = parsePrefixes(allow); err != nil {
+		return nil, err
+	}
+	if f.Deny, err = parsePrefixes(deny); err != nil {
+		return nil, err
+	}
+	if len(f.Allow) == 0 && len(f.Deny) == 0 {
+		return nil, nil
+	}
+	return &f, nil
+}
+
+func parsePrefixes(values []string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, value := range values {
+		for _, s := range strings.Split(value, ",") {
+			s = strings.TrimSpace(s)
+			if s == "" {
+				continue
+			}
+			if !strings.Contains(s, "/") {
+				addr, err := netip.ParseAddr(s)
+				if err != nil {
+					return nil, fmt.Errorf("invalid source address %q: %w", s, err)
+				}
+				prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+				continue
+			}
+			p, err := netip.ParsePrefix(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid source prefix %q: %w", s, err)
+			}
+			prefixes = append(prefixes, p.Masked())
+		}
+	}
+	return prefixes, nil
+}
+
+// Allowed reports whether packets and connections from addr are accepted,
+// and counts them if they are not.
+func (f *SourceFilter) Allowed(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, p := range f.Deny {
+		if p.Contains(addr) {
+			f.reject(p.String())
+			return false
+		}
+	}
+	if len(f.Allow) == 0 {
+		return true
+	}
+	for _, p := range f.Allow {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	f.reject(RuleNotAllowed)
+	return false
+}
+
+// AllowedAddr is like Allowed for a net.Addr. Addresses without an IP, such
+// as those of Unix sockets, are always accepted.
+func (f *SourceFilter) AllowedAddr(addr net.Addr) bool {
+	var ip net.IP
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		ip = a.IP
+	case *net.UDPAddr:
+		ip = a.IP
+	}
+	a, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return true
+	}
+	return f.Allowed(a)
+}
+
+func (f *SourceFilter) reject(rule string) {
+	if f.Rejected != nil {
+		f.Rejected.WithLabelValues(rule).Inc()
+	}
+}
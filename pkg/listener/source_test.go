@@ -0,0 +1,120 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package listener
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/promslog"
+
+	"github.com/prometheus/statsd_exporter/pkg/event"
+	"github.com/prometheus/statsd_exporter/pkg/line"
+)
+
+func TestSourceFilter(t *testing.T) {
+	if _, err := ParseSourceFilter([]string{"10.0.0.0/33"}, nil); err == nil {
+		t.Fatalf("expected invalid prefix to be an error")
+	}
+	if f, err := ParseSourceFilter(nil, []string{""}); err != nil || f != nil {
+		t.Fatalf("expected empty lists to disable filtering, got %v, %v", f, err)
+	}
+
+	f, err := ParseSourceFilter([]string{"10.0.0.0/8, 192.168.1.1", "2001:db8::/32"}, []string{"10.1.2.0/24"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Rejected = prometheus.NewCounterVec(prometheus.CounterOpts{}, []string{"rule"})
+
+	for addr, allowed := range map[string]bool{
+		"10.0.0.1":         true,
+		"::ffff:10.0.0.1":  true,
+		"192.168.1.1":      true,
+		"192.168.1.2":      false,
+		"2001:db8::1":      true,
+		"10.1.2.3":         false,
+		"::ffff:10.1.2.3":  false,
+		"172.16.0.1":       false,
+		"2001:db9::1":      false,
+		"::ffff:127.0.0.1": false,
+	} {
+		if got := f.Allowed(netip.MustParseAddr(addr)); got != allowed {
+			t.Errorf("expected %s to be allowed: %v, got %v", addr, allowed, got)
+		}
+	}
+	for rule, expected := range map[string]float64{"10.1.2.0/24": 2, RuleNotAllowed: 4} {
+		if v := metricValue(t, f.Rejected.WithLabelValues(rule)); v != expected {
+			t.Errorf("expected %v rejections by %s, got %v", expected, rule, v)
+		}
+	}
+}
+
+func TestUDPListenerSourceFilter(t *testing.T) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	defer conn.Close()
+
+	sources, err := ParseSourceFilter(nil, []string{"127.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sources.Rejected = prometheus.NewCounterVec(prometheus.CounterOpts{}, []string{"rule"})
+	events := make(chan event.Events, 1)
+	l := &StatsDUDPListener{
+		Conn:            conn,
+		EventHandler:    &event.UnbufferedEventHandler{C: events},
+		Logger:          promslog.NewNopLogger(),
+		LineParser:      line.NewParser(),
+		UDPPackets:      prometheus.NewCounter(prometheus.CounterOpts{}),
+		UDPPacketDrops:  prometheus.NewCounter(prometheus.CounterOpts{}),
+		LinesReceived:   prometheus.NewCounter(prometheus.CounterOpts{}),
+		SampleErrors:    *prometheus.NewCounterVec(prometheus.CounterOpts{}, []string{"reason"}),
+		SamplesReceived: prometheus.NewCounter(prometheus.CounterOpts{}),
+		TagErrors:       prometheus.NewCounter(prometheus.CounterOpts{}),
+		TagsReceived:    prometheus.NewCounter(prometheus.CounterOpts{}),
+		UdpPacketQueue:  make(chan []byte, 1),
+		Sources:         sources,
+	}
+	go l.Listen()
+
+	client, err := net.DialUDP("udp4", nil, conn.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if _, err := client.Write([]byte("foo:1|c")); err != nil {
+		t.Fatal(err)
+	}
+
+	rejected := sources.Rejected.WithLabelValues("127.0.0.0/8")
+	for deadline := time.Now().Add(5 * time.Second); metricValue(t, rejected) == 0; {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected packet to be rejected")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	select {
+	case e := <-events:
+		t.Fatalf("expected no events from a denied source, got %v", e)
+	default:
+	}
+	if v := metricValue(t, l.UDPPackets); v != 0 {
+		t.Fatalf("expected rejected packet to not be counted as received, got %v", v)
+	}
+}
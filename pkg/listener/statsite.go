@@ -0,0 +1,118 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package listener
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"runtime/trace"
+	"slices"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/prometheus/statsd_exporter/pkg/event"
+	"github.com/prometheus/statsd_exporter/pkg/line"
+	"github.com/prometheus/statsd_exporter/pkg/relay"
+)
+
+// ProtocolStatsiteBinary is the statsite binary protocol, which listeners
+// read instead of StatsD lines if their Protocol is set to it.
+const ProtocolStatsiteBinary = "statsite-binary"
+
+// statsiteDecoder turns statsite frames into events.
+type statsiteDecoder struct {
+	eventHandler    event.EventHandler
+	logger          *slog.Logger
+	relay           *relay.Relay
+	sampleErrors    prometheus.CounterVec
+	samplesReceived prometheus.Counter
+}
+
+// decode decodes a single frame and returns its event, or nil if it is
+// malformed.
+func (d statsiteDecoder) decode(frame []byte) event.Event {
+	defer trace.StartRegion(context.Background(), event.TraceRegionParse).End()
+	d.samplesReceived.Inc()
+	e, _, err := line.DecodeStatsiteFrame(frame)
+	if err != nil {
+		d.sampleErrors.WithLabelValues("malformed_frame").Inc()
+		d.logger.Debug("Bad statsite frame", "error", err)
+		return nil
+	}
+	return e
+}
+
+func (d statsiteDecoder) queue(events event.Events) {
+	if len(events) == 0 {
+		return
+	}
+	if d.relay != nil {
+		d.relay.RelayEvents(events)
+	}
+	d.eventHandler.Queue(events)
+}
+
+// handlePacket decodes all frames in a datagram.
+func (d statsiteDecoder) handlePacket(packet []byte) {
+	events := event.Events{}
+	for len(packet) > 0 {
+		n, err := line.StatsiteFrameLength(packet)
+		if err == nil && n > len(packet) {
+			err = line.ErrIncompleteFrame
+		}
+		if err != nil {
+			d.sampleErrors.WithLabelValues("malformed_frame").Inc()
+			d.logger.Debug("Bad statsite packet", "error", err)
+			break
+		}
+		if e := d.decode(packet[:n]); e != nil {
+			events = append(events, e)
+		}
+		packet = packet[n:]
+	}
+	d.queue(events)
+}
+
+// handleStream decodes frames from a stream until it ends. A stream that
+// does not start with a frame where one is expected cannot be resynchronized,
+// and is abandoned with an error.
+func (d statsiteDecoder) handleStream(r *bufio.Reader, read func()) error {
+	frame := make([]byte, 0, 256)
+	for {
+		frame = frame[:line.StatsiteHeaderLength]
+		if _, err := io.ReadFull(r, frame); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		n, err := line.StatsiteFrameLength(frame)
+		if err != nil {
+			return err
+		}
+		frame = slices.Grow(frame, n-len(frame))[:n]
+		if _, err := io.ReadFull(r, frame[line.StatsiteHeaderLength:]); err != nil {
+			return err
+		}
+		if read != nil {
+			read()
+		}
+		if e := d.decode(frame); e != nil {
+			d.queue(event.Events{e})
+		}
+	}
+}
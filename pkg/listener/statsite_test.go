@@ -0,0 +1,106 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package listener
+
+import (
+	"net"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/promslog"
+
+	"github.com/prometheus/statsd_exporter/pkg/event"
+	"github.com/prometheus/statsd_exporter/pkg/line"
+)
+
+func statsiteFrames(t *testing.T) []byte {
+	var b []byte
+	var err error
+	for _, name := range []string{"foo", "bar"} {
+		if b, err = line.AppendStatsiteFrame(b, "c", name, 1, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return b
+}
+
+func TestUDPListenerStatsite(t *testing.T) {
+	events := make(chan event.Events, 1)
+	sampleErrors := prometheus.NewCounterVec(prometheus.CounterOpts{}, []string{"reason"})
+	l := &StatsDUDPListener{
+		EventHandler:    &event.UnbufferedEventHandler{C: events},
+		Logger:          promslog.NewNopLogger(),
+		SampleErrors:    *sampleErrors,
+		SamplesReceived: prometheus.NewCounter(prometheus.CounterOpts{}),
+		Protocol:        ProtocolStatsiteBinary,
+	}
+
+	// The truncated frame at the end is counted as an error.
+	frames := statsiteFrames(t)
+	l.HandlePacket(append(frames, frames[:5]...))
+	e := <-events
+	if len(e) != 2 || e[0].MetricName() != "foo" || e[1].MetricName() != "bar" {
+		t.Fatalf("expected events for foo and bar, got %v", e)
+	}
+	if v := metricValue(t, sampleErrors.WithLabelValues("malformed_frame")); v != 1 {
+		t.Fatalf("expected 1 malformed frame, got %v", v)
+	}
+}
+
+func TestTCPListenerStatsite(t *testing.T) {
+	for _, detect := range []bool{false, true} {
+		conn, err := net.ListenTCP("tcp4", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		if err != nil {
+			t.Fatalf("listen failed: %v", err)
+		}
+
+		events := make(chan event.Events, 2)
+		counter := prometheus.NewCounter(prometheus.CounterOpts{})
+		l := &StatsDTCPListener{
+			Conn:            conn,
+			EventHandler:    &event.UnbufferedEventHandler{C: events},
+			Logger:          promslog.NewNopLogger(),
+			SampleErrors:    *prometheus.NewCounterVec(prometheus.CounterOpts{}, []string{"reason"}),
+			SamplesReceived: counter,
+			TCPConnections:  counter,
+			TCPErrors:       counter,
+		}
+		if detect {
+			l.DetectProtocol = true
+		} else {
+			l.Protocol = ProtocolStatsiteBinary
+		}
+		go l.Listen()
+
+		c, err := net.Dial("tcp4", conn.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Frames may be split across reads.
+		frames := statsiteFrames(t)
+		for _, b := range [][]byte{frames[:5], frames[5:20], frames[20:]} {
+			if _, err := c.Write(b); err != nil {
+				t.Fatal(err)
+			}
+		}
+		c.Close()
+
+		for _, name := range []string{"foo", "bar"} {
+			if e := <-events; len(e) != 1 || e[0].MetricName() != name {
+				t.Fatalf("detect %v: expected event for %s, got %v", detect, name, e)
+			}
+		}
+		conn.Close()
+	}
+}
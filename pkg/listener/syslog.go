@@ -0,0 +1,362 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package listener
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"runtime/trace"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/prometheus/statsd_exporter/pkg/event"
+	"github.com/prometheus/statsd_exporter/pkg/relay"
+)
+
+const (
+	// DefaultSyslogMaxMessageSize is the size of the largest syslog message
+	// accepted if no other limit is set. RFC 5425 requires at least 2048
+	// octets, and recommends 8192.
+	DefaultSyslogMaxMessageSize = 64 * 1024
+
+	// syslogStatsDSDID is the ID of structured data elements whose
+	// parameters are StatsD lines. IDs with an enterprise number, such as
+	// statsd@32473, are accepted as well.
+	syslogStatsDSDID = "statsd"
+
+	syslogHandshakeTimeout = 10 * time.Second
+)
+
+var errMalformedSyslog = errors.New("malformed syslog message")
+
+// NewSyslogTLSConfig returns the TLS configuration of a syslog listener that
+// presents the certificate in certFile and keyFile. If clientCAFile is set,
+// clients must present a certificate signed by one of its CAs.
+func NewSyslogTLSConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		// RFC 9662 updates RFC 5425 to require at least TLS 1.2.
+		MinVersion: tls.VersionTLS12,
+	}
+	if clientCAFile != "" {
+		pem, err := os.ReadFile(clientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.ClientCAs = x509.NewCertPool()
+		if !config.ClientCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", clientCAFile)
+		}
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
+
+// StatsDSyslogListener receives StatsD lines in RFC 5424 syslog messages over
+// TLS, framed as described in RFC 5425. The lines are taken from the
+// parameters of "statsd" structured data elements if a message has any, and
+// from the lines of its message otherwise.
+type StatsDSyslogListener struct {
+	// Conn is usually created with tls.NewListener. Connections that are
+	// not TLS connections are read as they are.
+	Conn            net.Listener
+	EventHandler    event.EventHandler
+	Logger          *slog.Logger
+	LineParser      Parser
+	LinesReceived   prometheus.Counter
+	Relay           *relay.Relay
+	SampleErrors    prometheus.CounterVec
+	SamplesReceived prometheus.Counter
+	TagErrors       prometheus.Counter
+	TagsReceived    prometheus.Counter
+	// SyslogErrors counts failed handshakes and reads, after which the
+	// connection is closed. SyslogMalformed counts messages that are
+	// skipped because they can't be parsed.
+	SyslogConnections prometheus.Counter
+	SyslogErrors      prometheus.Counter
+	SyslogMalformed   prometheus.Counter
+	// MaxMessageSize is the size of the largest message accepted. Larger
+	// messages close the connection. DefaultSyslogMaxMessageSize applies if
+	// it is 0.
+	MaxMessageSize int
+	// Health, if set, tracks the listener and restarts it on errors.
+	Health *Health
+	// Receive, if set, records the size of decrypted reads from
+	// connections.
+	Receive *ReceiveStats
+	// Idle, if set, flags the listener when it receives fewer lines than
+	// expected.
+	Idle *IdleWatchdog
+	// AcceptRetries, if set, counts accepts retried after transient
+	// errors, such as running out of file descriptors.
+	AcceptRetries prometheus.Counter
+}
+
+func (l *StatsDSyslogListener) SetEventHandler(eh event.EventHandler) {
+	l.EventHandler = eh
+}
+
+func (l *StatsDSyslogListener) Listen() {
+	if l.Health != nil {
+		l.Health.Run(l.acceptLoop)
+		return
+	}
+	if err := l.acceptLoop(); err != nil {
+		l.Logger.Error("Accepting syslog connection failed", "error", err)
+		os.Exit(1)
+	}
+}
+
+func (l *StatsDSyslogListener) acceptLoop() error {
+	var backoff time.Duration
+	for {
+		c, err := l.Conn.Accept()
+		if err != nil {
+			// ignore net: errClosing error as it will occur during shutdown
+			if isClosedErr(err) {
+				return nil
+			}
+			if l.Health != nil {
+				l.Health.ReadError()
+			}
+			if retryAccept(err, &backoff, l.AcceptRetries, l.Logger, "syslog_tls") {
+				continue
+			}
+			return err
+		}
+		backoff = 0
+		go l.HandleConn(c)
+	}
+}
+
+func (l *StatsDSyslogListener) HandleConn(c net.Conn) {
+	defer c.Close()
+
+	l.SyslogConnections.Inc()
+
+	if tc, ok := c.(*tls.Conn); ok {
+		tc.SetDeadline(time.Now().Add(syslogHandshakeTimeout))
+		if err := tc.Handshake(); err != nil {
+			l.SyslogErrors.Inc()
+			l.Logger.Debug("TLS handshake failed", "addr", c.RemoteAddr(), "error", err)
+			return
+		}
+		tc.SetDeadline(time.Time{})
+	}
+
+	maxSize := l.MaxMessageSize
+	if maxSize <= 0 {
+		maxSize = DefaultSyslogMaxMessageSize
+	}
+	var src io.Reader = c
+	if l.Receive != nil {
+		src = l.Receive.Reader(c)
+	}
+	r := bufio.NewReader(src)
+	for {
+		region := trace.StartRegion(context.Background(), event.TraceRegionRead)
+		msg, err := readSyslogFrame(r, maxSize)
+		region.End()
+		if err != nil {
+			if err != io.EOF {
+				l.SyslogErrors.Inc()
+				l.Logger.Debug("Read failed", "addr", c.RemoteAddr(), "error", err)
+			}
+			return
+		}
+		if l.Health != nil {
+			l.Health.Read()
+		}
+		lines, err := parseSyslogMessage(msg)
+		if err != nil {
+			l.SyslogMalformed.Inc()
+			l.Logger.Debug("Skipping syslog message", "addr", c.RemoteAddr(), "error", err)
+			continue
+		}
+		if l.Idle != nil {
+			l.Idle.Lines(len(lines))
+		}
+
+		var events event.Events
+		for _, line := range lines {
+			l.Logger.Debug("Incoming line", "proto", "syslog_tls", "line", line)
+			l.LinesReceived.Inc()
+			events = append(events, l.LineParser.LineToEvents(line, l.SampleErrors, l.SamplesReceived, l.TagErrors, l.TagsReceived, l.Logger)...)
+		}
+		if l.Relay != nil {
+			l.Relay.RelayEvents(events)
+		}
+		l.EventHandler.Queue(events)
+	}
+}
+
+// readSyslogFrame reads an octet-counted frame, the length of the message
+// followed by a space and the message.
+func readSyslogFrame(r *bufio.Reader, maxSize int) ([]byte, error) {
+	var length int
+	for digits := 0; ; digits++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			if err == io.EOF && digits > 0 {
+				return nil, io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		if b == ' ' && digits > 0 {
+			break
+		}
+		if b < '0' || b > '9' || (digits == 0 && b == '0') {
+			return nil, fmt.Errorf("invalid frame length at byte %q", b)
+		}
+		length = length*10 + int(b-'0')
+		if length > maxSize {
+			return nil, fmt.Errorf("message exceeds the maximum size of %d bytes", maxSize)
+		}
+	}
+
+	msg := make([]byte, length)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return msg, nil
+}
+
+// parseSyslogMessage returns the StatsD lines of an RFC 5424 syslog message.
+// They are the parameter values of its "statsd" structured data elements if
+// it has any, and the non-empty lines of its message otherwise.
+func parseSyslogMessage(msg []byte) ([]string, error) {
+	s := string(msg)
+
+	// The header is PRI and VERSION, followed by the timestamp, hostname,
+	// app name, process ID and message ID, which are not used.
+	pri, version, ok := strings.Cut(s, ">")
+	if !ok || !strings.HasPrefix(pri, "<") {
+		return nil, fmt.Errorf("%w: missing priority", errMalformedSyslog)
+	}
+	if p, err := strconv.Atoi(pri[1:]); err != nil || p < 0 || p > 191 {
+		return nil, fmt.Errorf("%w: invalid priority %q", errMalformedSyslog, pri[1:])
+	}
+	if !strings.HasPrefix(version, "1 ") {
+		return nil, fmt.Errorf("%w: unsupported version", errMalformedSyslog)
+	}
+	s = version
+	for i := 0; i < 6; i++ {
+		_, rest, ok := strings.Cut(s, " ")
+		if !ok {
+			return nil, fmt.Errorf("%w: incomplete header", errMalformedSyslog)
+		}
+		s = rest
+	}
+
+	var lines []string
+	var sdLines bool
+	if strings.HasPrefix(s, "-") {
+		s = s[1:]
+	} else {
+		if !strings.HasPrefix(s, "[") {
+			return nil, fmt.Errorf("%w: invalid structured data", errMalformedSyslog)
+		}
+		for strings.HasPrefix(s, "[") {
+			id, params, rest, err := parseSyslogSDElement(s)
+			if err != nil {
+				return nil, err
+			}
+			if id == syslogStatsDSDID || strings.HasPrefix(id, syslogStatsDSDID+"@") {
+				lines = append(lines, params...)
+				sdLines = true
+			}
+			s = rest
+		}
+	}
+	if sdLines {
+		return lines, nil
+	}
+
+	if s == "" {
+		return nil, nil
+	}
+	if s[0] != ' ' {
+		return nil, fmt.Errorf("%w: invalid structured data", errMalformedSyslog)
+	}
+	s = strings.TrimPrefix(s[1:], "\xef\xbb\xbf")
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
+
+// parseSyslogSDElement parses the structured data element at the start of s.
+// It returns its ID, the values of its parameters and the rest of s.
+func parseSyslogSDElement(s string) (string, []string, string, error) {
+	end := strings.IndexAny(s, " ]")
+	if end < 0 {
+		return "", nil, "", fmt.Errorf("%w: unterminated structured data", errMalformedSyslog)
+	}
+	id := s[1:end]
+	if id == "" {
+		return "", nil, "", fmt.Errorf("%w: empty structured data ID", errMalformedSyslog)
+	}
+	s = s[end:]
+
+	var values []string
+	for strings.HasPrefix(s, " ") {
+		name, rest, ok := strings.Cut(s[1:], "=\"")
+		if !ok || name == "" {
+			return "", nil, "", fmt.Errorf("%w: invalid parameter in %s", errMalformedSyslog, id)
+		}
+		// Values end at the first unescaped quote. Quotes, backslashes
+		// and closing brackets are escaped with a backslash.
+		var value strings.Builder
+		closed := false
+		for i := 0; i < len(rest); i++ {
+			if rest[i] == '\\' && i+1 < len(rest) && strings.IndexByte(`"\]`, rest[i+1]) >= 0 {
+				i++
+			} else if rest[i] == '"' {
+				s = rest[i+1:]
+				closed = true
+				break
+			}
+			value.WriteByte(rest[i])
+		}
+		if !closed {
+			return "", nil, "", fmt.Errorf("%w: unterminated parameter %s in %s", errMalformedSyslog, name, id)
+		}
+		values = append(values, value.String())
+	}
+	if !strings.HasPrefix(s, "]") {
+		return "", nil, "", fmt.Errorf("%w: unterminated structured data element %s", errMalformedSyslog, id)
+	}
+	return id, values, s[1:], nil
+}
@@ -0,0 +1,246 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package listener
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/promslog"
+
+	"github.com/prometheus/statsd_exporter/pkg/event"
+	"github.com/prometheus/statsd_exporter/pkg/line"
+)
+
+func TestParseSyslogMessage(t *testing.T) {
+	tests := []struct {
+		name  string
+		msg   string
+		lines []string
+		bad   bool
+	}{
+		{
+			name:  "message",
+			msg:   "<134>1 2026-10-15T12:00:00Z host app 42 - - foo:1|c\nbar:2|g\n",
+			lines: []string{"foo:1|c", "bar:2|g"},
+		},
+		{
+			name:  "message with BOM",
+			msg:   "<134>1 - - - - - - \xef\xbb\xbffoo:1|c",
+			lines: []string{"foo:1|c"},
+		},
+		{
+			name: "no message",
+			msg:  "<134>1 - - - - - -",
+		},
+		{
+			name:  "statsd structured data",
+			msg:   `<134>1 - host app - - [meta seq="1"][statsd m1="foo:1|c" m2="bar:2|g"] ignored`,
+			lines: []string{"foo:1|c", "bar:2|g"},
+		},
+		{
+			name:  "statsd structured data with enterprise number",
+			msg:   `<134>1 - - - - - [statsd@32473 m="foo:1|c|#tag:\"a\\b\]"]`,
+			lines: []string{`foo:1|c|#tag:"a\b]`},
+		},
+		{
+			name:  "other structured data",
+			msg:   `<134>1 - - - - - [meta seq="1"] foo:1|c`,
+			lines: []string{"foo:1|c"},
+		},
+		{
+			name: "missing priority",
+			msg:  "1 - - - - - - foo:1|c",
+			bad:  true,
+		},
+		{
+			name: "invalid priority",
+			msg:  "<192>1 - - - - - - foo:1|c",
+			bad:  true,
+		},
+		{
+			name: "BSD syslog",
+			msg:  "<134>Oct 15 12:00:00 host app: foo:1|c",
+			bad:  true,
+		},
+		{
+			name: "incomplete header",
+			msg:  "<134>1 - - -",
+			bad:  true,
+		},
+		{
+			name: "unterminated structured data",
+			msg:  `<134>1 - - - - - [statsd m="foo:1|c"`,
+			bad:  true,
+		},
+		{
+			name: "unterminated parameter",
+			msg:  `<134>1 - - - - - [statsd m="foo:1|c\"]`,
+			bad:  true,
+		},
+		{
+			name: "missing space before message",
+			msg:  "<134>1 - - - - - -foo:1|c",
+			bad:  true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			lines, err := parseSyslogMessage([]byte(tc.msg))
+			if tc.bad {
+				if err == nil {
+					t.Fatalf("expected an error, got lines %q", lines)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(lines, tc.lines) {
+				t.Fatalf("expected lines %q, got %q", tc.lines, lines)
+			}
+		})
+	}
+}
+
+func TestReadSyslogFrame(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("5 hello11 hello world3 abc"))
+	for _, expected := range []string{"hello", "hello world"} {
+		msg, err := readSyslogFrame(r, 11)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if string(msg) != expected {
+			t.Fatalf("expected %q, got %q", expected, msg)
+		}
+	}
+	if _, err := readSyslogFrame(r, 2); err == nil {
+		t.Fatalf("expected frame over the maximum size to be an error")
+	}
+
+	for _, frame := range []string{"05 hello", "x hello", " hello", "5 hel", "5"} {
+		if _, err := readSyslogFrame(bufio.NewReader(strings.NewReader(frame)), 100); err == nil {
+			t.Fatalf("expected frame %q to be an error", frame)
+		}
+	}
+}
+
+func TestSyslogListener(t *testing.T) {
+	cert := newTestCertificate(t)
+	conn, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	tconn := tls.NewListener(conn, &tls.Config{Certificates: []tls.Certificate{cert}})
+
+	events := make(chan event.Events, 2)
+	malformed := prometheus.NewCounter(prometheus.CounterOpts{})
+	l := &StatsDSyslogListener{
+		Conn:              tconn,
+		EventHandler:      &event.UnbufferedEventHandler{C: events},
+		Logger:            promslog.NewNopLogger(),
+		LineParser:        line.NewParser(),
+		LinesReceived:     prometheus.NewCounter(prometheus.CounterOpts{}),
+		SampleErrors:      *prometheus.NewCounterVec(prometheus.CounterOpts{}, []string{"reason"}),
+		SamplesReceived:   prometheus.NewCounter(prometheus.CounterOpts{}),
+		TagErrors:         prometheus.NewCounter(prometheus.CounterOpts{}),
+		TagsReceived:      prometheus.NewCounter(prometheus.CounterOpts{}),
+		SyslogConnections: prometheus.NewCounter(prometheus.CounterOpts{}),
+		SyslogErrors:      prometheus.NewCounter(prometheus.CounterOpts{}),
+		SyslogMalformed:   malformed,
+	}
+	done := make(chan struct{})
+	go func() {
+		l.Listen()
+		close(done)
+	}()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert.Leaf)
+	client, err := tls.Dial("tcp", conn.Addr().String(), &tls.Config{RootCAs: pool, ServerName: "localhost"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	for _, msg := range []string{
+		`<134>1 - - - - - [statsd m1="foo:1|c" m2="bar:2|g"]`,
+		"not syslog",
+		"<134>1 - - - - - - baz:3|ms",
+	} {
+		if _, err := fmt.Fprintf(client, "%d %s", len(msg), msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, names := range [][]string{{"foo", "bar"}, {"baz"}} {
+		e := <-events
+		if len(e) != len(names) {
+			t.Fatalf("expected events for %v, got %v", names, e)
+		}
+		for i, name := range names {
+			if e[i].MetricName() != name {
+				t.Fatalf("expected event for %s, got %v", name, e[i])
+			}
+		}
+	}
+	if v := metricValue(t, malformed); v != 1 {
+		t.Fatalf("expected 1 malformed message, got %v", v)
+	}
+
+	tconn.Close()
+	<-done
+}
+
+// newTestCertificate returns a self-signed certificate for localhost.
+func newTestCertificate(t *testing.T) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		DNSNames:              []string{"localhost"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
@@ -0,0 +1,162 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package listener
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"strconv"
+)
+
+// UnixSocketOptions controls the socket file of a Unix domain socket.
+type UnixSocketOptions struct {
+	// Mode is applied to the socket file unless it is 0.
+	Mode os.FileMode
+	// Owner and Group are user and group names or numeric IDs to change the
+	// ownership of the socket file to. Empty values are left unchanged.
+	Owner string
+	Group string
+	// RemoveStale removes a socket file left behind by a previous run that
+	// nothing is listening on anymore.
+	RemoveStale bool
+}
+
+// isAbstractSocket reports whether path names a Linux abstract socket, which
+// has no file on the filesystem. Abstract sockets are reachable from every
+// process in the same network namespace, without sharing a volume.
+func isAbstractSocket(path string) bool {
+	return len(path) > 0 && path[0] == '@'
+}
+
+// ListenUnixgram listens on a unixgram socket at path and sets up its socket
+// file according to opts. The socket file has to be removed by the caller
+// after closing the connection.
+func ListenUnixgram(path string, opts UnixSocketOptions) (*net.UnixConn, error) {
+	if !isAbstractSocket(path) {
+		if err := checkExistingSocket("unixgram", path, opts.RemoveStale); err != nil {
+			return nil, err
+		}
+	}
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Net: "unixgram", Name: path})
+	if err != nil {
+		return nil, err
+	}
+	if isAbstractSocket(path) {
+		return conn, nil
+	}
+
+	if err := setupSocketFile(path, opts); err != nil {
+		conn.Close()
+		os.Remove(path)
+		return nil, err
+	}
+	return conn, nil
+}
+
+// ListenUnix listens on a unix stream socket at path and sets up its socket
+// file according to opts. The socket file is removed when the listener is
+// closed.
+func ListenUnix(path string, opts UnixSocketOptions) (*net.UnixListener, error) {
+	if !isAbstractSocket(path) {
+		if err := checkExistingSocket("unix", path, opts.RemoveStale); err != nil {
+			return nil, err
+		}
+	}
+
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Net: "unix", Name: path})
+	if err != nil {
+		return nil, err
+	}
+	if isAbstractSocket(path) {
+		return l, nil
+	}
+
+	if err := setupSocketFile(path, opts); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// checkExistingSocket fails if a file exists at path, unless it is a stale
+// socket of the given network and removeStale is set, in which case it is
+// removed.
+func checkExistingSocket(network, path string, removeStale bool) error {
+	fi, err := os.Lstat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if fi.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s already exists and is not a socket", path)
+	}
+	if !removeStale {
+		return fmt.Errorf("socket %s already exists", path)
+	}
+
+	// A socket nothing listens on refuses connections.
+	c, err := net.Dial(network, path)
+	if err == nil {
+		c.Close()
+		return fmt.Errorf("socket %s is in use", path)
+	}
+	if !errors.Is(err, errConnRefused) {
+		return fmt.Errorf("checking whether socket %s is stale: %w", path, err)
+	}
+	return os.Remove(path)
+}
+
+func setupSocketFile(path string, opts UnixSocketOptions) error {
+	if opts.Mode != 0 {
+		if err := os.Chmod(path, opts.Mode); err != nil {
+			return err
+		}
+	}
+
+	if opts.Owner == "" && opts.Group == "" {
+		return nil
+	}
+	uid, gid := -1, -1
+	if opts.Owner != "" {
+		u, err := user.Lookup(opts.Owner)
+		if err != nil {
+			u, err = user.LookupId(opts.Owner)
+		}
+		if err != nil {
+			return fmt.Errorf("unknown socket owner %s: %w", opts.Owner, err)
+		}
+		if uid, err = strconv.Atoi(u.Uid); err != nil {
+			return fmt.Errorf("socket owner %s has non-numeric uid %s", opts.Owner, u.Uid)
+		}
+	}
+	if opts.Group != "" {
+		g, err := user.LookupGroup(opts.Group)
+		if err != nil {
+			g, err = user.LookupGroupId(opts.Group)
+		}
+		if err != nil {
+			return fmt.Errorf("unknown socket group %s: %w", opts.Group, err)
+		}
+		if gid, err = strconv.Atoi(g.Gid); err != nil {
+			return fmt.Errorf("socket group %s has non-numeric gid %s", opts.Group, g.Gid)
+		}
+	}
+	return os.Chown(path, uid, gid)
+}
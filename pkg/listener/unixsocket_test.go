@@ -0,0 +1,186 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package listener
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/promslog"
+
+	"github.com/prometheus/statsd_exporter/pkg/event"
+	"github.com/prometheus/statsd_exporter/pkg/line"
+)
+
+func TestListenUnixgramStaleSocket(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unixgram sockets are not supported on windows")
+	}
+	path := filepath.Join(t.TempDir(), "statsd.sock")
+
+	conn, err := ListenUnixgram(path, UnixSocketOptions{})
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+
+	if _, err := ListenUnixgram(path, UnixSocketOptions{RemoveStale: true}); err == nil {
+		t.Fatalf("expected socket in use to not be removed")
+	}
+
+	// Closing a unixgram connection leaves the socket file behind.
+	conn.Close()
+	if _, err := ListenUnixgram(path, UnixSocketOptions{}); err == nil {
+		t.Fatalf("expected existing socket to be an error")
+	}
+
+	conn, err = ListenUnixgram(path, UnixSocketOptions{RemoveStale: true, Mode: 0o600})
+	if err != nil {
+		t.Fatalf("expected stale socket to be removed, got %v", err)
+	}
+	defer conn.Close()
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0o600 {
+		t.Fatalf("expected mode 0600, got %v", fi.Mode().Perm())
+	}
+}
+
+func TestListenUnixgramNotASocket(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unixgram sockets are not supported on windows")
+	}
+	path := filepath.Join(t.TempDir(), "statsd.sock")
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ListenUnixgram(path, UnixSocketOptions{RemoveStale: true}); err == nil {
+		t.Fatalf("expected regular file to not be removed")
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("expected regular file to still exist: %v", err)
+	}
+}
+
+func TestListenUnixgramOwnership(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unixgram sockets are not supported on windows")
+	}
+	path := filepath.Join(t.TempDir(), "statsd.sock")
+
+	// Changing the ownership to the current user and group is always allowed.
+	conn, err := ListenUnixgram(path, UnixSocketOptions{
+		Owner: strconv.Itoa(os.Getuid()),
+		Group: strconv.Itoa(os.Getgid()),
+	})
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	conn.Close()
+	os.Remove(path)
+
+	if _, err := ListenUnixgram(path, UnixSocketOptions{Owner: "no-such-user-statsd"}); err == nil {
+		t.Fatalf("expected unknown owner to be an error")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected socket file to be removed after failing to set it up")
+	}
+}
+
+func TestListenUnixAbstract(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("abstract sockets are only supported on linux")
+	}
+	path := fmt.Sprintf("@statsd-exporter-test-%d", os.Getpid())
+
+	conn, err := ListenUnixgram(path, UnixSocketOptions{RemoveStale: true, Mode: 0o600})
+	if err != nil {
+		t.Fatalf("listen on unixgram failed: %v", err)
+	}
+	defer conn.Close()
+	l, err := ListenUnix(path, UnixSocketOptions{RemoveStale: true, Mode: 0o600})
+	if err != nil {
+		t.Fatalf("listen on unix failed: %v", err)
+	}
+	defer l.Close()
+
+	for _, network := range []string{"unixgram", "unix"} {
+		c, err := net.Dial(network, path)
+		if err != nil {
+			t.Fatalf("dialing %s failed: %v", network, err)
+		}
+		c.Close()
+	}
+}
+
+func TestUnixListener(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix sockets are not supported on windows")
+	}
+	path := filepath.Join(t.TempDir(), "statsd.sock")
+	conn, err := ListenUnix(path, UnixSocketOptions{})
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+
+	events := make(chan event.Events, 2)
+	l := &StatsDUnixListener{
+		Conn:            conn,
+		EventHandler:    &event.UnbufferedEventHandler{C: events},
+		Logger:          promslog.NewNopLogger(),
+		LineParser:      line.NewParser(),
+		LinesReceived:   prometheus.NewCounter(prometheus.CounterOpts{}),
+		SampleErrors:    *prometheus.NewCounterVec(prometheus.CounterOpts{}, []string{"reason"}),
+		SamplesReceived: prometheus.NewCounter(prometheus.CounterOpts{}),
+		TagErrors:       prometheus.NewCounter(prometheus.CounterOpts{}),
+		TagsReceived:    prometheus.NewCounter(prometheus.CounterOpts{}),
+		UnixConnections: prometheus.NewCounter(prometheus.CounterOpts{}),
+		UnixErrors:      prometheus.NewCounter(prometheus.CounterOpts{}),
+		UnixLineTooLong: prometheus.NewCounter(prometheus.CounterOpts{}),
+	}
+	done := make(chan struct{})
+	go func() {
+		l.Listen()
+		close(done)
+	}()
+
+	client, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if _, err := client.Write([]byte("foo:1|c\nbar:2|g\n")); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"foo", "bar"} {
+		e := <-events
+		if len(e) != 1 || e[0].MetricName() != name {
+			t.Fatalf("expected event for %s, got %v", name, e)
+		}
+	}
+
+	conn.Close()
+	<-done
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected socket file to be removed after closing the listener")
+	}
+}
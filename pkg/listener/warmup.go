@@ -0,0 +1,56 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package listener
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// rmemDefaultPath holds the read buffer size new sockets get on Linux.
+const rmemDefaultPath = "/proc/sys/net/core/rmem_default"
+
+// RaiseReadBuffer sets the read buffer of conn to size for the duration d,
+// to absorb bursts while the exporter warms up, and then sets it to restore.
+// If restore is 0, the system default is restored, which is only known on
+// Linux.
+func RaiseReadBuffer(conn *net.UDPConn, size, restore int, d time.Duration, logger *slog.Logger) error {
+	if restore == 0 {
+		data, err := os.ReadFile(rmemDefaultPath)
+		if err != nil {
+			return fmt.Errorf("unable to determine the default read buffer size to restore after warm-up, set it explicitly: %w", err)
+		}
+		restore, err = strconv.Atoi(strings.TrimSpace(string(data)))
+		if err != nil {
+			return fmt.Errorf("parsing %s: %w", rmemDefaultPath, err)
+		}
+	}
+
+	if err := conn.SetReadBuffer(size); err != nil {
+		return err
+	}
+	time.AfterFunc(d, func() {
+		if err := conn.SetReadBuffer(restore); err != nil {
+			logger.Warn("Failed to restore read buffer after warm-up", "error", err)
+			return
+		}
+		logger.Debug("Restored read buffer after warm-up", "size", restore)
+	})
+	return nil
+}
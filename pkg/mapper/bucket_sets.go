@@ -0,0 +1,88 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mapper
+
+import "fmt"
+
+// DefaultBucketHintLabel is the label clients set to select a bucket set,
+// unless configured otherwise.
+const DefaultBucketHintLabel = "buckets"
+
+// BucketSets are named histogram bucket layouts that clients select with a
+// hint label, such as the DogStatsD tag `#buckets:web`, so that one metric can
+// use different buckets per workload without separate mappings.
+type BucketSets struct {
+	Label string               `yaml:"label"`
+	Sets  map[string][]float64 `yaml:"sets"`
+}
+
+func validateBucketSets(b *BucketSets) error {
+	if len(b.Sets) == 0 {
+		return nil
+	}
+	if b.Label == "" {
+		b.Label = DefaultBucketHintLabel
+	}
+	if !labelNameRE.MatchString(b.Label) {
+		return fmt.Errorf("bucket hint label '%s' doesn't match regex '%s'", b.Label, labelNameRE)
+	}
+	for name, buckets := range b.Sets {
+		if len(buckets) == 0 {
+			return fmt.Errorf("bucket set %s has no buckets", name)
+		}
+		for i := 1; i < len(buckets); i++ {
+			if buckets[i] <= buckets[i-1] {
+				return fmt.Errorf("buckets of bucket set %s are not in increasing order", name)
+			}
+		}
+	}
+	return nil
+}
+
+// StripUnknownBucketHint returns labels without the bucket hint label if it
+// doesn't name a bucket set, so that clients can't create series with
+// arbitrary hints. Such histograms use the buckets of their mapping, like
+// histograms without a hint. labels is not modified.
+func (m *MetricMapper) StripUnknownBucketHint(labels map[string]string) map[string]string {
+	set, ok := labels[m.BucketSets.Label]
+	if !ok || len(m.BucketSets.Sets) == 0 {
+		return labels
+	}
+	if _, ok := m.BucketSets.Sets[set]; ok {
+		return labels
+	}
+	stripped := make(map[string]string, len(labels)-1)
+	for k, v := range labels {
+		if k != m.BucketSets.Label {
+			stripped[k] = v
+		}
+	}
+	return stripped
+}
+
+// HistogramBuckets returns the buckets of a histogram with the given labels
+// that was mapped by mapping. If the hint label selects a bucket set, its
+// buckets take precedence over the mapping's, and its name is returned as
+// well.
+func (m *MetricMapper) HistogramBuckets(mapping *MetricMapping, labels map[string]string) ([]float64, string) {
+	if set, ok := labels[m.BucketSets.Label]; ok && len(m.BucketSets.Sets) > 0 {
+		if buckets, ok := m.BucketSets.Sets[set]; ok {
+			return buckets, set
+		}
+	}
+	if mapping.HistogramOptions != nil && len(mapping.HistogramOptions.Buckets) > 0 {
+		return mapping.HistogramOptions.Buckets, ""
+	}
+	return m.Defaults.HistogramOptions.Buckets, ""
+}
@@ -0,0 +1,105 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mapper
+
+import "fmt"
+
+// DerivedMetric is a gauge computed from the current values of two exported
+// counters or gauges, for consumers that can't compute it themselves.
+type DerivedMetric struct {
+	Name  string    `yaml:"name"`
+	Help  string    `yaml:"help"`
+	Op    DerivedOp `yaml:"op"`
+	Left  string    `yaml:"left"`
+	Right string    `yaml:"right"`
+	// By lists the labels the derived metric keeps. The series of each
+	// operand are summed up by these labels before the operation is applied.
+	By []string `yaml:"by"`
+}
+
+type DerivedOp string
+
+const (
+	DerivedOpAdd      DerivedOp = "add"
+	DerivedOpSubtract DerivedOp = "subtract"
+	DerivedOpMultiply DerivedOp = "multiply"
+	DerivedOpDivide   DerivedOp = "divide"
+)
+
+func (op *DerivedOp) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var v string
+
+	if err := unmarshal(&v); err != nil {
+		return err
+	}
+
+	switch DerivedOp(v) {
+	case DerivedOpAdd, DerivedOpSubtract, DerivedOpMultiply, DerivedOpDivide:
+		*op = DerivedOp(v)
+	default:
+		return fmt.Errorf("invalid derived metric op %q", v)
+	}
+	return nil
+}
+
+// Apply returns the result of the operation. The second return value is
+// false if the result is undefined, that is when dividing by zero.
+func (op DerivedOp) Apply(left, right float64) (float64, bool) {
+	switch op {
+	case DerivedOpAdd:
+		return left + right, true
+	case DerivedOpSubtract:
+		return left - right, true
+	case DerivedOpMultiply:
+		return left * right, true
+	case DerivedOpDivide:
+		if right == 0 {
+			return 0, false
+		}
+		return left / right, true
+	}
+	return 0, false
+}
+
+func validateDerivedMetrics(derived []DerivedMetric) error {
+	names := map[string]bool{}
+	for _, d := range derived {
+		if !rollupNameRE.MatchString(d.Name) {
+			return fmt.Errorf("derived metric name '%s' doesn't match regex '%s'", d.Name, rollupNameRE)
+		}
+		if names[d.Name] {
+			return fmt.Errorf("derived metric %s is defined more than once", d.Name)
+		}
+		names[d.Name] = true
+		if d.Op == "" {
+			return fmt.Errorf("derived metric %s doesn't set an op", d.Name)
+		}
+		if d.Left == "" || d.Right == "" {
+			return fmt.Errorf("derived metric %s needs both a left and a right metric", d.Name)
+		}
+		for _, label := range d.By {
+			if !labelNameRE.MatchString(label) {
+				return fmt.Errorf("label name '%s' in derived metric %s doesn't match regex '%s'", label, d.Name, labelNameRE)
+			}
+		}
+	}
+	return nil
+}
+
+// GetDerivedMetrics returns the derived metrics of the current configuration.
+func (m *MetricMapper) GetDerivedMetrics() []DerivedMetric {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.DerivedMetrics
+}
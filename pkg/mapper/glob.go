@@ -0,0 +1,74 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mapper
+
+import (
+	"regexp"
+	"strings"
+)
+
+// globOptionsSet reports whether a glob mapping uses options that the FSM
+// cannot match. Such mappings are compiled into a regular expression and
+// evaluated together with the regex mappings, in the order of the
+// configuration.
+func (m *MetricMapping) globOptionsSet() bool {
+	return m.CaseInsensitive || m.MatchAnywhere
+}
+
+// globCaptureCount returns the number of captures in a glob match.
+func globCaptureCount(match string) int {
+	count := 0
+	for _, field := range strings.Split(match, ".") {
+		if field == "*" {
+			count++
+		}
+	}
+	return count
+}
+
+// compileGlob translates a glob match into a regular expression with one
+// group per "*". Unless anywhere is set, the glob has to match the full
+// metric name; otherwise it may match any run of dot-separated components,
+// such as a prefix.
+func compileGlob(match string, caseInsensitive, anywhere bool) (*regexp.Regexp, error) {
+	fields := strings.Split(match, ".")
+	for i, field := range fields {
+		if field == "*" {
+			fields[i] = `([^.]*)`
+		} else {
+			fields[i] = regexp.QuoteMeta(field)
+		}
+	}
+
+	expr := `^` + strings.Join(fields, `\.`) + `$`
+	if anywhere {
+		expr = `(?:^|\.)` + strings.Join(fields, `\.`) + `(?:\.|$)`
+	}
+	if caseInsensitive {
+		expr = `(?i)` + expr
+	}
+	return regexp.Compile(expr)
+}
+
+// globCaptures returns the strings captured by a glob compiled with
+// compileGlob, given the submatch indexes of a match.
+func globCaptures(s string, matches []int) []string {
+	captures := make([]string, len(matches)/2-1)
+	for i := range captures {
+		if start := matches[2*i+2]; start >= 0 {
+			captures[i] = s[start:matches[2*i+3]]
+		}
+	}
+	return captures
+}
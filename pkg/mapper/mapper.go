@@ -14,12 +14,14 @@
 package mapper
 
 import (
+	"crypto/sha256"
 	"fmt"
 	"log/slog"
 	"os"
 	"regexp"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/promslog"
@@ -39,6 +41,7 @@ var (
 	metricLineRE = regexp.MustCompile(`^(\*|` + statsdMetricRE + `)(\.\*|\.` + statsdMetricSubsequentRE + `)*$`)
 	metricNameRE = regexp.MustCompile(`^([a-zA-Z_]|` + templateReplaceRE + `)([a-zA-Z0-9_]|` + templateReplaceRE + `)*$`)
 	labelNameRE  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]+$`)
+	rollupNameRE = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
 )
 
 type MetricMapper struct {
@@ -53,7 +56,26 @@ type MetricMapper struct {
 
 	MappingsCount prometheus.Gauge
 
+	// Sample, if set, records the metric names the mapper evaluates, which
+	// Reload validates new configurations with.
+	Sample *NameSample
+	// CompileDuration and ValidationDuration, if set, observe how long Reload
+	// takes to compile and to validate a configuration.
+	CompileDuration    prometheus.Observer
+	ValidationDuration prometheus.Observer
+	reloadMutex        sync.Mutex
+
+	// DerivedMetrics are computed by the exporter from mapped metrics.
+	DerivedMetrics []DerivedMetric `yaml:"derived_metrics"`
+	// BucketSets are selected by clients with a hint label.
+	BucketSets BucketSets `yaml:"bucket_sets"`
+
 	Logger *slog.Logger
+	// UTF8Names accepts metric names and label names in mappings that are
+	// valid UTF-8, rather than only legacy Prometheus names.
+	UTF8Names bool
+
+	configHash string
 }
 
 type SummaryOptions struct {
@@ -81,11 +103,24 @@ var defaultQuantiles = []MetricObjective{
 }
 
 func (m *MetricMapper) InitFromYAMLString(fileContents string) error {
+	n, err := m.compile(fileContents)
+	if err != nil {
+		return err
+	}
+	m.apply(n)
+	return nil
+}
+
+// compile parses a mapping configuration into a new mapper, without
+// affecting m. The expensive work of a reload happens here, so that the
+// lookups of m are only blocked while the result is applied.
+func (m *MetricMapper) compile(fileContents string) (*MetricMapper, error) {
 	var n MetricMapper
 
 	if err := yaml.Unmarshal([]byte(fileContents), &n); err != nil {
-		return err
+		return nil, err
 	}
+	n.configHash = fmt.Sprintf("%x", sha256.Sum256([]byte(fileContents)))
 
 	if len(n.Defaults.HistogramOptions.Buckets) == 0 {
 		n.Defaults.HistogramOptions.Buckets = prometheus.DefBuckets
@@ -105,9 +140,23 @@ func (m *MetricMapper) InitFromYAMLString(fileContents string) error {
 		n.Defaults.MatchType = MatchTypeGlob
 	}
 
+	if err := m.validateRenameLabels(n.Defaults.RenameLabels, "defaults"); err != nil {
+		return nil, err
+	}
+	if err := validateDerivedMetrics(n.DerivedMetrics); err != nil {
+		return nil, err
+	}
+	if err := validateBucketSets(&n.BucketSets); err != nil {
+		return nil, err
+	}
+
+	if n.Defaults.OTelSemanticConventions {
+		n.Mappings = append(n.Mappings, otelMappings()...)
+	}
+
 	remainingMappingsCount := len(n.Mappings)
 
-	n.FSM = fsm.NewFSM([]string{string(MetricTypeCounter), string(MetricTypeGauge), string(MetricTypeObserver)},
+	n.FSM = fsm.NewFSM([]string{string(MetricTypeCounter), string(MetricTypeGauge), string(MetricTypeObserver), string(MetricTypeSet)},
 		remainingMappingsCount, n.Defaults.GlobDisableOrdering)
 
 	for i := range n.Mappings {
@@ -117,17 +166,62 @@ func (m *MetricMapper) InitFromYAMLString(fileContents string) error {
 
 		// check that label is correct
 		for k := range currentMapping.Labels {
-			if !labelNameRE.MatchString(k) {
-				return fmt.Errorf("invalid label key: %s", k)
+			if !m.validLabelName(k) {
+				return nil, fmt.Errorf("invalid label key: %s", k)
 			}
 		}
 
 		if currentMapping.Name == "" {
-			return fmt.Errorf("line %d: metric mapping didn't set a metric name", i)
+			return nil, fmt.Errorf("line %d: metric mapping didn't set a metric name", i)
 		}
 
-		if !metricNameRE.MatchString(currentMapping.Name) {
-			return fmt.Errorf("metric name '%s' doesn't match regex '%s'", currentMapping.Name, metricNameRE)
+		if !m.validMetricName(currentMapping.Name) {
+			return nil, fmt.Errorf("metric name '%s' doesn't match regex '%s'", currentMapping.Name, metricNameRE)
+		}
+
+		for _, rollup := range currentMapping.Rollups {
+			if !rollupNameRE.MatchString(rollup.Name) {
+				return nil, fmt.Errorf("rollup name '%s' in mapping %s doesn't match regex '%s'", rollup.Name, currentMapping.Match, rollupNameRE)
+			}
+			if len(rollup.DropLabels) == 0 {
+				return nil, fmt.Errorf("rollup %s in mapping %s doesn't drop any labels", rollup.Name, currentMapping.Match)
+			}
+		}
+
+		for _, previous := range currentMapping.PreviousNames {
+			if !m.validMetricName(previous.Name) {
+				return nil, fmt.Errorf("previous name '%s' in mapping %s doesn't match regex '%s'", previous.Name, currentMapping.Match, metricNameRE)
+			}
+			if previous.Until.IsZero() {
+				return nil, fmt.Errorf("previous name %s in mapping %s doesn't say until when it is exported", previous.Name, currentMapping.Match)
+			}
+		}
+
+		for _, label := range currentMapping.HashLabels {
+			if !m.validLabelName(label) {
+				return nil, fmt.Errorf("hashed label name '%s' in mapping %s doesn't match regex '%s'", label, currentMapping.Match, labelNameRE)
+			}
+		}
+
+		for _, label := range currentMapping.DropLabels {
+			if !m.validLabelName(label) {
+				return nil, fmt.Errorf("dropped label name '%s' in mapping %s doesn't match regex '%s'", label, currentMapping.Match, labelNameRE)
+			}
+			if _, ok := currentMapping.Labels[label]; ok {
+				return nil, fmt.Errorf("label %s in mapping %s is both set and dropped", label, currentMapping.Match)
+			}
+		}
+
+		for from, to := range n.Defaults.RenameLabels {
+			if currentMapping.RenameLabels == nil {
+				currentMapping.RenameLabels = map[string]string{}
+			}
+			if _, ok := currentMapping.RenameLabels[from]; !ok {
+				currentMapping.RenameLabels[from] = to
+			}
+		}
+		if err := m.validateRenameLabels(currentMapping.RenameLabels, "mapping "+currentMapping.Match); err != nil {
+			return nil, err
 		}
 
 		if currentMapping.MatchType == "" {
@@ -138,16 +232,57 @@ func (m *MetricMapper) InitFromYAMLString(fileContents string) error {
 			currentMapping.Action = ActionTypeMap
 		}
 
+		if currentMapping.Priority == "" {
+			currentMapping.Priority = PriorityNormal
+		}
+
+		if currentMapping.MatchType != MatchTypeGlob && currentMapping.globOptionsSet() {
+			return nil, fmt.Errorf("case_insensitive and match_anywhere are only supported by glob matches, in mapping %s", currentMapping.Match)
+		}
+
+		if currentMapping.CounterWindow < 0 {
+			return nil, fmt.Errorf("negative counter_window in mapping %s", currentMapping.Match)
+		}
+		if currentMapping.CounterWindow > 0 && currentMapping.MatchMetricType != "" && currentMapping.MatchMetricType != MetricTypeCounter {
+			return nil, fmt.Errorf("counter_window only applies to counters, but mapping %s matches %s metrics", currentMapping.Match, currentMapping.MatchMetricType)
+		}
+
+		if currentMapping.SparseScrapes < 0 {
+			return nil, fmt.Errorf("negative sparse_scrapes in mapping %s", currentMapping.Match)
+		}
+
+		if currentMapping.MaxIncrement.Set && currentMapping.MaxIncrement.Val <= 0 {
+			return nil, fmt.Errorf("max_increment in mapping %s must be positive", currentMapping.Match)
+		}
+		if currentMapping.MaxIncrement.Set && currentMapping.MatchMetricType != "" && currentMapping.MatchMetricType != MetricTypeCounter {
+			return nil, fmt.Errorf("max_increment only applies to counters, but mapping %s matches %s metrics", currentMapping.Match, currentMapping.MatchMetricType)
+		}
+
 		if currentMapping.MatchType == MatchTypeGlob {
-			n.doFSM = true
 			if !metricLineRE.MatchString(currentMapping.Match) {
-				return fmt.Errorf("invalid match: %s", currentMapping.Match)
+				return nil, fmt.Errorf("invalid match: %s", currentMapping.Match)
 			}
 
-			captureCount := n.FSM.AddState(currentMapping.Match, string(currentMapping.MatchMetricType),
-				remainingMappingsCount, currentMapping)
+			var captureCount int
+			if currentMapping.globOptionsSet() {
+				regex, err := compileGlob(currentMapping.Match, currentMapping.CaseInsensitive, currentMapping.MatchAnywhere)
+				if err != nil {
+					return nil, fmt.Errorf("invalid match %s in mapping: %v", currentMapping.Match, err)
+				}
+				currentMapping.regex = regex
+				captureCount = globCaptureCount(currentMapping.Match)
+				n.doRegex = true
+			} else {
+				captureCount = n.FSM.AddState(currentMapping.Match, string(currentMapping.MatchMetricType),
+					remainingMappingsCount, currentMapping)
+				n.doFSM = true
+			}
 
 			currentMapping.nameFormatter = fsm.NewTemplateFormatter(currentMapping.Name, captureCount)
+			for j := range currentMapping.PreviousNames {
+				previous := &currentMapping.PreviousNames[j]
+				previous.nameFormatter = fsm.NewTemplateFormatter(previous.Name, captureCount)
+			}
 
 			labelKeys := make([]string, len(currentMapping.Labels))
 			labelFormatters := make([]*fsm.TemplateFormatter, len(currentMapping.Labels))
@@ -161,7 +296,7 @@ func (m *MetricMapper) InitFromYAMLString(fileContents string) error {
 			currentMapping.labelKeys = labelKeys
 		} else {
 			if regex, err := regexp.Compile(currentMapping.Match); err != nil {
-				return fmt.Errorf("invalid regex %s in mapping: %v", currentMapping.Match, err)
+				return nil, fmt.Errorf("invalid regex %s in mapping: %v", currentMapping.Match, err)
 			} else {
 				currentMapping.regex = regex
 			}
@@ -170,6 +305,7 @@ func (m *MetricMapper) InitFromYAMLString(fileContents string) error {
 
 		if currentMapping.ObserverType == "" {
 			currentMapping.ObserverType = n.Defaults.ObserverType
+			currentMapping.observerTypeInherited = true
 		}
 
 		if currentMapping.LegacyQuantiles != nil &&
@@ -185,18 +321,18 @@ func (m *MetricMapper) InitFromYAMLString(fileContents string) error {
 		if currentMapping.SummaryOptions != nil &&
 			currentMapping.LegacyQuantiles != nil &&
 			currentMapping.SummaryOptions.Quantiles != nil {
-			return fmt.Errorf("cannot use quantiles in both the top level and summary options at the same time in %s", currentMapping.Match)
+			return nil, fmt.Errorf("cannot use quantiles in both the top level and summary options at the same time in %s", currentMapping.Match)
 		}
 
 		if currentMapping.HistogramOptions != nil &&
 			currentMapping.LegacyBuckets != nil &&
 			currentMapping.HistogramOptions.Buckets != nil {
-			return fmt.Errorf("cannot use buckets in both the top level and histogram options at the same time in %s", currentMapping.Match)
+			return nil, fmt.Errorf("cannot use buckets in both the top level and histogram options at the same time in %s", currentMapping.Match)
 		}
 
-		if currentMapping.ObserverType == ObserverTypeHistogram {
-			if currentMapping.SummaryOptions != nil {
-				return fmt.Errorf("cannot use histogram observer and summary options at the same time")
+		if currentMapping.ObserverType == ObserverTypeHistogram || currentMapping.ObserverType == ObserverTypeBoth {
+			if currentMapping.ObserverType == ObserverTypeHistogram && currentMapping.SummaryOptions != nil {
+				return nil, fmt.Errorf("cannot use histogram observer and summary options at the same time")
 			}
 			if currentMapping.HistogramOptions == nil {
 				currentMapping.HistogramOptions = &HistogramOptions{}
@@ -209,9 +345,9 @@ func (m *MetricMapper) InitFromYAMLString(fileContents string) error {
 			}
 		}
 
-		if currentMapping.ObserverType == ObserverTypeSummary {
-			if currentMapping.HistogramOptions != nil {
-				return fmt.Errorf("cannot use summary observer and histogram options at the same time")
+		if currentMapping.ObserverType == ObserverTypeSummary || currentMapping.ObserverType == ObserverTypeBoth {
+			if currentMapping.ObserverType == ObserverTypeSummary && currentMapping.HistogramOptions != nil {
+				return nil, fmt.Errorf("cannot use summary observer and histogram options at the same time")
 			}
 			if currentMapping.SummaryOptions == nil {
 				currentMapping.SummaryOptions = &SummaryOptions{}
@@ -233,11 +369,68 @@ func (m *MetricMapper) InitFromYAMLString(fileContents string) error {
 			}
 		}
 
-		if currentMapping.Ttl == 0 && n.Defaults.Ttl > 0 {
-			currentMapping.Ttl = n.Defaults.Ttl
+		// Mappings without a ttl inherit the default for the metric type,
+		// which is only known once an event is mapped.
+		if currentMapping.Ttl == 0 && currentMapping.MatchMetricType != "" {
+			currentMapping.Ttl = n.Defaults.TtlFor(currentMapping.MatchMetricType)
+		}
+	}
+
+	if n.doFSM {
+		logger := m.Logger
+		if logger == nil {
+			logger = promslog.NewNopLogger()
+		}
+		var mappings []string
+		for _, mapping := range n.Mappings {
+			if mapping.MatchType == MatchTypeGlob && mapping.regex == nil {
+				mappings = append(mappings, mapping.Match)
+			}
+		}
+		n.FSM.BacktrackingNeeded = fsm.TestIfNeedBacktracking(mappings, n.FSM.OrderingDisabled, logger)
+	}
+
+	return &n, nil
+}
+
+// validMetricName reports whether name is a valid metric name, which may
+// contain templates.
+func (m *MetricMapper) validMetricName(name string) bool {
+	if m.UTF8Names {
+		return name != "" && utf8.ValidString(name)
+	}
+	return metricNameRE.MatchString(name)
+}
+
+// validLabelName reports whether name is a valid label name.
+func (m *MetricMapper) validLabelName(name string) bool {
+	if m.UTF8Names {
+		return name != "" && utf8.ValidString(name)
+	}
+	return labelNameRE.MatchString(name)
+}
+
+// validateRenameLabels checks that label renames are between valid label
+// names, and that no two labels are renamed to the same name.
+func (m *MetricMapper) validateRenameLabels(renames map[string]string, where string) error {
+	targets := map[string]string{}
+	for from, to := range renames {
+		if other, ok := targets[to]; ok {
+			return fmt.Errorf("labels %s and %s in %s are both renamed to %s", other, from, where, to)
+		}
+		targets[to] = from
+		if !m.validLabelName(from) {
+			return fmt.Errorf("renamed label name '%s' in %s doesn't match regex '%s'", from, where, labelNameRE)
+		}
+		if !m.validLabelName(to) {
+			return fmt.Errorf("label %s in %s is renamed to '%s', which doesn't match regex '%s'", from, where, to, labelNameRE)
 		}
 	}
+	return nil
+}
 
+// apply replaces the configuration of m with the one compiled into n.
+func (m *MetricMapper) apply(n *MetricMapper) {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
@@ -247,6 +440,9 @@ func (m *MetricMapper) InitFromYAMLString(fileContents string) error {
 
 	m.Defaults = n.Defaults
 	m.Mappings = n.Mappings
+	m.DerivedMetrics = n.DerivedMetrics
+	m.BucketSets = n.BucketSets
+	m.configHash = n.configHash
 
 	// Reset the cache since this function can be used to reload config
 	if m.cache != nil {
@@ -254,24 +450,21 @@ func (m *MetricMapper) InitFromYAMLString(fileContents string) error {
 	}
 
 	if n.doFSM {
-		var mappings []string
-		for _, mapping := range n.Mappings {
-			if mapping.MatchType == MatchTypeGlob {
-				mappings = append(mappings, mapping.Match)
-			}
-		}
-		n.FSM.BacktrackingNeeded = fsm.TestIfNeedBacktracking(mappings, n.FSM.OrderingDisabled, m.Logger)
-
 		m.FSM = n.FSM
-		m.doRegex = n.doRegex
 	}
 	m.doFSM = n.doFSM
+	m.doRegex = n.doRegex
 
 	if m.MappingsCount != nil {
 		m.MappingsCount.Set(float64(len(n.Mappings)))
 	}
+}
 
-	return nil
+// ConfigHash returns the SHA-256 hash of the applied configuration, in hex.
+func (m *MetricMapper) ConfigHash() string {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.configHash
 }
 
 func (m *MetricMapper) InitFromFile(fileName string) error {
@@ -291,19 +484,51 @@ func (m *MetricMapper) UseCache(cache MetricMapperCache) {
 	m.cache = cache
 }
 
+// ResetCache empties the mapping cache, if there is one.
+func (m *MetricMapper) ResetCache() {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	if m.cache != nil {
+		m.cache.Reset()
+	}
+}
+
 func (m *MetricMapper) GetMapping(statsdMetric string, statsdMetricType MetricType) (*MetricMapping, prometheus.Labels, bool) {
 	m.mutex.RLock()
 	defer m.mutex.RUnlock()
 
+	if mapping, labels, present, ok := m.getMappingFast(statsdMetric, statsdMetricType); ok {
+		return mapping, labels, present
+	}
+	return m.getRegexMapping(statsdMetric, statsdMetricType)
+}
+
+// GetMappingFast looks up the mapping for a metric like GetMapping, but only
+// if that doesn't involve evaluating regex mappings, which can be expensive.
+// This is the case if the result is cached, the metric matches a glob
+// mapping, or there are no regex mappings. Otherwise ok is false, and the
+// mapping has to be looked up with GetMapping.
+func (m *MetricMapper) GetMappingFast(statsdMetric string, statsdMetricType MetricType) (mapping *MetricMapping, labels prometheus.Labels, present bool, ok bool) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return m.getMappingFast(statsdMetric, statsdMetricType)
+}
+
+func (m *MetricMapper) getMappingFast(statsdMetric string, statsdMetricType MetricType) (*MetricMapping, prometheus.Labels, bool, bool) {
 	// only use a cache if one is present
 	if m.cache != nil {
 		result, cached := m.cache.Get(formatKey(statsdMetric, statsdMetricType))
 		if cached {
 			r := result.(MetricMapperCacheResult)
-			return r.Mapping, r.Labels, r.Matched
+			return r.Mapping, r.Labels, r.Matched, true
 		}
 	}
 
+	if m.Sample != nil {
+		m.Sample.Add(statsdMetric, statsdMetricType)
+	}
+
 	// glob matching
 	if m.doFSM {
 		finalState, captures := m.FSM.GetMapping(statsdMetric, string(statsdMetricType))
@@ -311,6 +536,17 @@ func (m *MetricMapper) GetMapping(statsdMetric string, statsdMetricType MetricTy
 			v := finalState.Result.(*MetricMapping)
 			result := copyMetricMapping(v)
 			result.Name = result.nameFormatter.Format(captures)
+			if len(result.PreviousNames) > 0 {
+				previousNames := make([]MetricRename, len(result.PreviousNames))
+				for i, previous := range result.PreviousNames {
+					previous.Name = previous.nameFormatter.Format(captures)
+					previousNames[i] = previous
+				}
+				result.PreviousNames = previousNames
+			}
+			if result.Ttl == 0 {
+				result.Ttl = m.Defaults.TtlFor(statsdMetricType)
+			}
 
 			labels := prometheus.Labels{}
 			for index, formatter := range result.labelFormatters {
@@ -327,17 +563,22 @@ func (m *MetricMapper) GetMapping(statsdMetric string, statsdMetricType MetricTy
 				m.cache.Add(formatKey(statsdMetric, statsdMetricType), r)
 			}
 
-			return result, labels, true
-		} else if !m.doRegex {
-			// if there's no regex match type, return immediately
-			// Add miss to cache
-			if m.cache != nil {
-				m.cache.Add(formatKey(statsdMetric, statsdMetricType), MetricMapperCacheResult{})
-			}
-			return nil, nil, false
+			return result, labels, true, true
 		}
 	}
 
+	if !m.doRegex {
+		// if there's no regex match type, return immediately
+		// Add miss to cache
+		if m.cache != nil {
+			m.cache.Add(formatKey(statsdMetric, statsdMetricType), MetricMapperCacheResult{})
+		}
+		return nil, nil, false, true
+	}
+	return nil, nil, false, false
+}
+
+func (m *MetricMapper) getRegexMapping(statsdMetric string, statsdMetricType MetricType) (*MetricMapping, prometheus.Labels, bool) {
 	// regex matching
 	for _, mapping := range m.Mappings {
 		// if a rule don't have regex matching type, the regex field is unset
@@ -349,21 +590,51 @@ func (m *MetricMapper) GetMapping(statsdMetric string, statsdMetricType MetricTy
 			continue
 		}
 
-		mapping.Name = string(mapping.regex.ExpandString(
-			[]byte{},
-			mapping.Name,
-			statsdMetric,
-			matches,
-		))
+		// glob matches with options are formatted like in the FSM
+		var captures []string
+		if mapping.nameFormatter != nil {
+			captures = globCaptures(statsdMetric, matches)
+			mapping.Name = mapping.nameFormatter.Format(captures)
+		} else {
+			mapping.Name = string(mapping.regex.ExpandString(
+				[]byte{},
+				mapping.Name,
+				statsdMetric,
+				matches,
+			))
+		}
 
 		if mt := mapping.MatchMetricType; mt != "" && mt != statsdMetricType {
 			continue
 		}
 
+		if len(mapping.PreviousNames) > 0 {
+			previousNames := make([]MetricRename, len(mapping.PreviousNames))
+			for i, previous := range mapping.PreviousNames {
+				if captures != nil {
+					previous.Name = previous.nameFormatter.Format(captures)
+				} else {
+					previous.Name = string(mapping.regex.ExpandString([]byte{}, previous.Name, statsdMetric, matches))
+				}
+				previousNames[i] = previous
+			}
+			mapping.PreviousNames = previousNames
+		}
+
+		if mapping.Ttl == 0 {
+			mapping.Ttl = m.Defaults.TtlFor(statsdMetricType)
+		}
+
 		labels := prometheus.Labels{}
-		for label, valueExpr := range mapping.Labels {
-			value := mapping.regex.ExpandString([]byte{}, valueExpr, statsdMetric, matches)
-			labels[label] = string(value)
+		if captures != nil {
+			for index, formatter := range mapping.labelFormatters {
+				labels[mapping.labelKeys[index]] = formatter.Format(captures)
+			}
+		} else {
+			for label, valueExpr := range mapping.Labels {
+				value := mapping.regex.ExpandString([]byte{}, valueExpr, statsdMetric, matches)
+				labels[label] = string(value)
+			}
 		}
 
 		r := MetricMapperCacheResult{
@@ -20,8 +20,26 @@ type MapperConfigDefaults struct {
 	MatchType           MatchType        `yaml:"match_type"`
 	GlobDisableOrdering bool             `yaml:"glob_disable_ordering"`
 	Ttl                 time.Duration    `yaml:"ttl"`
+	CounterTtl          time.Duration    `yaml:"counter_ttl"`
+	GaugeTtl            time.Duration    `yaml:"gauge_ttl"`
+	ObserverTtl         time.Duration    `yaml:"observer_ttl"`
 	SummaryOptions      SummaryOptions   `yaml:"summary_options"`
 	HistogramOptions    HistogramOptions `yaml:"histogram_options"`
+	// OTelSemanticConventions maps the metrics of the OpenTelemetry semantic
+	// conventions that no configured mapping matches.
+	OTelSemanticConventions bool `yaml:"otel_semantic_conventions"`
+	// RenameLabels renames the labels of all metrics, from the keys to the
+	// values, before the labels of the mapping are added.
+	RenameLabels map[string]string `yaml:"rename_labels"`
+	// HistogramUnit is the unit the values of histograms and distributions
+	// are sent in.
+	HistogramUnit HistogramUnit `yaml:"histogram_unit"`
+	// TimerObserverType, HistogramObserverType and DistributionObserverType
+	// override ObserverType for values sent as timers ("ms"), histograms
+	// ("h") and distributions ("d").
+	TimerObserverType        ObserverType `yaml:"timer_observer_type"`
+	HistogramObserverType    ObserverType `yaml:"histogram_observer_type"`
+	DistributionObserverType ObserverType `yaml:"distribution_observer_type"`
 }
 
 // mapperConfigDefaultsAlias is used to unmarshal the yaml config into mapperConfigDefaults and allows deprecated fields
@@ -33,8 +51,19 @@ type mapperConfigDefaultsAlias struct {
 	MatchType           MatchType         `yaml:"match_type"`
 	GlobDisableOrdering bool              `yaml:"glob_disable_ordering"`
 	Ttl                 time.Duration     `yaml:"ttl"`
+	CounterTtl          time.Duration     `yaml:"counter_ttl"`
+	GaugeTtl            time.Duration     `yaml:"gauge_ttl"`
+	ObserverTtl         time.Duration     `yaml:"observer_ttl"`
 	SummaryOptions      SummaryOptions    `yaml:"summary_options"`
 	HistogramOptions    HistogramOptions  `yaml:"histogram_options"`
+
+	OTelSemanticConventions bool              `yaml:"otel_semantic_conventions"`
+	RenameLabels            map[string]string `yaml:"rename_labels"`
+	HistogramUnit           HistogramUnit     `yaml:"histogram_unit"`
+
+	TimerObserverType        ObserverType `yaml:"timer_observer_type"`
+	HistogramObserverType    ObserverType `yaml:"histogram_observer_type"`
+	DistributionObserverType ObserverType `yaml:"distribution_observer_type"`
 }
 
 // UnmarshalYAML is a custom unmarshal function to allow use of deprecated config keys
@@ -50,8 +79,17 @@ func (d *MapperConfigDefaults) UnmarshalYAML(unmarshal func(interface{}) error)
 	d.MatchType = tmp.MatchType
 	d.GlobDisableOrdering = tmp.GlobDisableOrdering
 	d.Ttl = tmp.Ttl
+	d.CounterTtl = tmp.CounterTtl
+	d.GaugeTtl = tmp.GaugeTtl
+	d.ObserverTtl = tmp.ObserverTtl
 	d.SummaryOptions = tmp.SummaryOptions
 	d.HistogramOptions = tmp.HistogramOptions
+	d.OTelSemanticConventions = tmp.OTelSemanticConventions
+	d.RenameLabels = tmp.RenameLabels
+	d.HistogramUnit = tmp.HistogramUnit
+	d.TimerObserverType = tmp.TimerObserverType
+	d.HistogramObserverType = tmp.HistogramObserverType
+	d.DistributionObserverType = tmp.DistributionObserverType
 
 	// Use deprecated TimerType if necessary
 	if tmp.ObserverType == "" {
@@ -70,3 +108,44 @@ func (d *MapperConfigDefaults) UnmarshalYAML(unmarshal func(interface{}) error)
 
 	return nil
 }
+
+// TtlFor returns the default TTL for metrics of the given type. The
+// type-specific default takes precedence over the global one.
+func (d *MapperConfigDefaults) TtlFor(metricType MetricType) time.Duration {
+	var ttl time.Duration
+	switch metricType {
+	case MetricTypeCounter:
+		ttl = d.CounterTtl
+	case MetricTypeGauge:
+		ttl = d.GaugeTtl
+	case MetricTypeObserver:
+		ttl = d.ObserverTtl
+	}
+	if ttl == 0 {
+		return d.Ttl
+	}
+	return ttl
+}
+
+// ObserverTypeFor returns the observer type for the values of mapping that
+// were sent with the given StatsD type, "ms", "h" or "d". An observer type
+// set on the mapping takes precedence, followed by the default for the StatsD
+// type and finally the global default.
+func (d *MapperConfigDefaults) ObserverTypeFor(mapping *MetricMapping, statsdType string) ObserverType {
+	if mapping != nil && mapping.ObserverType != ObserverTypeDefault && !mapping.observerTypeInherited {
+		return mapping.ObserverType
+	}
+	var t ObserverType
+	switch statsdType {
+	case "ms":
+		t = d.TimerObserverType
+	case "h":
+		t = d.HistogramObserverType
+	case "d":
+		t = d.DistributionObserverType
+	}
+	if t == ObserverTypeDefault {
+		return d.ObserverType
+	}
+	return t
+}
@@ -14,6 +14,8 @@
 package mapper
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -36,6 +38,7 @@ type mappings []struct {
 	bufCap       uint32
 	buckets      []float64
 	scale        MaybeFloat64
+	priority     Priority
 }
 
 func newTestMapperWithCache(cacheType string, size int) *MetricMapper {
@@ -1060,6 +1063,33 @@ mappings:
 				},
 			},
 		},
+		{
+			testName: "Config with histogram and summary options for both observer types",
+			config: `---
+mappings:
+- match: test.*.*
+  observer_type: both
+  name: "foo"
+  labels: {}
+  histogram_options:
+    buckets: [0.1, 1, 10]
+  summary_options:
+    quantiles:
+      - quantile: 0.42
+        error: 0.04
+`,
+			mappings: mappings{
+				{
+					statsdMetric: "test.*.*",
+					name:         "foo",
+					labels:       map[string]string{},
+					buckets:      []float64{0.1, 1, 10},
+					quantiles: []MetricObjective{
+						{Quantile: 0.42, Error: 0.04},
+					},
+				},
+			},
+		},
 		{
 			testName: "Config with default histogram options",
 			config: `---
@@ -1481,6 +1511,63 @@ mappings:
 				},
 			},
 		},
+		{
+			testName: "Config that has per-type default ttls",
+			config: `defaults:
+  ttl: 1m2s
+  gauge_ttl: 30s
+  observer_ttl: 10m
+mappings:
+- match: web.*
+  name: "web_requests"
+  match_metric_type: counter
+- match: web.*
+  name: "web_connections"
+  match_metric_type: gauge
+- match: web.*
+  name: "web_latency"
+  match_metric_type: observer
+- match: api\.(.*)
+  match_type: regex
+  name: "api_connections"
+  match_metric_type: gauge
+- match: db.*
+  name: "db_connections"
+  match_metric_type: gauge
+  ttl: 5s`,
+			mappings: mappings{
+				{
+					statsdMetric: "web.localhost",
+					name:         "web_requests",
+					metricType:   MetricTypeCounter,
+					ttl:          time.Minute + time.Second*2,
+				},
+				{
+					statsdMetric: "web.localhost",
+					name:         "web_connections",
+					metricType:   MetricTypeGauge,
+					ttl:          time.Second * 30,
+				},
+				{
+					statsdMetric: "web.localhost",
+					name:         "web_latency",
+					metricType:   MetricTypeObserver,
+					ttl:          time.Minute * 10,
+				},
+				{
+					statsdMetric: "api.localhost",
+					name:         "api_connections",
+					metricType:   MetricTypeGauge,
+					ttl:          time.Second * 30,
+				},
+				{
+					statsdMetric: "db.localhost",
+					name:         "db_connections",
+					metricType:   MetricTypeGauge,
+					ttl:          time.Second * 5,
+				},
+			},
+		},
 		{
 			testName: "Config with 'scale' field",
 			config: `mappings:
@@ -1529,6 +1616,459 @@ mappings:
 				},
 			},
 		},
+		{
+			testName: "Config with rollups",
+			config: `mappings:
+- match: request.*.*
+  name: requests_total
+  labels:
+    host: "$1"
+    code: "$2"
+  rollups:
+  - name: requests_by_code_total
+    drop_labels: [host]`,
+			mappings: mappings{
+				{
+					statsdMetric: "request.web01.200",
+					name:         "requests_total",
+					labels: map[string]string{
+						"host": "web01",
+						"code": "200",
+					},
+				},
+			},
+		},
+		{
+			testName: "Config with hashed labels",
+			config: `mappings:
+- match: session.*
+  name: sessions_total
+  labels:
+    user_id: "$1"
+  hash_labels: [user_id]`,
+			mappings: mappings{
+				{
+					statsdMetric: "session.alice",
+					name:         "sessions_total",
+					labels: map[string]string{
+						"user_id": "alice",
+					},
+				},
+			},
+		},
+		{
+			testName: "Config with priorities",
+			config: `mappings:
+- match: checkout.*
+  name: checkouts_total
+  priority: critical
+- match: debug.*
+  name: debug_timer
+  priority: debug
+- match: web.*
+  name: web_requests_total`,
+			mappings: mappings{
+				{
+					statsdMetric: "checkout.ok",
+					name:         "checkouts_total",
+					priority:     PriorityCritical,
+				},
+				{
+					statsdMetric: "debug.render",
+					name:         "debug_timer",
+					priority:     PriorityDebug,
+				},
+				{
+					statsdMetric: "web.index",
+					name:         "web_requests_total",
+					priority:     PriorityNormal,
+				},
+				{
+					statsdMetric: "unmapped",
+					notPresent:   true,
+					priority:     PriorityNormal,
+				},
+			},
+		},
+		{
+			testName: "Config with bad priority",
+			config: `mappings:
+- match: checkout.*
+  name: checkouts_total
+  priority: urgent`,
+			configBad: true,
+		},
+		{
+			testName: "Config with derived metrics",
+			config: `mappings:
+- match: cache.*.hit
+  name: cache_hits_total
+derived_metrics:
+- name: cache_hit_ratio
+  op: divide
+  left: cache_hits_total
+  right: cache_requests_total
+  by: [cache]`,
+			mappings: mappings{
+				{
+					statsdMetric: "cache.users.hit",
+					name:         "cache_hits_total",
+				},
+			},
+		},
+		{
+			testName: "Config with bad derived metric op",
+			config: `derived_metrics:
+- name: cache_hit_ratio
+  op: modulo
+  left: cache_hits_total
+  right: cache_requests_total`,
+			configBad: true,
+		},
+		{
+			testName: "Config with derived metric without right operand",
+			config: `derived_metrics:
+- name: cache_hit_ratio
+  op: divide
+  left: cache_hits_total`,
+			configBad: true,
+		},
+		{
+			testName: "Config with bad derived metric name",
+			config: `derived_metrics:
+- name: cache-hit-ratio
+  op: divide
+  left: cache_hits_total
+  right: cache_requests_total`,
+			configBad: true,
+		},
+		{
+			testName: "Config with bucket sets",
+			config: `mappings:
+- match: request.*
+  name: request_duration_seconds
+  observer_type: histogram
+bucket_sets:
+  sets:
+    web: [0.01, 0.1, 1]
+    batch: [1, 10, 100]`,
+			mappings: mappings{
+				{
+					statsdMetric: "request.login",
+					name:         "request_duration_seconds",
+				},
+			},
+		},
+		{
+			testName: "Config with unordered bucket set",
+			config: `bucket_sets:
+  sets:
+    web: [1, 0.1]`,
+			configBad: true,
+		},
+		{
+			testName: "Config with empty bucket set",
+			config: `bucket_sets:
+  sets:
+    web: []`,
+			configBad: true,
+		},
+		{
+			testName: "Config with bad bucket hint label",
+			config: `bucket_sets:
+  label: bucket-set
+  sets:
+    web: [0.1, 1]`,
+			configBad: true,
+		},
+		{
+			testName: "Config with bad hashed label name",
+			config: `mappings:
+- match: session.*
+  name: sessions_total
+  hash_labels: [user-id]`,
+			configBad: true,
+		},
+		{
+			testName: "Config with bad dropped label name",
+			config: `mappings:
+- match: request.*
+  name: requests_total
+  drop_labels: [host-name]`,
+			configBad: true,
+		},
+		{
+			testName: "Config dropping a label it sets",
+			config: `mappings:
+- match: request.*
+  name: requests_total
+  labels:
+    host: "$1"
+  drop_labels: [host]`,
+			configBad: true,
+		},
+		{
+			testName: "Config with bad rollup name",
+			config: `mappings:
+- match: request.*.*
+  name: requests_total
+  labels:
+    host: "$1"
+  rollups:
+  - name: requests-by-code
+    drop_labels: [host]`,
+			configBad: true,
+		},
+		{
+			testName: "Config with rollup without dropped labels",
+			config: `mappings:
+- match: request.*.*
+  name: requests_total
+  rollups:
+  - name: requests_by_code_total`,
+			configBad: true,
+		},
+		{
+			testName: "Config with previous names",
+			config: `mappings:
+- match: request.*
+  name: http_requests_total
+  labels:
+    code: "$1"
+  previous_names:
+  - name: requests_${1}_total
+    until: 2026-12-01`,
+			mappings: mappings{
+				{
+					statsdMetric: "request.200",
+					name:         "http_requests_total",
+					labels: map[string]string{
+						"code": "200",
+					},
+				},
+			},
+		},
+		{
+			testName: "Config with previous name without until",
+			config: `mappings:
+- match: request.*
+  name: http_requests_total
+  previous_names:
+  - name: requests_total`,
+			configBad: true,
+		},
+		{
+			testName: "Config with bad previous name",
+			config: `mappings:
+- match: request.*
+  name: http_requests_total
+  previous_names:
+  - name: requests-total
+    until: 2026-12-01`,
+			configBad: true,
+		},
+		{
+			testName: "Config with case insensitive glob",
+			config: `mappings:
+- match: API.*.requests
+  name: api_requests_total
+  case_insensitive: true
+  labels:
+    handler: "$1"
+- match: api.*.errors
+  name: api_errors_total`,
+			mappings: mappings{
+				{
+					statsdMetric: "api.Users.Requests",
+					name:         "api_requests_total",
+					labels: map[string]string{
+						"handler": "Users",
+					},
+				},
+				{
+					statsdMetric: "Api.users.errors",
+					name:         "api_requests_total",
+					notPresent:   true,
+				},
+				{
+					statsdMetric: "api.users.errors",
+					name:         "api_errors_total",
+				},
+			},
+		},
+		{
+			testName: "Config with glob matching anywhere",
+			config: `mappings:
+- match: api.*
+  name: api_total
+  match_anywhere: true
+  case_insensitive: true
+  labels:
+    handler: "$1"`,
+			mappings: mappings{
+				{
+					statsdMetric: "api.users",
+					name:         "api_total",
+					labels: map[string]string{
+						"handler": "users",
+					},
+				},
+				{
+					statsdMetric: "API.users.requests",
+					name:         "api_total",
+					labels: map[string]string{
+						"handler": "users",
+					},
+				},
+				{
+					statsdMetric: "prod.Api.orders",
+					name:         "api_total",
+					labels: map[string]string{
+						"handler": "orders",
+					},
+				},
+				{
+					statsdMetric: "rapi.users",
+					notPresent:   true,
+				},
+				{
+					statsdMetric: "api",
+					notPresent:   true,
+				},
+			},
+		},
+		{
+			testName: "Config with glob options on a regex match",
+			config: `mappings:
+- match: api\.(.*)
+  match_type: regex
+  name: api_total
+  case_insensitive: true`,
+			configBad: true,
+		},
+		{
+			testName: "Config with a negative counter window",
+			config: `mappings:
+- match: hot.*
+  name: hot_total
+  counter_window: -1s`,
+			configBad: true,
+		},
+		{
+			testName: "Config with a counter window for gauges",
+			config: `mappings:
+- match: hot.*
+  name: hot
+  match_metric_type: gauge
+  counter_window: 10s`,
+			configBad: true,
+		},
+		{
+			testName: "Config with an invalid histogram unit",
+			config: `mappings:
+- match: latency.*
+  name: latency_seconds
+  histogram_unit: minutes`,
+			configBad: true,
+		},
+		{
+			testName: "Config with negative sparse scrapes",
+			config: `mappings:
+- match: batch.*
+  name: batch_records
+  sparse_scrapes: -1`,
+			configBad: true,
+		},
+		{
+			testName: "Config with a negative max increment",
+			config: `mappings:
+- match: requests.*
+  name: requests_total
+  max_increment: -1`,
+			configBad: true,
+		},
+		{
+			testName: "Config with a max increment for observers",
+			config: `mappings:
+- match: requests.*
+  name: request_duration_seconds
+  match_metric_type: observer
+  max_increment: 1000`,
+			configBad: true,
+		},
+		{
+			testName: "Config with an invalid label rename",
+			config: `mappings:
+- match: requests.*
+  name: requests_total
+  rename_labels:
+    svc: service-name`,
+			configBad: true,
+		},
+		{
+			testName: "Config with labels renamed to the same name",
+			config: `defaults:
+  rename_labels:
+    svc: service
+mappings:
+- match: requests.*
+  name: requests_total
+  rename_labels:
+    srv: service`,
+			configBad: true,
+		},
+		{
+			testName: "Config with OpenTelemetry semantic conventions",
+			config: `defaults:
+  otel_semantic_conventions: true
+mappings:
+- match: http.server.active_requests
+  name: active_requests
+  match_metric_type: gauge`,
+			mappings: mappings{
+				{
+					statsdMetric: "http.server.request.duration",
+					name:         "http_server_request_duration_seconds",
+					metricType:   MetricTypeObserver,
+					buckets:      otelDurationBuckets,
+				},
+				{
+					statsdMetric: "http.server.duration",
+					name:         "http_server_duration_seconds",
+					metricType:   MetricTypeObserver,
+				},
+				{
+					statsdMetric: "http.server.response.body.size",
+					name:         "http_server_response_body_size_bytes",
+					metricType:   MetricTypeObserver,
+					buckets:      prometheus.DefBuckets,
+				},
+				{
+					statsdMetric: "messaging.client.sent.messages",
+					name:         "messaging_client_sent_messages_total",
+					metricType:   MetricTypeCounter,
+				},
+				{
+					statsdMetric: "process.cpu.time",
+					name:         "process_cpu_time_seconds_total",
+					metricType:   MetricTypeCounter,
+				},
+				{
+					statsdMetric: "system.cpu.utilization",
+					name:         "system_cpu_utilization_ratio",
+					metricType:   MetricTypeGauge,
+				},
+				{
+					statsdMetric: "http.server.active_requests",
+					name:         "active_requests",
+					metricType:   MetricTypeGauge,
+				},
+				{
+					statsdMetric: "http.server.request.duration",
+					notPresent:   true,
+				},
+			},
+		},
 	}
 
 	mapper := MetricMapper{}
@@ -1573,6 +2113,9 @@ mappings:
 				if mapping.ttl > 0 && mapping.ttl != m.Ttl {
 					t.Fatalf("%d.%q: Expected ttl of %s, got %s", i, metric, mapping.ttl.String(), m.Ttl.String())
 				}
+				if mapping.priority != "" && mapping.priority != mapper.PriorityOf(mapping.statsdMetric, mapType) {
+					t.Fatalf("%d.%q: Expected priority %s, got %s", i, metric, mapping.priority, mapper.PriorityOf(mapping.statsdMetric, mapType))
+				}
 				if mapping.metricType != "" && mapType != m.MatchMetricType {
 					t.Fatalf("%d.%q: Expected match metric of %s, got %s", i, metric, mapType, m.MatchMetricType)
 				}
@@ -1760,3 +2303,87 @@ mappings:
 		}
 	}
 }
+
+func TestUTF8Names(t *testing.T) {
+	config := `mappings:
+- match: "api.*.requests"
+  name: "http.requests"
+  labels:
+    service.name: "$1"`
+
+	legacy := MetricMapper{}
+	if err := legacy.InitFromYAMLString(config); err == nil {
+		t.Fatalf("expected dotted names to be rejected without UTF-8 names")
+	}
+
+	mapper := MetricMapper{UTF8Names: true}
+	if err := mapper.InitFromYAMLString(config); err != nil {
+		t.Fatal(err)
+	}
+	m, labels, ok := mapper.GetMapping("api.users.requests", MetricTypeCounter)
+	if !ok || m.Name != "http.requests" || labels["service.name"] != "users" {
+		t.Fatalf("unexpected mapping %v with labels %v", m, labels)
+	}
+}
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	writeConfig := func(name, config string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(config), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	mapper := newTestMapperWithCache("lru", 1000)
+	mapper.Sample = NewNameSample(10)
+	var compiles, validations int
+	mapper.CompileDuration = prometheus.ObserverFunc(func(float64) { compiles++ })
+	mapper.ValidationDuration = prometheus.ObserverFunc(func(float64) { validations++ })
+
+	err := mapper.InitFromYAMLString(`mappings:
+- match: (.*)\.requests
+  match_type: regex
+  name: ${1}_requests_total`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m, _, ok := mapper.GetMapping("api.requests", MetricTypeCounter); !ok || m.Name != "api_requests_total" {
+		t.Fatalf("unexpected mapping %v", m)
+	}
+	hash := mapper.ConfigHash()
+
+	// The second capture doesn't exist, so the sampled name would be mapped
+	// to an empty metric name.
+	bad := writeConfig("bad.yml", `mappings:
+- match: (.*)\.requests
+  match_type: regex
+  name: ${2}`)
+	if err := mapper.Reload(bad); err == nil {
+		t.Fatalf("expected configuration that fails validation to be rejected")
+	}
+	if m, _, ok := mapper.GetMapping("api.requests", MetricTypeCounter); !ok || m.Name != "api_requests_total" {
+		t.Fatalf("expected previous configuration to be kept, got %v", m)
+	}
+	if mapper.ConfigHash() != hash {
+		t.Fatalf("expected hash of previous configuration to be kept")
+	}
+
+	good := writeConfig("good.yml", `mappings:
+- match: (.*)\.requests
+  match_type: regex
+  name: ${1}_http_requests_total`)
+	if err := mapper.Reload(good); err != nil {
+		t.Fatal(err)
+	}
+	if m, _, ok := mapper.GetMapping("api.requests", MetricTypeCounter); !ok || m.Name != "api_http_requests_total" {
+		t.Fatalf("expected new configuration to be applied, got %v", m)
+	}
+	if h := mapper.ConfigHash(); h == hash || len(h) != 64 {
+		t.Fatalf("expected hash of new configuration, got %q", h)
+	}
+	if compiles != 2 || validations != 2 {
+		t.Fatalf("expected 2 compiles and validations, got %d and %d", compiles, validations)
+	}
+}
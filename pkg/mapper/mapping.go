@@ -43,6 +43,58 @@ type MetricMapping struct {
 	SummaryOptions   *SummaryOptions   `yaml:"summary_options"`
 	HistogramOptions *HistogramOptions `yaml:"histogram_options"`
 	Scale            MaybeFloat64      `yaml:"scale"`
+	Rollups          []MetricRollup    `yaml:"rollups"`
+	PreviousNames    []MetricRename    `yaml:"previous_names"`
+	HashLabels       []string          `yaml:"hash_labels"`
+	DropLabels       []string          `yaml:"drop_labels"`
+	Priority         Priority          `yaml:"priority"`
+	// CaseInsensitive and MatchAnywhere only apply to glob matches.
+	CaseInsensitive bool `yaml:"case_insensitive"`
+	MatchAnywhere   bool `yaml:"match_anywhere"`
+	// CounterWindow, if set, accumulates the increments of counters and
+	// only applies their sum once per window.
+	CounterWindow time.Duration `yaml:"counter_window"`
+	// MaxIncrement, if set, is the largest increment a single event may
+	// apply to a counter. Larger increments are dropped as suspect.
+	MaxIncrement MaybeFloat64 `yaml:"max_increment"`
+	// RenameLabels renames the labels of events, from the keys to the
+	// values. The renames of the defaults apply unless the mapping renames
+	// the same label.
+	RenameLabels map[string]string `yaml:"rename_labels"`
+	// SparseScrapes, if set, exports each series only for this many
+	// successful scrapes after it was last updated, and then removes it,
+	// regardless of the ttl.
+	SparseScrapes int `yaml:"sparse_scrapes"`
+	// HistogramUnit overrides the unit of the histogram and distribution
+	// values of the defaults.
+	HistogramUnit HistogramUnit `yaml:"histogram_unit"`
+
+	// observerTypeInherited is set if the observer type was copied from
+	// the defaults, and is overridden by the defaults for StatsD types.
+	observerTypeInherited bool
+}
+
+// MetricRollup additionally exports a mapped metric under a different name,
+// aggregated over all labels except DropLabels.
+type MetricRollup struct {
+	Name       string   `yaml:"name"`
+	DropLabels []string `yaml:"drop_labels"`
+}
+
+// MetricRename additionally exports a mapped metric under the name it had
+// before the mapping was changed, until the given time. This lets dashboards
+// and alerts move to the new name while both are exported. The name can use
+// the same captures as the name of the mapping.
+type MetricRename struct {
+	Name          string    `yaml:"name"`
+	Until         time.Time `yaml:"until"`
+	nameFormatter *fsm.TemplateFormatter
+}
+
+// Active reports whether the metric is still exported under the previous
+// name at time t.
+func (r MetricRename) Active(t time.Time) bool {
+	return t.Before(r.Until)
 }
 
 // UnmarshalYAML is a custom unmarshal function to allow use of deprecated config keys
@@ -70,6 +122,18 @@ func (m *MetricMapping) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	m.SummaryOptions = tmp.SummaryOptions
 	m.HistogramOptions = tmp.HistogramOptions
 	m.Scale = tmp.Scale
+	m.Rollups = tmp.Rollups
+	m.PreviousNames = tmp.PreviousNames
+	m.HashLabels = tmp.HashLabels
+	m.DropLabels = tmp.DropLabels
+	m.Priority = tmp.Priority
+	m.CaseInsensitive = tmp.CaseInsensitive
+	m.MatchAnywhere = tmp.MatchAnywhere
+	m.CounterWindow = tmp.CounterWindow
+	m.MaxIncrement = tmp.MaxIncrement
+	m.RenameLabels = tmp.RenameLabels
+	m.SparseScrapes = tmp.SparseScrapes
+	m.HistogramUnit = tmp.HistogramUnit
 
 	// Use deprecated TimerType if necessary
 	if tmp.ObserverType == "" {
@@ -21,6 +21,7 @@ const (
 	MetricTypeCounter  MetricType = "counter"
 	MetricTypeGauge    MetricType = "gauge"
 	MetricTypeObserver MetricType = "observer"
+	MetricTypeSet      MetricType = "set"
 	MetricTypeTimer    MetricType = "timer" // DEPRECATED
 )
 
@@ -39,6 +40,8 @@ func (m *MetricType) UnmarshalYAML(unmarshal func(interface{}) error) error {
 		*m = MetricTypeObserver
 	case MetricTypeTimer:
 		*m = MetricTypeObserver
+	case MetricTypeSet:
+		*m = MetricTypeSet
 	default:
 		return fmt.Errorf("invalid metric type '%s'", v)
 	}
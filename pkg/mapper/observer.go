@@ -20,9 +20,19 @@ type ObserverType string
 const (
 	ObserverTypeHistogram ObserverType = "histogram"
 	ObserverTypeSummary   ObserverType = "summary"
-	ObserverTypeDefault   ObserverType = ""
+	// ObserverTypeBoth exports a histogram, and a summary with the suffix
+	// "_summary", from the same observations.
+	ObserverTypeBoth ObserverType = "both"
+	// ObserverTypeDigest exports gauges with the quantiles of the values
+	// observed in an interval, estimated with a t-digest.
+	ObserverTypeDigest  ObserverType = "digest"
+	ObserverTypeDefault ObserverType = ""
 )
 
+// SummarySuffix is appended to the name of the summary exported next to the
+// histogram for ObserverTypeBoth.
+const SummarySuffix = "_summary"
+
 func (t *ObserverType) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	var v string
 	if err := unmarshal(&v); err != nil {
@@ -32,6 +42,10 @@ func (t *ObserverType) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	switch ObserverType(v) {
 	case ObserverTypeHistogram:
 		*t = ObserverTypeHistogram
+	case ObserverTypeBoth:
+		*t = ObserverTypeBoth
+	case ObserverTypeDigest:
+		*t = ObserverTypeDigest
 	case ObserverTypeSummary, ObserverTypeDefault:
 		*t = ObserverTypeSummary
 	default:
@@ -39,3 +53,32 @@ func (t *ObserverType) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	}
 	return nil
 }
+
+// HistogramUnit is the unit the values of StatsD histograms and distributions
+// are sent in. Timers are always sent in milliseconds, and converted to
+// seconds.
+type HistogramUnit string
+
+const (
+	// HistogramUnitBase values are observed as they are sent.
+	HistogramUnitBase HistogramUnit = "base"
+	// HistogramUnitMilliseconds values are converted to seconds like the
+	// values of timers.
+	HistogramUnitMilliseconds HistogramUnit = "milliseconds"
+	HistogramUnitDefault      HistogramUnit = ""
+)
+
+func (u *HistogramUnit) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var v string
+	if err := unmarshal(&v); err != nil {
+		return err
+	}
+
+	switch HistogramUnit(v) {
+	case HistogramUnitBase, HistogramUnitMilliseconds, HistogramUnitDefault:
+		*u = HistogramUnit(v)
+	default:
+		return fmt.Errorf("invalid histogram unit '%s'", v)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mapper
+
+import "strings"
+
+// otelInstrument is the kind of OpenTelemetry instrument a semantic
+// convention metric is recorded with.
+type otelInstrument int
+
+const (
+	otelCounter otelInstrument = iota
+	otelUpDownCounter
+	otelGauge
+	otelHistogram
+)
+
+// otelMetric is a metric defined by the OpenTelemetry semantic conventions.
+type otelMetric struct {
+	name       string
+	unit       string
+	instrument otelInstrument
+}
+
+// otelDurationBuckets are the bucket boundaries the semantic conventions
+// recommend for durations in seconds.
+var otelDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.075, 0.1, 0.25, 0.5, 0.75, 1, 2.5, 5, 7.5, 10}
+
+// otelMetrics are the semantic convention metrics that are mapped when
+// otel_semantic_conventions is enabled. Durations in milliseconds come from
+// older versions of the conventions.
+var otelMetrics = []otelMetric{
+	{"http.server.request.duration", "s", otelHistogram},
+	{"http.server.duration", "ms", otelHistogram},
+	{"http.server.active_requests", "{request}", otelUpDownCounter},
+	{"http.server.request.body.size", "By", otelHistogram},
+	{"http.server.response.body.size", "By", otelHistogram},
+	{"http.client.request.duration", "s", otelHistogram},
+	{"http.client.duration", "ms", otelHistogram},
+	{"http.client.request.body.size", "By", otelHistogram},
+	{"http.client.response.body.size", "By", otelHistogram},
+	{"rpc.server.duration", "ms", otelHistogram},
+	{"rpc.client.duration", "ms", otelHistogram},
+	{"db.client.operation.duration", "s", otelHistogram},
+	{"db.client.connection.count", "{connection}", otelUpDownCounter},
+	{"messaging.client.operation.duration", "s", otelHistogram},
+	{"messaging.client.sent.messages", "{message}", otelCounter},
+	{"messaging.client.consumed.messages", "{message}", otelCounter},
+	{"process.cpu.time", "s", otelCounter},
+	{"process.memory.usage", "By", otelUpDownCounter},
+	{"system.cpu.utilization", "1", otelGauge},
+	{"system.memory.usage", "By", otelUpDownCounter},
+	{"jvm.memory.used", "By", otelUpDownCounter},
+	{"jvm.gc.duration", "s", otelHistogram},
+	{"jvm.thread.count", "{thread}", otelUpDownCounter},
+}
+
+// otelUnitSuffixes are the suffixes of Prometheus metric names for the units
+// of semantic convention metrics. Annotations in braces, such as {request},
+// don't add a suffix. Milliseconds become seconds, because StatsD timers are
+// converted to seconds.
+var otelUnitSuffixes = map[string]string{
+	"s":  "seconds",
+	"ms": "seconds",
+	"By": "bytes",
+	"1":  "ratio",
+}
+
+// prometheusName translates the name of a semantic convention metric the way
+// the OpenTelemetry Prometheus exporter does: dots become underscores, the
+// unit is appended, and counters end in _total.
+func (o otelMetric) prometheusName() string {
+	name := strings.ReplaceAll(o.name, ".", "_")
+	if suffix, ok := otelUnitSuffixes[o.unit]; ok && !strings.HasSuffix(name, "_"+suffix) {
+		name += "_" + suffix
+	}
+	if o.instrument == otelCounter {
+		name += "_total"
+	}
+	return name
+}
+
+// mapping returns a glob mapping of the metric to its Prometheus name.
+// Histograms are exported as Prometheus histograms, and durations use the
+// recommended buckets.
+func (o otelMetric) mapping() MetricMapping {
+	m := MetricMapping{
+		Match:    o.name,
+		Name:     o.prometheusName(),
+		HelpText: "OpenTelemetry semantic convention metric " + o.name + ".",
+	}
+	switch o.instrument {
+	case otelCounter:
+		m.MatchMetricType = MetricTypeCounter
+	case otelUpDownCounter, otelGauge:
+		m.MatchMetricType = MetricTypeGauge
+	case otelHistogram:
+		m.MatchMetricType = MetricTypeObserver
+		m.ObserverType = ObserverTypeHistogram
+		if o.unit == "s" || o.unit == "ms" {
+			m.HistogramOptions = &HistogramOptions{Buckets: otelDurationBuckets}
+		}
+	}
+	return m
+}
+
+// otelMappings returns the mappings of all known semantic convention
+// metrics. They are added after the configured mappings, which therefore
+// take precedence.
+func otelMappings() []MetricMapping {
+	mappings := make([]MetricMapping, 0, len(otelMetrics))
+	for _, o := range otelMetrics {
+		mappings = append(mappings, o.mapping())
+	}
+	return mappings
+}
@@ -0,0 +1,57 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mapper
+
+import "fmt"
+
+// Priority decides which events are shed first when the exporter can't keep
+// up with incoming events.
+type Priority string
+
+const (
+	PriorityCritical Priority = "critical"
+	PriorityNormal   Priority = "normal"
+	PriorityDebug    Priority = "debug"
+	PriorityDefault  Priority = ""
+)
+
+func (p *Priority) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var v string
+
+	if err := unmarshal(&v); err != nil {
+		return err
+	}
+
+	switch Priority(v) {
+	case PriorityCritical:
+		*p = PriorityCritical
+	case PriorityDebug:
+		*p = PriorityDebug
+	case PriorityNormal, PriorityDefault:
+		*p = PriorityNormal
+	default:
+		return fmt.Errorf("invalid priority %q", v)
+	}
+	return nil
+}
+
+// PriorityOf returns the priority of the mapping matching a metric. Metrics
+// without a mapping have normal priority.
+func (m *MetricMapper) PriorityOf(statsdMetric string, statsdMetricType MetricType) Priority {
+	mapping, _, present := m.GetMapping(statsdMetric, statsdMetricType)
+	if !present || mapping.Priority == PriorityDefault {
+		return PriorityNormal
+	}
+	return mapping.Priority
+}
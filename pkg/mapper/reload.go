@@ -0,0 +1,134 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mapper
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// NameSample is a sample of the metric names the mapper recently evaluated.
+// Reload validates new configurations against it, and warms the cache with
+// it after applying them.
+type NameSample struct {
+	names []atomic.Pointer[sampledName]
+	next  atomic.Uint64
+}
+
+type sampledName struct {
+	name       string
+	metricType MetricType
+}
+
+// NewNameSample returns a sample of the size most recently evaluated names.
+func NewNameSample(size int) *NameSample {
+	return &NameSample{names: make([]atomic.Pointer[sampledName], size)}
+}
+
+// Add records an evaluated metric name, replacing the oldest one once the
+// sample is full.
+func (s *NameSample) Add(name string, metricType MetricType) {
+	if len(s.names) == 0 {
+		return
+	}
+	i := (s.next.Add(1) - 1) % uint64(len(s.names))
+	if prev := s.names[i].Load(); prev != nil && prev.name == name && prev.metricType == metricType {
+		return
+	}
+	s.names[i].Store(&sampledName{name: name, metricType: metricType})
+}
+
+func (s *NameSample) sampled() []sampledName {
+	var names []sampledName
+	seen := make(map[sampledName]struct{}, len(s.names))
+	for i := range s.names {
+		n := s.names[i].Load()
+		if n == nil {
+			continue
+		}
+		if _, ok := seen[*n]; ok {
+			continue
+		}
+		seen[*n] = struct{}{}
+		names = append(names, *n)
+	}
+	return names
+}
+
+// Reload compiles the mapping configuration in fileName, validates it against
+// the sampled names and applies it. Lookups continue with the previous
+// configuration until the new one is applied, and the sampled names are
+// mapped again right after, so that they are served from the cache instead
+// of all being evaluated on the hot path at once.
+func (m *MetricMapper) Reload(fileName string) error {
+	m.reloadMutex.Lock()
+	defer m.reloadMutex.Unlock()
+
+	contents, err := os.ReadFile(fileName)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	n, err := m.compile(string(contents))
+	if m.CompileDuration != nil {
+		m.CompileDuration.Observe(time.Since(start).Seconds())
+	}
+	if err != nil {
+		return err
+	}
+
+	var names []sampledName
+	if m.Sample != nil {
+		names = m.Sample.sampled()
+	}
+	start = time.Now()
+	err = m.validate(n, names)
+	if m.ValidationDuration != nil {
+		m.ValidationDuration.Observe(time.Since(start).Seconds())
+	}
+	if err != nil {
+		return err
+	}
+
+	m.apply(n)
+	for _, s := range names {
+		m.GetMapping(s.name, s.metricType)
+	}
+	return nil
+}
+
+// validate maps the sampled names with the compiled mapper n, and fails if a
+// mapping would generate an empty metric name for any of them, which the
+// exporter rejects.
+func (m *MetricMapper) validate(n *MetricMapper, names []sampledName) error {
+	changed := 0
+	for _, s := range names {
+		mapping, _, present := n.GetMapping(s.name, s.metricType)
+		if present && mapping.Action != ActionTypeDrop && mapping.Name == "" {
+			return fmt.Errorf("mapping %s generates an empty metric name for %s", mapping.Match, s.name)
+		}
+
+		previous, _, previousPresent := m.GetMapping(s.name, s.metricType)
+		if present != previousPresent || (present && mapping.Name != previous.Name) {
+			changed++
+		}
+	}
+	if len(names) > 0 && m.Logger != nil {
+		m.Logger.Info("Validated mapping configuration", "sampled_names", len(names), "changed_names", changed)
+	}
+	return nil
+}
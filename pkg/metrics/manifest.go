@@ -0,0 +1,109 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// ManifestEntry describes a metric family.
+type ManifestEntry struct {
+	Name   string   `json:"name"`
+	Type   string   `json:"type"`
+	Help   string   `json:"help"`
+	Labels []string `json:"labels,omitempty"`
+}
+
+// Manifest creates and registers metrics like promauto, and records them, so
+// that they can be listed with their type and help before they have a value.
+type Manifest struct {
+	reg     prometheus.Registerer
+	factory promauto.Factory
+	mutex   sync.Mutex
+	entries []ManifestEntry
+}
+
+// SelfMetrics is the manifest of the metrics the exporter exposes about
+// itself. Its metrics are registered with the default registerer.
+var SelfMetrics = NewManifest(prometheus.DefaultRegisterer)
+
+// NewManifest returns a manifest registering metrics with reg.
+func NewManifest(reg prometheus.Registerer) *Manifest {
+	return &Manifest{reg: reg, factory: promauto.With(reg)}
+}
+
+func (m *Manifest) record(namespace, subsystem, name, metricType, help string, labels []string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.entries = append(m.entries, ManifestEntry{
+		Name:   prometheus.BuildFQName(namespace, subsystem, name),
+		Type:   metricType,
+		Help:   help,
+		Labels: labels,
+	})
+}
+
+// Entries returns the recorded metric families, sorted by name.
+func (m *Manifest) Entries() []ManifestEntry {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	entries := append([]ManifestEntry(nil), m.entries...)
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries
+}
+
+func (m *Manifest) NewCounter(opts prometheus.CounterOpts) prometheus.Counter {
+	m.record(opts.Namespace, opts.Subsystem, opts.Name, "counter", opts.Help, nil)
+	return m.factory.NewCounter(opts)
+}
+
+// NewShardedCounter creates and registers a ShardedCounter, for counters
+// incremented by many goroutines at once.
+func (m *Manifest) NewShardedCounter(opts prometheus.CounterOpts) *ShardedCounter {
+	m.record(opts.Namespace, opts.Subsystem, opts.Name, "counter", opts.Help, nil)
+	c := NewShardedCounter(opts)
+	if m.reg != nil {
+		m.reg.MustRegister(c)
+	}
+	return c
+}
+
+func (m *Manifest) NewCounterVec(opts prometheus.CounterOpts, labelNames []string) *prometheus.CounterVec {
+	m.record(opts.Namespace, opts.Subsystem, opts.Name, "counter", opts.Help, labelNames)
+	return m.factory.NewCounterVec(opts, labelNames)
+}
+
+func (m *Manifest) NewGauge(opts prometheus.GaugeOpts) prometheus.Gauge {
+	m.record(opts.Namespace, opts.Subsystem, opts.Name, "gauge", opts.Help, nil)
+	return m.factory.NewGauge(opts)
+}
+
+func (m *Manifest) NewGaugeVec(opts prometheus.GaugeOpts, labelNames []string) *prometheus.GaugeVec {
+	m.record(opts.Namespace, opts.Subsystem, opts.Name, "gauge", opts.Help, labelNames)
+	return m.factory.NewGaugeVec(opts, labelNames)
+}
+
+func (m *Manifest) NewHistogram(opts prometheus.HistogramOpts) prometheus.Histogram {
+	m.record(opts.Namespace, opts.Subsystem, opts.Name, "histogram", opts.Help, nil)
+	return m.factory.NewHistogram(opts)
+}
+
+func (m *Manifest) NewHistogramVec(opts prometheus.HistogramOpts, labelNames []string) *prometheus.HistogramVec {
+	m.record(opts.Namespace, opts.Subsystem, opts.Name, "histogram", opts.Help, labelNames)
+	return m.factory.NewHistogramVec(opts, labelNames)
+}
@@ -64,4 +64,53 @@ type RegisteredMetric struct {
 	TTL              time.Duration
 	Metric           MetricHolder
 	VecKey           NameHash
+	// SparseScrapes is the number of scrapes after which the series is
+	// removed, or 0. ScrapesSeen counts the scrapes since it was last
+	// updated.
+	SparseScrapes int
+	ScrapesSeen   int
+}
+
+// TrackedGauge is a gauge that remembers the value it was last set to, so
+// that setting an unchanged value can be detected cheaply.
+type TrackedGauge struct {
+	prometheus.Gauge
+	last  float64
+	known bool
+}
+
+// Changed reports whether setting the gauge to v would change its value.
+func (g *TrackedGauge) Changed(v float64) bool {
+	return !g.known || g.last != v
+}
+
+func (g *TrackedGauge) Set(v float64) {
+	g.Gauge.Set(v)
+	g.last = v
+	g.known = true
+}
+
+func (g *TrackedGauge) Add(v float64) {
+	g.Gauge.Add(v)
+	g.known = false
+}
+
+func (g *TrackedGauge) Sub(v float64) {
+	g.Gauge.Sub(v)
+	g.known = false
+}
+
+func (g *TrackedGauge) Inc() {
+	g.Gauge.Inc()
+	g.known = false
+}
+
+func (g *TrackedGauge) Dec() {
+	g.Gauge.Dec()
+	g.known = false
+}
+
+func (g *TrackedGauge) SetToCurrentTime() {
+	g.Gauge.SetToCurrentTime()
+	g.known = false
 }
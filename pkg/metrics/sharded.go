@@ -0,0 +1,123 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"errors"
+	"math"
+	"math/rand/v2"
+	"runtime"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"golang.org/x/sys/cpu"
+)
+
+// ShardedCounter is a counter for the hottest paths, such as counting every
+// received line. A prometheus.Counter keeps its value in a single cache line,
+// which goroutines incrementing it on different CPUs take turns owning.
+// ShardedCounter spreads increments over shards on separate cache lines,
+// and only sums them up when it is collected.
+type ShardedCounter struct {
+	desc   *prometheus.Desc
+	shards []counterShard
+	mask   uint32
+}
+
+// counterShard holds integer increments and float increments separately,
+// like prometheus.Counter, so that Inc never has to retry. It is padded to
+// the cache line size of the architecture.
+type counterShard struct {
+	valInt  atomic.Uint64
+	valBits atomic.Uint64
+	_       cpu.CacheLinePad
+}
+
+// NewShardedCounter returns a counter with a shard for each CPU the Go
+// runtime may use, rounded up to a power of two.
+func NewShardedCounter(opts prometheus.CounterOpts) *ShardedCounter {
+	n := 1
+	for n < runtime.GOMAXPROCS(0) {
+		n *= 2
+	}
+	return &ShardedCounter{
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(opts.Namespace, opts.Subsystem, opts.Name),
+			opts.Help,
+			nil,
+			opts.ConstLabels,
+		),
+		shards: make([]counterShard, n),
+		mask:   uint32(n - 1),
+	}
+}
+
+// shard picks a shard at random. The runtime's random number generator is
+// per thread, so this doesn't contend either.
+func (c *ShardedCounter) shard() *counterShard {
+	return &c.shards[rand.Uint32()&c.mask]
+}
+
+func (c *ShardedCounter) Inc() {
+	c.shard().valInt.Add(1)
+}
+
+// Add panics if v is negative, like prometheus.Counter.
+func (c *ShardedCounter) Add(v float64) {
+	if v < 0 {
+		panic(errors.New("counter cannot decrease in value"))
+	}
+	s := c.shard()
+	if ival := uint64(v); float64(ival) == v {
+		s.valInt.Add(ival)
+		return
+	}
+	for {
+		oldBits := s.valBits.Load()
+		newBits := math.Float64bits(math.Float64frombits(oldBits) + v)
+		if s.valBits.CompareAndSwap(oldBits, newBits) {
+			return
+		}
+	}
+}
+
+// Value returns the sum of all shards.
+func (c *ShardedCounter) Value() float64 {
+	var ival uint64
+	var fval float64
+	for i := range c.shards {
+		ival += c.shards[i].valInt.Load()
+		fval += math.Float64frombits(c.shards[i].valBits.Load())
+	}
+	return float64(ival) + fval
+}
+
+func (c *ShardedCounter) Desc() *prometheus.Desc {
+	return c.desc
+}
+
+func (c *ShardedCounter) Write(m *dto.Metric) error {
+	return prometheus.MustNewConstMetric(c.desc, prometheus.CounterValue, c.Value()).Write(m)
+}
+
+func (c *ShardedCounter) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *ShardedCounter) Collect(ch chan<- prometheus.Metric) {
+	ch <- c
+}
+
+var _ prometheus.Counter = &ShardedCounter{}
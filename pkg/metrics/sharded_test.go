@@ -0,0 +1,73 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestShardedCounter(t *testing.T) {
+	c := NewShardedCounter(prometheus.CounterOpts{Name: "lines_total", Help: "Lines."})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				c.Inc()
+				c.Add(2)
+				c.Add(0.5)
+			}
+		}()
+	}
+	wg.Wait()
+
+	var m dto.Metric
+	if err := c.Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	if v := m.GetCounter().GetValue(); v != 8*1000*3.5 {
+		t.Fatalf("expected %v, got %v", 8*1000*3.5, v)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected adding a negative value to panic")
+		}
+	}()
+	c.Add(-1)
+}
+
+func BenchmarkCounterParallel(b *testing.B) {
+	for _, bc := range []struct {
+		name    string
+		counter prometheus.Counter
+	}{
+		{"prometheus", prometheus.NewCounter(prometheus.CounterOpts{Name: "lines_total", Help: "Lines."})},
+		{"sharded", NewShardedCounter(prometheus.CounterOpts{Name: "lines_total", Help: "Lines."})},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					bc.counter.Inc()
+				}
+			})
+		})
+	}
+}
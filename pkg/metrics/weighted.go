@@ -0,0 +1,153 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// WeightedObserver is an Observer that can also observe a value that stands
+// for several observations, such as a sampled StatsD timer.
+type WeightedObserver interface {
+	prometheus.Observer
+	ObserveWeighted(value float64, weight uint64)
+}
+
+// WeightedHistogram is a histogram with classic buckets that observes
+// weighted values arithmetically: a value observed with a weight of n updates
+// its bucket, the count and the sum once, as if it had been observed n times.
+// Values without a weight are observed by the embedded Histogram.
+type WeightedHistogram struct {
+	prometheus.Histogram
+	upperBounds []float64
+
+	mu     sync.Mutex
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func (h *WeightedHistogram) ObserveWeighted(v float64, weight uint64) {
+	if weight == 1 {
+		h.Histogram.Observe(v)
+		return
+	}
+	// Values above the highest bucket and NaN only count towards +Inf.
+	i := sort.SearchFloat64s(h.upperBounds, v)
+	h.mu.Lock()
+	if i < len(h.counts) {
+		h.counts[i] += weight
+	}
+	h.count += weight
+	h.sum += v * float64(weight)
+	h.mu.Unlock()
+}
+
+// Write writes the histogram with the weighted observations added.
+func (h *WeightedHistogram) Write(m *dto.Metric) error {
+	if err := h.Histogram.Write(m); err != nil {
+		return err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.count == 0 {
+		return nil
+	}
+	histogram := m.GetHistogram()
+	count := histogram.GetSampleCount() + h.count
+	sum := histogram.GetSampleSum() + h.sum
+	histogram.SampleCount, histogram.SampleSum = &count, &sum
+	var cumulative uint64
+	for i, bucket := range histogram.GetBucket() {
+		cumulative += h.counts[i]
+		c := bucket.GetCumulativeCount() + cumulative
+		bucket.CumulativeCount = &c
+	}
+	return nil
+}
+
+var _ WeightedObserver = &WeightedHistogram{}
+
+// WeightedHistogramVec is a vector of WeightedHistograms, partitioned by
+// label values like a prometheus.HistogramVec.
+type WeightedHistogramVec struct {
+	*prometheus.MetricVec
+}
+
+// NewWeightedHistogramVec returns a vector of weighted histograms with the
+// classic buckets of opts. Native histogram options are ignored. Like for a
+// prometheus.HistogramVec, the buckets must be in increasing order, and there
+// must be no "le" label.
+func NewWeightedHistogramVec(opts prometheus.HistogramOpts, labelNames []string) (*WeightedHistogramVec, error) {
+	upperBounds := opts.Buckets
+	if len(upperBounds) == 0 {
+		upperBounds = prometheus.DefBuckets
+	}
+	if n := len(upperBounds); n > 0 && math.IsInf(upperBounds[n-1], +1) {
+		upperBounds = upperBounds[:n-1]
+	}
+	for i := 1; i < len(upperBounds); i++ {
+		if upperBounds[i] <= upperBounds[i-1] {
+			return nil, fmt.Errorf("histogram buckets must be in increasing order: %v >= %v", upperBounds[i-1], upperBounds[i])
+		}
+	}
+	for _, name := range labelNames {
+		if name == "le" {
+			return nil, fmt.Errorf("histograms can't have a label named %q", name)
+		}
+	}
+	opts.NativeHistogramBucketFactor = 0
+
+	desc := prometheus.NewDesc(
+		prometheus.BuildFQName(opts.Namespace, opts.Subsystem, opts.Name),
+		opts.Help,
+		labelNames,
+		opts.ConstLabels,
+	)
+	return &WeightedHistogramVec{
+		MetricVec: prometheus.NewMetricVec(desc, func(lvs ...string) prometheus.Metric {
+			// The label values are already validated by the MetricVec, and
+			// become constant labels of the histogram of the series.
+			childOpts := opts
+			childOpts.ConstLabels = make(prometheus.Labels, len(opts.ConstLabels)+len(labelNames))
+			for name, value := range opts.ConstLabels {
+				childOpts.ConstLabels[name] = value
+			}
+			for i, name := range labelNames {
+				childOpts.ConstLabels[name] = lvs[i]
+			}
+			return &WeightedHistogram{
+				Histogram:   prometheus.NewHistogram(childOpts),
+				upperBounds: upperBounds,
+				counts:      make([]uint64, len(upperBounds)),
+			}
+		}),
+	}, nil
+}
+
+// GetMetricWith returns the histogram for the given labels, creating it if
+// it doesn't exist yet.
+func (v *WeightedHistogramVec) GetMetricWith(labels prometheus.Labels) (*WeightedHistogram, error) {
+	m, err := v.MetricVec.GetMetricWith(labels)
+	if err != nil {
+		return nil, err
+	}
+	return m.(*WeightedHistogram), nil
+}
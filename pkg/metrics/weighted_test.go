@@ -0,0 +1,90 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"math"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestWeightedHistogram(t *testing.T) {
+	opts := prometheus.HistogramOpts{Name: "latency", Help: "Latency.", Buckets: []float64{1, 2, 5, math.Inf(+1)}}
+	vec, err := NewWeightedHistogramVec(opts, []string{"path"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	weighted, err := vec.GetMetricWith(prometheus.Labels{"path": "/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	repeated := prometheus.NewHistogram(opts)
+
+	for _, o := range []struct {
+		value  float64
+		weight uint64
+	}{{0.5, 100}, {1, 1}, {2, 3}, {10, 2}} {
+		weighted.ObserveWeighted(o.value, o.weight)
+		for i := uint64(0); i < o.weight; i++ {
+			repeated.Observe(o.value)
+		}
+	}
+	weighted.Observe(3)
+	repeated.Observe(3)
+
+	var got, expected dto.Metric
+	if err := weighted.Write(&got); err != nil {
+		t.Fatal(err)
+	}
+	if err := repeated.Write(&expected); err != nil {
+		t.Fatal(err)
+	}
+	if n, sum := got.GetHistogram().GetSampleCount(), got.GetHistogram().GetSampleSum(); n != 107 || sum != 80 {
+		t.Fatalf("expected 107 observations with a sum of 80, got %d with a sum of %v", n, sum)
+	}
+	if len(got.GetHistogram().GetBucket()) != len(expected.GetHistogram().GetBucket()) {
+		t.Fatalf("expected buckets %v, got %v", expected.GetHistogram().GetBucket(), got.GetHistogram().GetBucket())
+	}
+	for i, b := range expected.GetHistogram().GetBucket() {
+		g := got.GetHistogram().GetBucket()[i]
+		if g.GetUpperBound() != b.GetUpperBound() || g.GetCumulativeCount() != b.GetCumulativeCount() {
+			t.Fatalf("expected bucket %v, got %v", b, g)
+		}
+	}
+	if got.GetLabel()[0].GetValue() != "/" {
+		t.Fatalf("expected the label values to be written, got %v", got.GetLabel())
+	}
+
+	if !vec.Delete(prometheus.Labels{"path": "/"}) {
+		t.Fatal("expected the histogram to be deleted")
+	}
+}
+
+func TestWeightedHistogramVecValidation(t *testing.T) {
+	for _, tc := range []struct {
+		buckets    []float64
+		labelNames []string
+	}{
+		{buckets: []float64{1, 1}},
+		{buckets: []float64{2, 1, math.Inf(+1)}},
+		{buckets: []float64{1, 2}, labelNames: []string{"le"}},
+	} {
+		opts := prometheus.HistogramOpts{Name: "latency", Help: "Latency.", Buckets: tc.buckets}
+		if _, err := NewWeightedHistogramVec(opts, tc.labelNames); err == nil {
+			t.Errorf("expected buckets %v with labels %v to be rejected", tc.buckets, tc.labelNames)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"hash/fnv"
+	"math"
+	"math/bits"
+	"sort"
+	"time"
+)
+
+// hllPrecision is the number of bits of a hash that select the register of a
+// HyperLogLog sketch. 2^12 registers estimate with a standard error of about
+// 1.6% in 4KiB.
+const hllPrecision = 12
+
+// hyperLogLog estimates the number of distinct values added to it.
+type hyperLogLog struct {
+	registers [1 << hllPrecision]uint8
+}
+
+func (h *hyperLogLog) add(value string) {
+	f := fnv.New64a()
+	f.Write([]byte(value))
+	x := Mix64(f.Sum64())
+	i := x >> (64 - hllPrecision)
+	rank := uint8(bits.LeadingZeros64(x<<hllPrecision|1<<(hllPrecision-1))) + 1
+	if rank > h.registers[i] {
+		h.registers[i] = rank
+	}
+}
+
+// count returns the estimated number of distinct values, using linear
+// counting for small cardinalities where the raw estimate is biased.
+func (h *hyperLogLog) count() uint64 {
+	const m = float64(len(h.registers))
+	sum, zeros := 0.0, 0
+	for _, r := range h.registers {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+	estimate := 0.7213 / (1 + 1.079/m) * m * m / sum
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return uint64(estimate + 0.5)
+}
+
+// Mix64 is the finalizer of SplitMix64, which spreads the bits of x. FNV
+// leaves the high bits of hashes of short, similar strings correlated, which
+// HyperLogLog and rendezvous hashing rely on.
+func Mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// LabelCardinality describes how a label name is used across the series of
+// the registry.
+type LabelCardinality struct {
+	Label string `json:"label"`
+	// Values is the estimated number of distinct values of the label.
+	Values uint64 `json:"values"`
+	// Series and MetricNames are the numbers of series and metric names
+	// that have the label.
+	Series      int `json:"series"`
+	MetricNames int `json:"metric_names"`
+	// Bytes is the total length of the values of the label over all series.
+	Bytes int `json:"bytes"`
+}
+
+// LabelCardinality returns the cardinality of every label name over the
+// series that were updated at or after t, ordered by decreasing number of
+// distinct values.
+func (r *Registry) LabelCardinality(t time.Time) []LabelCardinality {
+	type labelStats struct {
+		LabelCardinality
+		values   hyperLogLog
+		lastName string
+	}
+	stats := map[string]*labelStats{}
+	for metricName, metric := range r.Metrics {
+		for _, rm := range metric.Metrics {
+			if rm.LastRegisteredAt.Before(t) {
+				continue
+			}
+			for label, value := range rm.Labels {
+				s, ok := stats[label]
+				if !ok {
+					s = &labelStats{LabelCardinality: LabelCardinality{Label: label}}
+					stats[label] = s
+				}
+				s.values.add(value)
+				s.Series++
+				s.Bytes += len(value)
+				if s.lastName != metricName {
+					s.MetricNames++
+					s.lastName = metricName
+				}
+			}
+		}
+	}
+
+	result := make([]LabelCardinality, 0, len(stats))
+	for _, s := range stats {
+		// The estimate can't exceed the number of values it was built from.
+		s.LabelCardinality.Values = min(s.values.count(), uint64(s.Series))
+		result = append(result, s.LabelCardinality)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Values != result[j].Values {
+			return result[i].Values > result[j].Values
+		}
+		return result[i].Label < result[j].Label
+	})
+	return result
+}
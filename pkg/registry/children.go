@@ -0,0 +1,105 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+
+	"github.com/prometheus/statsd_exporter/pkg/clock"
+	"github.com/prometheus/statsd_exporter/pkg/metrics"
+)
+
+// childKey identifies a series by its metric name and the fingerprint of its
+// labels.
+type childKey struct {
+	metricName  string
+	fingerprint uint64
+}
+
+// cachedChild is a series in the child cache.
+type cachedChild struct {
+	metricType metrics.MetricType
+	rm         *metrics.RegisteredMetric
+}
+
+const (
+	offset64 = 14695981039346656037
+	prime64  = 1099511628211
+)
+
+// fingerprint returns a hash of labels that doesn't depend on the order of
+// the label names. Unlike HashLabels, it doesn't have to sort them, which
+// makes it cheap enough to compute for every event.
+func fingerprint(labels prometheus.Labels) uint64 {
+	var fp uint64
+	for name, value := range labels {
+		h := uint64(offset64)
+		for i := 0; i < len(name); i++ {
+			h ^= uint64(name[i])
+			h *= prime64
+		}
+		h ^= uint64(model.SeparatorByte)
+		h *= prime64
+		for i := 0; i < len(value); i++ {
+			h ^= uint64(value[i])
+			h *= prime64
+		}
+		fp ^= h
+	}
+	return fp
+}
+
+// cachedChild returns the existing series of the metric with the given name,
+// type and labels from the child cache, and marks it as updated like Get
+// does. Label sets whose fingerprints collide are told apart by comparing
+// the labels, so a collision only costs a cache miss.
+func (r *Registry) cachedChild(metricName string, metricType metrics.MetricType, labels prometheus.Labels) metrics.MetricHolder {
+	c, ok := r.children[childKey{metricName, fingerprint(labels)}]
+	if !ok || c.metricType != metricType || !equalLabels(c.rm.Labels, labels) {
+		return nil
+	}
+	c.rm.LastRegisteredAt = clock.Now()
+	c.rm.ScrapesSeen = 0
+	return c.rm.Metric
+}
+
+// cacheChild adds a series to the child cache.
+func (r *Registry) cacheChild(metricName string, metricType metrics.MetricType, rm *metrics.RegisteredMetric) {
+	if r.children == nil {
+		r.children = map[childKey]cachedChild{}
+	}
+	r.children[childKey{metricName, fingerprint(rm.Labels)}] = cachedChild{metricType: metricType, rm: rm}
+}
+
+// uncacheChild removes a series from the child cache, unless the cache holds
+// another series with the same fingerprint.
+func (r *Registry) uncacheChild(metricName string, rm *metrics.RegisteredMetric) {
+	key := childKey{metricName, fingerprint(rm.Labels)}
+	if c, ok := r.children[key]; ok && c.rm == rm {
+		delete(r.children, key)
+	}
+}
+
+func equalLabels(a, b prometheus.Labels) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, value := range a {
+		if v, ok := b[name]; !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
@@ -15,6 +15,7 @@ package registry
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"hash"
 	"hash/fnv"
@@ -23,6 +24,7 @@ import (
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/prometheus/common/model"
 
 	"github.com/prometheus/statsd_exporter/pkg/clock"
@@ -33,7 +35,8 @@ import (
 // uncheckedCollector wraps a Collector but its Describe method yields no Desc.
 // This allows incoming metrics to have inconsistent label sets
 type uncheckedCollector struct {
-	c prometheus.Collector
+	c    prometheus.Collector
+	name string
 }
 
 func (u uncheckedCollector) Describe(_ chan<- *prometheus.Desc) {}
@@ -41,6 +44,12 @@ func (u uncheckedCollector) Collect(c chan<- prometheus.Metric) {
 	u.c.Collect(c)
 }
 
+// MetricName returns the name of the metric family the collector collects,
+// which can't be taken from a Desc.
+func (u uncheckedCollector) MetricName() string {
+	return u.name
+}
+
 type Registry struct {
 	Registerer prometheus.Registerer
 	Metrics    map[string]metrics.Metric
@@ -50,8 +59,24 @@ type Registry struct {
 	// hash.
 	ValueBuf, NameBuf bytes.Buffer
 	Hasher            hash.Hash64
+	// labelNames is reused across calls to HashLabels for the same reason.
+	labelNames []string
+	// seriesPerMetric is the expected number of series per metric name,
+	// used to size new maps.
+	seriesPerMetric int
+	rejectNewSeries bool
+	// constLabels are added to every metric the registry creates.
+	constLabels prometheus.Labels
+	// children caches the series by metric name and label fingerprint, so
+	// that updating an existing series doesn't require hashing its sorted
+	// labels. Series are removed from it when they are removed from Metrics.
+	children map[childKey]cachedChild
 }
 
+// ErrNewSeriesRejected is returned for series that don't exist yet while new
+// series are rejected.
+var ErrNewSeriesRejected = errors.New("new series are rejected")
+
 func NewRegistry(reg prometheus.Registerer, mapper *mapper.MetricMapper) *Registry {
 	return &Registry{
 		Registerer: reg,
@@ -61,6 +86,69 @@ func NewRegistry(reg prometheus.Registerer, mapper *mapper.MetricMapper) *Regist
 	}
 }
 
+// Presize allocates room for the expected number of metric names and series,
+// for example from a previous run, to avoid growing maps while the exporter
+// is under load. It has no effect once metrics have been registered.
+func (r *Registry) Presize(metricNames, series int) {
+	if len(r.Metrics) > 0 || metricNames <= 0 {
+		return
+	}
+	r.Metrics = make(map[string]metrics.Metric, metricNames)
+	r.children = make(map[childKey]cachedChild, series)
+	r.seriesPerMetric = series / metricNames
+}
+
+// RejectNewSeries makes the registry return ErrNewSeriesRejected instead of
+// creating new series, while existing series can still be updated.
+func (r *Registry) RejectNewSeries(reject bool) {
+	r.rejectNewSeries = reject
+}
+
+// SetConstLabels sets labels that are added to every metric created from now
+// on, such as the attributes of the resource the exporter runs on. Unlike
+// wrapping the Registerer, this keeps the collectors registered with it
+// intact.
+func (r *Registry) SetConstLabels(labels prometheus.Labels) {
+	r.constLabels = labels
+}
+
+// checkConstLabels returns an error if labels would override a constant
+// label.
+func (r *Registry) checkConstLabels(labels prometheus.Labels) error {
+	for name := range labels {
+		if _, ok := r.constLabels[name]; ok {
+			return fmt.Errorf("label %s conflicts with a constant label", name)
+		}
+	}
+	return nil
+}
+
+// Size returns the number of metric names and series in the registry.
+func (r *Registry) Size() (metricNames, series int) {
+	for _, metric := range r.Metrics {
+		series += len(metric.Metrics)
+	}
+	return len(r.Metrics), series
+}
+
+// ActiveSince returns the number of metric names and series that were
+// updated at or after t.
+func (r *Registry) ActiveSince(t time.Time) (metricNames, series int) {
+	for _, metric := range r.Metrics {
+		active := 0
+		for _, rm := range metric.Metrics {
+			if !rm.LastRegisteredAt.Before(t) {
+				active++
+			}
+		}
+		if active > 0 {
+			metricNames++
+			series += active
+		}
+	}
+	return metricNames, series
+}
+
 func (r *Registry) MetricConflicts(metricName string, metricType metrics.MetricType) bool {
 	vector, hasMetrics := r.Metrics[metricName]
 	if !hasMetrics {
@@ -79,28 +167,28 @@ func (r *Registry) MetricConflicts(metricName string, metricType metrics.MetricT
 	return true
 }
 
-func (r *Registry) StoreCounter(metricName string, hash metrics.LabelHash, labels prometheus.Labels, vec *prometheus.CounterVec, c prometheus.Counter, ttl time.Duration) {
-	r.Store(metricName, hash, labels, vec, c, metrics.CounterMetricType, ttl)
+func (r *Registry) StoreCounter(metricName string, hash metrics.LabelHash, labels prometheus.Labels, vec *prometheus.CounterVec, c prometheus.Counter, ttl time.Duration, sparseScrapes int) {
+	r.Store(metricName, hash, labels, vec, c, metrics.CounterMetricType, ttl, sparseScrapes)
 }
 
-func (r *Registry) StoreGauge(metricName string, hash metrics.LabelHash, labels prometheus.Labels, vec *prometheus.GaugeVec, g prometheus.Gauge, ttl time.Duration) {
-	r.Store(metricName, hash, labels, vec, g, metrics.GaugeMetricType, ttl)
+func (r *Registry) StoreGauge(metricName string, hash metrics.LabelHash, labels prometheus.Labels, vec *prometheus.GaugeVec, g prometheus.Gauge, ttl time.Duration, sparseScrapes int) {
+	r.Store(metricName, hash, labels, vec, g, metrics.GaugeMetricType, ttl, sparseScrapes)
 }
 
-func (r *Registry) StoreHistogram(metricName string, hash metrics.LabelHash, labels prometheus.Labels, vec *prometheus.HistogramVec, o prometheus.Observer, ttl time.Duration) {
-	r.Store(metricName, hash, labels, vec, o, metrics.HistogramMetricType, ttl)
+func (r *Registry) StoreHistogram(metricName string, hash metrics.LabelHash, labels prometheus.Labels, vec metrics.VectorHolder, o prometheus.Observer, ttl time.Duration, sparseScrapes int) {
+	r.Store(metricName, hash, labels, vec, o, metrics.HistogramMetricType, ttl, sparseScrapes)
 }
 
-func (r *Registry) StoreSummary(metricName string, hash metrics.LabelHash, labels prometheus.Labels, vec *prometheus.SummaryVec, o prometheus.Observer, ttl time.Duration) {
-	r.Store(metricName, hash, labels, vec, o, metrics.SummaryMetricType, ttl)
+func (r *Registry) StoreSummary(metricName string, hash metrics.LabelHash, labels prometheus.Labels, vec *prometheus.SummaryVec, o prometheus.Observer, ttl time.Duration, sparseScrapes int) {
+	r.Store(metricName, hash, labels, vec, o, metrics.SummaryMetricType, ttl, sparseScrapes)
 }
 
-func (r *Registry) Store(metricName string, hash metrics.LabelHash, labels prometheus.Labels, vh metrics.VectorHolder, mh metrics.MetricHolder, metricType metrics.MetricType, ttl time.Duration) {
+func (r *Registry) Store(metricName string, hash metrics.LabelHash, labels prometheus.Labels, vh metrics.VectorHolder, mh metrics.MetricHolder, metricType metrics.MetricType, ttl time.Duration, sparseScrapes int) {
 	metric, hasMetrics := r.Metrics[metricName]
 	if !hasMetrics {
 		metric.MetricType = metricType
 		metric.Vectors = make(map[metrics.NameHash]*metrics.Vector)
-		metric.Metrics = make(map[metrics.ValueHash]*metrics.RegisteredMetric)
+		metric.Metrics = make(map[metrics.ValueHash]*metrics.RegisteredMetric, r.seriesPerMetric)
 
 		r.Metrics[metricName] = metric
 	}
@@ -120,14 +208,18 @@ func (r *Registry) Store(metricName string, hash metrics.LabelHash, labels prome
 			TTL:              ttl,
 			Metric:           mh,
 			VecKey:           hash.Names,
+			SparseScrapes:    sparseScrapes,
 		}
 		metric.Metrics[hash.Values] = rm
 		v.RefCount++
+		r.cacheChild(metricName, metricType, rm)
 		return
 	}
 	rm.LastRegisteredAt = now
+	rm.ScrapesSeen = 0
 	// Update ttl from mapping
 	rm.TTL = ttl
+	rm.SparseScrapes = sparseScrapes
 }
 
 func (r *Registry) Get(metricName string, hash metrics.LabelHash, metricType metrics.MetricType) (metrics.VectorHolder, metrics.MetricHolder) {
@@ -144,7 +236,8 @@ func (r *Registry) Get(metricName string, hash metrics.LabelHash, metricType met
 	if ok {
 		now := clock.Now()
 		rm.LastRegisteredAt = now
-		return metric.Vectors[hash.Names].Holder, rm.Metric
+		rm.ScrapesSeen = 0
+		return metric.Vectors[rm.VecKey].Holder, rm.Metric
 	}
 
 	vector, ok := metric.Vectors[hash.Names]
@@ -156,12 +249,19 @@ func (r *Registry) Get(metricName string, hash metrics.LabelHash, metricType met
 }
 
 func (r *Registry) GetCounter(metricName string, labels prometheus.Labels, help string, mapping *mapper.MetricMapping, metricsCount *prometheus.GaugeVec) (prometheus.Counter, error) {
+	if mh := r.cachedChild(metricName, metrics.CounterMetricType, labels); mh != nil {
+		return mh.(prometheus.Counter), nil
+	}
 	hash, labelNames := r.HashLabels(labels)
 	vh, mh := r.Get(metricName, hash, metrics.CounterMetricType)
 	if mh != nil {
 		return mh.(prometheus.Counter), nil
 	}
 
+	if r.rejectNewSeries {
+		return nil, ErrNewSeriesRejected
+	}
+
 	if r.MetricConflicts(metricName, metrics.CounterMetricType) {
 		return nil, fmt.Errorf("metric with name %s is already registered", metricName)
 	}
@@ -173,13 +273,17 @@ func (r *Registry) GetCounter(metricName string, labels prometheus.Labels, help
 
 	var counterVec *prometheus.CounterVec
 	if vh == nil {
+		if err := r.checkConstLabels(labels); err != nil {
+			return nil, err
+		}
 		metricsCount.WithLabelValues("counter").Inc()
 		counterVec = prometheus.NewCounterVec(prometheus.CounterOpts{
-			Name: metricName,
-			Help: help,
-		}, labelNames)
+			Name:        metricName,
+			Help:        help,
+			ConstLabels: r.constLabels,
+		}, copyLabelNames(labelNames))
 
-		if err := r.Registerer.Register(uncheckedCollector{counterVec}); err != nil {
+		if err := r.Registerer.Register(uncheckedCollector{c: counterVec, name: metricName}); err != nil {
 			return nil, err
 		}
 	} else {
@@ -190,7 +294,7 @@ func (r *Registry) GetCounter(metricName string, labels prometheus.Labels, help
 	if counter, err = counterVec.GetMetricWith(labels); err != nil {
 		return nil, err
 	}
-	r.StoreCounter(metricName, hash, labels, counterVec, counter, mapping.Ttl)
+	r.StoreCounter(metricName, hash, labels, counterVec, counter, mapping.Ttl, mapping.SparseScrapes)
 
 	return counter, nil
 }
@@ -208,12 +312,19 @@ func (r *Registry) checkHistogramNameCollision(metricName string) error {
 }
 
 func (r *Registry) GetGauge(metricName string, labels prometheus.Labels, help string, mapping *mapper.MetricMapping, metricsCount *prometheus.GaugeVec) (prometheus.Gauge, error) {
+	if mh := r.cachedChild(metricName, metrics.GaugeMetricType, labels); mh != nil {
+		return mh.(prometheus.Gauge), nil
+	}
 	hash, labelNames := r.HashLabels(labels)
 	vh, mh := r.Get(metricName, hash, metrics.GaugeMetricType)
 	if mh != nil {
 		return mh.(prometheus.Gauge), nil
 	}
 
+	if r.rejectNewSeries {
+		return nil, ErrNewSeriesRejected
+	}
+
 	if r.MetricConflicts(metricName, metrics.GaugeMetricType) {
 		return nil, fmt.Errorf("metrics.Metric with name %s is already registered", metricName)
 	}
@@ -225,13 +336,17 @@ func (r *Registry) GetGauge(metricName string, labels prometheus.Labels, help st
 
 	var gaugeVec *prometheus.GaugeVec
 	if vh == nil {
+		if err := r.checkConstLabels(labels); err != nil {
+			return nil, err
+		}
 		metricsCount.WithLabelValues("gauge").Inc()
 		gaugeVec = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-			Name: metricName,
-			Help: help,
-		}, labelNames)
+			Name:        metricName,
+			Help:        help,
+			ConstLabels: r.constLabels,
+		}, copyLabelNames(labelNames))
 
-		if err := r.Registerer.Register(uncheckedCollector{gaugeVec}); err != nil {
+		if err := r.Registerer.Register(uncheckedCollector{c: gaugeVec, name: metricName}); err != nil {
 			return nil, err
 		}
 	} else {
@@ -242,18 +357,36 @@ func (r *Registry) GetGauge(metricName string, labels prometheus.Labels, help st
 	if gauge, err = gaugeVec.GetMetricWith(labels); err != nil {
 		return nil, err
 	}
-	r.StoreGauge(metricName, hash, labels, gaugeVec, gauge, mapping.Ttl)
+	gauge = &metrics.TrackedGauge{Gauge: gauge}
+	r.StoreGauge(metricName, hash, labels, gaugeVec, gauge, mapping.Ttl, mapping.SparseScrapes)
 
 	return gauge, nil
 }
 
 func (r *Registry) GetHistogram(metricName string, labels prometheus.Labels, help string, mapping *mapper.MetricMapping, metricsCount *prometheus.GaugeVec) (prometheus.Observer, error) {
+	labels = r.Mapper.StripUnknownBucketHint(labels)
+	if mh := r.cachedChild(metricName, metrics.HistogramMetricType, labels); mh != nil {
+		return mh.(prometheus.Observer), nil
+	}
 	hash, labelNames := r.HashLabels(labels)
-	vh, mh := r.Get(metricName, hash, metrics.HistogramMetricType)
+	_, mh := r.Get(metricName, hash, metrics.HistogramMetricType)
 	if mh != nil {
 		return mh.(prometheus.Observer), nil
 	}
 
+	// The buckets of a series are fixed when it is created, so they are only
+	// resolved for new series.
+	buckets, bucketSet := r.Mapper.HistogramBuckets(mapping, labels)
+	if bucketSet != "" {
+		// Histograms with different buckets can't share a vector.
+		hash.Names ^= metrics.NameHash(hashString(r.Hasher, bucketSet))
+	}
+	vh, _ := r.Get(metricName, hash, metrics.HistogramMetricType)
+
+	if r.rejectNewSeries {
+		return nil, ErrNewSeriesRejected
+	}
+
 	if r.MetricConflicts(metricName, metrics.HistogramMetricType) {
 		return nil, fmt.Errorf("metrics.Metric with name %s is already registered", metricName)
 	}
@@ -267,14 +400,11 @@ func (r *Registry) GetHistogram(metricName string, labels prometheus.Labels, hel
 		return nil, fmt.Errorf("metrics.Metric with name %s is already registered", metricName)
 	}
 
-	var histogramVec *prometheus.HistogramVec
 	if vh == nil {
-		metricsCount.WithLabelValues("histogram").Inc()
-		buckets := r.Mapper.Defaults.HistogramOptions.Buckets
-		if mapping.HistogramOptions != nil && len(mapping.HistogramOptions.Buckets) > 0 {
-			buckets = mapping.HistogramOptions.Buckets
+		if err := r.checkConstLabels(labels); err != nil {
+			return nil, err
 		}
-
+		metricsCount.WithLabelValues("histogram").Inc()
 		bucketFactor := r.Mapper.Defaults.HistogramOptions.NativeHistogramBucketFactor
 		if mapping.HistogramOptions != nil && mapping.HistogramOptions.NativeHistogramBucketFactor > 0 {
 			bucketFactor = mapping.HistogramOptions.NativeHistogramBucketFactor
@@ -284,38 +414,64 @@ func (r *Registry) GetHistogram(metricName string, labels prometheus.Labels, hel
 		if mapping.HistogramOptions != nil && mapping.HistogramOptions.NativeHistogramMaxBuckets > 0 {
 			maxBuckets = mapping.HistogramOptions.NativeHistogramMaxBuckets
 		}
-		histogramVec = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		opts := prometheus.HistogramOpts{
 			Name:                           metricName,
 			Help:                           help,
 			Buckets:                        buckets,
 			NativeHistogramBucketFactor:    bucketFactor,
 			NativeHistogramMaxBucketNumber: maxBuckets,
-		}, labelNames)
+			ConstLabels:                    r.constLabels,
+		}
+		// Histograms with only classic buckets observe sampled values
+		// arithmetically. Native histograms, which are disabled by a bucket
+		// factor of at most 1, can't be weighted.
+		var collector prometheus.Collector
+		if bucketFactor > 1 {
+			histogramVec := prometheus.NewHistogramVec(opts, copyLabelNames(labelNames))
+			vh, collector = histogramVec, histogramVec
+		} else {
+			histogramVec, err := metrics.NewWeightedHistogramVec(opts, copyLabelNames(labelNames))
+			if err != nil {
+				return nil, err
+			}
+			vh, collector = histogramVec, histogramVec
+		}
 
-		if err := r.Registerer.Register(uncheckedCollector{histogramVec}); err != nil {
+		if err := r.Registerer.Register(uncheckedCollector{c: collector, name: metricName}); err != nil {
 			return nil, err
 		}
-	} else {
-		histogramVec = vh.(*prometheus.HistogramVec)
 	}
 
 	var observer prometheus.Observer
 	var err error
-	if observer, err = histogramVec.GetMetricWith(labels); err != nil {
+	switch histogramVec := vh.(type) {
+	case *prometheus.HistogramVec:
+		observer, err = histogramVec.GetMetricWith(labels)
+	case *metrics.WeightedHistogramVec:
+		observer, err = histogramVec.GetMetricWith(labels)
+	}
+	if err != nil {
 		return nil, err
 	}
-	r.StoreHistogram(metricName, hash, labels, histogramVec, observer, mapping.Ttl)
+	r.StoreHistogram(metricName, hash, labels, vh, observer, mapping.Ttl, mapping.SparseScrapes)
 
 	return observer, nil
 }
 
 func (r *Registry) GetSummary(metricName string, labels prometheus.Labels, help string, mapping *mapper.MetricMapping, metricsCount *prometheus.GaugeVec) (prometheus.Observer, error) {
+	if mh := r.cachedChild(metricName, metrics.SummaryMetricType, labels); mh != nil {
+		return mh.(prometheus.Observer), nil
+	}
 	hash, labelNames := r.HashLabels(labels)
 	vh, mh := r.Get(metricName, hash, metrics.SummaryMetricType)
 	if mh != nil {
 		return mh.(prometheus.Observer), nil
 	}
 
+	if r.rejectNewSeries {
+		return nil, ErrNewSeriesRejected
+	}
+
 	if r.MetricConflicts(metricName, metrics.SummaryMetricType) {
 		return nil, fmt.Errorf("metrics.Metric with name %s is already registered", metricName)
 	}
@@ -328,6 +484,9 @@ func (r *Registry) GetSummary(metricName string, labels prometheus.Labels, help
 
 	var summaryVec *prometheus.SummaryVec
 	if vh == nil {
+		if err := r.checkConstLabels(labels); err != nil {
+			return nil, err
+		}
 		metricsCount.WithLabelValues("summary").Inc()
 		quantiles := r.Mapper.Defaults.SummaryOptions.Quantiles
 		if mapping != nil && mapping.SummaryOptions != nil && len(mapping.SummaryOptions.Quantiles) > 0 {
@@ -353,15 +512,16 @@ func (r *Registry) GetSummary(metricName string, labels prometheus.Labels, help
 			objectives = map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001}
 		}
 		summaryVec = prometheus.NewSummaryVec(prometheus.SummaryOpts{
-			Name:       metricName,
-			Help:       help,
-			Objectives: objectives,
-			MaxAge:     summaryOptions.MaxAge,
-			AgeBuckets: summaryOptions.AgeBuckets,
-			BufCap:     summaryOptions.BufCap,
-		}, labelNames)
-
-		if err := r.Registerer.Register(uncheckedCollector{summaryVec}); err != nil {
+			Name:        metricName,
+			Help:        help,
+			Objectives:  objectives,
+			MaxAge:      summaryOptions.MaxAge,
+			AgeBuckets:  summaryOptions.AgeBuckets,
+			BufCap:      summaryOptions.BufCap,
+			ConstLabels: r.constLabels,
+		}, copyLabelNames(labelNames))
+
+		if err := r.Registerer.Register(uncheckedCollector{c: summaryVec, name: metricName}); err != nil {
 			return nil, err
 		}
 	} else {
@@ -373,7 +533,7 @@ func (r *Registry) GetSummary(metricName string, labels prometheus.Labels, help
 	if observer, err = summaryVec.GetMetricWith(labels); err != nil {
 		return nil, err
 	}
-	r.StoreSummary(metricName, hash, labels, summaryVec, observer, mapping.Ttl)
+	r.StoreSummary(metricName, hash, labels, summaryVec, observer, mapping.Ttl, mapping.SparseScrapes)
 
 	return observer, nil
 }
@@ -381,31 +541,108 @@ func (r *Registry) GetSummary(metricName string, labels prometheus.Labels, help
 func (r *Registry) RemoveStaleMetrics() {
 	now := clock.Now()
 	// delete timeseries with expired ttl
-	for _, metric := range r.Metrics {
+	for metricName, metric := range r.Metrics {
 		for hash, rm := range metric.Metrics {
 			if rm.TTL == 0 {
 				continue
 			}
 			if rm.LastRegisteredAt.Add(rm.TTL).Before(now) {
-				metric.Vectors[rm.VecKey].Holder.Delete(rm.Labels)
-				metric.Vectors[rm.VecKey].RefCount--
-				delete(metric.Metrics, hash)
+				r.removeSeries(metricName, metric, hash, rm)
 			}
 		}
 	}
 }
 
+// RemoveSparseMetrics counts the scrapes that started at the given times
+// towards the series of mappings with sparse_scrapes, and removes the series
+// that have been exported by that many scrapes since they were last updated.
+// Scrapes that started before a series was updated may not have seen the
+// update, and are not counted.
+func (r *Registry) RemoveSparseMetrics(scrapes []time.Time) {
+	if len(scrapes) == 0 {
+		return
+	}
+	for metricName, metric := range r.Metrics {
+		for hash, rm := range metric.Metrics {
+			if rm.SparseScrapes == 0 {
+				continue
+			}
+			for _, start := range scrapes {
+				if start.After(rm.LastRegisteredAt) {
+					rm.ScrapesSeen++
+				}
+			}
+			if rm.ScrapesSeen >= rm.SparseScrapes {
+				r.removeSeries(metricName, metric, hash, rm)
+			}
+		}
+	}
+}
+
+func (r *Registry) removeSeries(metricName string, metric metrics.Metric, hash metrics.ValueHash, rm *metrics.RegisteredMetric) {
+	metric.Vectors[rm.VecKey].Holder.Delete(rm.Labels)
+	metric.Vectors[rm.VecKey].RefCount--
+	delete(metric.Metrics, hash)
+	r.uncacheChild(metricName, rm)
+}
+
+// DelayExpiry postpones the expiry of all metrics by d. It is used after the
+// exporter stalled, when no samples could be received to keep metrics alive.
+func (r *Registry) DelayExpiry(d time.Duration) {
+	for _, metric := range r.Metrics {
+		for _, rm := range metric.Metrics {
+			rm.LastRegisteredAt = rm.LastRegisteredAt.Add(d)
+		}
+	}
+}
+
+// Sample is the current value of a series.
+type Sample struct {
+	Labels prometheus.Labels
+	Value  float64
+}
+
+// Values returns the current values of all series of a counter or gauge.
+// It returns nothing for other metric types.
+func (r *Registry) Values(metricName string) []Sample {
+	metric, ok := r.Metrics[metricName]
+	if !ok || (metric.MetricType != metrics.CounterMetricType && metric.MetricType != metrics.GaugeMetricType) {
+		return nil
+	}
+
+	samples := make([]Sample, 0, len(metric.Metrics))
+	for _, rm := range metric.Metrics {
+		m, ok := rm.Metric.(prometheus.Metric)
+		if !ok {
+			continue
+		}
+		var pb dto.Metric
+		if err := m.Write(&pb); err != nil {
+			continue
+		}
+		value := pb.GetCounter().GetValue()
+		if pb.Gauge != nil {
+			value = pb.GetGauge().GetValue()
+		}
+		samples = append(samples, Sample{Labels: rm.Labels, Value: value})
+	}
+	return samples
+}
+
 // Calculates a hash of both the label names and values.
+// The returned label names are only valid until the next call, use
+// copyLabelNames to retain them.
 func (r *Registry) HashLabels(labels prometheus.Labels) (metrics.LabelHash, []string) {
 	r.Hasher.Reset()
 	r.NameBuf.Reset()
 	r.ValueBuf.Reset()
-	labelNames := make([]string, 0, len(labels))
+	labelNames := r.labelNames[:0]
 
 	for labelName := range labels {
 		labelNames = append(labelNames, labelName)
 	}
 	sort.Strings(labelNames)
+	r.labelNames = labelNames
 
 	r.ValueBuf.WriteByte(model.SeparatorByte)
 	for _, labelName := range labelNames {
@@ -426,3 +663,16 @@ func (r *Registry) HashLabels(labels prometheus.Labels) (metrics.LabelHash, []st
 
 	return lh, labelNames
 }
+
+// copyLabelNames returns a copy of the label names returned by HashLabels, so
+// they can be handed to a newly created vector.
+func copyLabelNames(labelNames []string) []string {
+	return append(make([]string, 0, len(labelNames)), labelNames...)
+}
+
+// hashString returns the hash of s, computed with hasher.
+func hashString(hasher hash.Hash64, s string) uint64 {
+	hasher.Reset()
+	hasher.Write([]byte(s))
+	return hasher.Sum64()
+}
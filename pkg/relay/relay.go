@@ -15,6 +15,7 @@ package relay
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net"
@@ -22,57 +23,99 @@ import (
 	"time"
 
 	"github.com/prometheus/statsd_exporter/pkg/clock"
+	"github.com/prometheus/statsd_exporter/pkg/event"
+	"github.com/prometheus/statsd_exporter/pkg/line"
+	"github.com/prometheus/statsd_exporter/pkg/metrics"
 
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promauto"
 )
 
 type Relay struct {
 	addr          *net.UDPAddr
 	bufferChannel chan []byte
 	conn          *net.UDPConn
+	connected     bool
 	logger        *slog.Logger
 	packetLength  uint
 
 	packetsTotal      prometheus.Counter
 	longLinesTotal    prometheus.Counter
 	relayedLinesTotal prometheus.Counter
+	unreachableTotal  prometheus.Counter
+	reachable         prometheus.Gauge
+}
+
+// Options controls how a relay sends packets to its target.
+type Options struct {
+	// Connected sends packets through a UDP socket connected to the target.
+	// ICMP port unreachable messages for a connected socket are reported by
+	// the kernel on the next send, so a target that nothing listens on is
+	// detected instead of silently dropping every packet.
+	Connected bool
 }
 
 var (
-	relayPacketsTotal = promauto.NewCounterVec(
+	relayPacketsTotal = metrics.SelfMetrics.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "statsd_exporter_relay_packets_total",
 			Help: "The number of StatsD packets relayed.",
 		},
 		[]string{"target"},
 	)
-	relayLongLinesTotal = promauto.NewCounterVec(
+	relayLongLinesTotal = metrics.SelfMetrics.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "statsd_exporter_relay_long_lines_total",
 			Help: "The number lines that were too long to relay.",
 		},
 		[]string{"target"},
 	)
-	relayLinesRelayedTotal = promauto.NewCounterVec(
+	relayLinesRelayedTotal = metrics.SelfMetrics.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "statsd_exporter_relay_lines_relayed_total",
 			Help: "The number of lines that were buffered to be relayed.",
 		},
 		[]string{"target"},
 	)
+	relayUnreachableTotal = metrics.SelfMetrics.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "statsd_exporter_relay_unreachable_total",
+			Help: "The number of times the relay target was reported unreachable. Only reported for connected relays.",
+		},
+		[]string{"target"},
+	)
+	relayTargetReachable = metrics.SelfMetrics.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "statsd_exporter_relay_target_reachable",
+			Help: "Whether the last packet was sent without the relay target being reported unreachable. Only reported for connected relays.",
+		},
+		[]string{"target"},
+	)
 )
 
 // NewRelay creates a statsd UDP relay. It can be used to send copies of statsd raw
 // lines to a separate service.
 func NewRelay(l *slog.Logger, target string, packetLength uint) (*Relay, error) {
+	return NewRelayWithOptions(l, target, packetLength, Options{})
+}
+
+// NewRelayWithOptions creates a statsd UDP relay like NewRelay, sending
+// packets as configured by opts.
+func NewRelayWithOptions(l *slog.Logger, target string, packetLength uint, opts Options) (*Relay, error) {
 	addr, err := net.ResolveUDPAddr("udp", target)
 	if err != nil {
 		return nil, fmt.Errorf("unable to resolve target %s, err: %w", target, err)
 	}
-	conn, err := net.ListenUDP("udp", nil)
-	if err != nil {
-		return nil, fmt.Errorf("unable to listen on UDP, err: %w", err)
+	var conn *net.UDPConn
+	if opts.Connected {
+		conn, err = net.DialUDP("udp", nil, addr)
+		if err != nil {
+			return nil, fmt.Errorf("unable to connect to %s, err: %w", target, err)
+		}
+	} else {
+		conn, err = net.ListenUDP("udp", nil)
+		if err != nil {
+			return nil, fmt.Errorf("unable to listen on UDP, err: %w", err)
+		}
 	}
 
 	c := make(chan []byte, 100)
@@ -81,6 +124,7 @@ func NewRelay(l *slog.Logger, target string, packetLength uint) (*Relay, error)
 		addr:          addr,
 		bufferChannel: c,
 		conn:          conn,
+		connected:     opts.Connected,
 		logger:        l,
 		packetLength:  packetLength,
 
@@ -88,6 +132,11 @@ func NewRelay(l *slog.Logger, target string, packetLength uint) (*Relay, error)
 		longLinesTotal:    relayLongLinesTotal.WithLabelValues(target),
 		relayedLinesTotal: relayLinesRelayedTotal.WithLabelValues(target),
 	}
+	if opts.Connected {
+		r.unreachableTotal = relayUnreachableTotal.WithLabelValues(target)
+		r.reachable = relayTargetReachable.WithLabelValues(target)
+		r.reachable.Set(1)
+	}
 
 	// Startup the UDP sender.
 	go r.relayOutput()
@@ -139,8 +188,26 @@ func (r *Relay) sendPacket(buf []byte) error {
 		return nil
 	}
 	r.logger.Debug("Sending packet", "length", len(buf), "data", string(buf))
-	_, err := r.conn.WriteToUDP(buf, r.addr)
+	if !r.connected {
+		_, err := r.conn.WriteToUDP(buf, r.addr)
+		r.packetsTotal.Inc()
+		return err
+	}
+
+	_, err := r.conn.Write(buf)
 	r.packetsTotal.Inc()
+	// The kernel reports an ICMP port unreachable message received for an
+	// earlier packet on the next send. The target may come back, so keep
+	// relaying.
+	if errors.Is(err, errConnRefused) {
+		r.logger.Warn("Relay target is unreachable", "target", r.addr.String())
+		r.unreachableTotal.Inc()
+		r.reachable.Set(0)
+		return nil
+	}
+	if err == nil {
+		r.reachable.Set(1)
+	}
 	return err
 }
 
@@ -163,3 +230,20 @@ func (r *Relay) RelayLine(l string) {
 	r.relayedLinesTotal.Inc()
 	r.bufferChannel <- []byte(l)
 }
+
+// RelayEvents formats events as statsd lines and forwards them to the relay
+// target. Events that cannot be formatted are logged and skipped. Listeners
+// relay the events of all lines this way, so that the target receives a
+// single dialect whatever the protocol and tagging format of the input.
+func (r *Relay) RelayEvents(events event.Events) {
+	for _, e := range events {
+		lines, err := line.Format(e)
+		if err != nil {
+			r.logger.Debug("Unable to format event, not relaying", "metric", e.MetricName(), "error", err)
+			continue
+		}
+		for _, l := range strings.Split(lines, "\n") {
+			r.RelayLine(l)
+		}
+	}
+}
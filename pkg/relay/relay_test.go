@@ -15,6 +15,7 @@ package relay
 
 import (
 	"fmt"
+	"net"
 	"runtime"
 	"testing"
 	"time"
@@ -174,3 +175,48 @@ func labelPairsAsLabels(pairs []*dto.LabelPair) (labels prometheus.Labels) {
 	}
 	return
 }
+
+func TestRelayConnectedUnreachable(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("ICMP errors are not reported on windows")
+	}
+	// Find a port that nothing listens on.
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	target := conn.LocalAddr().String()
+	conn.Close()
+
+	clock.ClockInstance = &clock.Clock{TickerCh: make(chan time.Time)}
+	r, err := NewRelayWithOptions(promslog.NewNopLogger(), target, 200, Options{Connected: true})
+	if err != nil {
+		t.Fatalf("Did not expect error while creating relay: %v", err)
+	}
+
+	// The ICMP port unreachable message for a packet is reported when sending
+	// the next one.
+	for i := 0; i < 100 && metricValue(t, r.unreachableTotal) == 0; i++ {
+		if err := r.sendPacket([]byte("foo:1|c\n")); err != nil {
+			t.Fatalf("Did not expect error while sending: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if v := metricValue(t, r.unreachableTotal); v == 0 {
+		t.Fatalf("Expected target to be reported unreachable")
+	}
+	if v := metricValue(t, r.reachable); v != 0 {
+		t.Fatalf("Expected target to not be reachable, got %v", v)
+	}
+}
+
+func metricValue(t *testing.T, m prometheus.Metric) float64 {
+	var metric dto.Metric
+	if err := m.Write(&metric); err != nil {
+		t.Fatal(err)
+	}
+	if metric.Gauge != nil {
+		return metric.Gauge.GetValue()
+	}
+	return metric.Counter.GetValue()
+}
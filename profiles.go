@@ -0,0 +1,101 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/alecthomas/kingpin/v2"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	profileFlag       = "config.profile"
+	profilesFileFlag  = "config.profiles-file"
+	profileEnvar      = "STATSD_EXPORTER_PROFILE"
+	profilesFileEnvar = "STATSD_EXPORTER_PROFILES_FILE"
+)
+
+// profilesConfig is the format of the profiles file. Each profile maps flag
+// names to the values they default to when the profile is selected.
+type profilesConfig struct {
+	Profiles map[string]map[string]string `yaml:"profiles"`
+}
+
+// applyProfile changes the defaults of command line flags to the values of the
+// selected profile. Flags set on the command line still take precedence. It
+// must be called after all flags are defined, and before parsing.
+func applyProfile(app *kingpin.Application, args []string) error {
+	profile := os.Getenv(profileEnvar)
+	fileName := os.Getenv(profilesFileEnvar)
+
+	// Errors are reported by the actual parse.
+	ctx, err := app.ParseContext(args)
+	if err != nil {
+		return nil
+	}
+	for _, element := range ctx.Elements {
+		flag, ok := element.Clause.(*kingpin.FlagClause)
+		if !ok || element.Value == nil {
+			continue
+		}
+		switch flag.Model().Name {
+		case profileFlag:
+			profile = *element.Value
+		case profilesFileFlag:
+			fileName = *element.Value
+		}
+	}
+
+	if profile == "" {
+		return nil
+	}
+	if fileName == "" {
+		return fmt.Errorf("profile %q selected, but no profiles file given", profile)
+	}
+
+	content, err := os.ReadFile(fileName)
+	if err != nil {
+		return err
+	}
+	var config profilesConfig
+	if err := yaml.UnmarshalStrict(content, &config); err != nil {
+		return fmt.Errorf("unable to parse profiles file %s: %w", fileName, err)
+	}
+
+	settings, ok := config.Profiles[profile]
+	if !ok {
+		available := make([]string, 0, len(config.Profiles))
+		for name := range config.Profiles {
+			available = append(available, name)
+		}
+		sort.Strings(available)
+		return fmt.Errorf("unknown profile %q, available profiles: %s", profile, strings.Join(available, ", "))
+	}
+
+	for name, value := range settings {
+		if name == profileFlag || name == profilesFileFlag {
+			return fmt.Errorf("profile %q cannot set %s", profile, name)
+		}
+		flag := app.GetFlag(name)
+		if flag == nil {
+			return fmt.Errorf("profile %q sets unknown flag %q", profile, name)
+		}
+		flag.Default(value)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alecthomas/kingpin/v2"
+)
+
+func TestApplyProfile(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "profiles.yml")
+	err := os.WriteFile(fileName, []byte(`profiles:
+  dev:
+    statsd.event-queue-size: 100
+    log.level: debug
+  prod:
+    statsd.event-queue-size: 100000
+`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scenarios := []struct {
+		name      string
+		args      []string
+		queueSize uint
+		logLevel  string
+		err       bool
+	}{
+		{
+			name:      "no profile",
+			args:      []string{},
+			queueSize: 10000,
+			logLevel:  "info",
+		}, {
+			name:      "dev profile",
+			args:      []string{"--config.profile=dev", "--config.profiles-file=" + fileName},
+			queueSize: 100,
+			logLevel:  "debug",
+		}, {
+			name:      "flag overrides profile",
+			args:      []string{"--config.profile=prod", "--config.profiles-file=" + fileName, "--statsd.event-queue-size=5"},
+			queueSize: 5,
+			logLevel:  "info",
+		}, {
+			name: "unknown profile",
+			args: []string{"--config.profile=staging", "--config.profiles-file=" + fileName},
+			err:  true,
+		}, {
+			name: "missing profiles file",
+			args: []string{"--config.profile=dev"},
+			err:  true,
+		},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			app := kingpin.New("test", "")
+			app.Flag(profileFlag, "").String()
+			app.Flag(profilesFileFlag, "").String()
+			queueSize := app.Flag("statsd.event-queue-size", "").Default("10000").Uint()
+			logLevel := app.Flag("log.level", "").Default("info").String()
+
+			err := applyProfile(app, s.args)
+			if s.err {
+				if err == nil {
+					t.Fatal("expected error applying profile")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error applying profile: %v", err)
+			}
+			if _, err := app.Parse(s.args); err != nil {
+				t.Fatalf("unexpected parse error: %v", err)
+			}
+			if *queueSize != s.queueSize {
+				t.Errorf("expected queue size %d, got %d", s.queueSize, *queueSize)
+			}
+			if *logLevel != s.logLevel {
+				t.Errorf("expected log level %s, got %s", s.logLevel, *logLevel)
+			}
+		})
+	}
+}
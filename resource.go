@@ -0,0 +1,263 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Resource detectors that can be enabled with --statsd.resource-detector.
+const (
+	resourceEnv        = "env"
+	resourceHost       = "host"
+	resourceKubernetes = "kubernetes"
+	resourceEC2        = "ec2"
+	resourceGCP        = "gcp"
+)
+
+var resourceLabelRE = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// resourceDetector detects OpenTelemetry resource attributes, such as
+// service.name, host.name and cloud.region, of the environment the exporter
+// runs in. They are added as constant labels to the metrics created from
+// StatsD events, so that metrics from many exporters can be told apart
+// without mapping rules.
+type resourceDetector struct {
+	client      *http.Client
+	ec2Endpoint string
+	gcpEndpoint string
+	getenv      func(string) string
+	hostname    func() (string, error)
+	readFile    func(string) ([]byte, error)
+}
+
+func newResourceDetector(timeout time.Duration) *resourceDetector {
+	return &resourceDetector{
+		client:      &http.Client{Timeout: timeout},
+		ec2Endpoint: "http://169.254.169.254",
+		gcpEndpoint: "http://metadata.google.internal",
+		getenv:      os.Getenv,
+		hostname:    os.Hostname,
+		readFile:    os.ReadFile,
+	}
+}
+
+// detect runs the named detectors in order. Attributes found by a detector
+// are not overridden by later ones, so the order sets the precedence.
+// Detectors that fail, for example because the exporter does not run on the
+// cloud they query, are logged and skipped.
+func (d *resourceDetector) detect(ctx context.Context, detectors []string, logger *slog.Logger) map[string]string {
+	attrs := map[string]string{}
+	for _, name := range detectors {
+		var found map[string]string
+		var err error
+		switch name {
+		case resourceEnv:
+			found, err = d.detectEnv()
+		case resourceHost:
+			found, err = d.detectHost()
+		case resourceKubernetes:
+			found, err = d.detectKubernetes()
+		case resourceEC2:
+			found, err = d.detectEC2(ctx)
+		case resourceGCP:
+			found, err = d.detectGCP(ctx)
+		default:
+			err = fmt.Errorf("unknown resource detector")
+		}
+		if err != nil {
+			logger.Warn("Resource detection failed", "detector", name, "error", err)
+			continue
+		}
+		for k, v := range found {
+			if _, ok := attrs[k]; !ok && v != "" {
+				attrs[k] = v
+			}
+		}
+	}
+	return attrs
+}
+
+// detectEnv reads the OTEL_RESOURCE_ATTRIBUTES and OTEL_SERVICE_NAME
+// environment variables of the OpenTelemetry SDKs.
+func (d *resourceDetector) detectEnv() (map[string]string, error) {
+	attrs := map[string]string{}
+	if s := d.getenv("OTEL_RESOURCE_ATTRIBUTES"); s != "" {
+		for _, pair := range strings.Split(s, ",") {
+			k, v, ok := strings.Cut(pair, "=")
+			if !ok {
+				return nil, fmt.Errorf("invalid resource attribute %q in OTEL_RESOURCE_ATTRIBUTES", pair)
+			}
+			value, err := url.PathUnescape(strings.TrimSpace(v))
+			if err != nil {
+				return nil, fmt.Errorf("invalid resource attribute %q in OTEL_RESOURCE_ATTRIBUTES: %w", pair, err)
+			}
+			attrs[strings.TrimSpace(k)] = value
+		}
+	}
+	if name := d.getenv("OTEL_SERVICE_NAME"); name != "" {
+		attrs["service.name"] = name
+	}
+	return attrs, nil
+}
+
+func (d *resourceDetector) detectHost() (map[string]string, error) {
+	name, err := d.hostname()
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"host.name": name}, nil
+}
+
+// detectKubernetes reads the pod, namespace and node from the POD_NAME,
+// POD_NAMESPACE and NODE_NAME environment variables, which have to be set
+// from the downward API. The namespace falls back to the one of the service
+// account, and the pod name to the hostname.
+func (d *resourceDetector) detectKubernetes() (map[string]string, error) {
+	if d.getenv("KUBERNETES_SERVICE_HOST") == "" {
+		return nil, fmt.Errorf("not running on Kubernetes")
+	}
+	attrs := map[string]string{
+		"k8s.pod.name":       d.getenv("POD_NAME"),
+		"k8s.namespace.name": d.getenv("POD_NAMESPACE"),
+		"k8s.node.name":      d.getenv("NODE_NAME"),
+	}
+	if attrs["k8s.namespace.name"] == "" {
+		if b, err := d.readFile("/var/run/secrets/kubernetes.io/serviceaccount/namespace"); err == nil {
+			attrs["k8s.namespace.name"] = strings.TrimSpace(string(b))
+		}
+	}
+	if attrs["k8s.pod.name"] == "" {
+		attrs["k8s.pod.name"] = d.getenv("HOSTNAME")
+	}
+	return attrs, nil
+}
+
+// detectEC2 queries the instance identity document from the EC2 instance
+// metadata service, using IMDSv2.
+func (d *resourceDetector) detectEC2(ctx context.Context) (map[string]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, d.ec2Endpoint+"/latest/api/token", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "60")
+	token, err := d.fetch(req)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err = http.NewRequestWithContext(ctx, http.MethodGet, d.ec2Endpoint+"/latest/dynamic/instance-identity/document", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token", token)
+	body, err := d.fetch(req)
+	if err != nil {
+		return nil, err
+	}
+	var doc struct {
+		Region           string `json:"region"`
+		AvailabilityZone string `json:"availabilityZone"`
+		InstanceID       string `json:"instanceId"`
+		AccountID        string `json:"accountId"`
+	}
+	if err := json.Unmarshal([]byte(body), &doc); err != nil {
+		return nil, fmt.Errorf("invalid instance identity document: %w", err)
+	}
+	return map[string]string{
+		"cloud.provider":          "aws",
+		"cloud.account.id":        doc.AccountID,
+		"cloud.region":            doc.Region,
+		"cloud.availability_zone": doc.AvailabilityZone,
+		"host.id":                 doc.InstanceID,
+	}, nil
+}
+
+// detectGCP queries the zone and ID of the instance from the GCE metadata
+// server.
+func (d *resourceDetector) detectGCP(ctx context.Context) (map[string]string, error) {
+	get := func(path string) (string, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.gcpEndpoint+"/computeMetadata/v1/"+path, nil)
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Metadata-Flavor", "Google")
+		return d.fetch(req)
+	}
+
+	// The zone is returned as projects/<number>/zones/<zone>.
+	zone, err := get("instance/zone")
+	if err != nil {
+		return nil, err
+	}
+	zone = zone[strings.LastIndex(zone, "/")+1:]
+	id, err := get("instance/id")
+	if err != nil {
+		return nil, err
+	}
+	project, err := get("project/project-id")
+	if err != nil {
+		return nil, err
+	}
+
+	attrs := map[string]string{
+		"cloud.provider":          "gcp",
+		"cloud.account.id":        project,
+		"cloud.availability_zone": zone,
+		"host.id":                 id,
+	}
+	if i := strings.LastIndex(zone, "-"); i > 0 {
+		attrs["cloud.region"] = zone[:i]
+	}
+	return attrs, nil
+}
+
+func (d *resourceDetector) fetch(req *http.Request) (string, error) {
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s %s returned %s", req.Method, req.URL, resp.Status)
+	}
+	return strings.TrimSpace(string(body)), nil
+}
+
+// resourceLabels turns resource attributes into label names, replacing the
+// dots and other characters that aren't allowed in label names with
+// underscores, so service.name becomes service_name.
+func resourceLabels(attrs map[string]string) prometheus.Labels {
+	labels := prometheus.Labels{}
+	for k, v := range attrs {
+		labels[resourceLabelRE.ReplaceAllString(k, "_")] = v
+	}
+	return labels
+}
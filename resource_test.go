@@ -0,0 +1,109 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/promslog"
+)
+
+func TestResourceDetection(t *testing.T) {
+	ec2 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPut && r.URL.Path == "/latest/api/token":
+			w.Write([]byte("token"))
+		case r.URL.Path == "/latest/dynamic/instance-identity/document" && r.Header.Get("X-aws-ec2-metadata-token") == "token":
+			w.Write([]byte(`{"region": "eu-west-1", "availabilityZone": "eu-west-1b", "instanceId": "i-123", "accountId": "42"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ec2.Close()
+
+	env := map[string]string{
+		"OTEL_RESOURCE_ATTRIBUTES": "service.name=checkout,deployment.environment=prod%2Feu",
+		"OTEL_SERVICE_NAME":        "payments",
+		"KUBERNETES_SERVICE_HOST":  "10.0.0.1",
+		"POD_NAME":                 "payments-0",
+		"NODE_NAME":                "node-1",
+	}
+	d := newResourceDetector(0)
+	d.ec2Endpoint = ec2.URL
+	d.gcpEndpoint = ec2.URL
+	d.getenv = func(k string) string { return env[k] }
+	d.hostname = func() (string, error) { return "payments-0.local", nil }
+	d.readFile = func(string) ([]byte, error) { return []byte("shop\n"), nil }
+
+	attrs := d.detect(context.Background(), []string{resourceEnv, resourceHost, resourceKubernetes, resourceGCP, resourceEC2}, promslog.NewNopLogger())
+	expected := prometheus.Labels{
+		"service_name":            "payments",
+		"deployment_environment":  "prod/eu",
+		"host_name":               "payments-0.local",
+		"k8s_pod_name":            "payments-0",
+		"k8s_namespace_name":      "shop",
+		"k8s_node_name":           "node-1",
+		"cloud_provider":          "aws",
+		"cloud_account_id":        "42",
+		"cloud_region":            "eu-west-1",
+		"cloud_availability_zone": "eu-west-1b",
+		"host_id":                 "i-123",
+	}
+	if labels := resourceLabels(attrs); !reflect.DeepEqual(labels, expected) {
+		t.Fatalf("expected %v, got %v", expected, labels)
+	}
+}
+
+func TestResourceDetectionGCP(t *testing.T) {
+	gcp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			http.Error(w, "missing header", http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/computeMetadata/v1/instance/zone":
+			w.Write([]byte("projects/123/zones/us-central1-a"))
+		case "/computeMetadata/v1/instance/id":
+			w.Write([]byte("456"))
+		case "/computeMetadata/v1/project/project-id":
+			w.Write([]byte("shop"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer gcp.Close()
+
+	d := newResourceDetector(0)
+	d.gcpEndpoint = gcp.URL
+	d.getenv = func(string) string { return "" }
+	d.hostname = func() (string, error) { return "", errors.New("no hostname") }
+
+	attrs := d.detect(context.Background(), []string{resourceKubernetes, resourceHost, resourceGCP}, promslog.NewNopLogger())
+	expected := map[string]string{
+		"cloud.provider":          "gcp",
+		"cloud.account.id":        "shop",
+		"cloud.region":            "us-central1",
+		"cloud.availability_zone": "us-central1-a",
+		"host.id":                 "456",
+	}
+	if !reflect.DeepEqual(attrs, expected) {
+		t.Fatalf("expected %v, got %v", expected, attrs)
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/prometheus/statsd_exporter/pkg/metrics"
+)
+
+// selfMetricsPrefix is the prefix of the metrics the exporter exposes about
+// itself.
+const selfMetricsPrefix = "statsd_exporter_"
+
+// selfMetricsManifest lists the metrics the exporter exposes about itself,
+// sorted by name. Besides the metrics created through metrics.SelfMetrics,
+// it includes those gathered from g with the selfMetricsPrefix, such as the
+// build info.
+func selfMetricsManifest(g prometheus.Gatherer) ([]metrics.ManifestEntry, error) {
+	entries := metrics.SelfMetrics.Entries()
+	known := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		known[e.Name] = true
+	}
+
+	families, err := g.Gather()
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range families {
+		if !strings.HasPrefix(f.GetName(), selfMetricsPrefix) || known[f.GetName()] || len(f.GetMetric()) == 0 {
+			continue
+		}
+		var labels []string
+		for _, l := range f.GetMetric()[0].GetLabel() {
+			labels = append(labels, l.GetName())
+		}
+		entries = append(entries, metrics.ManifestEntry{
+			Name:   f.GetName(),
+			Type:   strings.ToLower(f.GetType().String()),
+			Help:   f.GetHelp(),
+			Labels: labels,
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries, nil
+}
+
+// runSelfMetrics writes the manifest of the metrics the exporter exposes
+// about itself to w, as JSON.
+func runSelfMetrics(g prometheus.Gatherer, w io.Writer) error {
+	entries, err := selfMetricsManifest(g)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(entries)
+}
@@ -0,0 +1,83 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	versioncollector "github.com/prometheus/client_golang/prometheus/collectors/version"
+
+	"github.com/prometheus/statsd_exporter/pkg/metrics"
+)
+
+const selfMetricsFile = "self-metrics.json"
+
+// TestSelfMetricsManifest keeps the published manifest of self-metrics up to
+// date, and only allows additive changes to it: metrics can be added, and
+// their help changed, but they can't be removed or change their type or
+// labels. Run with -update to accept new metrics.
+func TestSelfMetricsManifest(t *testing.T) {
+	// The build info is registered when the exporter starts.
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(versioncollector.NewCollector("statsd_exporter"))
+	var manifest bytes.Buffer
+	if err := runSelfMetrics(prometheus.Gatherers{prometheus.DefaultGatherer, reg}, &manifest); err != nil {
+		t.Fatal(err)
+	}
+
+	published, err := os.ReadFile(selfMetricsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var before, after []metrics.ManifestEntry
+	if err := json.Unmarshal(published, &before); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(manifest.Bytes(), &after); err != nil {
+		t.Fatal(err)
+	}
+	current := make(map[string]metrics.ManifestEntry, len(after))
+	for _, e := range after {
+		current[e.Name] = e
+	}
+	for _, e := range before {
+		c, ok := current[e.Name]
+		switch {
+		case !ok:
+			t.Errorf("%s was removed", e.Name)
+		case c.Type != e.Type:
+			t.Errorf("%s changed its type from %s to %s", e.Name, e.Type, c.Type)
+		case !slices.Equal(c.Labels, e.Labels):
+			t.Errorf("%s changed its labels from %v to %v", e.Name, e.Labels, c.Labels)
+		}
+	}
+	if t.Failed() {
+		t.Fatalf("self-metrics must only change in a backwards compatible way")
+	}
+
+	if *updateGolden {
+		if err := os.WriteFile(selfMetricsFile, manifest.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	if !bytes.Equal(manifest.Bytes(), published) {
+		t.Fatalf("%s is out of date, run the test with -update to accept the changes", selfMetricsFile)
+	}
+}
@@ -0,0 +1,25 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build js || plan9
+
+package main
+
+import "os"
+
+// These platforms have no SIGHUP and SIGTERM. The mapping config can still
+// be reloaded through the /-/reload endpoint if --web.enable-lifecycle is set.
+var (
+	reloadSignals []os.Signal
+	stopSignals   = []os.Signal{os.Interrupt}
+)
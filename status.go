@@ -0,0 +1,282 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"math"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/statsd_exporter/pkg/clock"
+	"github.com/prometheus/statsd_exporter/pkg/event"
+	"github.com/prometheus/statsd_exporter/pkg/exporter"
+	"github.com/prometheus/statsd_exporter/pkg/listener"
+	"github.com/prometheus/statsd_exporter/pkg/mapper"
+)
+
+const (
+	// statusSampleInterval is how often the number of received lines is
+	// sampled for the status page.
+	statusSampleInterval = 10 * time.Second
+	// statusTopMetrics is the number of metrics with the highest ingest
+	// rates shown on the status page.
+	statusTopMetrics = 10
+)
+
+// lineRates keeps the rate of received lines per second over a sliding
+// window, sampled every statusSampleInterval.
+type lineRates struct {
+	lines  interface{ Value() float64 }
+	window time.Duration
+
+	mtx       sync.Mutex
+	lastValue float64
+	lastTime  time.Time
+	samples   []lineRateSample
+}
+
+type lineRateSample struct {
+	time time.Time
+	rate float64
+}
+
+// lineRateSummary is the JSON representation of the line rates within the
+// window. The rates are 0 until the first sample is taken.
+type lineRateSummary struct {
+	Window  string  `json:"window"`
+	Samples int     `json:"samples"`
+	Current float64 `json:"current"`
+	Min     float64 `json:"min"`
+	Max     float64 `json:"max"`
+	Avg     float64 `json:"avg"`
+}
+
+func newLineRates(lines interface{ Value() float64 }, window time.Duration) *lineRates {
+	return &lineRates{
+		lines:     lines,
+		window:    window,
+		lastValue: lines.Value(),
+		lastTime:  clock.Now(),
+	}
+}
+
+// run samples the number of received lines until the process exits.
+func (r *lineRates) run() {
+	ticker := clock.NewTicker(statusSampleInterval)
+	for range ticker.C {
+		r.sample(clock.Now())
+	}
+}
+
+// sample records the rate of lines received since the previous sample, and
+// forgets samples that are no longer within the window.
+func (r *lineRates) sample(now time.Time) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	elapsed := now.Sub(r.lastTime).Seconds()
+	if elapsed <= 0 {
+		return
+	}
+	value := r.lines.Value()
+	r.samples = append(r.samples, lineRateSample{time: now, rate: (value - r.lastValue) / elapsed})
+	r.lastValue, r.lastTime = value, now
+
+	cutoff := now.Add(-r.window)
+	i := 0
+	for i < len(r.samples) && !r.samples[i].time.After(cutoff) {
+		i++
+	}
+	r.samples = r.samples[i:]
+}
+
+func (r *lineRates) summary() lineRateSummary {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	s := lineRateSummary{Window: r.window.String(), Samples: len(r.samples)}
+	if len(r.samples) == 0 {
+		return s
+	}
+	s.Min = math.Inf(1)
+	s.Max = math.Inf(-1)
+	var sum float64
+	for _, sample := range r.samples {
+		s.Min = math.Min(s.Min, sample.rate)
+		s.Max = math.Max(s.Max, sample.rate)
+		sum += sample.rate
+	}
+	s.Current = r.samples[len(r.samples)-1].rate
+	s.Avg = sum / float64(len(r.samples))
+	return s
+}
+
+// statusPage replaces the landing page with an overview of the exporter for
+// on-call engineers, as HTML or as JSON.
+type statusPage struct {
+	version     string
+	started     time.Time
+	metricsPath string
+	lineRates   *lineRates
+	// ingestRates is nil unless the ingest rates of metrics are tracked.
+	ingestRates *exporter.RateTracker
+	queue       *event.EventQueue
+	listeners   *listener.HealthReport
+	// mapper is nil unless a mapping configuration is loaded.
+	mapper        *mapper.MetricMapper
+	webConfigFile string
+}
+
+type statusReport struct {
+	Version      string                  `json:"version"`
+	Uptime       string                  `json:"uptime"`
+	LineRates    lineRateSummary         `json:"lines_per_second"`
+	TopMetrics   []exporter.MetricRate   `json:"top_metrics,omitempty"`
+	Queue        queueStatus             `json:"queue"`
+	Listeners    []listener.HealthStatus `json:"listeners"`
+	ConfigHashes map[string]string       `json:"config_hashes"`
+}
+
+type queueStatus struct {
+	Length         int    `json:"length"`
+	FlushThreshold int    `json:"flush_threshold"`
+	FlushInterval  string `json:"flush_interval"`
+}
+
+func (p *statusPage) report() statusReport {
+	report := statusReport{
+		Version:   p.version,
+		Uptime:    clock.Now().Sub(p.started).Truncate(time.Second).String(),
+		LineRates: p.lineRates.summary(),
+		Queue: queueStatus{
+			Length:         p.queue.Len(),
+			FlushThreshold: p.queue.FlushThreshold(),
+			FlushInterval:  p.queue.FlushInterval().String(),
+		},
+		Listeners:    p.listeners.Statuses(),
+		ConfigHashes: map[string]string{},
+	}
+	if p.ingestRates != nil {
+		report.TopMetrics = p.ingestRates.Rates()
+		if len(report.TopMetrics) > statusTopMetrics {
+			report.TopMetrics = report.TopMetrics[:statusTopMetrics]
+		}
+	}
+	if p.mapper != nil {
+		report.ConfigHashes["mapping"] = p.mapper.ConfigHash()
+	}
+	// The web configuration is read again for every connection, so the
+	// file is the configuration in use.
+	if p.webConfigFile != "" {
+		if contents, err := os.ReadFile(p.webConfigFile); err == nil {
+			report.ConfigHashes["web"] = fmt.Sprintf("%x", sha256.Sum256(contents))
+		}
+	}
+	return report
+}
+
+func (p *statusPage) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	report := p.report()
+	if r.URL.Query().Get("format") == "json" || strings.Contains(r.Header.Get("Accept"), "application/json") {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err := statusTemplate.Execute(w, struct {
+		statusReport
+		MetricsPath string
+		IngestRates bool
+	}{report, p.metricsPath, p.ingestRates != nil})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+var statusTemplate = template.Must(template.New("status").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>StatsD Exporter</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 1.5em; }
+th, td { border: 1px solid #ccc; padding: 0.25em 0.75em; text-align: left; }
+td.number { text-align: right; }
+.down { color: #b00; }
+</style>
+</head>
+<body>
+<h1>StatsD Exporter</h1>
+<p>Prometheus Exporter for converting StatsD to Prometheus metrics</p>
+<p>Version {{.Version}}, up for {{.Uptime}}. <a href="{{.MetricsPath}}">Metrics</a>, <a href="?format=json">JSON</a></p>
+
+<h2>Lines per second</h2>
+{{if .LineRates.Samples}}
+<table>
+<tr><th>Current</th><th>Min</th><th>Avg</th><th>Max</th></tr>
+<tr><td class="number">{{printf "%.1f" .LineRates.Current}}</td><td class="number">{{printf "%.1f" .LineRates.Min}}</td><td class="number">{{printf "%.1f" .LineRates.Avg}}</td><td class="number">{{printf "%.1f" .LineRates.Max}}</td></tr>
+</table>
+<p>Over the last {{.LineRates.Window}}, from {{.LineRates.Samples}} samples.</p>
+{{else}}
+<p>No samples yet.</p>
+{{end}}
+
+<h2>Top metrics</h2>
+{{if .IngestRates}}
+<table>
+<tr><th>Metric</th><th>Samples per second</th></tr>
+{{range .TopMetrics}}<tr><td>{{.Name}}</td><td class="number">{{printf "%.2f" .SamplesPerSecond}}</td></tr>
+{{end}}</table>
+{{else}}
+<p>Enable <code>--debug.ingest-rate-window</code> to track the ingest rates of metrics.</p>
+{{end}}
+
+<h2>Event queue</h2>
+<table>
+<tr><th>Queued events</th><th>Flush threshold</th><th>Flush interval</th></tr>
+<tr><td class="number">{{.Queue.Length}}</td><td class="number">{{.Queue.FlushThreshold}}</td><td>{{.Queue.FlushInterval}}</td></tr>
+</table>
+
+<h2>Listeners</h2>
+<table>
+<tr><th>Listener</th><th>Status</th><th>Restarts</th><th>Last error</th></tr>
+{{range .Listeners}}<tr><td>{{.Listener}}</td>{{if .Up}}<td>up</td>{{else}}<td class="down">down</td>{{end}}<td class="number">{{.Restarts}}</td><td>{{.LastError}}</td></tr>
+{{end}}</table>
+
+<h2>Configuration</h2>
+<table>
+<tr><th>Configuration</th><th>SHA-256</th></tr>
+{{range $name, $hash := .ConfigHashes}}<tr><td>{{$name}}</td><td><code>{{$hash}}</code></td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
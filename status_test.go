@@ -0,0 +1,117 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/prometheus/statsd_exporter/pkg/clock"
+	"github.com/prometheus/statsd_exporter/pkg/event"
+	"github.com/prometheus/statsd_exporter/pkg/listener"
+	"github.com/prometheus/statsd_exporter/pkg/mapper"
+)
+
+type testLineCount float64
+
+func (c *testLineCount) Value() float64 { return float64(*c) }
+
+func TestLineRates(t *testing.T) {
+	start := time.Unix(1000, 0)
+	clock.ClockInstance = &clock.Clock{Instant: start}
+	defer func() { clock.ClockInstance = nil }()
+
+	var lines testLineCount
+	r := newLineRates(&lines, 30*time.Second)
+	if s := r.summary(); s.Samples != 0 || s.Max != 0 {
+		t.Fatalf("expected no samples, got %+v", s)
+	}
+
+	for i, count := range []testLineCount{100, 400, 400, 700} {
+		lines = count
+		r.sample(start.Add(time.Duration(i+1) * 10 * time.Second))
+	}
+	// The first sample is outside of the window.
+	s := r.summary()
+	if s.Samples != 3 || s.Current != 30 || s.Min != 0 || s.Max != 30 || s.Avg != 20 {
+		t.Fatalf("unexpected summary %+v", s)
+	}
+}
+
+func TestStatusPage(t *testing.T) {
+	start := time.Unix(1000, 0)
+	clock.ClockInstance = &clock.Clock{Instant: start}
+	defer func() { clock.ClockInstance = nil }()
+
+	testMapper := &mapper.MetricMapper{}
+	if err := testMapper.InitFromYAMLString("mappings: []"); err != nil {
+		t.Fatal(err)
+	}
+	listeners := &listener.HealthReport{}
+	listeners.Add(listener.NewHealth("udp", listener.HealthMetrics{
+		Up:         prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "up"}, []string{"listener"}),
+		LastRead:   prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "last_read"}, []string{"listener"}),
+		ReadErrors: prometheus.NewCounterVec(prometheus.CounterOpts{Name: "read_errors"}, []string{"listener"}),
+		Restarts:   prometheus.NewCounterVec(prometheus.CounterOpts{Name: "restarts"}, []string{"listener"}),
+	}, nil))
+	queue := event.NewEventQueue(make(chan event.Events, 1), 100, time.Second, prometheus.NewCounter(prometheus.CounterOpts{}))
+	queue.Queue(make(event.Events, 3))
+
+	var lines testLineCount
+	p := &statusPage{
+		version:     "test",
+		started:     start.Add(-time.Hour),
+		metricsPath: "/metrics",
+		lineRates:   newLineRates(&lines, time.Minute),
+		queue:       queue,
+		listeners:   listeners,
+		mapper:      testMapper,
+	}
+	lines = 50
+	p.lineRates.sample(start.Add(10 * time.Second))
+
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?format=json", nil))
+	var report statusReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatalf("invalid JSON %s: %v", rec.Body, err)
+	}
+	if report.Uptime != "1h0m0s" || report.LineRates.Current != 5 || report.Queue.Length != 3 || report.Queue.FlushThreshold != 100 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if len(report.Listeners) != 1 || report.Listeners[0].Listener != "udp" {
+		t.Fatalf("unexpected listeners %+v", report.Listeners)
+	}
+	if report.ConfigHashes["mapping"] != testMapper.ConfigHash() || report.TopMetrics != nil {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	rec = httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `<a href="/metrics">Metrics</a>`) {
+		t.Fatalf("unexpected status page %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/missing", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected unknown path to not be found, got %d", rec.Code)
+	}
+}